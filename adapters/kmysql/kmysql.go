@@ -3,12 +3,15 @@ package kmysql
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"strconv"
 
 	"github.com/vingarcia/ksql"
 
 	// This is imported here so the user don't
-	// have to worry about it when he uses it.
-	_ "github.com/go-sql-driver/mysql"
+	// have to worry about it when he uses it,
+	// it is also used for parsing the DSN.
+	"github.com/go-sql-driver/mysql"
 )
 
 // NewFromSQLDB builds a ksql.DB from a *sql.DB instance
@@ -24,6 +27,11 @@ func New(
 ) (ksql.DB, error) {
 	config.SetDefaultValues()
 
+	connectionString, err := buildDSN(connectionString, config)
+	if err != nil {
+		return ksql.DB{}, err
+	}
+
 	db, err := sql.Open("mysql", connectionString)
 	if err != nil {
 		return ksql.DB{}, err
@@ -36,3 +44,38 @@ func New(
 
 	return ksql.NewWithAdapter(NewSQLAdapter(db), "mysql")
 }
+
+// buildDSN adds the settings of the config to the connection string,
+// reporting the ones that are not supported by MySQL.
+func buildDSN(connectionString string, config ksql.Config) (string, error) {
+	if config.Schema != "" {
+		return "", fmt.Errorf("ksql: the Schema config is not supported by the kmysql adapter")
+	}
+
+	if config.ApplicationName != "" {
+		return "", fmt.Errorf("ksql: the ApplicationName config is not supported by the kmysql adapter")
+	}
+
+	if config.StatementTimeout <= 0 && len(config.SessionSettings) == 0 {
+		return connectionString, nil
+	}
+
+	mysqlConf, err := mysql.ParseDSN(connectionString)
+	if err != nil {
+		return "", err
+	}
+
+	if mysqlConf.Params == nil {
+		mysqlConf.Params = map[string]string{}
+	}
+
+	// The driver sets these params on each new connection:
+	for key, value := range config.SessionSettings {
+		mysqlConf.Params[key] = value
+	}
+	if config.StatementTimeout > 0 {
+		mysqlConf.Params["max_execution_time"] = strconv.FormatInt(config.StatementTimeout.Milliseconds(), 10)
+	}
+
+	return mysqlConf.FormatDSN(), nil
+}
@@ -1,13 +1,16 @@
 package kmysql
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"io"
 	"log"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/ory/dockertest"
 	"github.com/ory/dockertest/docker"
 	"github.com/vingarcia/ksql"
@@ -24,6 +27,74 @@ func TestAdapter(t *testing.T) {
 		}
 		return SQLAdapter{db}, db
 	})
+
+	t.Run("should set the StatementTimeout on the connections", func(t *testing.T) {
+		ctx := context.Background()
+		db, err := New(ctx, mysqlURL, ksql.Config{
+			StatementTimeout: 1500 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		var row struct {
+			Timeout int `ksql:"timeout"`
+		}
+		err = db.QueryOne(ctx, &row, "SELECT @@max_execution_time AS timeout")
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if row.Timeout != 1500 {
+			t.Fatalf("expected max_execution_time to be 1500, but got: %d", row.Timeout)
+		}
+	})
+}
+
+func TestBuildDSN(t *testing.T) {
+	t.Run("should add the StatementTimeout and SessionSettings to the DSN", func(t *testing.T) {
+		dsn, err := buildDSN("root:mysql@(localhost:3306)/ksql?timeout=30s", ksql.Config{
+			StatementTimeout: 1500 * time.Millisecond,
+			SessionSettings:  map[string]string{"sql_mode": "'ANSI'"},
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		conf, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if conf.Params["max_execution_time"] != "1500" || conf.Params["sql_mode"] != "'ANSI'" {
+			t.Fatalf("expected the DSN to contain the session settings, but got: %s", dsn)
+		}
+	})
+
+	t.Run("should keep the DSN unchanged when there are no settings", func(t *testing.T) {
+		dsn, err := buildDSN("root:mysql@(localhost:3306)/ksql", ksql.Config{})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if dsn != "root:mysql@(localhost:3306)/ksql" {
+			t.Fatalf("expected the DSN to be unchanged, but got: %s", dsn)
+		}
+	})
+
+	t.Run("should report the configs that are not supported", func(t *testing.T) {
+		_, err := buildDSN("root:mysql@(localhost:3306)/ksql", ksql.Config{
+			ApplicationName: "fake-app",
+		})
+		if err == nil || !strings.Contains(err.Error(), "ApplicationName") {
+			t.Fatalf("expected an error reporting ApplicationName is not supported, but got: %v", err)
+		}
+
+		_, err = buildDSN("root:mysql@(localhost:3306)/ksql", ksql.Config{
+			Schema: "fake_schema",
+		})
+		if err == nil || !strings.Contains(err.Error(), "Schema") {
+			t.Fatalf("expected an error reporting Schema is not supported, but got: %v", err)
+		}
+	})
 }
 
 func startMySQLDB(dbName string) (databaseURL string, closer func()) {
@@ -2,7 +2,9 @@ package kpgx
 
 import (
 	"context"
+	"strconv"
 
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/vingarcia/ksql"
 
@@ -31,6 +33,22 @@ func New(
 
 	pgxConf.MaxConns = int32(config.MaxOpenConns)
 
+	for key, value := range config.SessionSettings {
+		pgxConf.ConnConfig.RuntimeParams[key] = value
+	}
+
+	if config.ApplicationName != "" {
+		pgxConf.ConnConfig.RuntimeParams["application_name"] = config.ApplicationName
+	}
+
+	if config.StatementTimeout > 0 {
+		pgxConf.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(config.StatementTimeout.Milliseconds(), 10)
+	}
+
+	if config.Schema != "" {
+		pgxConf.ConnConfig.RuntimeParams["search_path"] = pgx.Identifier{config.Schema}.Sanitize()
+	}
+
 	pool, err := pgxpool.ConnectConfig(ctx, pgxConf)
 	if err != nil {
 		return ksql.DB{}, err
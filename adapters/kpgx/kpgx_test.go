@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"strings"
 	"testing"
 	"time"
 
@@ -26,6 +27,116 @@ func TestAdapter(t *testing.T) {
 		}
 		return PGXAdapter{pool}, closerAdapter{close: pool.Close}
 	})
+
+	t.Run("should abort statements that exceed the StatementTimeout", func(t *testing.T) {
+		ctx := context.Background()
+		db, err := New(ctx, postgresURL, ksql.Config{
+			StatementTimeout: 100 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		_, err = db.Exec(ctx, "SELECT pg_sleep(2)")
+		if err == nil || !strings.Contains(err.Error(), "statement timeout") {
+			t.Fatalf("expected a statement timeout error, but got: %v", err)
+		}
+	})
+
+	t.Run("should set the ApplicationName and SessionSettings on the connections", func(t *testing.T) {
+		ctx := context.Background()
+		db, err := New(ctx, postgresURL, ksql.Config{
+			ApplicationName: "ksql-test-app",
+			SessionSettings: map[string]string{
+				"work_mem": "12MB",
+			},
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		var activity struct {
+			ApplicationName string `ksql:"application_name"`
+		}
+		err = db.QueryOne(ctx, &activity, "SELECT application_name FROM pg_stat_activity WHERE pid = pg_backend_pid()")
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if activity.ApplicationName != "ksql-test-app" {
+			t.Fatalf("expected application_name to be `ksql-test-app`, but got: `%s`", activity.ApplicationName)
+		}
+
+		var setting struct {
+			WorkMem string `ksql:"work_mem"`
+		}
+		err = db.QueryOne(ctx, &setting, "SELECT current_setting('work_mem') AS work_mem")
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if setting.WorkMem != "12MB" {
+			t.Fatalf("expected work_mem to be `12MB`, but got: `%s`", setting.WorkMem)
+		}
+	})
+
+	t.Run("should resolve the table names to the configured Schema", func(t *testing.T) {
+		ctx := context.Background()
+		db, err := New(ctx, postgresURL, ksql.Config{})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		for _, schema := range []string{"tenant_a", "tenant_b"} {
+			_, err = db.Exec(ctx, fmt.Sprintf(`DROP SCHEMA IF EXISTS %s CASCADE`, schema))
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			_, err = db.Exec(ctx, fmt.Sprintf(`CREATE SCHEMA %s`, schema))
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			_, err = db.Exec(ctx, fmt.Sprintf(`CREATE TABLE %s.items (id serial PRIMARY KEY, name TEXT)`, schema))
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+		}
+
+		type item struct {
+			ID   int    `ksql:"id"`
+			Name string `ksql:"name"`
+		}
+		itemsTable := ksql.NewTable("items")
+
+		tenantA, err := New(ctx, postgresURL, ksql.Config{Schema: "tenant_a"})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		tenantB, err := New(ctx, postgresURL, ksql.Config{Schema: "tenant_b"})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		err = tenantA.Insert(ctx, itemsTable, &item{Name: "item-a"})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		err = tenantB.Insert(ctx, itemsTable, &item{Name: "item-b"})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		for schema, c := range map[string]ksql.DB{"tenant_a": tenantA, "tenant_b": tenantB} {
+			var items []item
+			err = c.Query(ctx, &items, "FROM items")
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+
+			expected := []item{{ID: 1, Name: "item-" + strings.TrimPrefix(schema, "tenant_")}}
+			if len(items) != 1 || items[0] != expected[0] {
+				t.Fatalf("expected %s to contain %v, but got: %v", schema, expected, items)
+			}
+		}
+	})
 }
 
 type closerAdapter struct {
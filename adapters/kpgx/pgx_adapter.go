@@ -2,6 +2,7 @@ package kpgx
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 
 	"github.com/jackc/pgconn"
@@ -42,6 +43,26 @@ func (p PGXAdapter) BeginTx(ctx context.Context) (ksql.Tx, error) {
 	return PGXTx{tx}, err
 }
 
+// Stats converts the pgx pool stats to the sql.DBStats
+// format so it can be used by the ksql.Stats() function.
+//
+// The WaitCount is the number of acquires that had to wait for a
+// connection and the WaitDuration is approximated by the total time
+// spent acquiring connections. Since the pgx pool doesn't track closed
+// connections the MaxIdleClosed, MaxIdleTimeClosed and MaxLifetimeClosed
+// fields are always zero.
+func (p PGXAdapter) Stats() sql.DBStats {
+	stat := p.db.Stat()
+	return sql.DBStats{
+		MaxOpenConnections: int(stat.MaxConns()),
+		OpenConnections:    int(stat.TotalConns()),
+		InUse:              int(stat.AcquiredConns()),
+		Idle:               int(stat.IdleConns()),
+		WaitCount:          stat.EmptyAcquireCount(),
+		WaitDuration:       stat.AcquireDuration(),
+	}
+}
+
 // PGXResult is used to implement the DBAdapter interface and implements
 // the Result interface
 type PGXResult struct {
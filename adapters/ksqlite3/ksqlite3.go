@@ -3,6 +3,7 @@ package ksqlite3
 import (
 	"context"
 	"database/sql"
+	"fmt"
 
 	"github.com/vingarcia/ksql"
 
@@ -24,6 +25,22 @@ func New(
 ) (ksql.DB, error) {
 	config.SetDefaultValues()
 
+	if config.StatementTimeout > 0 {
+		return ksql.DB{}, fmt.Errorf("ksql: the StatementTimeout config is not supported by the ksqlite3 adapter")
+	}
+
+	if config.Schema != "" {
+		return ksql.DB{}, fmt.Errorf("ksql: the Schema config is not supported by the ksqlite3 adapter")
+	}
+
+	if config.ApplicationName != "" {
+		return ksql.DB{}, fmt.Errorf("ksql: the ApplicationName config is not supported by the ksqlite3 adapter")
+	}
+
+	if len(config.SessionSettings) > 0 {
+		return ksql.DB{}, fmt.Errorf("ksql: the SessionSettings config is not supported by the ksqlite3 adapter")
+	}
+
 	db, err := sql.Open("sqlite3", connectionString)
 	if err != nil {
 		return ksql.DB{}, err
@@ -1,9 +1,12 @@
 package ksqlite3
 
 import (
+	"context"
 	"database/sql"
 	"io"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/vingarcia/ksql"
 )
@@ -17,3 +20,64 @@ func TestAdapter(t *testing.T) {
 		return SQLAdapter{db}, db
 	})
 }
+
+func TestStats(t *testing.T) {
+	ctx := context.Background()
+	db, err := New(ctx, "/tmp/ksql.db", ksql.Config{
+		MaxOpenConns: 3,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	_, err = db.Exec(ctx, "SELECT 1")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	stats, err := db.Stats()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if stats.MaxOpenConnections != 3 {
+		t.Fatalf("expected MaxOpenConnections to be 3, but got: %d", stats.MaxOpenConnections)
+	}
+	if stats.OpenConnections < 1 {
+		t.Fatalf("expected at least one open connection, but got: %d", stats.OpenConnections)
+	}
+}
+
+func TestStatementTimeout(t *testing.T) {
+	_, err := New(context.Background(), "/tmp/ksql.db", ksql.Config{
+		StatementTimeout: time.Second,
+	})
+	if err == nil || !strings.Contains(err.Error(), "StatementTimeout") {
+		t.Fatalf("expected an error reporting StatementTimeout is not supported, but got: %v", err)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	_, err := New(context.Background(), "/tmp/ksql.db", ksql.Config{
+		Schema: "tenant_a",
+	})
+	if err == nil || !strings.Contains(err.Error(), "Schema") {
+		t.Fatalf("expected an error reporting Schema is not supported, but got: %v", err)
+	}
+}
+
+func TestSessionSettings(t *testing.T) {
+	_, err := New(context.Background(), "/tmp/ksql.db", ksql.Config{
+		ApplicationName: "fake-app",
+	})
+	if err == nil || !strings.Contains(err.Error(), "ApplicationName") {
+		t.Fatalf("expected an error reporting ApplicationName is not supported, but got: %v", err)
+	}
+
+	_, err = New(context.Background(), "/tmp/ksql.db", ksql.Config{
+		SessionSettings: map[string]string{"foo": "bar"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "SessionSettings") {
+		t.Fatalf("expected an error reporting SessionSettings is not supported, but got: %v", err)
+	}
+}
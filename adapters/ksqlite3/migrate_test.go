@@ -0,0 +1,81 @@
+//go:build go1.16
+// +build go1.16
+
+package ksqlite3
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/vingarcia/ksql"
+)
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	db, err := New(ctx, filepath.Join(t.TempDir(), "migrations.db"), ksql.Config{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	fsys := fstest.MapFS{
+		"migrations/0001_create_users.sql": {Data: []byte(`
+			CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);
+			INSERT INTO users (name) VALUES ('first user');
+		`)},
+		"migrations/0002_add_age.sql": {Data: []byte(`ALTER TABLE users ADD COLUMN age INTEGER`)},
+	}
+
+	err = db.Migrate(ctx, fsys, "migrations")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var users []struct {
+		Name string `ksql:"name"`
+		Age  *int   `ksql:"age"`
+	}
+	err = db.Query(ctx, &users, "SELECT name, age FROM users")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(users) != 1 || users[0].Name != "first user" || users[0].Age != nil {
+		t.Fatalf("unexpected users after the migrations: %v", users)
+	}
+
+	// Running it again should not apply the migrations twice:
+	fsys["migrations/0003_add_posts.sql"] = &fstest.MapFile{Data: []byte(`CREATE TABLE posts (id INTEGER PRIMARY KEY)`)}
+	err = db.Migrate(ctx, fsys, "migrations")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var versions []struct {
+		Version int64 `ksql:"version"`
+	}
+	err = db.Query(ctx, &versions, "SELECT version FROM schema_migrations ORDER BY version")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(versions) != 3 || versions[0].Version != 1 || versions[1].Version != 2 || versions[2].Version != 3 {
+		t.Fatalf("unexpected applied versions: %v", versions)
+	}
+
+	// A failing migration should not be marked as applied:
+	fsys["migrations/0004_invalid.sql"] = &fstest.MapFile{Data: []byte(`not valid sql`)}
+	err = db.Migrate(ctx, fsys, "migrations")
+	if err == nil || !strings.Contains(err.Error(), "0004_invalid.sql") {
+		t.Fatalf("expected an error on the invalid migration, but got: %v", err)
+	}
+
+	var count int64
+	count, err = db.CountOf(ctx, "FROM schema_migrations WHERE version = 4")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if count != 0 {
+		t.Fatalf("expected the invalid migration not to be marked as applied")
+	}
+}
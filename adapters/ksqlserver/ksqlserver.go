@@ -3,6 +3,7 @@ package ksqlserver
 import (
 	"context"
 	"database/sql"
+	"fmt"
 
 	"github.com/vingarcia/ksql"
 
@@ -24,6 +25,22 @@ func New(
 ) (ksql.DB, error) {
 	config.SetDefaultValues()
 
+	if config.StatementTimeout > 0 {
+		return ksql.DB{}, fmt.Errorf("ksql: the StatementTimeout config is not supported by the ksqlserver adapter")
+	}
+
+	if config.Schema != "" {
+		return ksql.DB{}, fmt.Errorf("ksql: the Schema config is not supported by the ksqlserver adapter")
+	}
+
+	if config.ApplicationName != "" {
+		return ksql.DB{}, fmt.Errorf("ksql: the ApplicationName config is not supported by the ksqlserver adapter")
+	}
+
+	if len(config.SessionSettings) > 0 {
+		return ksql.DB{}, fmt.Errorf("ksql: the SessionSettings config is not supported by the ksqlserver adapter")
+	}
+
 	db, err := sql.Open("sqlserver", connectionString)
 	if err != nil {
 		return ksql.DB{}, err
@@ -0,0 +1,574 @@
+package ksql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/vingarcia/ksql/internal/structs"
+)
+
+// defaultBatchSize is the number of records inserted by each
+// statement of InsertBatch if not configured with WithBatchSize.
+const defaultBatchSize = 500
+
+// sqlserverMaxParams is the maximum number of parameters
+// accepted by SQL Server on a single statement.
+const sqlserverMaxParams = 2100
+
+// WithBatchSize returns a copy of the DB configured to insert
+// at most size records on each statement executed by InsertBatch.
+//
+// The size must be a positive number, passing 0 restores the default
+// batch size of 500 records and negative values will cause InsertBatch
+// to return an error.
+func (c DB) WithBatchSize(size int) DB {
+	c.batchSize = size
+	return c
+}
+
+// InsertBatch inserts all the records of the input slice using
+// multi-row INSERT statements, e.g.:
+//
+//	err := c.InsertBatch(ctx, UsersTable, []User{{Name: "foo"}, {Name: "bar"}})
+//
+// The records are split into statements of at most the batch
+// size configured with WithBatchSize (500 by default) and
+// if more than one statement is needed they all run inside
+// a single transaction.
+//
+// All the tagged attributes are inserted, with nil pointers inserted as
+// NULL, and the ID columns and attributes tagged with omitempty are only
+// inserted if they are set on all the records, since a multi-row INSERT
+// can't omit a column on only some of its rows. Unlike Insert the
+// generated IDs are not written back to the input records.
+func (c DB) InsertBatch(ctx context.Context, table Table, records interface{}) (err error) {
+	ctx, finish := c.observe(ctx, "insert_batch", table.name)
+	defer func() { finish(err) }()
+
+	return c.insertBatches(ctx, "InsertBatch", table, records, func(tx DB, columns []*structs.FieldInfo, structValues []reflect.Value) error {
+		return tx.insertBatch(ctx, table, columns, structValues)
+	})
+}
+
+// InsertBatchReturning works like InsertBatch but also writes back
+// to each of the input records all the columns returned by the
+// database after the insertion, e.g. generated IDs and default values:
+//
+//	users := []User{{Name: "foo"}, {Name: "bar"}}
+//	err := c.InsertBatchReturning(ctx, UsersTable, users)
+//	// now users[0].ID and users[1].ID are set
+//
+// The returned rows are written back by position, so the database
+// must return them in the same order they were inserted, which is
+// what Postgres and SQLite do for plain multi-row INSERT statements.
+//
+// It is only supported on Postgres and SQLite,
+// since it relies on the RETURNING clause.
+func (c DB) InsertBatchReturning(ctx context.Context, table Table, records interface{}) (err error) {
+	ctx, finish := c.observe(ctx, "insert_batch_returning", table.name)
+	defer func() { finish(err) }()
+
+	switch c.dialect.DriverName() {
+	case "postgres", "sqlite3":
+	default:
+		return fmt.Errorf(
+			"ksql: InsertBatchReturning is not supported by the `%s` driver since it has no RETURNING clause",
+			c.dialect.DriverName(),
+		)
+	}
+
+	return c.insertBatches(ctx, "InsertBatchReturning", table, records, func(tx DB, columns []*structs.FieldInfo, structValues []reflect.Value) error {
+		return tx.insertBatchReturning(ctx, table, columns, structValues)
+	})
+}
+
+// InsertStream reads records from the input channel and inserts them
+// in batches of batchSize records using InsertBatch, e.g.:
+//
+//	recordsCh := make(chan interface{})
+//	go func() {
+//		defer close(recordsCh)
+//		for msg := range messages {
+//			recordsCh <- User{Name: msg.Name}
+//		}
+//	}()
+//
+//	err := c.InsertStream(ctx, UsersTable, recordsCh, 500)
+//
+// All the records must have the same type, either a struct or a pointer
+// to struct, and each batch is inserted as soon as it is full, the last
+// batch being inserted when the channel is closed.
+//
+// It returns on the first error or when the context is canceled, in which
+// case the records not yet inserted are discarded. Since the channel is
+// not read after that the producer should also stop on ctx cancellation.
+func (c DB) InsertStream(ctx context.Context, table Table, records <-chan interface{}, batchSize int) (err error) {
+	ctx, finish := c.observe(ctx, "insert_stream", table.name)
+	defer func() { finish(err) }()
+
+	if batchSize <= 0 {
+		return fmt.Errorf("ksql: the batch size must be a positive number, but got: %d", batchSize)
+	}
+	c.batchSize = batchSize
+
+	return readStreamBatches(ctx, records, batchSize, func(batch reflect.Value) error {
+		return c.InsertBatch(ctx, table, batch.Interface())
+	})
+}
+
+// InsertStreamReturningIDs works like InsertStream but after each batch
+// is inserted the onInserted callback is called once for each of its
+// records, in the order they were read from the channel, with the ID
+// generated for it by the database, e.g.:
+//
+//	err := c.InsertStreamReturningIDs(ctx, UsersTable, recordsCh, 500,
+//		func(record interface{}, id interface{}) error {
+//			log.Println("inserted", record.(User).Name, "with id", id)
+//			return nil
+//		},
+//	)
+//
+// The records received by the callback are the ones read from the
+// channel, which are not modified, and the IDs have the type of the
+// ID attribute of the records, so the table must have a single ID
+// column and it must be tagged on the records.
+//
+// On Postgres and SQLite each batch is inserted with a single statement
+// using the RETURNING clause, on the other drivers the records of each
+// batch are inserted one at a time inside a transaction. In both cases
+// the callback is only called after the whole batch is inserted, and
+// if it returns an error the stream stops with this error.
+func (c DB) InsertStreamReturningIDs(
+	ctx context.Context,
+	table Table,
+	records <-chan interface{},
+	batchSize int,
+	onInserted func(record interface{}, id interface{}) error,
+) (err error) {
+	ctx, finish := c.observe(ctx, "insert_stream_returning_ids", table.name)
+	defer func() { finish(err) }()
+
+	if batchSize <= 0 {
+		return fmt.Errorf("ksql: the batch size must be a positive number, but got: %d", batchSize)
+	}
+	c.batchSize = batchSize
+
+	if err := table.validate(); err != nil {
+		return fmt.Errorf("can't insert in ksql.Table: %s", err)
+	}
+
+	if len(table.idColumns) != 1 {
+		return fmt.Errorf("ksql: InsertStreamReturningIDs requires a table with a single ID column, but got: %v", table.idColumns)
+	}
+
+	return readStreamBatches(ctx, records, batchSize, func(batch reflect.Value) error {
+		ids, err := c.insertBatchReturningIDs(ctx, table, batch)
+		if err != nil {
+			return err
+		}
+
+		for i, id := range ids {
+			err := onInserted(batch.Index(i).Interface(), id)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// readStreamBatches reads the records of the channel into slices
+// of at most batchSize records and calls flushFn for each of them.
+func readStreamBatches(
+	ctx context.Context,
+	records <-chan interface{},
+	batchSize int,
+	flushFn func(batch reflect.Value) error,
+) error {
+	var batch reflect.Value
+	flush := func() error {
+		if !batch.IsValid() || batch.Len() == 0 {
+			return nil
+		}
+
+		err := flushFn(batch)
+		batch = batch.Slice(0, 0)
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case record, ok := <-records:
+			if !ok {
+				return flush()
+			}
+
+			if record == nil {
+				return fmt.Errorf("ksql: expected records to be structs or pointers to struct, but got nil")
+			}
+
+			v := reflect.ValueOf(record)
+			if !batch.IsValid() {
+				batch = reflect.MakeSlice(reflect.SliceOf(v.Type()), 0, batchSize)
+			} else if v.Type() != batch.Type().Elem() {
+				return fmt.Errorf(
+					"ksql: expected all records to have type %v, but got: %T",
+					batch.Type().Elem(), record,
+				)
+			}
+
+			batch = reflect.Append(batch, v)
+			if batch.Len() < batchSize {
+				continue
+			}
+
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// insertBatchReturningIDs inserts copies of the records of the batch
+// and returns the IDs written back to each of the copies.
+func (c DB) insertBatchReturningIDs(ctx context.Context, table Table, batch reflect.Value) ([]interface{}, error) {
+	structType := batch.Type().Elem()
+	isPtr := structType.Kind() == reflect.Ptr
+	if isPtr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("ksql: expected records to be structs or pointers to struct, but got: %v", batch.Type().Elem())
+	}
+
+	info, err := c.getTagInfo(structType)
+	if err != nil {
+		return nil, err
+	}
+
+	idField := info.ByName(table.idColumns[0])
+	if !idField.Valid {
+		return nil, fmt.Errorf("ksql: the ID column `%s` is not tagged on type %v", table.idColumns[0], structType)
+	}
+
+	copies := reflect.MakeSlice(reflect.SliceOf(reflect.PtrTo(structType)), batch.Len(), batch.Len())
+	for i := 0; i < batch.Len(); i++ {
+		v := batch.Index(i)
+		if isPtr {
+			if v.IsNil() {
+				return nil, fmt.Errorf("ksql: expected records to be structs or pointers to struct, but got nil")
+			}
+			v = v.Elem()
+		}
+
+		record := reflect.New(structType)
+		record.Elem().Set(v)
+		copies.Index(i).Set(record)
+	}
+
+	switch c.dialect.DriverName() {
+	case "postgres", "sqlite3":
+		err = c.InsertBatchReturning(ctx, table, copies.Interface())
+	default:
+		c.skipIDWriteBack = false
+		err = c.Transaction(ctx, func(p Provider) error {
+			for i := 0; i < copies.Len(); i++ {
+				err := p.Insert(ctx, table, copies.Index(i).Interface())
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]interface{}, copies.Len())
+	for i := range ids {
+		ids[i] = copies.Index(i).Elem().Field(idField.Index).Interface()
+	}
+
+	return ids, nil
+}
+
+// insertBatches validates the input records and calls insertFn
+// once for each batch, inside a transaction if more than one
+// batch is necessary.
+func (c DB) insertBatches(
+	ctx context.Context,
+	fnName string,
+	table Table,
+	records interface{},
+	insertFn func(tx DB, columns []*structs.FieldInfo, structValues []reflect.Value) error,
+) error {
+	if err := table.validate(); err != nil {
+		return fmt.Errorf("can't insert in ksql.Table: %s", err)
+	}
+
+	batchSize := c.batchSize
+	if batchSize == 0 {
+		batchSize = defaultBatchSize
+	}
+	if batchSize < 0 {
+		return fmt.Errorf("ksql: the batch size must be a positive number, but got: %d", batchSize)
+	}
+
+	slice := reflect.ValueOf(records)
+	if slice.Kind() == reflect.Ptr {
+		slice = slice.Elem()
+	}
+	if slice.Kind() != reflect.Slice {
+		return fmt.Errorf("ksql: expected records to be a slice of structs, but got: %T", records)
+	}
+
+	structType, isSliceOfPtrs, err := structs.DecodeAsSliceOfStructs(slice.Type())
+	if err != nil {
+		return fmt.Errorf("ksql: expected records to be a slice of structs, but got: %T", records)
+	}
+
+	info, err := c.getTagInfo(structType)
+	if err != nil {
+		return err
+	}
+
+	if info.IsNestedStruct {
+		return fmt.Errorf("ksql: %s doesn't support nested structs", fnName)
+	}
+
+	if slice.Len() == 0 {
+		return nil
+	}
+
+	structValues := make([]reflect.Value, slice.Len())
+	for i := range structValues {
+		structValues[i] = slice.Index(i)
+		if isSliceOfPtrs {
+			if structValues[i].IsNil() {
+				return fmt.Errorf("ksql: expected a valid pointer to struct on position %d, but got nil", i)
+			}
+			structValues[i] = structValues[i].Elem()
+		}
+	}
+
+	columns, err := getBatchInsertColumns(table, structType, info, structValues)
+	if err != nil {
+		return err
+	}
+
+	if c.dialect.DriverName() == "sqlserver" && batchSize*len(columns) > sqlserverMaxParams {
+		batchSize = sqlserverMaxParams / len(columns)
+		if batchSize == 0 {
+			batchSize = 1
+		}
+	}
+
+	if len(structValues) <= batchSize {
+		return insertFn(c, columns, structValues)
+	}
+
+	return c.Transaction(ctx, func(p Provider) error {
+		tx := p.(DB)
+		for start := 0; start < len(structValues); start += batchSize {
+			end := start + batchSize
+			if end > len(structValues) {
+				end = len(structValues)
+			}
+
+			err := insertFn(tx, columns, structValues[start:end])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (c DB) insertBatch(
+	ctx context.Context,
+	table Table,
+	columns []*structs.FieldInfo,
+	structValues []reflect.Value,
+) error {
+	query, params, err := buildBatchInsertQuery(c.dialect, table, columns, structValues)
+	if err != nil {
+		return err
+	}
+	c.convertParamsToLocation(params)
+
+	_, err = c.execContext(ctx, query, params...)
+	if err != nil {
+		return fmt.Errorf("ksql: InsertBatch into %q failed: %w", table.name, err)
+	}
+
+	return nil
+}
+
+func (c DB) insertBatchReturning(
+	ctx context.Context,
+	table Table,
+	columns []*structs.FieldInfo,
+	structValues []reflect.Value,
+) error {
+	query, params, err := buildBatchInsertQuery(c.dialect, table, columns, structValues)
+	if err != nil {
+		return err
+	}
+	c.convertParamsToLocation(params)
+
+	structType := structValues[0].Type()
+	info, err := c.getTagInfo(structType)
+	if err != nil {
+		return err
+	}
+
+	escapedNames := []string{}
+	for i := 0; i < structType.NumField(); i++ {
+		if fieldInfo := info.ByIndex(i); fieldInfo.Valid {
+			escapedNames = append(escapedNames, c.dialect.Escape(fieldInfo.Name))
+		}
+	}
+	query += " RETURNING " + strings.Join(escapedNames, ", ")
+
+	rows, err := c.queryContext(ctx, query, params...)
+	if err != nil {
+		return fmt.Errorf("ksql: InsertBatchReturning into %q failed: %w", table.name, err)
+	}
+	defer rows.Close()
+
+	i := 0
+	for ; rows.Next(); i++ {
+		if i >= len(structValues) {
+			return fmt.Errorf("ksql: unexpected error: the batch insert returned more rows than inserted")
+		}
+
+		err = c.scanRows(rows, structValues[i].Addr().Interface())
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+
+	if i != len(structValues) {
+		return fmt.Errorf("ksql: unexpected error: the batch insert returned %d rows, but %d were inserted", i, len(structValues))
+	}
+
+	return nil
+}
+
+// getBatchInsertColumns returns the columns that should be inserted in
+// declaration order, ignoring the ID columns and the omitempty attributes
+// not set on any record.
+func getBatchInsertColumns(
+	table Table,
+	structType reflect.Type,
+	info structs.StructInfo,
+	structValues []reflect.Value,
+) ([]*structs.FieldInfo, error) {
+	isIDColumn := map[string]bool{}
+	for _, id := range table.idColumns {
+		isIDColumn[id] = true
+	}
+
+	columns := []*structs.FieldInfo{}
+	for i := 0; i < structType.NumField(); i++ {
+		fieldInfo := info.ByIndex(i)
+		if !fieldInfo.Valid || fieldInfo.ReadOnly {
+			continue
+		}
+
+		if isIDColumn[fieldInfo.Name] || fieldInfo.OmitEmpty {
+			numSet := 0
+			for _, v := range structValues {
+				if !v.Field(i).IsZero() {
+					numSet++
+				}
+			}
+
+			if numSet == 0 {
+				continue
+			}
+
+			if numSet != len(structValues) {
+				kind := "ID column"
+				if !isIDColumn[fieldInfo.Name] {
+					kind = "omitempty column"
+				}
+				return nil, fmt.Errorf(
+					"ksql: the %s `%s` must be either set on all records or on none of them",
+					kind, fieldInfo.Name,
+				)
+			}
+		}
+
+		columns = append(columns, fieldInfo)
+	}
+
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("ksql: no attributes to insert on type %v", structType)
+	}
+
+	return columns, nil
+}
+
+func buildBatchInsertQuery(
+	dialect Dialect,
+	table Table,
+	columns []*structs.FieldInfo,
+	structValues []reflect.Value,
+) (query string, params []interface{}, err error) {
+	escapedColumnNames := make([]string, len(columns))
+	for i, col := range columns {
+		escapedColumnNames[i] = dialect.Escape(col.Name)
+	}
+
+	valuesQuery := make([]string, len(structValues))
+	for i, v := range structValues {
+		placeholders := make([]string, len(columns))
+		for j, col := range columns {
+			placeholders[j] = dialect.Placeholder(len(params))
+
+			// Nil pointers are inserted as NULL:
+			var value interface{}
+			field, found, err := structs.ReadField(v, col)
+			if err != nil {
+				return "", nil, err
+			}
+			if found {
+				value = field.Interface()
+			}
+
+			value = getUnixTimestampParam(col, value)
+			if col.SerializeAsJSON && value != nil {
+				value = jsonSerializable{
+					DriverName: dialect.DriverName(),
+					Attr:       value,
+				}
+			}
+
+			params = append(params, value)
+		}
+		valuesQuery[i] = "(" + strings.Join(placeholders, ", ") + ")"
+	}
+
+	query = fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES %s",
+		dialect.Escape(table.name),
+		strings.Join(escapedColumnNames, ", "),
+		strings.Join(valuesQuery, ", "),
+	)
+
+	return query, params, nil
+}
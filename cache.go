@@ -0,0 +1,191 @@
+package ksql
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/vingarcia/ksql/internal/structs"
+)
+
+// QueryCache is the interface used by CachedQuery for storing
+// the serialized results of the queries, it should be safe for
+// concurrent use, and the entries should expire after the ttl.
+type QueryCache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, val []byte, ttl time.Duration)
+}
+
+// CachedQuery works like Query but the results are stored on the input
+// cache for the duration of the ttl, and while they are cached the
+// database is not queried, e.g.:
+//
+//	var countries []Country
+//	err := c.CachedQuery(ctx, cache, time.Hour, &countries, "FROM countries ORDER BY name")
+//
+// The cache keys are generated from the driver, the type of the records,
+// and the query and params sent to the database, i.e. including the
+// values of the Named params and the scopes added with Where. The records are serialized with encoding/json,
+// so this should only be used with structs that can be converted to JSON
+// and back without losing information, and for rarely changing data,
+// since the cached results are not invalidated by writes.
+func (c DB) CachedQuery(
+	ctx context.Context,
+	cache QueryCache,
+	ttl time.Duration,
+	records interface{},
+	query string,
+	params ...interface{},
+) (err error) {
+	ctx, finish := c.observe(ctx, "cached_query", "")
+	defer func() { finish(err) }()
+
+	key, err := c.buildQueryCacheKey(records, query, params)
+	if err != nil {
+		return err
+	}
+
+	if cached, found := cache.Get(key); found {
+		// Entries that can't be decoded, e.g. because the struct
+		// has changed, are just replaced with fresh results:
+		if json.Unmarshal(cached, records) == nil {
+			// The decoded times keep only the offset of their
+			// original location, so they are converted again:
+			return c.convertRecordsToLocation(records)
+		}
+	}
+
+	err = c.Query(ctx, records, query, params...)
+	if err != nil {
+		return err
+	}
+
+	serialized, err := json.Marshal(records)
+	if err != nil {
+		return fmt.Errorf("ksql: unable to serialize the results of the query for caching: %w", err)
+	}
+	cache.Set(key, serialized, ttl)
+
+	return nil
+}
+
+// buildQueryCacheKey builds the key from the query and the params
+// actually sent to the database, i.e. after binding the named params,
+// applying the scopes and the default ORDER BY, and from the options of
+// the DB that change how the rows are scanned, including the columns
+// resolved for each attribute, so queries with different results never
+// share the same key.
+func (c DB) buildQueryCacheKey(records interface{}, query string, params []interface{}) (string, error) {
+	query, params, err := bindNamedParams(c.dialect, query, params, c.getColumnResolver())
+	if err != nil {
+		return "", err
+	}
+
+	recordsType := reflect.TypeOf(records)
+	if recordsType == nil || recordsType.Kind() != reflect.Ptr {
+		return "", fmt.Errorf("ksql: expected to receive a pointer to slice of structs, but got: %T", records)
+	}
+
+	structType, _, err := structs.DecodeAsSliceOfStructs(recordsType.Elem())
+	if err != nil {
+		return "", err
+	}
+
+	info, err := c.getTagInfo(structType)
+	if err != nil {
+		return "", err
+	}
+
+	// The column of each attribute is part of the key since DBs with
+	// different column resolvers scan the same rows differently:
+	columns := make([]string, structType.NumField())
+	for i := range columns {
+		if fieldInfo := info.ByIndex(i); fieldInfo.Valid {
+			columns[i] = fieldInfo.Name
+		}
+	}
+
+	query, params, err = c.prepareQuery(query, params, structType, info)
+	if err != nil {
+		return "", err
+	}
+
+	serializedParams := make([]interface{}, len(params))
+	for i, param := range params {
+		// Valuers, e.g. the JSON attributes, might have unexported
+		// attributes so their values are serialized instead:
+		if valuer, ok := param.(driver.Valuer); ok {
+			param, err = valuer.Value()
+			if err != nil {
+				return "", fmt.Errorf("ksql: unable to serialize the query params for building the cache key: %w", err)
+			}
+		}
+		serializedParams[i] = param
+	}
+
+	rawParams, err := json.Marshal(serializedParams)
+	if err != nil {
+		return "", fmt.Errorf("ksql: unable to serialize the query params for building the cache key: %w", err)
+	}
+
+	var location string
+	if c.location != nil {
+		location = c.location.String()
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%v\x00%q\x00%s\x00%s\x00%t\x00%t\x00%s\x00",
+		c.dialect.DriverName(),
+		recordsType,
+		columns,
+		query,
+		c.columnPrefix,
+		c.nullAsZero,
+		c.caseInsensitiveColumns,
+		location,
+	)
+	h.Write(rawParams)
+
+	return "ksql:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// convertRecordsToLocation converts the time attributes of the records
+// decoded from the cache to the location configured on the DB, if any.
+func (c DB) convertRecordsToLocation(records interface{}) error {
+	if c.location == nil {
+		return nil
+	}
+
+	slice := reflect.ValueOf(records).Elem()
+	structType, isSliceOfPtrs, err := structs.DecodeAsSliceOfStructs(slice.Type())
+	if err != nil {
+		return err
+	}
+
+	info, err := c.getTagInfo(structType)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < slice.Len(); i++ {
+		record := slice.Index(i)
+		if isSliceOfPtrs {
+			if record.IsNil() {
+				continue
+			}
+			record = record.Elem()
+		}
+
+		err = convertTimesToLocation(record, info, c.location, c.getColumnResolver())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
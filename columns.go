@@ -0,0 +1,99 @@
+package ksql
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/vingarcia/ksql/internal/structs"
+)
+
+// ColumnName returns the name of the column mapped to the
+// attribute with the input name on the struct, e.g.:
+//
+//	column, err := ksql.ColumnName(&User{}, "Email")
+//
+// An error is returned if the struct has no attribute
+// with this name or if the attribute is not tagged.
+func ColumnName(structPtr interface{}, fieldName string) (string, error) {
+	t, _, err := parseColumnStruct(structPtr)
+	if err != nil {
+		return "", err
+	}
+
+	field, found := t.FieldByName(fieldName)
+	if !found || len(field.Index) != 1 {
+		return "", fmt.Errorf("ksql: the type %v has no attribute named `%s`", t, fieldName)
+	}
+
+	return getColumnByIndex(t, field.Index[0])
+}
+
+// Col returns the name of the column mapped to the attribute
+// referenced by the fieldPtr, which must point to an attribute
+// of the struct referenced by the structPtr, e.g.:
+//
+//	var u User
+//	query := kbuilder.Where(ksql.Col(&u, &u.Email)+" = %s", email)
+//
+// Unlike ColumnName, renaming or removing the attribute breaks the
+// build instead of the query. Since this is meant for building queries
+// it panics if the arguments are invalid, e.g. if the attribute is not
+// tagged or if the fieldPtr doesn't point inside of the structPtr.
+func Col(structPtr interface{}, fieldPtr interface{}) string {
+	column, err := getColumnByPtr(structPtr, fieldPtr)
+	if err != nil {
+		panic(err)
+	}
+	return column
+}
+
+func getColumnByPtr(structPtr interface{}, fieldPtr interface{}) (string, error) {
+	t, v, err := parseColumnStruct(structPtr)
+	if err != nil {
+		return "", err
+	}
+
+	f := reflect.ValueOf(fieldPtr)
+	if f.Kind() != reflect.Ptr || f.IsNil() {
+		return "", fmt.Errorf("ksql: expected fieldPtr to be a pointer to an attribute of %v, but got: %T", t, fieldPtr)
+	}
+
+	offset := f.Pointer() - v.Pointer()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		// Checking the type is necessary since zero sized
+		// attributes might share the offset of the next one:
+		if field.Offset == offset && field.Type == f.Type().Elem() {
+			return getColumnByIndex(t, i)
+		}
+	}
+
+	return "", fmt.Errorf("ksql: the fieldPtr doesn't point to an attribute of the structPtr of type %v", t)
+}
+
+func parseColumnStruct(structPtr interface{}) (reflect.Type, reflect.Value, error) {
+	v := reflect.ValueOf(structPtr)
+	if !v.IsValid() || assertStructPtr(v.Type()) != nil {
+		return nil, reflect.Value{}, fmt.Errorf("ksql: expected structPtr to be a pointer to struct, but got: %T", structPtr)
+	}
+	t := v.Type()
+	if v.IsNil() {
+		return nil, reflect.Value{}, fmt.Errorf("ksql: expected a valid pointer to struct as argument but received a nil pointer: %v", structPtr)
+	}
+
+	return t.Elem(), v, nil
+}
+
+func getColumnByIndex(t reflect.Type, idx int) (string, error) {
+	info, err := structs.GetTagInfo(t)
+	if err != nil {
+		return "", err
+	}
+
+	fieldInfo := info.ByIndex(idx)
+	if !fieldInfo.Valid {
+		return "", fmt.Errorf("ksql: the attribute `%s` of type %v is not tagged with a column name", t.Field(idx).Name, t)
+	}
+
+	return fieldInfo.Name, nil
+}
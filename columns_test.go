@@ -0,0 +1,72 @@
+package ksql
+
+import (
+	"testing"
+
+	tt "github.com/vingarcia/ksql/internal/testtools"
+)
+
+type columnsTestUser struct {
+	ID       int    `ksql:"id"`
+	Email    string `ksql:"email_address"`
+	Untagged string
+	Empty    struct{} `ksql:"empty"`
+	Name     string   `ksql:"name"`
+}
+
+func TestColumnName(t *testing.T) {
+	t.Run("should return the column mapped to the attribute", func(t *testing.T) {
+		column, err := ColumnName(&columnsTestUser{}, "Email")
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, column, "email_address")
+	})
+
+	t.Run("should report error for missing or untagged attributes", func(t *testing.T) {
+		_, err := ColumnName(&columnsTestUser{}, "NotAnAttribute")
+		tt.AssertErrContains(t, err, "NotAnAttribute")
+
+		_, err = ColumnName(&columnsTestUser{}, "Untagged")
+		tt.AssertErrContains(t, err, "Untagged", "not tagged")
+	})
+
+	t.Run("should report error for invalid structs", func(t *testing.T) {
+		_, err := ColumnName(columnsTestUser{}, "Email")
+		tt.AssertErrContains(t, err, "pointer to struct")
+
+		_, err = ColumnName(nil, "Email")
+		tt.AssertErrContains(t, err, "pointer to struct")
+
+		_, err = ColumnName((*columnsTestUser)(nil), "Email")
+		tt.AssertErrContains(t, err, "nil pointer")
+	})
+}
+
+func TestCol(t *testing.T) {
+	t.Run("should return the column mapped to the referenced attribute", func(t *testing.T) {
+		var u columnsTestUser
+		tt.AssertEqual(t, Col(&u, &u.ID), "id")
+		tt.AssertEqual(t, Col(&u, &u.Email), "email_address")
+		tt.AssertEqual(t, Col(&u, &u.Empty), "empty")
+		tt.AssertEqual(t, Col(&u, &u.Name), "name")
+	})
+
+	t.Run("should report error for pointers outside of the struct", func(t *testing.T) {
+		var u, other columnsTestUser
+		_, err := getColumnByPtr(&u, &other.Email)
+		tt.AssertErrContains(t, err, "doesn't point to an attribute")
+
+		_, err = getColumnByPtr(&u, u.Email)
+		tt.AssertErrContains(t, err, "fieldPtr")
+
+		_, err = getColumnByPtr(&u, &u.Untagged)
+		tt.AssertErrContains(t, err, "Untagged", "not tagged")
+	})
+
+	t.Run("should panic for invalid arguments", func(t *testing.T) {
+		var u columnsTestUser
+		defer func() {
+			tt.AssertNotEqual(t, recover(), nil)
+		}()
+		Col(&u, &u.Untagged)
+	})
+}
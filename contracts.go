@@ -11,6 +11,19 @@ import (
 // ErrRecordNotFound ...
 var ErrRecordNotFound error = errors.Wrap(sql.ErrNoRows, "ksql: the query returned no results")
 
+// ErrMultipleRecordsFound is returned by QueryOne when the query
+// returns more than one row and the DB was configured with WithStrictQueryOne(true)
+var ErrMultipleRecordsFound error = fmt.Errorf("ksql: the query returned more than one result")
+
+// ErrNoFieldsToUpdate is returned by Patch when all the attributes of the record,
+// except for the IDs, are nil pointers or were filtered out, so there is nothing to update.
+var ErrNoFieldsToUpdate error = fmt.Errorf("ksql: no fields to update")
+
+// ErrDeadlock is returned when the database aborts an operation because of a
+// deadlock, wrapping the driver error, so it can be checked with errors.Is,
+// e.g. for retrying the transaction. The driver error can still be read with errors.As.
+var ErrDeadlock error = fmt.Errorf("ksql: the operation was aborted because of a deadlock")
+
 // ErrAbortIteration ...
 var ErrAbortIteration error = fmt.Errorf("ksql: abort iteration, should only be used inside QueryChunks function")
 
@@ -77,13 +90,21 @@ func NewTable(tableName string, ids ...string) Table {
 
 func (t Table) validate() error {
 	if t.name == "" {
-		return fmt.Errorf("table name cannot be an empty string")
+		return fmt.Errorf("no table name configured: the table name cannot be an empty string")
+	}
+
+	if err := ValidateIdentifier(t.name); err != nil {
+		return fmt.Errorf("invalid table name: %s", err)
 	}
 
 	for _, fieldName := range t.idColumns {
 		if fieldName == "" {
 			return fmt.Errorf("ID columns cannot be empty strings")
 		}
+
+		if err := ValidateIdentifier(fieldName); err != nil {
+			return fmt.Errorf("invalid ID column: %s", err)
+		}
 	}
 
 	return nil
@@ -118,4 +139,26 @@ type ChunkParser struct {
 	// Where the actual Record type should be of a struct
 	// representing the rows you are expecting to receive.
 	ForEachChunk interface{}
+
+	// OnProgress is optional and, if set, is called after each
+	// successful call to ForEachChunk with the total number of
+	// rows processed so far, e.g. for reporting the progress of
+	// long exports without coupling it to the ForEachChunk function.
+	OnProgress func(rowsSoFar int)
+
+	// ServerCursor configures QueryChunks to read the rows using a
+	// server-side cursor, i.e. `DECLARE ... CURSOR` followed by one
+	// `FETCH` of ChunkSize rows for each chunk, so that only one chunk
+	// is transferred over the wire at a time.
+	//
+	// Cursors are only used on Postgres and only exist inside transactions,
+	// so if QueryChunks is not called inside a transaction a new one is
+	// started just for reading the rows. On other drivers this option is ignored.
+	ServerCursor bool
+}
+
+func (p ChunkParser) reportProgress(rowsSoFar int) {
+	if p.OnProgress != nil {
+		p.OnProgress(rowsSoFar)
+	}
 }
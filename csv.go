@@ -0,0 +1,69 @@
+package ksql
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"time"
+)
+
+// QueryToCSV runs a query and writes its results to the input writer
+// as CSV, with a header row containing the column names, e.g.:
+//
+//	err := c.QueryToCSV(ctx, os.Stdout, "SELECT id, name FROM users WHERE age > $1", 18)
+//
+// The rows are streamed from the database to the writer one at a time,
+// so the results are never fully loaded into memory. NULL values are
+// written as empty fields and time values are written in RFC3339 format.
+func (c DB) QueryToCSV(ctx context.Context, w io.Writer, query string, params ...interface{}) (err error) {
+	ctx, finish := c.observe(ctx, "query_to_csv", "")
+	defer func() { finish(err) }()
+
+	it, err := c.QueryMapIter(ctx, query, params...)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	csvWriter := csv.NewWriter(w)
+	err = csvWriter.Write(it.columns)
+	if err != nil {
+		return fmt.Errorf("ksql: error writing CSV header: %w", err)
+	}
+
+	record := make([]string, len(it.columns))
+	for row, ok := it.Next(); ok; row, ok = it.Next() {
+		for i, column := range it.columns {
+			record[i] = formatCSVValue(row[column])
+		}
+
+		err = csvWriter.Write(record)
+		if err != nil {
+			return fmt.Errorf("ksql: error writing CSV row: %w", err)
+		}
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		return fmt.Errorf("ksql: error writing CSV: %w", err)
+	}
+
+	return nil
+}
+
+func formatCSVValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
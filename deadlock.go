@@ -0,0 +1,74 @@
+package ksql
+
+import (
+	"errors"
+	"strings"
+)
+
+// deadlockError wraps the driver errors caused by
+// deadlocks so they match ErrDeadlock on errors.Is.
+type deadlockError struct {
+	err error
+}
+
+func (d deadlockError) Error() string {
+	return "ksql: deadlock detected: " + d.err.Error()
+}
+
+func (d deadlockError) Unwrap() error {
+	return d.err
+}
+
+func (d deadlockError) Is(target error) bool {
+	return target == ErrDeadlock
+}
+
+// wrapDeadlockError wraps the input error with ErrDeadlock if it was
+// caused by a deadlock, other errors are returned as they are.
+func wrapDeadlockError(err error) error {
+	if !isDeadlockError(err) || errors.Is(err, ErrDeadlock) {
+		return err
+	}
+	return deadlockError{err: err}
+}
+
+// postgresDeadlockCode is the SQLSTATE code used by Postgres for deadlocks.
+const postgresDeadlockCode = "40P01"
+
+// isDeadlockError reports if the error is a deadlock
+// error from either Postgres or MySQL.
+func isDeadlockError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	// Both the pgx and the lib/pq errors implement this method:
+	var sqlStateErr interface {
+		SQLState() string
+	}
+	if errors.As(err, &sqlStateErr) {
+		return sqlStateErr.SQLState() == postgresDeadlockCode
+	}
+
+	// The MySQL driver reports the error number only as a struct
+	// attribute, so it is read from the message instead, which
+	// is either "Error 1213: ..." or "Error 1213 (40001): ...":
+	for ; err != nil; err = errors.Unwrap(err) {
+		msg := err.Error()
+		if strings.HasPrefix(msg, "Error 1213:") || strings.HasPrefix(msg, "Error 1213 (") {
+			return true
+		}
+	}
+	return false
+}
+
+// deadlockRows wraps the errors reported by the rows with ErrDeadlock,
+// since some drivers only report the errors of the query while reading
+// its results, e.g. the deadlocks of `UPDATE ... RETURNING` queries on pgx.
+type deadlockRows struct {
+	Rows
+}
+
+func (r deadlockRows) Err() error {
+	return wrapDeadlockError(r.Rows.Err())
+}
@@ -3,6 +3,8 @@ package ksql
 import (
 	"fmt"
 	"strconv"
+	"strings"
+	"unicode"
 )
 
 type insertMethod int
@@ -78,6 +80,63 @@ func GetDriverDialect(driver string) (Dialect, error) {
 	return dialect, nil
 }
 
+// ValidateIdentifier checks if the input string is safe for being
+// used as a table or column name on the generated queries.
+//
+// This is necessary because the Dialect.Escape() method only adds the quotes
+// around the identifier, so a name containing quotes could otherwise be used
+// for injecting arbitrary SQL in the query.
+//
+// Valid identifiers start with a letter or an underscore
+// and contain only letters, digits, underscores and dollar signs.
+func ValidateIdentifier(name string) error {
+	if name == "" {
+		return fmt.Errorf("identifiers cannot be empty strings")
+	}
+
+	for i, c := range name {
+		if unicode.IsLetter(c) || c == '_' {
+			continue
+		}
+
+		if i > 0 && (unicode.IsDigit(c) || c == '$') {
+			continue
+		}
+
+		return fmt.Errorf("invalid identifier `%s`: unexpected character %q", name, c)
+	}
+
+	return nil
+}
+
+// LikeEscapeChar is the character used by EscapeLike for escaping
+// the special characters of LIKE patterns. It works on all the supported
+// drivers but must be informed with an ESCAPE clause, e.g. `ESCAPE '!'`.
+const LikeEscapeChar = "!"
+
+var likeReplacer = strings.NewReplacer(
+	LikeEscapeChar, LikeEscapeChar+LikeEscapeChar,
+	"%", LikeEscapeChar+"%",
+	"_", LikeEscapeChar+"_",
+	// `[` is only special on sqlserver, but escaping it works on all drivers:
+	"[", LikeEscapeChar+"[",
+)
+
+// EscapeLike escapes the special characters of LIKE patterns so the input
+// string is matched literally, which is useful for building patterns
+// from user input, e.g.:
+//
+//	err := c.Query(ctx, &users,
+//		"FROM users WHERE name LIKE $1 ESCAPE '!'",
+//		"%"+ksql.EscapeLike(searchTerm)+"%",
+//	)
+//
+// Note that the `ESCAPE '!'` clause is required, since
+// by default each driver uses a different escape character.
+func EscapeLike(input string) string {
+	return likeReplacer.Replace(input)
+}
+
 type mysqlDialect struct{}
 
 func (mysqlDialect) DriverName() string {
@@ -22,3 +22,51 @@ func TestGetDriverDialect(t *testing.T) {
 		tt.AssertErrContains(t, err, "unsupported driver", "non-existing-driver")
 	})
 }
+
+func TestValidateIdentifier(t *testing.T) {
+	t.Run("should accept valid identifiers", func(t *testing.T) {
+		for _, name := range []string{"users", "_users", "user_permissions", "users2", "users$", "usuários"} {
+			tt.AssertNoErr(t, ValidateIdentifier(name))
+		}
+	})
+
+	t.Run("should reject invalid identifiers", func(t *testing.T) {
+		tests := []struct {
+			desc string
+			name string
+		}{
+			{desc: "empty", name: ""},
+			{desc: "starting with a digit", name: "2users"},
+			{desc: "with spaces", name: "my users"},
+			{desc: "with a postgres quote", name: `users"; DROP TABLE "users`},
+			{desc: "with a mysql quote", name: "users`; DROP TABLE `users"},
+			{desc: "with a sqlserver quote", name: "users]; DROP TABLE [users"},
+		}
+		for _, test := range tests {
+			t.Run(test.desc, func(t *testing.T) {
+				tt.AssertNotEqual(t, ValidateIdentifier(test.name), nil)
+			})
+		}
+	})
+}
+
+func TestEscapeLike(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "", expected: ""},
+		{input: "john", expected: "john"},
+		{input: "50% off", expected: "50!% off"},
+		{input: "user_name", expected: "user!_name"},
+		{input: "%_%", expected: "!%!_!%"},
+		{input: "wow!", expected: "wow!!"},
+		{input: "[a-z]", expected: "![a-z]"},
+		{input: `C:\path`, expected: `C:\path`},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			tt.AssertEqual(t, EscapeLike(test.input), test.expected)
+		})
+	}
+}
@@ -3,7 +3,11 @@ package structs
 import (
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode"
 )
 
 // StructInfo stores metainformation of the struct
@@ -13,6 +17,7 @@ type StructInfo struct {
 	IsNestedStruct bool
 	byIndex        map[int]*FieldInfo
 	byName         map[string]*FieldInfo
+	byLowerName    map[string]*FieldInfo
 }
 
 // FieldInfo contains reflection and tags
@@ -23,6 +28,25 @@ type FieldInfo struct {
 	Index           int
 	Valid           bool
 	SerializeAsJSON bool
+
+	// OmitEmpty is set by the `omitempty` tag option and
+	// causes StructToMap to ignore the field if it is zero.
+	OmitEmpty bool
+
+	// ReadOnly is set by the `readonly` tag option and marks
+	// columns that are scanned but never inserted or updated,
+	// e.g. generated columns.
+	ReadOnly bool
+
+	// UnixTimestampUnit is set by the `unix` and `unixmilli` tag options
+	// to time.Second and time.Millisecond respectively, and marks integer
+	// fields that are stored on timestamp columns of the database.
+	UnixTimestampUnit time.Duration
+
+	// Default is set by the `default=<value>` tag option to the value
+	// converted to the type of the field, or to its element type for
+	// pointers, and it is invalid for fields without this option.
+	Default reflect.Value
 }
 
 // ByIndex returns either the *FieldInfo of a valid
@@ -45,10 +69,26 @@ func (s StructInfo) ByName(name string) *FieldInfo {
 	return field
 }
 
+// ByNameCaseInsensitive works like ByName but ignores the case
+// of the names, if more than one field matches the input name
+// the first one declared on the struct is returned.
+func (s StructInfo) ByNameCaseInsensitive(name string) *FieldInfo {
+	field, found := s.byLowerName[strings.ToLower(name)]
+	if !found {
+		return &FieldInfo{}
+	}
+	return field
+}
+
 func (s StructInfo) add(field FieldInfo) {
 	field.Valid = true
 	s.byIndex[field.Index] = &field
 	s.byName[field.Name] = &field
+
+	lowerName := strings.ToLower(field.Name)
+	if _, found := s.byLowerName[lowerName]; !found {
+		s.byLowerName[lowerName] = &field
+	}
 }
 
 // NumFields ...
@@ -60,7 +100,94 @@ func (s StructInfo) NumFields() int {
 // because the total number of types on a program
 // should be finite. So keeping a single cache here
 // works fine.
-var tagInfoCache = map[reflect.Type]StructInfo{}
+//
+// This is also true for anonymous structs, since identical
+// struct types are represented by the same reflect.Type, so
+// an anonymous struct declared inside a function produces
+// a single entry no matter how many times it is called.
+//
+// Types created dynamically, e.g. with reflect.StructOf, can still make
+// it grow indefinitely, so the cache is limited to maxTagInfoCacheSize
+// entries and when it is full a random entry is evicted.
+//
+// Since the information depends on the ColumnResolver, the entries
+// are keyed by the type and the resolver used for building them.
+var tagInfoCache = map[tagInfoCacheKey]StructInfo{}
+var tagInfoCacheMutex sync.RWMutex
+
+type tagInfoCacheKey struct {
+	t        reflect.Type
+	resolver *ColumnResolver
+}
+
+// maxTagInfoCacheSize is a variable only so we can change it on the tests.
+var maxTagInfoCacheSize = 10000
+
+// ColumnResolver derives the column names of the exported attributes
+// without the `ksql` tag from their names, attributes resolved to
+// empty strings are ignored.
+//
+// It is used as a pointer, so it can be part of the cache keys,
+// and a nil pointer or a nil Resolve function disables it.
+type ColumnResolver struct {
+	Resolve func(fieldName string) string
+}
+
+// NewColumnResolver returns a ColumnResolver for the input function,
+// each call returns a new resolver with its own cache entries.
+func NewColumnResolver(resolve func(fieldName string) string) *ColumnResolver {
+	return &ColumnResolver{
+		Resolve: resolve,
+	}
+}
+
+func (r *ColumnResolver) isEnabled() bool {
+	return r != nil && r.Resolve != nil
+}
+
+// defaultColumnResolver is protected by the tagInfoCacheMutex.
+var defaultColumnResolver *ColumnResolver
+
+// SetSnakeCaseUntaggedFields enables or disables deriving the column
+// names of exported attributes without the `ksql` tag from their names,
+// e.g. `UserID` becomes `user_id`.
+//
+// It is the same as calling SetColumnResolver with ToSnakeCase or nil.
+func SetSnakeCaseUntaggedFields(enabled bool) {
+	if enabled {
+		SetColumnResolver(ToSnakeCase)
+	} else {
+		SetColumnResolver(nil)
+	}
+}
+
+// SetColumnResolver sets the default function used for deriving the
+// column names of exported attributes without the `ksql` tag from their
+// names, i.e. the one used by GetTagInfo and StructToMap. Passing nil
+// disables it, so only the tagged attributes are used.
+func SetColumnResolver(resolve func(fieldName string) string) {
+	tagInfoCacheMutex.Lock()
+	defer tagInfoCacheMutex.Unlock()
+
+	defaultColumnResolver = nil
+	if resolve != nil {
+		defaultColumnResolver = NewColumnResolver(resolve)
+	}
+}
+
+// DefaultColumnResolver returns the resolver set with SetColumnResolver
+// or SetSnakeCaseUntaggedFields, or nil if there is none.
+func DefaultColumnResolver() *ColumnResolver {
+	tagInfoCacheMutex.RLock()
+	defer tagInfoCacheMutex.RUnlock()
+	return defaultColumnResolver
+}
+
+// HasColumnResolver reports if a resolver was set
+// with SetColumnResolver or SetSnakeCaseUntaggedFields.
+func HasColumnResolver() bool {
+	return DefaultColumnResolver() != nil
+}
 
 // GetTagInfo efficiently returns the type information
 // using a global private cache
@@ -69,37 +196,70 @@ var tagInfoCache = map[reflect.Type]StructInfo{}
 // a struct, but for now this accessor is the one
 // we are using
 func GetTagInfo(key reflect.Type) (StructInfo, error) {
-	return getCachedTagInfo(tagInfoCache, key)
+	return GetTagInfoWithResolver(key, DefaultColumnResolver())
 }
 
-func getCachedTagInfo(tagInfoCache map[reflect.Type]StructInfo, key reflect.Type) (StructInfo, error) {
-	if info, found := tagInfoCache[key]; found {
+// GetTagInfoWithResolver works like GetTagInfo but derives the names
+// of the untagged attributes with the input resolver instead of the
+// default one, passing nil means only the tagged attributes are used.
+func GetTagInfoWithResolver(key reflect.Type, resolver *ColumnResolver) (StructInfo, error) {
+	cacheKey := tagInfoCacheKey{t: key, resolver: resolver}
+
+	tagInfoCacheMutex.RLock()
+	info, found := tagInfoCache[cacheKey]
+	tagInfoCacheMutex.RUnlock()
+	if found {
 		return info, nil
 	}
 
-	info, err := getTagNames(key)
+	tagInfoCacheMutex.Lock()
+	defer tagInfoCacheMutex.Unlock()
+
+	info, err := getTagNames(key, resolver)
 	if err != nil {
 		return StructInfo{}, err
 	}
 
-	tagInfoCache[key] = info
+	if len(tagInfoCache) >= maxTagInfoCacheSize {
+		// Since the map iteration order is random this evicts a random entry:
+		for k := range tagInfoCache {
+			delete(tagInfoCache, k)
+			break
+		}
+	}
+
+	tagInfoCache[cacheKey] = info
 	return info, nil
 }
 
 // StructToMap converts any struct type to a map based on
 // the tag named `ksql`, i.e. `ksql:"map_key_name"`
 //
-// Valid pointers are dereferenced and copied to the map,
-// null pointers are ignored.
+// Valid pointers, including pointers to pointers, are fully
+// dereferenced and copied to the map, null pointers are ignored,
+// and so are zero values for attributes tagged with the omitempty
+// option, e.g.: `ksql:"map_key_name,omitempty"`.
 //
 // This function is efficient in the fact that it caches
 // the slower steps of the reflection required to perform
 // this task.
 func StructToMap(obj interface{}) (map[string]interface{}, error) {
+	return StructToMapWithResolver(obj, DefaultColumnResolver())
+}
+
+// StructToMapWithResolver works like StructToMap but derives the names
+// of the untagged attributes with the input resolver, see GetTagInfoWithResolver.
+func StructToMapWithResolver(obj interface{}, resolver *ColumnResolver) (map[string]interface{}, error) {
 	v := reflect.ValueOf(obj)
+	if !v.IsValid() {
+		return nil, fmt.Errorf("input must be a struct or struct pointer, but got nil")
+	}
 	t := v.Type()
 
 	if t.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, fmt.Errorf("input must be a struct or struct pointer, but got a nil pointer: %T", obj)
+		}
 		v = v.Elem()
 		t = t.Elem()
 	}
@@ -107,7 +267,7 @@ func StructToMap(obj interface{}) (map[string]interface{}, error) {
 		return nil, fmt.Errorf("input must be a struct or struct pointer")
 	}
 
-	info, err := getCachedTagInfo(tagInfoCache, t)
+	info, err := GetTagInfoWithResolver(t, resolver)
 	if err != nil {
 		return nil, err
 	}
@@ -119,14 +279,17 @@ func StructToMap(obj interface{}) (map[string]interface{}, error) {
 			continue
 		}
 
-		field := v.Field(i)
-		ft := field.Type()
-		if ft.Kind() == reflect.Ptr {
-			if field.IsNil() {
-				continue
-			}
+		field, found, err := ReadField(v, fieldInfo)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
 
-			field = field.Elem()
+		if fieldInfo.UnixTimestampUnit != 0 {
+			m[fieldInfo.Name] = UnixToTime(field.Int(), fieldInfo.UnixTimestampUnit)
+			continue
 		}
 
 		m[fieldInfo.Name] = field.Interface()
@@ -135,6 +298,172 @@ func StructToMap(obj interface{}) (map[string]interface{}, error) {
 	return m, nil
 }
 
+// ReadField reads the attribute described by the fieldInfo from the
+// struct value the same way StructToMap does, i.e. pointers, including
+// pointers to pointers, are fully dereferenced, and found is false
+// for nil pointers and for zero values tagged with omitempty.
+//
+// An error is returned if the attribute can't be read
+// because it is not exported.
+func ReadField(v reflect.Value, fieldInfo *FieldInfo) (field reflect.Value, found bool, err error) {
+	field = v.Field(fieldInfo.Index)
+	if !field.CanInterface() {
+		return reflect.Value{}, false, fmt.Errorf(
+			"the attribute '%s' tagged as '%s' can't be read since it is not exported",
+			v.Type().Field(fieldInfo.Index).Name, fieldInfo.Name,
+		)
+	}
+
+	if fieldInfo.OmitEmpty && field.IsZero() {
+		return reflect.Value{}, false, nil
+	}
+
+	// Pointers to pointers are fully dereferenced
+	// and ignored if any of the pointers are nil:
+	for field.Kind() == reflect.Ptr && !field.IsNil() {
+		field = field.Elem()
+	}
+	if field.Kind() == reflect.Ptr {
+		return reflect.Value{}, false, nil
+	}
+
+	return field, true, nil
+}
+
+// UnixToTime converts a Unix timestamp measured in the
+// input unit, e.g. time.Second or time.Millisecond, to time.Time.
+func UnixToTime(timestamp int64, unit time.Duration) time.Time {
+	perSecond := int64(time.Second / unit)
+	return time.Unix(timestamp/perSecond, (timestamp%perSecond)*int64(unit))
+}
+
+// TimeToUnix converts a time.Time to a Unix timestamp measured
+// in the input unit, e.g. time.Second or time.Millisecond.
+func TimeToUnix(t time.Time, unit time.Duration) int64 {
+	return t.Unix()*int64(time.Second/unit) + int64(t.Nanosecond())/int64(unit)
+}
+
+// assertUnixTimestampField checks if a field tagged with the
+// `unix` or `unixmilli` options can store a Unix timestamp.
+func assertUnixTimestampField(field reflect.StructField, serializeAsJSON bool) error {
+	if serializeAsJSON {
+		return fmt.Errorf(
+			"the attribute '%s' can't be tagged with both the json and the unix options",
+			field.Name,
+		)
+	}
+
+	t := field.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return nil
+	}
+
+	return fmt.Errorf(
+		"the attribute '%s' is tagged with a unix timestamp option but its type is not an integer: %v",
+		field.Name, field.Type,
+	)
+}
+
+// parseDefaultValue converts the value of the `default` tag option to the
+// type of the field, which must be a string, a bool or a number,
+// or a pointer to one of these types.
+func parseDefaultValue(field reflect.StructField, value string, serializeAsJSON bool) (reflect.Value, error) {
+	if serializeAsJSON {
+		return reflect.Value{}, fmt.Errorf(
+			"the attribute '%s' can't be tagged with both the json and the default options",
+			field.Name,
+		)
+	}
+
+	t := field.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	v := reflect.New(t).Elem()
+	var err error
+	switch t.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(value)
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		i, err = strconv.ParseInt(value, 10, t.Bits())
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		u, err = strconv.ParseUint(value, 10, t.Bits())
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		f, err = strconv.ParseFloat(value, t.Bits())
+		v.SetFloat(f)
+	default:
+		return reflect.Value{}, fmt.Errorf(
+			"the attribute '%s' has a default value but its type is not supported by the default option: %v",
+			field.Name, field.Type,
+		)
+	}
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf(
+			"invalid default value for the attribute '%s' of type %v: %q",
+			field.Name, field.Type, value,
+		)
+	}
+
+	return v, nil
+}
+
+// ApplyDefaults sets the attributes tagged with the `default` option
+// to their default values if they are zero, or nil for pointers.
+//
+// The input must be an addressable struct value, e.g. v.Elem() of a pointer to struct.
+//
+// Like on StructToMap an error is returned for attributes that are not
+// exported, and in this case none of the attributes are modified.
+func ApplyDefaults(v reflect.Value, info StructInfo) error {
+	for _, fieldInfo := range info.byIndex {
+		if fieldInfo.Default.IsValid() && !v.Field(fieldInfo.Index).CanSet() {
+			return fmt.Errorf(
+				"the attribute '%s' tagged as '%s' can't be read since it is not exported",
+				v.Type().Field(fieldInfo.Index).Name, fieldInfo.Name,
+			)
+		}
+	}
+
+	for _, fieldInfo := range info.byIndex {
+		if !fieldInfo.Default.IsValid() {
+			continue
+		}
+
+		field := v.Field(fieldInfo.Index)
+		if !field.IsZero() {
+			continue
+		}
+
+		if field.Kind() == reflect.Ptr {
+			// A new pointer is allocated every time so the
+			// records never share the default values:
+			ptr := reflect.New(field.Type().Elem())
+			ptr.Elem().Set(fieldInfo.Default)
+			field.Set(ptr)
+			continue
+		}
+
+		field.Set(fieldInfo.Default)
+	}
+
+	return nil
+}
+
 // PtrConverter was created to make it easier
 // to handle conversion between ptr and non ptr types, e.g.:
 //
@@ -221,22 +550,80 @@ func (p PtrConverter) Convert(destType reflect.Type) (reflect.Value, error) {
 //
 // This should save several calls to `Field(i).Tag.Get("foo")`
 // which improves performance by a lot.
-func getTagNames(t reflect.Type) (StructInfo, error) {
+func getTagNames(t reflect.Type, columnResolver *ColumnResolver) (StructInfo, error) {
 	info := StructInfo{
-		byIndex: map[int]*FieldInfo{},
-		byName:  map[string]*FieldInfo{},
+		byIndex:     map[int]*FieldInfo{},
+		byName:      map[string]*FieldInfo{},
+		byLowerName: map[string]*FieldInfo{},
 	}
+
+	var resolver func(fieldName string) string
+	if columnResolver.isEnabled() && !hasTablenameTags(t) {
+		// Structs used for JOINs keep working as before:
+		resolver = columnResolver.Resolve
+	}
+
 	for i := 0; i < t.NumField(); i++ {
-		name := t.Field(i).Tag.Get("ksql")
+		field := t.Field(i)
+		name, found := field.Tag.Lookup("ksql")
+		if resolver != nil {
+			if name == "-" || (!found && (field.PkgPath != "" || field.Anonymous)) {
+				continue
+			}
+			if name == "" || name[0] == ',' {
+				// Fields without a name on the tag, e.g. `ksql:",json"`,
+				// also have their names derived:
+				resolvedName := resolver(field.Name)
+				if resolvedName == "" {
+					continue
+				}
+				name = resolvedName + name
+			}
+		}
 		if name == "" {
 			continue
 		}
 
 		tags := strings.Split(name, ",")
-		serializeAsJSON := false
-		if len(tags) > 1 {
-			name = tags[0]
-			serializeAsJSON = tags[1] == "json"
+		name = tags[0]
+
+		var serializeAsJSON, omitEmpty, readOnly bool
+		var unixTimestampUnit time.Duration
+		var defaultValue *string
+		for _, option := range tags[1:] {
+			if strings.HasPrefix(option, "default=") {
+				value := strings.TrimPrefix(option, "default=")
+				defaultValue = &value
+				continue
+			}
+
+			switch option {
+			case "json":
+				serializeAsJSON = true
+			case "omitempty":
+				omitEmpty = true
+			case "readonly":
+				readOnly = true
+			case "unix":
+				unixTimestampUnit = time.Second
+			case "unixmilli":
+				unixTimestampUnit = time.Millisecond
+			}
+		}
+
+		if unixTimestampUnit != 0 {
+			if err := assertUnixTimestampField(field, serializeAsJSON); err != nil {
+				return StructInfo{}, err
+			}
+		}
+
+		var parsedDefault reflect.Value
+		if defaultValue != nil {
+			var err error
+			parsedDefault, err = parseDefaultValue(field, *defaultValue, serializeAsJSON)
+			if err != nil {
+				return StructInfo{}, err
+			}
 		}
 
 		if _, found := info.byName[name]; found {
@@ -247,9 +634,13 @@ func getTagNames(t reflect.Type) (StructInfo, error) {
 		}
 
 		info.add(FieldInfo{
-			Name:            name,
-			Index:           i,
-			SerializeAsJSON: serializeAsJSON,
+			Name:              name,
+			Index:             i,
+			SerializeAsJSON:   serializeAsJSON,
+			OmitEmpty:         omitEmpty,
+			ReadOnly:          readOnly,
+			UnixTimestampUnit: unixTimestampUnit,
+			Default:           parsedDefault,
 		})
 	}
 
@@ -281,6 +672,57 @@ func getTagNames(t reflect.Type) (StructInfo, error) {
 	return info, nil
 }
 
+func hasTablenameTags(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if _, found := t.Field(i).Tag.Lookup("tablename"); found {
+			return true
+		}
+	}
+	return false
+}
+
+// ToSnakeCase converts a Go attribute name to snake_case keeping
+// acronyms together, e.g. `UserID` becomes `user_id`, `HTTPServerURL`
+// becomes `http_server_url` and `UserIDs` becomes `user_ids`.
+func ToSnakeCase(name string) string {
+	runes := []rune(name)
+
+	var b strings.Builder
+	for i, r := range runes {
+		if !unicode.IsUpper(r) {
+			b.WriteRune(r)
+			continue
+		}
+
+		if i > 0 {
+			prev := runes[i-1]
+			startsWord := unicode.IsLower(prev) || unicode.IsDigit(prev)
+
+			// Checking if this is the first letter of a word following an acronym,
+			// e.g. the `S` on `HTTPServer`, but not the `D` on `UserIDs`:
+			if unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+				startsWord = !isPluralAcronymSuffix(runes, i+1)
+			}
+
+			if startsWord {
+				b.WriteRune('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+
+	return b.String()
+}
+
+// isPluralAcronymSuffix checks if the rune at idx is an `s` ending
+// a word, which is used for detecting plural acronyms, e.g. `IDs`.
+func isPluralAcronymSuffix(runes []rune, idx int) bool {
+	if runes[idx] != 's' {
+		return false
+	}
+	return idx+1 == len(runes) || !unicode.IsLower(runes[idx+1])
+}
+
 // DecodeAsSliceOfStructs makes several checks
 // while decoding an input type and returns
 // useful information so that it is easier
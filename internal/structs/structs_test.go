@@ -0,0 +1,425 @@
+package structs
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	tt "github.com/vingarcia/ksql/internal/testtools"
+)
+
+func TestGetTagInfo(t *testing.T) {
+	t.Run("should cache anonymous structs only once", func(t *testing.T) {
+		newAnonymousStruct := func() interface{} {
+			return struct {
+				ID   int    `ksql:"id"`
+				Name string `ksql:"name"`
+			}{}
+		}
+
+		info, err := GetTagInfo(reflect.TypeOf(newAnonymousStruct()))
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, info.ByName("name").Index, 1)
+
+		cacheSize := len(tagInfoCache)
+		for i := 0; i < 100; i++ {
+			_, err := GetTagInfo(reflect.TypeOf(newAnonymousStruct()))
+			tt.AssertNoErr(t, err)
+		}
+
+		tt.AssertEqual(t, len(tagInfoCache), cacheSize)
+	})
+	t.Run("should keep the cache bounded when many types are created", func(t *testing.T) {
+		defer func(maxSize int) {
+			maxTagInfoCacheSize = maxSize
+		}(maxTagInfoCacheSize)
+		maxTagInfoCacheSize = 100
+
+		for i := 0; i < 1000; i++ {
+			structType := reflect.StructOf([]reflect.StructField{{
+				Name: "Name",
+				Type: reflect.TypeOf(""),
+				Tag:  reflect.StructTag(fmt.Sprintf(`ksql:"name_%d"`, i)),
+			}})
+
+			info, err := GetTagInfo(structType)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, info.ByIndex(0).Name, fmt.Sprintf("name_%d", i))
+
+			tt.AssertEqual(t, len(tagInfoCache) <= 100, true)
+		}
+
+		// The last type should still be cached:
+		lastType := reflect.StructOf([]reflect.StructField{{
+			Name: "Name",
+			Type: reflect.TypeOf(""),
+			Tag:  `ksql:"name_999"`,
+		}})
+		_, found := tagInfoCache[tagInfoCacheKey{t: lastType}]
+		tt.AssertEqual(t, found, true)
+	})
+
+	t.Run("should parse the readonly option", func(t *testing.T) {
+		info, err := GetTagInfo(reflect.TypeOf(struct {
+			ID        int    `ksql:"id"`
+			CreatedAt string `ksql:"created_at,readonly"`
+			Attrs     string `ksql:"attrs,json,readonly"`
+		}{}))
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, info.ByName("id").ReadOnly, false)
+		tt.AssertEqual(t, info.ByName("created_at").ReadOnly, true)
+		tt.AssertEqual(t, info.ByName("attrs").ReadOnly, true)
+		tt.AssertEqual(t, info.ByName("attrs").SerializeAsJSON, true)
+	})
+
+	t.Run("should parse the unix timestamp options", func(t *testing.T) {
+		info, err := GetTagInfo(reflect.TypeOf(struct {
+			ID        int    `ksql:"id"`
+			CreatedAt int64  `ksql:"created_at,unix"`
+			UpdatedAt *int64 `ksql:"updated_at,unixmilli"`
+		}{}))
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, info.ByName("id").UnixTimestampUnit, time.Duration(0))
+		tt.AssertEqual(t, info.ByName("created_at").UnixTimestampUnit, time.Second)
+		tt.AssertEqual(t, info.ByName("updated_at").UnixTimestampUnit, time.Millisecond)
+	})
+
+	t.Run("should report error for unix timestamp options on invalid types", func(t *testing.T) {
+		_, err := GetTagInfo(reflect.TypeOf(struct {
+			CreatedAt string `ksql:"created_at,unix"`
+		}{}))
+		tt.AssertErrContains(t, err, "CreatedAt", "integer")
+
+		_, err = GetTagInfo(reflect.TypeOf(struct {
+			CreatedAt int64 `ksql:"created_at,json,unix"`
+		}{}))
+		tt.AssertErrContains(t, err, "CreatedAt", "json")
+	})
+
+	t.Run("should parse the default option", func(t *testing.T) {
+		info, err := GetTagInfo(reflect.TypeOf(struct {
+			ID     int      `ksql:"id"`
+			Status string   `ksql:"status,default=new"`
+			Age    *int     `ksql:"age,omitempty,default=18"`
+			Score  float64  `ksql:"score,default=0.5"`
+			Active bool     `ksql:"active,default=true"`
+			Rank   *uint8   `ksql:"rank,default=3"`
+			Tags   []string `ksql:"tags"`
+		}{}))
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, info.ByName("id").Default.IsValid(), false)
+		tt.AssertEqual(t, info.ByName("status").Default.Interface(), "new")
+		tt.AssertEqual(t, info.ByName("age").Default.Interface(), 18)
+		tt.AssertEqual(t, info.ByName("age").OmitEmpty, true)
+		tt.AssertEqual(t, info.ByName("score").Default.Interface(), 0.5)
+		tt.AssertEqual(t, info.ByName("active").Default.Interface(), true)
+		tt.AssertEqual(t, info.ByName("rank").Default.Interface(), uint8(3))
+	})
+
+	t.Run("should report error for invalid default values", func(t *testing.T) {
+		_, err := GetTagInfo(reflect.TypeOf(struct {
+			Age int `ksql:"age,default=eighteen"`
+		}{}))
+		tt.AssertErrContains(t, err, "Age", "eighteen")
+
+		_, err = GetTagInfo(reflect.TypeOf(struct {
+			Rank uint8 `ksql:"rank,default=300"`
+		}{}))
+		tt.AssertErrContains(t, err, "Rank", "300")
+
+		_, err = GetTagInfo(reflect.TypeOf(struct {
+			CreatedAt time.Time `ksql:"created_at,default=now"`
+		}{}))
+		tt.AssertErrContains(t, err, "CreatedAt", "not supported")
+
+		_, err = GetTagInfo(reflect.TypeOf(struct {
+			Address map[string]string `ksql:"address,json,default={}"`
+		}{}))
+		tt.AssertErrContains(t, err, "Address", "json")
+	})
+}
+
+func TestApplyDefaults(t *testing.T) {
+	type record struct {
+		Name string `ksql:"name,default=Unnamed"`
+		Age  *int   `ksql:"age,default=18"`
+		Kind string `ksql:"kind"`
+	}
+
+	info, err := GetTagInfo(reflect.TypeOf(record{}))
+	tt.AssertNoErr(t, err)
+
+	t.Run("should set the zero attributes to their defaults", func(t *testing.T) {
+		var r1, r2 record
+		err := ApplyDefaults(reflect.ValueOf(&r1).Elem(), info)
+		tt.AssertNoErr(t, err)
+		err = ApplyDefaults(reflect.ValueOf(&r2).Elem(), info)
+		tt.AssertNoErr(t, err)
+
+		tt.AssertEqual(t, r1.Name, "Unnamed")
+		tt.AssertEqual(t, *r1.Age, 18)
+		tt.AssertEqual(t, r1.Kind, "")
+
+		// The records should not share the pointers:
+		*r1.Age = 20
+		tt.AssertEqual(t, *r2.Age, 18)
+	})
+
+	t.Run("should keep the attributes that are already set", func(t *testing.T) {
+		age := 0
+		r := record{Name: "Bob", Age: &age}
+		err := ApplyDefaults(reflect.ValueOf(&r).Elem(), info)
+		tt.AssertNoErr(t, err)
+
+		tt.AssertEqual(t, r.Name, "Bob")
+		tt.AssertEqual(t, *r.Age, 0)
+	})
+
+	t.Run("should report an error for unexported attributes", func(t *testing.T) {
+		type recordWithUnexported struct {
+			Name string `ksql:"name,default=Unnamed"`
+			kind string `ksql:"kind,default=user"`
+		}
+
+		info, err := GetTagInfo(reflect.TypeOf(recordWithUnexported{}))
+		tt.AssertNoErr(t, err)
+
+		var r recordWithUnexported
+		err = ApplyDefaults(reflect.ValueOf(&r).Elem(), info)
+		tt.AssertErrContains(t, err, "kind", "not exported")
+		tt.AssertEqual(t, r, recordWithUnexported{})
+	})
+}
+
+func TestUnixTimestampConversions(t *testing.T) {
+	tests := []struct {
+		desc      string
+		timestamp int64
+		unit      time.Duration
+		expected  time.Time
+	}{
+		{
+			desc:      "seconds",
+			timestamp: 1641092645,
+			unit:      time.Second,
+			expected:  time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{
+			desc:      "milliseconds",
+			timestamp: 1641092645250,
+			unit:      time.Millisecond,
+			expected:  time.Date(2022, 1, 2, 3, 4, 5, 250*int(time.Millisecond), time.UTC),
+		},
+		{
+			desc:      "seconds before the epoch",
+			timestamp: -86400,
+			unit:      time.Second,
+			expected:  time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			tt.AssertEqual(t, UnixToTime(test.timestamp, test.unit).Equal(test.expected), true)
+			tt.AssertEqual(t, TimeToUnix(test.expected, test.unit), test.timestamp)
+		})
+	}
+}
+
+func TestToSnakeCase(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{name: "Name", expected: "name"},
+		{name: "name", expected: "name"},
+		{name: "FirstName", expected: "first_name"},
+		{name: "ID", expected: "id"},
+		{name: "UserID", expected: "user_id"},
+		{name: "IDNumber", expected: "id_number"},
+		{name: "UserIDs", expected: "user_ids"},
+		{name: "URL", expected: "url"},
+		{name: "AvatarURL", expected: "avatar_url"},
+		{name: "HTTPServerURL", expected: "http_server_url"},
+		{name: "URLsList", expected: "urls_list"},
+		{name: "APIKey", expected: "api_key"},
+		{name: "Address2", expected: "address2"},
+		{name: "Line2Text", expected: "line2_text"},
+		{name: "already_snake", expected: "already_snake"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tt.AssertEqual(t, ToSnakeCase(test.name), test.expected)
+		})
+	}
+}
+
+func TestSnakeCaseUntaggedFields(t *testing.T) {
+	type User struct {
+		UserID    int
+		AvatarURL string
+		Name      string            `ksql:"full_name"`
+		Address   map[string]string `ksql:",json"`
+		Password  string            `ksql:"-"`
+		internal  string
+	}
+
+	type Post struct {
+		Title string
+	}
+
+	type UserAndPost struct {
+		User User `tablename:"u"`
+		Post Post `tablename:"p"`
+	}
+
+	t.Run("should be disabled by default", func(t *testing.T) {
+		info, err := GetTagInfo(reflect.TypeOf(User{}))
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, info.NumFields(), 3)
+		tt.AssertEqual(t, info.ByName("user_id").Valid, false)
+		tt.AssertEqual(t, info.ByName("full_name").Index, 2)
+	})
+
+	t.Run("should derive the names of the untagged fields when enabled", func(t *testing.T) {
+		SetSnakeCaseUntaggedFields(true)
+		defer SetSnakeCaseUntaggedFields(false)
+
+		info, err := GetTagInfo(reflect.TypeOf(User{}))
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, info.NumFields(), 4)
+		tt.AssertEqual(t, info.ByName("user_id").Index, 0)
+		tt.AssertEqual(t, info.ByName("avatar_url").Index, 1)
+		tt.AssertEqual(t, info.ByName("full_name").Index, 2)
+		tt.AssertEqual(t, info.ByName("address").Index, 3)
+		tt.AssertEqual(t, info.ByName("address").SerializeAsJSON, true)
+		tt.AssertEqual(t, info.ByName("name").Valid, false)
+		tt.AssertEqual(t, info.ByName("password").Valid, false)
+		tt.AssertEqual(t, info.ByName("-").Valid, false)
+		tt.AssertEqual(t, info.ByName("internal").Valid, false)
+
+		m, err := StructToMap(User{UserID: 42, AvatarURL: "fakeURL", Name: "Bia"})
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, m, map[string]interface{}{
+			"user_id":    42,
+			"avatar_url": "fakeURL",
+			"full_name":  "Bia",
+			"address":    map[string]string(nil),
+		})
+	})
+
+	t.Run("should not affect structs with tablename tags", func(t *testing.T) {
+		SetSnakeCaseUntaggedFields(true)
+		defer SetSnakeCaseUntaggedFields(false)
+
+		info, err := GetTagInfo(reflect.TypeOf(UserAndPost{}))
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, info.IsNestedStruct, true)
+		tt.AssertEqual(t, info.NumFields(), 2)
+		tt.AssertEqual(t, info.ByName("u").Index, 0)
+		tt.AssertEqual(t, info.ByName("p").Index, 1)
+	})
+
+	t.Run("should report error if a derived name conflicts with a tag", func(t *testing.T) {
+		SetSnakeCaseUntaggedFields(true)
+		defer SetSnakeCaseUntaggedFields(false)
+
+		_, err := GetTagInfo(reflect.TypeOf(struct {
+			UserID int
+			ID     int `ksql:"user_id"`
+		}{}))
+		tt.AssertErrContains(t, err, "user_id")
+	})
+
+	t.Run("should clear the cache when the setting changes", func(t *testing.T) {
+		SetSnakeCaseUntaggedFields(true)
+		info, err := GetTagInfo(reflect.TypeOf(User{}))
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, info.ByName("user_id").Valid, true)
+
+		SetSnakeCaseUntaggedFields(false)
+		info, err = GetTagInfo(reflect.TypeOf(User{}))
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, info.ByName("user_id").Valid, false)
+	})
+}
+
+func TestColumnResolver(t *testing.T) {
+	type User struct {
+		UserID   int
+		Name     string `ksql:"full_name"`
+		Password string `ksql:"-"`
+		Ignored  string
+	}
+
+	resolver := func(fieldName string) string {
+		if fieldName == "Ignored" {
+			return ""
+		}
+		return strings.ToUpper(fieldName)
+	}
+
+	t.Run("should derive the names of the untagged fields with the resolver", func(t *testing.T) {
+		SetColumnResolver(resolver)
+		defer SetColumnResolver(nil)
+
+		tt.AssertEqual(t, HasColumnResolver(), true)
+
+		info, err := GetTagInfo(reflect.TypeOf(User{}))
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, info.NumFields(), 2)
+		tt.AssertEqual(t, info.ByName("USERID").Index, 0)
+		tt.AssertEqual(t, info.ByName("full_name").Index, 1)
+		tt.AssertEqual(t, info.ByName("PASSWORD").Valid, false)
+		tt.AssertEqual(t, info.ByName("IGNORED").Valid, false)
+		tt.AssertEqual(t, info.ByName("").Valid, false)
+	})
+
+	t.Run("should not reuse the cached information when the resolver changes", func(t *testing.T) {
+		SetColumnResolver(resolver)
+		info, err := GetTagInfo(reflect.TypeOf(User{}))
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, info.ByName("USERID").Valid, true)
+
+		SetSnakeCaseUntaggedFields(true)
+		info, err = GetTagInfo(reflect.TypeOf(User{}))
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, info.ByName("USERID").Valid, false)
+		tt.AssertEqual(t, info.ByName("user_id").Valid, true)
+
+		SetColumnResolver(nil)
+		tt.AssertEqual(t, HasColumnResolver(), false)
+		info, err = GetTagInfo(reflect.TypeOf(User{}))
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, info.ByName("user_id").Valid, false)
+	})
+
+	t.Run("should cache the information of each resolver separately", func(t *testing.T) {
+		upperResolver := NewColumnResolver(resolver)
+		snakeResolver := NewColumnResolver(ToSnakeCase)
+
+		for i := 0; i < 2; i++ {
+			info, err := GetTagInfoWithResolver(reflect.TypeOf(User{}), upperResolver)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, info.ByName("USERID").Valid, true)
+			tt.AssertEqual(t, info.ByName("user_id").Valid, false)
+
+			info, err = GetTagInfoWithResolver(reflect.TypeOf(User{}), snakeResolver)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, info.ByName("USERID").Valid, false)
+			tt.AssertEqual(t, info.ByName("user_id").Valid, true)
+
+			info, err = GetTagInfoWithResolver(reflect.TypeOf(User{}), nil)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, info.ByName("USERID").Valid, false)
+			tt.AssertEqual(t, info.ByName("user_id").Valid, false)
+			tt.AssertEqual(t, info.ByName("full_name").Valid, true)
+		}
+
+		m, err := StructToMapWithResolver(User{UserID: 42, Name: "Alice"}, upperResolver)
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, m, map[string]interface{}{"USERID": 42, "full_name": "Alice"})
+	})
+}
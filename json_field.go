@@ -0,0 +1,199 @@
+package ksql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// UpdateJSONField merges the input patch into the JSON document stored
+// on a column of the record with the input ID, without overwriting the
+// keys of the document that are not on the patch, e.g.:
+//
+//	err := c.UpdateJSONField(ctx, UsersTable, user.ID, "preferences", map[string]interface{}{
+//		"theme": "dark",
+//		"notifications": map[string]interface{}{
+//			"email": false,
+//		},
+//	})
+//
+// The patch follows the JSON merge patch semantics (RFC 7396): nested
+// maps of type map[string]interface{} are merged recursively, nil values
+// remove the key from the document and any other value replaces it.
+// If the column is NULL or not a JSON object it is replaced by the patch.
+//
+// It works on Postgres, where the column should be of type jsonb and the
+// nested keys are updated with jsonb_set, and it's also supported on MySQL
+// and SQLite, which implement the merge patch natively. SQLServer is not
+// supported.
+//
+// The idOrRecord argument works like the one of Delete and if no
+// record matches it ErrRecordNotFound is returned.
+func (c DB) UpdateJSONField(
+	ctx context.Context,
+	table Table,
+	idOrRecord interface{},
+	column string,
+	patch map[string]interface{},
+) (err error) {
+	ctx, finish := c.observe(ctx, "update_json_field", table.name)
+	defer func() { finish(err) }()
+
+	if err := table.validate(); err != nil {
+		return fmt.Errorf("can't update ksql.Table: %s", err)
+	}
+
+	if err := ValidateIdentifier(column); err != nil {
+		return fmt.Errorf("ksql: invalid JSON column name: %w", err)
+	}
+
+	if len(patch) == 0 {
+		return fmt.Errorf("ksql: the JSON patch for column `%s` is empty", column)
+	}
+
+	idMap, err := normalizeIDsAsMap(table.idColumns, idOrRecord, c.getColumnResolver())
+	if err != nil {
+		return err
+	}
+
+	query, params, err := buildUpdateJSONFieldQuery(c.dialect, table, idMap, column, patch)
+	if err != nil {
+		return err
+	}
+
+	query, params, err = c.applyScopesToWhere(query, params)
+	if err != nil {
+		return err
+	}
+
+	result, err := c.execContext(ctx, query, params...)
+	if err != nil {
+		return fmt.Errorf("ksql: UpdateJSONField on %q failed: %w", table.name, err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("unable to check if the record was succesfully updated: %s", err)
+	}
+
+	if n == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func buildUpdateJSONFieldQuery(
+	dialect Dialect,
+	table Table,
+	idMap map[string]interface{},
+	column string,
+	patch map[string]interface{},
+) (query string, params []interface{}, err error) {
+	escapedColumn := dialect.Escape(column)
+
+	var setExpr string
+	switch dialect.DriverName() {
+	case "postgres":
+		setExpr, err = buildPostgresJSONMerge(dialect, escapedColumn, patch, &params)
+		if err != nil {
+			return "", nil, err
+		}
+	case "mysql", "sqlite3":
+		rawJSON, err := marshalJSONPatch(patch)
+		if err != nil {
+			return "", nil, err
+		}
+		params = append(params, rawJSON)
+
+		// Both implement the RFC 7396 merge patch natively:
+		setExpr = fmt.Sprintf("JSON_MERGE_PATCH(COALESCE(%s, '{}'), %s)", escapedColumn, dialect.Placeholder(0))
+		if dialect.DriverName() == "sqlite3" {
+			// The CAST is necessary since the JSON attributes are stored as BLOBs:
+			setExpr = fmt.Sprintf("json_patch(COALESCE(CAST(%s AS TEXT), '{}'), %s)", escapedColumn, dialect.Placeholder(0))
+		}
+	default:
+		return "", nil, fmt.Errorf("ksql: UpdateJSONField is not supported on %s", dialect.DriverName())
+	}
+
+	whereQuery := []string{}
+	for _, idName := range table.idColumns {
+		whereQuery = append(whereQuery, fmt.Sprintf(
+			"%s = %s", dialect.Escape(idName), dialect.Placeholder(len(params)),
+		))
+		params = append(params, idMap[idName])
+	}
+
+	return fmt.Sprintf(
+		"UPDATE %s SET %s = %s WHERE %s",
+		dialect.Escape(table.name),
+		escapedColumn,
+		setExpr,
+		strings.Join(whereQuery, " AND "),
+	), params, nil
+}
+
+// buildPostgresJSONMerge builds an expression merging the patch into
+// the doc expression, the nested patches are merged with the matching
+// sub documents of the original column so each key is referenced once.
+func buildPostgresJSONMerge(dialect Dialect, doc string, patch map[string]interface{}, params *[]interface{}) (string, error) {
+	values := map[string]interface{}{}
+	var removedKeys, nestedKeys []string
+	for key, value := range patch {
+		switch value.(type) {
+		case nil:
+			removedKeys = append(removedKeys, key)
+		case map[string]interface{}:
+			nestedKeys = append(nestedKeys, key)
+		default:
+			values[key] = value
+		}
+	}
+	sort.Strings(removedKeys)
+	sort.Strings(nestedKeys)
+
+	expr := fmt.Sprintf("CASE jsonb_typeof(%s) WHEN 'object' THEN %s ELSE '{}'::jsonb END", doc, doc)
+
+	if len(values) > 0 {
+		rawJSON, err := marshalJSONPatch(values)
+		if err != nil {
+			return "", err
+		}
+		expr = fmt.Sprintf("(%s || %s::jsonb)", expr, dialect.Placeholder(len(*params)))
+		*params = append(*params, rawJSON)
+	}
+
+	for _, key := range removedKeys {
+		expr = fmt.Sprintf("(%s - %s::text)", expr, dialect.Placeholder(len(*params)))
+		*params = append(*params, key)
+	}
+
+	for _, key := range nestedKeys {
+		keyPlaceholder := dialect.Placeholder(len(*params))
+		*params = append(*params, key)
+
+		nestedExpr, err := buildPostgresJSONMerge(
+			dialect,
+			fmt.Sprintf("(%s -> %s::text)", doc, keyPlaceholder),
+			patch[key].(map[string]interface{}),
+			params,
+		)
+		if err != nil {
+			return "", err
+		}
+
+		expr = fmt.Sprintf("jsonb_set(%s, ARRAY[%s::text], %s)", expr, keyPlaceholder, nestedExpr)
+	}
+
+	return expr, nil
+}
+
+func marshalJSONPatch(patch map[string]interface{}) (string, error) {
+	rawJSON, err := json.Marshal(patch)
+	if err != nil {
+		return "", fmt.Errorf("ksql: unable to serialize the JSON patch: %w", err)
+	}
+	return string(rawJSON), nil
+}
@@ -5,6 +5,7 @@ import (
 	"reflect"
 	"strings"
 
+	"github.com/pkg/errors"
 	"github.com/vingarcia/ksql"
 	"github.com/vingarcia/ksql/internal/structs"
 )
@@ -32,15 +33,19 @@ func (i Insert) Build(driver string) (sqlQuery string, params []interface{}, _ e
 
 // BuildQuery implements the queryBuilder interface
 func (i Insert) BuildQuery(dialect ksql.Dialect) (sqlQuery string, params []interface{}, _ error) {
-	var b strings.Builder
-	b.WriteString("INSERT INTO " + dialect.Escape(i.Into))
-
 	if i.Into == "" {
 		return "", nil, fmt.Errorf(
 			"expected the Into attr to contain the tablename, but got an empty string instead",
 		)
 	}
 
+	if err := ksql.ValidateIdentifier(i.Into); err != nil {
+		return "", nil, errors.Wrap(err, "invalid Into attr")
+	}
+
+	var b strings.Builder
+	b.WriteString("INSERT INTO " + dialect.Escape(i.Into))
+
 	if i.Data == nil {
 		return "", nil, fmt.Errorf(
 			"expected the Data attr to contain a struct or a list of structs, but got `%v`",
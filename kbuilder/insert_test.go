@@ -66,6 +66,18 @@ func TestInsertQuery(t *testing.T) {
 
 			expectedErr: true,
 		},
+		{
+			desc: "should report error if the `Into` attribute is not a valid identifier",
+			query: kbuilder.Insert{
+				Into: `users" (name) VALUES ('foo'); DROP TABLE "users`,
+				Data: &User{
+					Name: "foo",
+					Age:  42,
+				},
+			},
+
+			expectedErr: true,
+		},
 		{
 			desc: "should report error if `Data` contains an empty list",
 			query: kbuilder.Insert{
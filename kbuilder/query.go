@@ -21,13 +21,47 @@ type Query struct {
 	// From expects the FROM clause from an SQL query, e.g. `users JOIN posts USING(post_id)`
 	From string
 
+	// UseIndex expects the name of an index that should be used for
+	// reading the table of the From field, and the hint is written right
+	// after the From field using the syntax of each driver, e.g.:
+	//
+	//   - mysql: `FROM users u USE INDEX (idx_name)`
+	//   - sqlserver: `FROM users u WITH (INDEX (idx_name))`
+	//   - sqlite3: `FROM users u INDEXED BY idx_name`, which makes
+	//     the query fail if the index can't be used.
+	//   - postgres: no hint is written since postgres has no index hints,
+	//     use planner settings instead, e.g. `SET LOCAL enable_seqscan = off`.
+	//
+	// So when it is set the From field should only contain the table name,
+	// optionally followed by an alias, and the JOINs should use the Join field.
+	UseIndex string
+
+	// Join expects a list of JOIN clauses built
+	// by the public Join() or LeftJoin() functions.
+	Join JoinQueries
+
 	// Where expects a list of WhereQuery instances built
 	// by the public Where() function.
 	Where WhereQueries
 
+	// GroupBy expects the list of expressions for the
+	// GROUP BY clause using SQL syntax, e.g.: `u.id, u.name`
+	GroupBy string
+
+	// Having expects a list of WhereQuery instances built
+	// by the public Where() function, its placeholders are
+	// numbered after the ones used on the Where field.
+	Having WhereQueries
+
 	Limit   int
 	Offset  int
 	OrderBy OrderByQuery
+
+	// ErrorOnEmptyIn makes BuildQuery return an error if WhereIn
+	// receives an empty slice, by default an empty slice produces
+	// a condition that matches no rows, i.e. `1 = 0`, since the
+	// `IN ()` syntax is invalid on most databases.
+	ErrorOnEmptyIn bool
 }
 
 // Build is a utility function for finding the dialect based on the driver and
@@ -58,16 +92,50 @@ func (q Query) BuildQuery(dialect ksql.Dialect) (sqlQuery string, params []inter
 
 	b.WriteString(" FROM " + q.From)
 
+	if q.UseIndex != "" {
+		b.WriteString(buildIndexHint(dialect, q.UseIndex))
+	}
+
+	for _, join := range q.Join {
+		b.WriteString(" " + join.kind + " " + join.clause)
+	}
+
 	if len(q.Where) > 0 {
 		var whereQuery string
-		whereQuery, params = q.Where.build(dialect)
-		b.WriteString(" WHERE " + whereQuery)
+		var err error
+		whereQuery, params, err = q.Where.build(dialect, params, q.ErrorOnEmptyIn)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "error reading the Where field")
+		}
+		if whereQuery != "" {
+			b.WriteString(" WHERE " + whereQuery)
+		}
 	}
 
 	if strings.TrimSpace(q.From) == "" {
 		return "", nil, fmt.Errorf("the From field is mandatory for every query")
 	}
 
+	if q.GroupBy != "" {
+		b.WriteString(" GROUP BY " + q.GroupBy)
+	}
+
+	if len(q.Having) > 0 {
+		if q.GroupBy == "" {
+			return "", nil, fmt.Errorf("the Having field can only be used together with the GroupBy field")
+		}
+
+		var havingQuery string
+		var err error
+		havingQuery, params, err = q.Having.build(dialect, params, q.ErrorOnEmptyIn)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "error reading the Having field")
+		}
+		if havingQuery != "" {
+			b.WriteString(" HAVING " + havingQuery)
+		}
+	}
+
 	if q.OrderBy.fields != "" {
 		b.WriteString(" ORDER BY " + q.OrderBy.fields)
 		if q.OrderBy.desc {
@@ -86,6 +154,22 @@ func (q Query) BuildQuery(dialect ksql.Dialect) (sqlQuery string, params []inter
 	return b.String(), params, nil
 }
 
+// buildIndexHint returns the hint for using the input
+// index with the syntax of the dialect, or an empty
+// string if the dialect doesn't support index hints.
+func buildIndexHint(dialect ksql.Dialect, index string) string {
+	switch dialect.DriverName() {
+	case "mysql":
+		return " USE INDEX (" + dialect.Escape(index) + ")"
+	case "sqlserver":
+		return " WITH (INDEX (" + dialect.Escape(index) + "))"
+	case "sqlite3":
+		return " INDEXED BY " + dialect.Escape(index)
+	default:
+		return ""
+	}
+}
+
 // WhereQuery represents a single condition in a WHERE expression.
 type WhereQuery struct {
 	// Accepts any SQL boolean expression
@@ -100,15 +184,66 @@ type WhereQuery struct {
 	// for postgres or `?` for sqlite3.
 	cond   string
 	params []interface{}
+
+	// If set the condition is true if any of these groups are true,
+	// and the cond and params fields are ignored.
+	or []WhereQueries
+
+	// Set by WhereIn when it receives an empty slice.
+	emptyInColumn string
+
+	// Set for conditions built with invalid arguments,
+	// so it can be reported by BuildQuery.
+	err error
 }
 
 // WhereQueries is the helper for creating complex WHERE queries
 // in a dynamic way.
 type WhereQueries []WhereQuery
 
-func (w WhereQueries) build(dialect ksql.Dialect) (query string, params []interface{}) {
+// build returns the conditions ANDed together and the input params
+// followed by the params of the conditions, the placeholders are
+// numbered after the input params.
+func (w WhereQueries) build(
+	dialect ksql.Dialect,
+	params []interface{},
+	errorOnEmptyIn bool,
+) (query string, _ []interface{}, _ error) {
 	var conds []string
 	for _, whereQuery := range w {
+		if whereQuery.err != nil {
+			return "", nil, whereQuery.err
+		}
+
+		if whereQuery.emptyInColumn != "" && errorOnEmptyIn {
+			return "", nil, fmt.Errorf("WhereIn received an empty slice for column `%s`", whereQuery.emptyInColumn)
+		}
+
+		if whereQuery.or != nil {
+			var groups []string
+			for _, group := range whereQuery.or {
+				if len(group) == 0 {
+					continue
+				}
+
+				var groupQuery string
+				var err error
+				groupQuery, params, err = group.build(dialect, params, errorOnEmptyIn)
+				if err != nil {
+					return "", nil, err
+				}
+				if len(group) > 1 {
+					groupQuery = "(" + groupQuery + ")"
+				}
+				groups = append(groups, groupQuery)
+			}
+
+			if len(groups) > 0 {
+				conds = append(conds, "("+strings.Join(groups, " OR ")+")")
+			}
+			continue
+		}
+
 		var placeholders []interface{}
 		for i := range whereQuery.params {
 			placeholders = append(placeholders, dialect.Placeholder(len(params)+i))
@@ -118,7 +253,7 @@ func (w WhereQueries) build(dialect ksql.Dialect) (query string, params []interf
 		params = append(params, whereQuery.params...)
 	}
 
-	return strings.Join(conds, " AND "), params
+	return strings.Join(conds, " AND "), params, nil
 }
 
 // Where adds a new bollean condition to an existing
@@ -142,6 +277,57 @@ func (w WhereQueries) WhereIf(cond string, param interface{}) WhereQueries {
 	})
 }
 
+// WhereLike adds a new condition to the WhereQueries helper that is true
+// if the column contains the input string, e.g.:
+//
+//	kbuilder.Where("age > %s", 18).WhereLike("name", searchTerm)
+//
+// results in: `age > $1 AND name LIKE $2 ESCAPE '!'` with `%searchTerm%`
+// as the second param, and the special characters of LIKE patterns on
+// the input string, i.e. `%` and `_`, are escaped with ksql.EscapeLike.
+func (w WhereQueries) WhereLike(column string, input string) WhereQueries {
+	return append(w, newWhereLike(column, input))
+}
+
+// WhereIn adds a new condition to the WhereQueries helper that is true
+// if the column is equal to one of the values of the input slice, e.g.:
+//
+//	kbuilder.Where("age > %s", 18).WhereIn("id", []int{1, 2, 3})
+//
+// results in: `age > $1 AND id IN ($2, $3, $4)`.
+//
+// If the slice is empty the condition matches no rows, i.e. `1 = 0`,
+// unless the Query is configured with ErrorOnEmptyIn, in which case
+// BuildQuery returns an error.
+func (w WhereQueries) WhereIn(column string, values interface{}) WhereQueries {
+	return append(w, newWhereIn(column, values))
+}
+
+// WhereOr adds a new boolean expression to the WhereQueries helper
+// that is true if any of the input groups of conditions are true, e.g.:
+//
+//	kbuilder.Where("age > %s", 18).WhereOr(
+//		kbuilder.Where("role = %s", "admin"),
+//		kbuilder.Where("role = %s", "user").Where("verified = %s", true),
+//	)
+//
+// results in: `age > $1 AND (role = $2 OR (role = $3 AND verified = $4))`
+//
+// Empty groups, e.g. created by WhereIf with a nil param, are ignored.
+func (w WhereQueries) WhereOr(groups ...WhereQueries) WhereQueries {
+	return append(w, WhereQuery{
+		or: append([]WhereQueries{}, groups...),
+	})
+}
+
+// WhereOr creates a WhereQueries helper with a boolean expression
+// that is true if any of the input groups of conditions are true.
+func WhereOr(groups ...WhereQueries) WhereQueries {
+	return WhereQueries{{
+		or: append([]WhereQueries{}, groups...),
+	}}
+}
+
 // Where adds a new bollean condition to an existing
 // WhereQueries helper.
 func Where(cond string, params ...interface{}) WhereQueries {
@@ -151,6 +337,53 @@ func Where(cond string, params ...interface{}) WhereQueries {
 	}}
 }
 
+// WhereLike creates a WhereQueries helper with a condition
+// that is true if the column contains the input string.
+func WhereLike(column string, input string) WhereQueries {
+	return WhereQueries{newWhereLike(column, input)}
+}
+
+// WhereIn creates a WhereQueries helper with a condition that is
+// true if the column is equal to one of the values of the input slice.
+func WhereIn(column string, values interface{}) WhereQueries {
+	return WhereQueries{newWhereIn(column, values)}
+}
+
+func newWhereIn(column string, values interface{}) WhereQuery {
+	v := reflect.ValueOf(values)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return WhereQuery{
+			err: fmt.Errorf("expected WhereIn to receive a slice of values for column `%s`, but got: %T", column, values),
+		}
+	}
+
+	if v.Len() == 0 {
+		return WhereQuery{
+			cond:          "1 = 0",
+			emptyInColumn: column,
+		}
+	}
+
+	params := make([]interface{}, v.Len())
+	directives := make([]string, v.Len())
+	for i := range params {
+		params[i] = v.Index(i).Interface()
+		directives[i] = "%s"
+	}
+
+	return WhereQuery{
+		cond:   strings.ReplaceAll(column, "%", "%%") + " IN (" + strings.Join(directives, ", ") + ")",
+		params: params,
+	}
+}
+
+func newWhereLike(column string, input string) WhereQuery {
+	return WhereQuery{
+		cond:   strings.ReplaceAll(column, "%", "%%") + " LIKE %s ESCAPE '" + ksql.LikeEscapeChar + "'",
+		params: []interface{}{"%" + ksql.EscapeLike(input) + "%"},
+	}
+}
+
 // WhereIf condionally adds a new boolean expression to the WhereQueries helper
 func WhereIf(cond string, param interface{}) WhereQueries {
 	if param == nil || reflect.ValueOf(param).IsNil() {
@@ -163,6 +396,41 @@ func WhereIf(cond string, param interface{}) WhereQueries {
 	}}
 }
 
+// JoinQuery represents a single JOIN clause of the query.
+type JoinQuery struct {
+	kind   string
+	clause string
+}
+
+// JoinQueries is the helper for adding JOIN clauses
+// to a query in a dynamic way, e.g.:
+//
+//	kbuilder.Join("posts p ON p.user_id = u.id").LeftJoin("comments c ON c.post_id = p.id")
+type JoinQueries []JoinQuery
+
+// Join adds a new JOIN clause to an existing JoinQueries helper.
+func (j JoinQueries) Join(clause string) JoinQueries {
+	return append(j, JoinQuery{kind: "JOIN", clause: clause})
+}
+
+// LeftJoin adds a new LEFT JOIN clause to an existing JoinQueries helper.
+func (j JoinQueries) LeftJoin(clause string) JoinQueries {
+	return append(j, JoinQuery{kind: "LEFT JOIN", clause: clause})
+}
+
+// Join is a helper for building the JOIN part of the query,
+// it expects the joined table followed by the join condition
+// using SQL syntax, e.g.: `posts p ON p.user_id = u.id`
+func Join(clause string) JoinQueries {
+	return JoinQueries{{kind: "JOIN", clause: clause}}
+}
+
+// LeftJoin is a helper for building the JOIN part
+// of the query using LEFT JOIN clauses.
+func LeftJoin(clause string) JoinQueries {
+	return JoinQueries{{kind: "LEFT JOIN", clause: clause}}
+}
+
 // OrderByQuery represents the ORDER BY part of the query
 type OrderByQuery struct {
 	fields string
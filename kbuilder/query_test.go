@@ -102,6 +102,148 @@ func TestSelectQuery(t *testing.T) {
 			expectedQuery: `SELECT "name", "age" FROM users ORDER BY id DESC LIMIT 10 OFFSET 100`,
 		},
 
+		{
+			desc: "should build queries with JOIN, GROUP BY and HAVING clauses",
+			query: kbuilder.Query{
+				Select: "u.name, count(p.id)",
+				From:   "users u",
+				Join: kbuilder.
+					Join("posts p ON p.user_id = u.id").
+					LeftJoin("comments c ON c.post_id = p.id"),
+				Where: kbuilder.
+					Where("u.age > %s", 18).
+					Where("p.title LIKE %s", "%ksql%"),
+				GroupBy: "u.name",
+				Having:  kbuilder.Where("count(p.id) > %s", 2),
+
+				OrderBy: kbuilder.OrderBy("u.name"),
+				Limit:   10,
+			},
+			expectedQuery: `SELECT u.name, count(p.id) FROM users u JOIN posts p ON p.user_id = u.id` +
+				` LEFT JOIN comments c ON c.post_id = p.id WHERE u.age > $1 AND p.title LIKE $2` +
+				` GROUP BY u.name HAVING count(p.id) > $3 ORDER BY u.name LIMIT 10`,
+			expectedParams: []interface{}{18, "%ksql%", 2},
+		},
+		{
+			desc: "should number the HAVING placeholders correctly when there is no WHERE clause",
+			query: kbuilder.Query{
+				Select:  "name, count(*)",
+				From:    "users",
+				GroupBy: "name",
+				Having:  kbuilder.Where("count(*) BETWEEN %s AND %s", 2, 5),
+			},
+			expectedQuery:  `SELECT name, count(*) FROM users GROUP BY name HAVING count(*) BETWEEN $1 AND $2`,
+			expectedParams: []interface{}{2, 5},
+		},
+
+		{
+			desc: "should build OR groups with the correct parenthesization and params order",
+			query: kbuilder.Query{
+				Select: &User{},
+				From:   "users",
+				Where: kbuilder.
+					Where("age > %s", 18).
+					WhereOr(
+						kbuilder.Where("role = %s", "admin"),
+						kbuilder.Where("role = %s", "user").Where("verified = %s", true),
+					).
+					Where("deleted_at IS NULL"),
+			},
+			expectedQuery:  `SELECT "name", "age" FROM users WHERE age > $1 AND (role = $2 OR (role = $3 AND verified = $4)) AND deleted_at IS NULL`,
+			expectedParams: []interface{}{18, "admin", "user", true},
+		},
+		{
+			desc: "should build nested OR groups",
+			query: kbuilder.Query{
+				Select: &User{},
+				From:   "users",
+				Where: kbuilder.WhereOr(
+					kbuilder.Where("a = %s", 1).WhereOr(
+						kbuilder.Where("b = %s", 2),
+						kbuilder.Where("c = %s", 3),
+					),
+					kbuilder.Where("d = %s", 4),
+				),
+			},
+			expectedQuery:  `SELECT "name", "age" FROM users WHERE ((a = $1 AND (b = $2 OR c = $3)) OR d = $4)`,
+			expectedParams: []interface{}{1, 2, 3, 4},
+		},
+		{
+			desc: "should ignore empty OR groups",
+			query: kbuilder.Query{
+				Select: &User{},
+				From:   "users",
+				Where: kbuilder.
+					Where("a = %s", 1).
+					WhereOr(
+						kbuilder.WhereIf("b = %s", nullField),
+						kbuilder.Where("c = %s", 3),
+					).
+					WhereOr(kbuilder.WhereIf("d = %s", nullField)).
+					WhereOr(),
+			},
+			expectedQuery:  `SELECT "name", "age" FROM users WHERE a = $1 AND (c = $2)`,
+			expectedParams: []interface{}{1, 3},
+		},
+		{
+			desc: "should omit the WHERE clause if all OR groups are empty",
+			query: kbuilder.Query{
+				Select: &User{},
+				From:   "users",
+				Where:  kbuilder.WhereOr(kbuilder.WhereIf("a = %s", nullField)),
+			},
+			expectedQuery: `SELECT "name", "age" FROM users`,
+		},
+		{
+			desc: "should build LIKE conditions escaping the input",
+			query: kbuilder.Query{
+				Select: &User{},
+				From:   "users",
+				Where: kbuilder.
+					WhereLike("name", "50%_off").
+					WhereLike("email", "john!"),
+			},
+			expectedQuery:  `SELECT "name", "age" FROM users WHERE name LIKE $1 ESCAPE '!' AND email LIKE $2 ESCAPE '!'`,
+			expectedParams: []interface{}{"%50!%!_off%", "%john!!%"},
+		},
+		{
+			desc: "should build IN conditions with one placeholder per value",
+			query: kbuilder.Query{
+				Select: &User{},
+				From:   "users",
+				Where: kbuilder.
+					Where("age > %s", 18).
+					WhereIn("id", []int{1, 2, 3}),
+			},
+			expectedQuery:  `SELECT "name", "age" FROM users WHERE age > $1 AND id IN ($2, $3, $4)`,
+			expectedParams: []interface{}{18, 1, 2, 3},
+		},
+		{
+			desc: "should build IN conditions that match no rows for empty slices",
+			query: kbuilder.Query{
+				Select: &User{},
+				From:   "users",
+				Where: kbuilder.
+					WhereIn("id", []int{}).
+					Where("age > %s", 18),
+			},
+			expectedQuery:  `SELECT "name", "age" FROM users WHERE 1 = 0 AND age > $1`,
+			expectedParams: []interface{}{18},
+		},
+		{
+			desc: "should build empty IN conditions inside OR groups",
+			query: kbuilder.Query{
+				Select: &User{},
+				From:   "users",
+				Where: kbuilder.WhereOr(
+					kbuilder.WhereIn("id", []string(nil)),
+					kbuilder.Where("age > %s", 18),
+				),
+			},
+			expectedQuery:  `SELECT "name", "age" FROM users WHERE (1 = 0 OR age > $1)`,
+			expectedParams: []interface{}{18},
+		},
+
 		/* * * * * Testing error cases: * * * * */
 		{
 			desc: "should report error if the FROM clause is missing",
@@ -117,6 +259,40 @@ func TestSelectQuery(t *testing.T) {
 				Limit:   10,
 			},
 
+			expectedErr: true,
+		},
+		{
+			desc: "should report error for empty IN conditions if ErrorOnEmptyIn is set",
+			query: kbuilder.Query{
+				Select: &User{},
+				From:   "users",
+				Where: kbuilder.WhereOr(
+					kbuilder.WhereIn("id", []int{}),
+					kbuilder.Where("age > %s", 18),
+				),
+				ErrorOnEmptyIn: true,
+			},
+
+			expectedErr: true,
+		},
+		{
+			desc: "should report error if WhereIn doesn't receive a slice",
+			query: kbuilder.Query{
+				Select: &User{},
+				From:   "users",
+				Where:  kbuilder.WhereIn("id", 42),
+			},
+
+			expectedErr: true,
+		},
+		{
+			desc: "should report error if the HAVING clause is used without GROUP BY",
+			query: kbuilder.Query{
+				Select: &User{},
+				From:   "users",
+				Having: kbuilder.Where("count(*) > %s", 2),
+			},
+
 			expectedErr: true,
 		},
 	}
@@ -135,6 +311,87 @@ func TestSelectQuery(t *testing.T) {
 	}
 }
 
+func TestWhereInOnAllDrivers(t *testing.T) {
+	tests := []struct {
+		driver        string
+		expectedQuery string
+	}{
+		{
+			driver:        "postgres",
+			expectedQuery: `SELECT "name", "age" FROM users WHERE id IN ($1, $2) AND 1 = 0`,
+		},
+		{
+			driver:        "sqlite3",
+			expectedQuery: `SELECT "name", "age" FROM users WHERE id IN (?, ?) AND 1 = 0`,
+		},
+		{
+			driver:        "mysql",
+			expectedQuery: `SELECT "name", "age" FROM users WHERE id IN (?, ?) AND 1 = 0`,
+		},
+		{
+			driver:        "sqlserver",
+			expectedQuery: `SELECT "name", "age" FROM users WHERE id IN (@p1, @p2) AND 1 = 0`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.driver, func(t *testing.T) {
+			q := kbuilder.Query{
+				Select: &User{},
+				From:   "users",
+				Where:  kbuilder.WhereIn("id", []int{1, 2}).WhereIn("name", []string{}),
+			}
+
+			query, params, err := q.Build(test.driver)
+			assert.Equal(t, nil, err)
+			assert.Equal(t, test.expectedQuery, query)
+			assert.Equal(t, []interface{}{1, 2}, params)
+
+			q.ErrorOnEmptyIn = true
+			_, _, err = q.Build(test.driver)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "name")
+		})
+	}
+}
+
+func TestUseIndexOnAllDrivers(t *testing.T) {
+	tests := []struct {
+		driver        string
+		expectedQuery string
+	}{
+		{
+			driver:        "mysql",
+			expectedQuery: "SELECT name FROM users u USE INDEX (`idx_users_name`) JOIN posts p ON p.user_id = u.id WHERE u.age > ?",
+		},
+		{
+			driver:        "sqlserver",
+			expectedQuery: `SELECT name FROM users u WITH (INDEX ([idx_users_name])) JOIN posts p ON p.user_id = u.id WHERE u.age > @p1`,
+		},
+		{
+			driver:        "sqlite3",
+			expectedQuery: "SELECT name FROM users u INDEXED BY `idx_users_name` JOIN posts p ON p.user_id = u.id WHERE u.age > ?",
+		},
+		{
+			driver:        "postgres",
+			expectedQuery: `SELECT name FROM users u JOIN posts p ON p.user_id = u.id WHERE u.age > $1`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.driver, func(t *testing.T) {
+			query, params, err := kbuilder.Query{
+				Select:   "name",
+				From:     "users u",
+				UseIndex: "idx_users_name",
+				Join:     kbuilder.Join("posts p ON p.user_id = u.id"),
+				Where:    kbuilder.Where("u.age > %s", 18),
+			}.Build(test.driver)
+			assert.Equal(t, nil, err)
+			assert.Equal(t, test.expectedQuery, query)
+			assert.Equal(t, []interface{}{18}, params)
+		})
+	}
+}
+
 func expectError(t *testing.T, expect bool, err error) {
 	if expect {
 		require.Equal(t, true, err != nil, "expected an error, but got nothing")
@@ -3,21 +3,37 @@ package ksql
 import (
 	"context"
 	"crypto/tls"
+	"database/sql"
 	"fmt"
 	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 	"unicode"
 
 	"github.com/pkg/errors"
 	"github.com/vingarcia/ksql/internal/structs"
-	"github.com/vingarcia/ksql/ksqltest"
 )
 
-var selectQueryCache = map[string]map[reflect.Type]string{}
+// selectQueryCache stores the SELECT prefixes generated for each dialect,
+// like the tag info cache it is protected by a mutex, keyed by the type
+// and the column resolver, and limited to maxSelectQueryCacheSize entries
+// per dialect, evicting a random entry when it is full.
+var selectQueryCache = map[string]map[selectQueryCacheKey]string{}
+var selectQueryCacheMutex sync.RWMutex
+
+type selectQueryCacheKey struct {
+	structType reflect.Type
+	resolver   *structs.ColumnResolver
+}
+
+// maxSelectQueryCacheSize is a variable only so we can change it on the tests.
+var maxSelectQueryCacheSize = 10000
 
 func init() {
 	for dname := range supportedDialects {
-		selectQueryCache[dname] = map[reflect.Type]string{}
+		selectQueryCache[dname] = map[selectQueryCacheKey]string{}
 	}
 }
 
@@ -28,6 +44,29 @@ type DB struct {
 	driver  string
 	dialect Dialect
 	db      DBAdapter
+	replica DBAdapter
+
+	strictQueryOne         bool
+	nullAsZero             bool
+	skipIDWriteBack        bool
+	skipEmptyPatches       bool
+	caseInsensitiveColumns bool
+	strictColumns          bool
+	retryOnConnectionLoss  bool
+	retryPolicy            RetryPolicy
+	idempotentWrites       bool
+	readFromPrimary        bool
+	columnPrefix           string
+	uuidKeyColumn          string
+	updatedAtColumn        string
+	defaultOrderBy         string
+	location               *time.Location
+	batchSize              int
+	columnResolver         *structs.ColumnResolver
+	logger                 QueryLogger
+	metrics                Metrics
+
+	scopes []scope
 }
 
 // DBAdapter is minimalistic interface to decouple our implementation
@@ -46,6 +85,15 @@ type TxBeginner interface {
 	BeginTx(ctx context.Context) (Tx, error)
 }
 
+// StatsGetter needs to be implemented by the DBAdapter in order
+// to make it possible to use the `ksql.Stats()` function.
+//
+// All the adapters of this repository that use database/sql implement it
+// by embedding the *sql.DB, and the PGXAdapter converts the pgx pool stats.
+type StatsGetter interface {
+	Stats() sql.DBStats
+}
+
 // Result stores information about the result of an Exec query
 type Result interface {
 	LastInsertId() (int64, error)
@@ -77,6 +125,47 @@ type Config struct {
 
 	// Used by some adapters (such as kpgx) where nil disables TLS
 	TLSConfig *tls.Config
+
+	// StatementTimeout, if set, makes the database abort any statement
+	// that takes longer than this duration on all the connections
+	// created by the adapter, regardless of the contexts used.
+	//
+	// It is supported by the kpgx adapter, using the `statement_timeout`
+	// setting, and by the kmysql adapter, using the `max_execution_time`
+	// setting which only applies to SELECT statements. The other adapters
+	// return an error if it is set.
+	StatementTimeout time.Duration
+
+	// Schema, if set, makes the unqualified table names, including
+	// the ones of the ksql.Table arguments, resolve to this schema on
+	// all the connections created by the adapter, so the same code can
+	// target different schemas without prefixing the table names.
+	//
+	// It is supported by the kpgx adapter, using the `search_path`
+	// setting, so tables missing from the schema are not found even if
+	// they exist on the `public` schema. The other adapters return an
+	// error if it is set.
+	Schema string
+
+	// ApplicationName, if set, identifies the connections created by the
+	// adapter on the database, e.g. on the `pg_stat_activity` view of Postgres,
+	// so the DBAs can tell which service is running each query.
+	//
+	// It is supported by the kpgx adapter, using the `application_name`
+	// setting. The other adapters return an error if it is set.
+	ApplicationName string
+
+	// SessionSettings are set on all the connections created by the adapter,
+	// as if `SET key = value` was executed for each of them after connecting,
+	// e.g. `map[string]string{"work_mem": "64MB"}`.
+	//
+	// It is supported by the kpgx adapter, using the runtime params of the
+	// connections, and by the kmysql adapter, using the system variables of
+	// the driver, which sends the values as they are, so string values must
+	// be quoted, e.g. `"'TRADITIONAL'"`. The other adapters return an error
+	// if it is set. The settings are applied before the other options of the
+	// Config, so for example StatementTimeout overrides `statement_timeout`.
+	SessionSettings map[string]string
 }
 
 // SetDefaultValues should be called by all adapters
@@ -105,10 +194,99 @@ func NewWithAdapter(
 	}, nil
 }
 
+// WithStrictQueryOne returns a copy of the DB configured to
+// make QueryOne return ErrMultipleRecordsFound when the query
+// returns more than one row.
+//
+// By default QueryOne just ignores the extra rows and
+// scans only the first one.
+func (c DB) WithStrictQueryOne(strict bool) DB {
+	c.strictQueryOne = strict
+	return c
+}
+
+// WithNullAsZero returns a copy of the DB configured to scan
+// NULL values into non-pointer attributes as the zero value
+// of the attribute type, e.g. NULL into an `int` becomes 0.
+//
+// By default scanning a NULL into a non-pointer attribute
+// returns the error reported by the driver.
+func (c DB) WithNullAsZero(enabled bool) DB {
+	c.nullAsZero = enabled
+	return c
+}
+
+// WithSkipIDWriteBack returns a copy of the DB configured to
+// make Insert just execute the INSERT statement without writing
+// the generated IDs back into the input struct.
+//
+// This is useful when the input structs are shared and
+// should not be mutated.
+func (c DB) WithSkipIDWriteBack(skip bool) DB {
+	c.skipIDWriteBack = skip
+	return c
+}
+
+// WithSkipEmptyPatches returns a copy of the DB configured to make
+// Patch and its variants return no error, without running any query,
+// when there are no attributes to update on the record.
+//
+// By default ErrNoFieldsToUpdate is returned in this case. Note
+// that when skipped the existence of the record is not checked.
+func (c DB) WithSkipEmptyPatches(skip bool) DB {
+	c.skipEmptyPatches = skip
+	return c
+}
+
+// WithLocation returns a copy of the DB configured to convert
+// all the time.Time attributes to the input location, both when
+// reading them from the database and when writing them with
+// Insert, Update and Patch.
+//
+// By default the location of the time values depends on
+// the driver and connection settings.
+func (c DB) WithLocation(location *time.Location) DB {
+	c.location = location
+	return c
+}
+
+// WithCaseInsensitiveColumns returns a copy of the DB configured to
+// match the columns returned by the queries to the `ksql` tags ignoring
+// their case, e.g. a column aliased as `Name` will be scanned into
+// an attribute tagged as `ksql:"name"`.
+//
+// Exact matches are always preferred, and by default
+// the columns are matched in a case-sensitive way.
+func (c DB) WithCaseInsensitiveColumns(enabled bool) DB {
+	c.caseInsensitiveColumns = enabled
+	return c
+}
+
+// WithStrictColumns returns a copy of the DB configured to report an
+// error when a query returns columns that don't match any of the `ksql`
+// tags of the struct, e.g. because of a typo on an alias, listing all
+// the unmapped columns at once, e.g.:
+//
+//	ksql: unmapped columns: [user_nmae extra]
+//
+// By default these columns are just ignored. Nested structs
+// are not affected since their columns are always generated by ksql.
+func (c DB) WithStrictColumns(enabled bool) DB {
+	c.strictColumns = enabled
+	return c
+}
+
 // Query queries several rows from the database,
 // the input should be a slice of structs (or *struct) passed
 // by reference and it will be filled with all the results.
 //
+// The columns are matched to the attributes by name, so computed
+// columns can be scanned alongside the columns of the table by tagging
+// an attribute with their alias, e.g. an attribute tagged with
+// `ksql:"total"` for `SELECT *, count(*) OVER() AS total FROM users`.
+// In this case the SELECT part can't be omitted, since the generated
+// one would select the alias as a column of the table.
+//
 // Note: it is very important to make sure the query will
 // return a small known number of results, otherwise you risk
 // of overloading the available memory.
@@ -117,7 +295,15 @@ func (c DB) Query(
 	records interface{},
 	query string,
 	params ...interface{},
-) error {
+) (err error) {
+	ctx, finish := c.observe(ctx, "query", "")
+	defer func() { finish(err) }()
+
+	query, params, err = bindNamedParams(c.dialect, query, params, c.getColumnResolver())
+	if err != nil {
+		return err
+	}
+
 	slicePtr := reflect.ValueOf(records)
 	slicePtrType := slicePtr.Type()
 	if slicePtrType.Kind() != reflect.Ptr {
@@ -137,28 +323,19 @@ func (c DB) Query(
 		slice = slice.Slice(0, 0)
 	}
 
-	info, err := structs.GetTagInfo(structType)
+	info, err := c.getTagInfo(structType)
 	if err != nil {
 		return err
 	}
 
-	firstToken := strings.ToUpper(getFirstToken(query))
-	if info.IsNestedStruct && firstToken == "SELECT" {
-		// This error check is necessary, since if we can't build the select part of the query this feature won't work.
-		return fmt.Errorf("can't generate SELECT query for nested struct: when using this feature omit the SELECT part of the query")
-	}
-
-	if firstToken == "FROM" {
-		selectPrefix, err := buildSelectQuery(c.dialect, structType, info, selectQueryCache[c.dialect.DriverName()])
-		if err != nil {
-			return err
-		}
-		query = selectPrefix + query
+	query, params, err = c.prepareQuery(query, params, structType, info)
+	if err != nil {
+		return err
 	}
 
-	rows, err := c.db.QueryContext(ctx, query, params...)
+	rows, err := c.queryContext(ctx, query, params...)
 	if err != nil {
-		return fmt.Errorf("error running query: %s", err)
+		return fmt.Errorf("error running query: %w", err)
 	}
 	defer rows.Close()
 
@@ -180,7 +357,7 @@ func (c DB) Query(
 			elemPtr = elemPtr.Elem()
 		}
 
-		err = scanRows(c.dialect, rows, elemPtr.Interface())
+		err = c.scanRows(rows, elemPtr.Interface())
 		if err != nil {
 			return err
 		}
@@ -200,506 +377,2400 @@ func (c DB) Query(
 	return nil
 }
 
-// QueryOne queries one instance from the database,
-// the input struct must be passed by reference
-// and the query should return only one result.
-//
-// QueryOne returns a ErrRecordNotFound if
-// the query returns no results.
-func (c DB) QueryOne(
-	ctx context.Context,
-	record interface{},
+// prepareQuery builds the query sent to the database by Query, i.e.
+// with the SELECT part if it was omitted, the scopes and the default
+// ORDER BY, the named params should be bound before calling it.
+func (c DB) prepareQuery(
 	query string,
-	params ...interface{},
-) error {
-	v := reflect.ValueOf(record)
-	t := v.Type()
-	if t.Kind() != reflect.Ptr {
-		return fmt.Errorf("ksql: expected to receive a pointer to struct, but got: %T", record)
+	params []interface{},
+	structType reflect.Type,
+	info structs.StructInfo,
+) (string, []interface{}, error) {
+	query, err := c.buildSelectPrefixIfOmitted(query, structType, info)
+	if err != nil {
+		return "", nil, err
 	}
 
-	if v.IsNil() {
-		return fmt.Errorf("ksql: expected a valid pointer to struct as argument but received a nil pointer: %v", record)
+	// Checked before the scopes since these wrap the query in a subquery:
+	needsDefaultOrderBy := c.needsDefaultOrderBy(query)
+
+	query, params, err = c.applyScopesToQuery(query, params, info)
+	if err != nil {
+		return "", nil, err
 	}
 
-	tStruct := t.Elem()
-	if tStruct.Kind() != reflect.Struct {
-		return fmt.Errorf("ksql: expected to receive a pointer to struct, but got: %T", record)
+	if needsDefaultOrderBy {
+		query = strings.TrimRight(strings.TrimSpace(query), ";") + " ORDER BY " + c.defaultOrderBy
 	}
 
-	info, err := structs.GetTagInfo(tStruct)
+	return query, params, nil
+}
+
+// QueryMap queries several rows from the database and
+// saves them on a map indexed by one of the columns, e.g.:
+//
+//	var usersByID map[int]User
+//	err := c.QueryMap(ctx, &usersByID, "id", "FROM users WHERE age > $1", 18)
+//
+// The records argument should be a pointer to a map of structs
+// (or *struct) and the keyColumn must be the name of one of the
+// `ksql` tags of this struct.
+//
+// QueryMap returns an error if two rows contain the same key.
+func (c DB) QueryMap(
+	ctx context.Context,
+	records interface{},
+	keyColumn string,
+	query string,
+	params ...interface{},
+) (err error) {
+	ctx, finish := c.observe(ctx, "query_map", "")
+	defer func() { finish(err) }()
+
+	query, params, err = bindNamedParams(c.dialect, query, params, c.getColumnResolver())
 	if err != nil {
 		return err
 	}
 
-	firstToken := strings.ToUpper(getFirstToken(query))
-	if info.IsNestedStruct && firstToken == "SELECT" {
-		// This error check is necessary, since if we can't build the select part of the query this feature won't work.
-		return fmt.Errorf("can't generate SELECT query for nested struct: when using this feature omit the SELECT part of the query")
-	}
-
-	if firstToken == "FROM" {
-		selectPrefix, err := buildSelectQuery(c.dialect, tStruct, info, selectQueryCache[c.dialect.DriverName()])
-		if err != nil {
-			return err
-		}
-		query = selectPrefix + query
+	mapPtr := reflect.ValueOf(records)
+	mapPtrType := mapPtr.Type()
+	if mapPtrType.Kind() != reflect.Ptr || mapPtrType.Elem().Kind() != reflect.Map {
+		return fmt.Errorf("ksql: expected to receive a pointer to map of structs, but got: %T", records)
 	}
 
-	rows, err := c.db.QueryContext(ctx, query, params...)
-	if err != nil {
-		return fmt.Errorf("error running query: %s", err)
+	mapType := mapPtrType.Elem()
+	keyType := mapType.Key()
+	structType := mapType.Elem()
+	isMapOfPtrs := structType.Kind() == reflect.Ptr
+	if isMapOfPtrs {
+		structType = structType.Elem()
 	}
-	defer rows.Close()
 
-	if !rows.Next() {
-		if rows.Err() != nil {
-			return rows.Err()
-		}
-		return ErrRecordNotFound
+	if structType.Kind() != reflect.Struct {
+		return fmt.Errorf("ksql: expected to receive a pointer to map of structs, but got: %T", records)
 	}
 
-	err = scanRowsFromType(c.dialect, rows, record, t, v)
+	info, err := c.getTagInfo(structType)
 	if err != nil {
 		return err
 	}
 
-	return rows.Close()
-}
+	if info.IsNestedStruct {
+		return fmt.Errorf("ksql: QueryMap doesn't support nested structs")
+	}
 
-// QueryChunks is meant to perform queries that returns
-// more results than would normally fit on memory,
-// for others cases the Query and QueryOne functions are indicated.
-//
-// The ChunkParser argument has 4 attributes:
-// (1) The Query;
-// (2) The query args;
-// (3) The chunk size;
-// (4) A callback function called ForEachChunk, that will be called
-// to process each chunk loaded from the database.
-//
-// Note that the signature of the ForEachChunk callback can be
-// any function that receives a slice of structs or a slice of
-// pointers to struct as its only argument and that reflection
-// will be used to instantiate this argument and to fill it
-// with the database rows.
-func (c DB) QueryChunks(
-	ctx context.Context,
-	parser ChunkParser,
-) error {
-	fnValue := reflect.ValueOf(parser.ForEachChunk)
-	chunkType, err := structs.ParseInputFunc(parser.ForEachChunk)
-	if err != nil {
-		return err
+	keyField := info.ByName(keyColumn)
+	if !keyField.Valid {
+		return fmt.Errorf("ksql: the key column `%s` is not tagged on type %v", keyColumn, structType)
 	}
 
-	chunk := reflect.MakeSlice(chunkType, 0, parser.ChunkSize)
+	keyFieldType := structType.Field(keyField.Index).Type
+	if !keyFieldType.ConvertibleTo(keyType) {
+		return fmt.Errorf(
+			"ksql: can't use field `%s` of type %v as a key for %v",
+			keyColumn, keyFieldType, mapType,
+		)
+	}
 
-	structType, isSliceOfPtrs, err := structs.DecodeAsSliceOfStructs(chunkType)
+	query, err = c.buildSelectPrefixIfOmitted(query, structType, info)
 	if err != nil {
 		return err
 	}
 
-	info, err := structs.GetTagInfo(structType)
+	query, params, err = c.applyScopesToQuery(query, params, info)
 	if err != nil {
 		return err
 	}
 
-	firstToken := strings.ToUpper(getFirstToken(parser.Query))
-	if info.IsNestedStruct && firstToken == "SELECT" {
-		// This error check is necessary, since if we can't build the select part of the query this feature won't work.
-		return fmt.Errorf("can't generate SELECT query for nested struct: when using this feature omit the SELECT part of the query")
-	}
-
-	if firstToken == "FROM" {
-		selectPrefix, err := buildSelectQuery(c.dialect, structType, info, selectQueryCache[c.dialect.DriverName()])
-		if err != nil {
-			return err
-		}
-		parser.Query = selectPrefix + parser.Query
-	}
-
-	rows, err := c.db.QueryContext(ctx, parser.Query, parser.Params...)
+	rows, err := c.queryContext(ctx, query, params...)
 	if err != nil {
-		return err
+		return fmt.Errorf("error running query: %w", err)
 	}
 	defer rows.Close()
 
-	var idx = 0
+	m := reflect.MakeMap(mapType)
 	for rows.Next() {
-		// Allocate new slice elements
-		// only if they are not already allocated:
-		if chunk.Len() <= idx {
-			var elemValue reflect.Value
-			elemValue = reflect.New(structType)
-			if !isSliceOfPtrs {
-				elemValue = elemValue.Elem()
-			}
-			chunk = reflect.Append(chunk, elemValue)
-		}
-
-		err = scanRows(c.dialect, rows, chunk.Index(idx).Addr().Interface())
+		elemPtr := reflect.New(structType)
+		err = c.scanRows(rows, elemPtr.Interface())
 		if err != nil {
 			return err
 		}
 
-		if idx < parser.ChunkSize-1 {
-			idx++
-			continue
+		key := elemPtr.Elem().Field(keyField.Index).Convert(keyType)
+		if m.MapIndex(key).IsValid() {
+			return fmt.Errorf("ksql: QueryMap found more than one row with %s = %v", keyColumn, key.Interface())
 		}
 
-		idx = 0
-		err, _ = fnValue.Call([]reflect.Value{chunk})[0].Interface().(error)
-		if err != nil {
-			if err == ErrAbortIteration {
-				return nil
-			}
-			return err
+		elemValue := elemPtr
+		if !isMapOfPtrs {
+			elemValue = elemPtr.Elem()
 		}
+		m.SetMapIndex(key, elemValue)
 	}
 
-	if err := rows.Close(); err != nil {
-		return err
-	}
-
-	// If Next() returned false because of an error:
 	if rows.Err() != nil {
 		return rows.Err()
 	}
 
-	// If no rows were found or idx was reset to 0
-	// on the last iteration skip this last call to ForEachChunk:
-	if idx > 0 {
-		chunk = chunk.Slice(0, idx)
-
-		err, _ = fnValue.Call([]reflect.Value{chunk})[0].Interface().(error)
-		if err != nil {
-			if err == ErrAbortIteration {
-				return nil
-			}
-			return err
-		}
+	if err := rows.Close(); err != nil {
+		return err
 	}
 
+	mapPtr.Elem().Set(m)
+
 	return nil
 }
 
-// Insert one or more instances on the database
+// QueryOne queries one instance from the database,
+// the input struct must be passed by reference
+// and the query should return only one result.
 //
-// If the original instances have been passed by reference
-// the ID is automatically updated after insertion is completed.
-func (c DB) Insert(
+// QueryOne returns a ErrRecordNotFound if
+// the query returns no results.
+//
+// The columns are matched with the attributes by name, so the query
+// may select only a subset of the attributes of the struct, in
+// which case the attributes not returned are left untouched.
+//
+// The record may also be a pointer to a pointer to struct, e.g.
+// `var u *User; err := c.QueryOne(ctx, &u, ...)`, in which case a new
+// struct is allocated and assigned to it only if a row is found,
+// so a nil pointer is kept nil when ErrRecordNotFound is returned.
+//
+// If the query returns more than one row only the first
+// one is used, unless the DB was configured with
+// WithStrictQueryOne(true), in which case QueryOne returns
+// a ErrMultipleRecordsFound instead.
+func (c DB) QueryOne(
 	ctx context.Context,
-	table Table,
 	record interface{},
-) error {
+	query string,
+	params ...interface{},
+) (err error) {
+	ctx, finish := c.observe(ctx, "query_one", "")
+	defer func() { finish(err) }()
+
+	query, params, err = bindNamedParams(c.dialect, query, params, c.getColumnResolver())
+	if err != nil {
+		return err
+	}
+
 	v := reflect.ValueOf(record)
 	t := v.Type()
-	if err := assertStructPtr(t); err != nil {
-		return fmt.Errorf(
-			"ksql: expected record to be a pointer to struct, but got: %T",
-			record,
-		)
+	if t.Kind() != reflect.Ptr {
+		return fmt.Errorf("ksql: expected to receive a pointer to struct, but got: %T", record)
 	}
 
 	if v.IsNil() {
 		return fmt.Errorf("ksql: expected a valid pointer to struct as argument but received a nil pointer: %v", record)
 	}
 
-	if err := table.validate(); err != nil {
-		return fmt.Errorf("can't insert in ksql.Table: %s", err)
+	tStruct := t.Elem()
+	if tStruct.Kind() == reflect.Ptr && tStruct.Elem().Kind() == reflect.Struct {
+		// The struct is only allocated and assigned if a row is found:
+		target := reflect.New(tStruct.Elem())
+		err := c.QueryOne(ctx, target.Interface(), query, params...)
+		if err != nil {
+			return err
+		}
+
+		v.Elem().Set(target)
+		return nil
 	}
 
-	info, err := structs.GetTagInfo(t.Elem())
+	if tStruct.Kind() != reflect.Struct {
+		return fmt.Errorf("ksql: expected to receive a pointer to struct, but got: %T", record)
+	}
+
+	info, err := c.getTagInfo(tStruct)
 	if err != nil {
 		return err
 	}
 
-	query, params, scanValues, err := buildInsertQuery(c.dialect, table, t, v, info, record)
+	query, err = c.buildSelectPrefixIfOmitted(query, tStruct, info)
 	if err != nil {
 		return err
 	}
 
-	switch table.insertMethodFor(c.dialect) {
-	case insertWithReturning, insertWithOutput:
-		err = c.insertReturningIDs(ctx, query, params, scanValues, table.idColumns)
-	case insertWithLastInsertID:
-		err = c.insertWithLastInsertID(ctx, t, v, info, record, query, params, table.idColumns[0])
-	case insertWithNoIDRetrieval:
-		err = c.insertWithNoIDRetrieval(ctx, query, params)
-	default:
-		// Unsupported drivers should be detected on the New() function,
-		// So we don't expect the code to ever get into this default case.
-		err = fmt.Errorf("code error: unsupported driver `%s`", c.driver)
+	query, params, err = c.applyScopesToQuery(query, params, info)
+	if err != nil {
+		return err
 	}
 
-	return err
-}
-
-func (c DB) insertReturningIDs(
-	ctx context.Context,
-	query string,
-	params []interface{},
-	scanValues []interface{},
-	idNames []string,
-) error {
-	rows, err := c.db.QueryContext(ctx, query, params...)
+	rows, err := c.queryContext(ctx, query, params...)
 	if err != nil {
-		return err
+		return fmt.Errorf("error running query: %w", err)
 	}
 	defer rows.Close()
 
 	if !rows.Next() {
-		err := fmt.Errorf("unexpected error when retrieving the id columns from the database")
 		if rows.Err() != nil {
-			err = rows.Err()
+			return rows.Err()
 		}
+		return ErrRecordNotFound
+	}
 
+	err = c.scanRowsFromType(rows, record, t, v)
+	if err != nil {
+		return err
+	}
+
+	if c.strictQueryOne {
+		if rows.Next() {
+			return ErrMultipleRecordsFound
+		}
+		if rows.Err() != nil {
+			return rows.Err()
+		}
+	}
+
+	return rows.Close()
+}
+
+// First loads the first row returned by the query into the record,
+// reporting if a row was found instead of returning ErrRecordNotFound,
+// which is convenient for optional lookups, e.g.:
+//
+//	var u User
+//	found, err := c.First(ctx, &u, "FROM users WHERE email = $1 ORDER BY id", email)
+//
+// A LIMIT clause with the syntax of the driver is added to the query, so it
+// shouldn't have one already, and on sqlserver queries without an ORDER BY
+// clause receive an `ORDER BY (SELECT NULL)`, since it is required for limiting
+// the results. Like on QueryOne the record may also be a pointer to a pointer to struct.
+func (c DB) First(
+	ctx context.Context,
+	record interface{},
+	query string,
+	params ...interface{},
+) (found bool, err error) {
+	ctx, finish := c.observe(ctx, "first", "")
+	defer func() { finish(err) }()
+
+	query, params, err = bindNamedParams(c.dialect, query, params, c.getColumnResolver())
+	if err != nil {
+		return false, err
+	}
+
+	t := reflect.TypeOf(record)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return false, fmt.Errorf("ksql: expected to receive a pointer to struct, but got: %T", record)
+	}
+	tStruct := t.Elem()
+	if tStruct.Kind() == reflect.Ptr {
+		tStruct = tStruct.Elem()
+	}
+	if tStruct.Kind() != reflect.Struct {
+		return false, fmt.Errorf("ksql: expected to receive a pointer to struct, but got: %T", record)
+	}
+
+	info, err := c.getTagInfo(tStruct)
+	if err != nil {
+		return false, err
+	}
+
+	query, err = c.buildSelectPrefixIfOmitted(query, tStruct, info)
+	if err != nil {
+		return false, err
+	}
+	query = c.addDefaultOrderBy(query)
+
+	// The scopes must be applied before the LIMIT:
+	query, params, err = c.applyScopesBeforeLimit(query, params, info)
+	if err != nil {
+		return false, err
+	}
+	unscoped := c
+	unscoped.scopes = nil
+	unscoped.strictQueryOne = false
+
+	err = unscoped.QueryOne(ctx, record, buildLimitOneQuery(c.dialect, query), params...)
+	if err == ErrRecordNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// buildLimitOneQuery limits the results of the
+// input query to a single row using the syntax of the dialect.
+func buildLimitOneQuery(dialect Dialect, query string) string {
+	if dialect.DriverName() != "sqlserver" {
+		return query + " LIMIT 1"
+	}
+
+	if stripTrailingOrderBy(query) == query {
+		query += " ORDER BY (SELECT NULL)"
+	}
+	return query + " OFFSET 0 ROWS FETCH NEXT 1 ROWS ONLY"
+}
+
+// QueryOneWithPrefix works like QueryOne but only the columns starting
+// with the input prefix are scanned, and the prefix is removed from their
+// names before matching them to the tags of the struct, e.g.:
+//
+//	var u User
+//	err := c.QueryOneWithPrefix(ctx, &u, "u_",
+//		"SELECT u.id AS u_id, u.name AS u_name, a.street FROM users u JOIN addresses a ON a.user_id = u.id WHERE u.id = $1",
+//		userID,
+//	)
+//
+// fills the attributes tagged with `id` and `name` and ignores the `street` column.
+//
+// The SELECT part of the query can't be omitted and nested structs are not supported.
+func (c DB) QueryOneWithPrefix(
+	ctx context.Context,
+	record interface{},
+	prefix string,
+	query string,
+	params ...interface{},
+) (err error) {
+	ctx, finish := c.observe(ctx, "query_one_with_prefix", "")
+	defer func() { finish(err) }()
+
+	if prefix == "" {
+		return fmt.Errorf("ksql: QueryOneWithPrefix expects a non empty prefix")
+	}
+
+	if strings.ToUpper(getFirstToken(query)) == "FROM" {
+		return fmt.Errorf("ksql: QueryOneWithPrefix can't generate the SELECT part of the query, please write it explicitly")
+	}
+
+	t := reflect.TypeOf(record)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t != nil && t.Kind() == reflect.Struct {
+		info, err := c.getTagInfo(t)
+		if err != nil {
+			return err
+		}
+
+		if info.IsNestedStruct {
+			return fmt.Errorf("ksql: QueryOneWithPrefix doesn't support nested structs")
+		}
+	}
+
+	c.columnPrefix = prefix
+	return c.QueryOne(ctx, record, query, params...)
+}
+
+// QueryOneInto works like QueryOne but splits the columns of the
+// row between several structs, which is useful for JOINs, e.g.:
+//
+//	var u User
+//	var a Address
+//	err := c.QueryOneInto(ctx, []interface{}{&u, &a},
+//		"SELECT u.id, u.name, a.id, a.street FROM users u JOIN addresses a ON a.user_id = u.id WHERE u.id = $1",
+//		userID,
+//	)
+//
+// Each column is scanned into the first record, in the input order,
+// with an attribute tagged with its name that was not already filled
+// by a previous column, so in the example above the first `id` goes
+// to the User and the second one to the Address.
+//
+// No prefixes are removed from the column names, so if the records
+// use different tags for the same column, e.g. `ksql:"address_id"`,
+// the query should alias the column accordingly, e.g. `a.id AS address_id`.
+//
+// The SELECT part of the query can't be omitted, nested structs are not
+// supported and since the columns might have repeated names the query
+// can't be wrapped by the scopes created with Where().
+func (c DB) QueryOneInto(
+	ctx context.Context,
+	records []interface{},
+	query string,
+	params ...interface{},
+) (err error) {
+	ctx, finish := c.observe(ctx, "query_one_into", "")
+	defer func() { finish(err) }()
+
+	if len(records) == 0 {
+		return fmt.Errorf("ksql: QueryOneInto expects at least one record")
+	}
+
+	if len(c.scopes) > 0 {
+		return fmt.Errorf("ksql: QueryOneInto doesn't support scopes created with Where()")
+	}
+
+	if strings.ToUpper(getFirstToken(query)) == "FROM" {
+		return fmt.Errorf("ksql: QueryOneInto can't generate the SELECT part of the query, please write it explicitly")
+	}
+
+	query, params, err = bindNamedParams(c.dialect, query, params, c.getColumnResolver())
+	if err != nil {
+		return err
+	}
+
+	values := make([]reflect.Value, len(records))
+	infos := make([]structs.StructInfo, len(records))
+	for i, record := range records {
+		v := reflect.ValueOf(record)
+		if !v.IsValid() || assertStructPtr(v.Type()) != nil {
+			return fmt.Errorf("ksql: expected record on position %d to be a pointer to struct, but got: %T", i, record)
+		}
+
+		if v.IsNil() {
+			return fmt.Errorf("ksql: expected a valid pointer to struct on position %d but received a nil pointer", i)
+		}
+
+		info, err := c.getTagInfo(v.Type().Elem())
+		if err != nil {
+			return err
+		}
+
+		if info.IsNestedStruct {
+			return fmt.Errorf("ksql: QueryOneInto doesn't support nested structs")
+		}
+
+		values[i] = v.Elem()
+		infos[i] = info
+	}
+
+	rows, err := c.queryContext(ctx, query, params...)
+	if err != nil {
+		return fmt.Errorf("error running query: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if rows.Err() != nil {
+			return rows.Err()
+		}
+		return ErrRecordNotFound
+	}
+
+	names, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	scanArgs, nullableArgs, err := c.getScanArgsForRecords(names, values, infos)
+	if err != nil {
+		return err
+	}
+
+	err = rows.Scan(scanArgs...)
+	if err != nil {
+		return err
+	}
+
+	for _, arg := range nullableArgs {
+		arg.fill()
+	}
+
+	if c.location != nil {
+		for i, v := range values {
+			if err := convertTimesToLocation(v, infos[i], c.location, c.getColumnResolver()); err != nil {
+				return err
+			}
+		}
+	}
+
+	if c.strictQueryOne {
+		if rows.Next() {
+			return ErrMultipleRecordsFound
+		}
+		if rows.Err() != nil {
+			return rows.Err()
+		}
+	}
+
+	return rows.Close()
+}
+
+// getScanArgsForRecords works like getScanArgsFromNames but assigns each
+// column to the first record that has a matching field not yet filled.
+func (c DB) getScanArgsForRecords(
+	names []string,
+	values []reflect.Value,
+	infos []structs.StructInfo,
+) ([]interface{}, []nullableScanArg, error) {
+	filled := make([]map[int]bool, len(values))
+	for i := range filled {
+		filled[i] = map[int]bool{}
+	}
+
+	scanArgs := []interface{}{}
+	nullableArgs := []nullableScanArg{}
+	var unmappedColumns []string
+	for _, name := range names {
+		valueScanner := nopScannerValue
+		found := false
+		for i, info := range infos {
+			fieldInfo := info.ByName(name)
+			if !fieldInfo.Valid && c.caseInsensitiveColumns {
+				fieldInfo = info.ByNameCaseInsensitive(name)
+			}
+			if !fieldInfo.Valid || filled[i][fieldInfo.Index] {
+				continue
+			}
+			filled[i][fieldInfo.Index] = true
+
+			var nullableArg *nullableScanArg
+			valueScanner, nullableArg = c.getScanArgForField(values[i].Field(fieldInfo.Index), fieldInfo)
+			if nullableArg != nil {
+				nullableArgs = append(nullableArgs, *nullableArg)
+			}
+			found = true
+			break
+		}
+
+		if !found && c.strictColumns {
+			unmappedColumns = append(unmappedColumns, name)
+		}
+
+		scanArgs = append(scanArgs, valueScanner)
+	}
+
+	if len(unmappedColumns) > 0 {
+		return nil, nil, fmt.Errorf("ksql: unmapped columns: %v", unmappedColumns)
+	}
+
+	return scanArgs, nullableArgs, nil
+}
+
+// QueryAll loads all the rows of the table into the input slice
+// of structs (or *struct), optionally sorted by the orderBy
+// columns, e.g.:
+//
+//	var countries []Country
+//	err := c.QueryAll(ctx, CountriesTable, &countries, "name", "population DESC")
+//
+// It is meant for small tables, e.g. lookup tables, since all
+// the rows are loaded into memory.
+func (c DB) QueryAll(
+	ctx context.Context,
+	table Table,
+	records interface{},
+	orderBy ...string,
+) (err error) {
+	ctx, finish := c.observe(ctx, "query_all", table.name)
+	defer func() { finish(err) }()
+
+	if err := table.validate(); err != nil {
+		return fmt.Errorf("can't query ksql.Table: %s", err)
+	}
+
+	query := "FROM " + c.dialect.Escape(table.name)
+	if len(orderBy) > 0 {
+		orderByQuery, err := buildOrderByQuery(c.dialect, orderBy)
+		if err != nil {
+			return err
+		}
+		query += " ORDER BY " + orderByQuery
+	}
+
+	return c.Query(ctx, records, query)
+}
+
+// buildOrderByQuery escapes each of the input terms, which are
+// expected to be a column name optionally followed by ASC or DESC.
+func buildOrderByQuery(dialect Dialect, orderBy []string) (string, error) {
+	terms := make([]string, len(orderBy))
+	for i, term := range orderBy {
+		tokens := strings.Fields(term)
+		if len(tokens) == 0 || len(tokens) > 2 {
+			return "", fmt.Errorf("ksql: invalid ORDER BY term: `%s`", term)
+		}
+
+		if err := ValidateIdentifier(tokens[0]); err != nil {
+			return "", fmt.Errorf("ksql: invalid ORDER BY column: %s", err)
+		}
+		terms[i] = dialect.Escape(tokens[0])
+
+		if len(tokens) == 2 {
+			direction := strings.ToUpper(tokens[1])
+			if direction != "ASC" && direction != "DESC" {
+				return "", fmt.Errorf("ksql: invalid ORDER BY direction: `%s`", tokens[1])
+			}
+			terms[i] += " " + direction
+		}
+	}
+
+	return strings.Join(terms, ", "), nil
+}
+
+// QueryOneByExample queries one instance from the table filtering
+// by the attributes of the example struct, e.g.:
+//
+//	var user User
+//	err := c.QueryOneByExample(ctx, UsersTable, &user, User{Email: "john@example.com"})
+//
+// will run `SELECT ... FROM users WHERE email = $1` with each non-nil
+// attribute of the example becoming an equality condition.
+//
+// Note that zero values of non-pointer attributes are also part of
+// the filter, e.g. an `Age int` attribute left as 0 will produce a
+// `age = 0` condition. So it is recommended to use an example struct
+// with pointer attributes (or tagged with `omitempty`) so that only the
+// attributes explicitly set are used for filtering.
+//
+// QueryOneByExample returns a ErrRecordNotFound if no rows match.
+func (c DB) QueryOneByExample(
+	ctx context.Context,
+	table Table,
+	record interface{},
+	example interface{},
+) (err error) {
+	ctx, finish := c.observe(ctx, "query_one_by_example", table.name)
+	defer func() { finish(err) }()
+
+	if err := table.validate(); err != nil {
+		return fmt.Errorf("can't query ksql.Table: %s", err)
+	}
+
+	whereQuery, params, err := buildWhereByExample(c.dialect, example, c.getColumnResolver())
+	if err != nil {
+		return err
+	}
+
+	return c.QueryOne(ctx, record, fmt.Sprintf(
+		"FROM %s WHERE %s",
+		c.dialect.Escape(table.name),
+		whereQuery,
+	), params...)
+}
+
+func buildWhereByExample(
+	dialect Dialect,
+	example interface{},
+	resolver *structs.ColumnResolver,
+) (query string, params []interface{}, err error) {
+	t := reflect.TypeOf(example)
+	if t == nil {
+		return "", nil, fmt.Errorf("ksql: expected example to be a struct, but got: %T", example)
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return "", nil, fmt.Errorf("ksql: expected example to be a struct, but got: %T", example)
+	}
+
+	info, err := structs.GetTagInfoWithResolver(t, resolver)
+	if err != nil {
+		return "", nil, err
+	}
+
+	exampleMap, err := structs.StructToMapWithResolver(example, resolver)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Using the struct declaration order so the generated query is deterministic:
+	conditions := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		fieldInfo := info.ByIndex(i)
+		value, found := exampleMap[fieldInfo.Name]
+		if !fieldInfo.Valid || !found {
+			continue
+		}
+
+		if fieldInfo.SerializeAsJSON {
+			return "", nil, fmt.Errorf("ksql: can't filter by the json attribute `%s`", fieldInfo.Name)
+		}
+
+		conditions = append(conditions, fmt.Sprintf(
+			"%s = %s",
+			dialect.Escape(fieldInfo.Name),
+			dialect.Placeholder(len(params)),
+		))
+		params = append(params, value)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil, fmt.Errorf("ksql: the example struct has no attributes set for filtering: %T", example)
+	}
+
+	return strings.Join(conditions, " AND "), params, nil
+}
+
+// QueryByIDs loads in a single query the rows of the table with the
+// input ids, returning them in the same order as the ids, e.g.:
+//
+//	var users []User
+//	err := c.QueryByIDs(ctx, UsersTable, &users, []interface{}{3, 1, 2})
+//
+// The table must have a single ID column and the records argument
+// should be a pointer to a slice of structs (or *struct).
+//
+// IDs with no matching rows are left out of the results, so the
+// resulting slice might be shorter than the input ids and duplicated
+// ids will produce repeated entries on the results.
+func (c DB) QueryByIDs(
+	ctx context.Context,
+	table Table,
+	records interface{},
+	ids []interface{},
+) (err error) {
+	ctx, finish := c.observe(ctx, "query_by_ids", table.name)
+	defer func() { finish(err) }()
+
+	if err := table.validate(); err != nil {
+		return fmt.Errorf("can't query ksql.Table: %s", err)
+	}
+
+	if len(table.idColumns) != 1 {
+		return fmt.Errorf("ksql: QueryByIDs expects a table with a single ID column, but got: %v", table.idColumns)
+	}
+	idColumn := table.idColumns[0]
+
+	slicePtr := reflect.ValueOf(records)
+	if slicePtr.Kind() != reflect.Ptr {
+		return fmt.Errorf("ksql: expected to receive a pointer to slice of structs, but got: %T", records)
+	}
+	sliceType := slicePtr.Type().Elem()
+
+	structType, isSliceOfPtrs, err := structs.DecodeAsSliceOfStructs(sliceType)
+	if err != nil {
+		return err
+	}
+
+	info, err := c.getTagInfo(structType)
+	if err != nil {
+		return err
+	}
+
+	if info.IsNestedStruct {
+		return fmt.Errorf("ksql: QueryByIDs doesn't support nested structs")
+	}
+
+	idField := info.ByName(idColumn)
+	if !idField.Valid {
+		return fmt.Errorf("ksql: the ID column `%s` is not tagged on type %v", idColumn, structType)
+	}
+
+	idType := structType.Field(idField.Index).Type
+	keys := make([]interface{}, len(ids))
+	for i, id := range ids {
+		keys[i], err = convertIDToType(id, idType)
+		if err != nil {
+			return err
+		}
+	}
+
+	results := reflect.MakeSlice(sliceType, 0, len(ids))
+	if len(ids) == 0 {
+		slicePtr.Elem().Set(results)
+		return nil
+	}
+
+	placeholders := make([]string, len(ids))
+	for i := range ids {
+		placeholders[i] = c.dialect.Placeholder(i)
+	}
+
+	unordered := reflect.New(sliceType)
+	err = c.Query(ctx, unordered.Interface(), fmt.Sprintf(
+		"FROM %s WHERE %s IN (%s)",
+		c.dialect.Escape(table.name),
+		c.dialect.Escape(idColumn),
+		strings.Join(placeholders, ", "),
+	), keys...)
+	if err != nil {
+		return err
+	}
+
+	recordsByID := map[interface{}]reflect.Value{}
+	for i := 0; i < unordered.Elem().Len(); i++ {
+		record := unordered.Elem().Index(i)
+		structValue := record
+		if isSliceOfPtrs {
+			structValue = record.Elem()
+		}
+		recordsByID[structValue.Field(idField.Index).Interface()] = record
+	}
+
+	for _, key := range keys {
+		record, found := recordsByID[key]
+		if !found {
+			continue
+		}
+		results = reflect.Append(results, record)
+	}
+
+	slicePtr.Elem().Set(results)
+	return nil
+}
+
+// convertIDToType converts the input id to the type of the ID attribute,
+// so ids of different numeric types can be compared with the loaded ones.
+func convertIDToType(id interface{}, idType reflect.Type) (interface{}, error) {
+	idValue := reflect.ValueOf(id)
+	if !idValue.IsValid() ||
+		!idValue.Type().ConvertibleTo(idType) ||
+		(idValue.Kind() == reflect.String) != (idType.Kind() == reflect.String) {
+		return nil, fmt.Errorf("ksql: can't use id `%v` of type %T as the ID of type %v", id, id, idType)
+	}
+	return idValue.Convert(idType).Interface(), nil
+}
+
+// QueryChunks is meant to perform queries that returns
+// more results than would normally fit on memory,
+// for others cases the Query and QueryOne functions are indicated.
+//
+// The ChunkParser argument has 4 attributes:
+// (1) The Query;
+// (2) The query args;
+// (3) The chunk size;
+// (4) A callback function called ForEachChunk, that will be called
+// to process each chunk loaded from the database.
+//
+// Note that the signature of the ForEachChunk callback can be
+// any function that receives a slice of structs or a slice of
+// pointers to struct as its only argument and that reflection
+// will be used to instantiate this argument and to fill it
+// with the database rows.
+func (c DB) QueryChunks(
+	ctx context.Context,
+	parser ChunkParser,
+) (err error) {
+	ctx, finish := c.observe(ctx, "query_chunks", "")
+	defer func() { finish(err) }()
+
+	parser.Query, parser.Params, err = bindNamedParams(c.dialect, parser.Query, parser.Params, c.getColumnResolver())
+	if err != nil {
+		return err
+	}
+
+	fnValue := reflect.ValueOf(parser.ForEachChunk)
+	chunkType, err := structs.ParseInputFunc(parser.ForEachChunk)
+	if err != nil {
+		return err
+	}
+
+	chunk := reflect.MakeSlice(chunkType, 0, parser.ChunkSize)
+
+	structType, isSliceOfPtrs, err := structs.DecodeAsSliceOfStructs(chunkType)
+	if err != nil {
+		return err
+	}
+
+	info, err := c.getTagInfo(structType)
+	if err != nil {
+		return err
+	}
+
+	parser.Query, err = c.buildSelectPrefixIfOmitted(parser.Query, structType, info)
+	if err != nil {
+		return err
+	}
+
+	parser.Query, parser.Params, err = c.applyScopesToQuery(parser.Query, parser.Params, info)
+	if err != nil {
+		return err
+	}
+
+	if parser.ServerCursor && c.dialect.DriverName() == "postgres" {
+		return c.Transaction(ctx, func(p Provider) error {
+			return p.(DB).queryChunksWithServerCursor(ctx, parser, fnValue, chunkType, structType, isSliceOfPtrs)
+		})
+	}
+
+	rows, err := c.queryContext(ctx, parser.Query, parser.Params...)
+	if err != nil {
+		return fmt.Errorf("error running query: %w", err)
+	}
+	defer rows.Close()
+
+	var idx = 0
+	var rowsSoFar = 0
+	for rows.Next() {
+		// Allocate new slice elements
+		// only if they are not already allocated:
+		if chunk.Len() <= idx {
+			var elemValue reflect.Value
+			elemValue = reflect.New(structType)
+			if !isSliceOfPtrs {
+				elemValue = elemValue.Elem()
+			}
+			chunk = reflect.Append(chunk, elemValue)
+		}
+
+		err = c.scanRows(rows, chunk.Index(idx).Addr().Interface())
+		if err != nil {
+			return err
+		}
+
+		if idx < parser.ChunkSize-1 {
+			idx++
+			continue
+		}
+
+		idx = 0
+		err, _ = fnValue.Call([]reflect.Value{chunk})[0].Interface().(error)
+		if err != nil {
+			if err == ErrAbortIteration {
+				return nil
+			}
+			return err
+		}
+
+		rowsSoFar += chunk.Len()
+		parser.reportProgress(rowsSoFar)
+	}
+
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	// If Next() returned false because of an error:
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+
+	// If no rows were found or idx was reset to 0
+	// on the last iteration skip this last call to ForEachChunk:
+	if idx > 0 {
+		chunk = chunk.Slice(0, idx)
+
+		err, _ = fnValue.Call([]reflect.Value{chunk})[0].Interface().(error)
+		if err != nil {
+			if err == ErrAbortIteration {
+				return nil
+			}
+			return err
+		}
+
+		rowsSoFar += idx
+		parser.reportProgress(rowsSoFar)
+	}
+
+	return nil
+}
+
+// cursorCounter is used for generating unique cursor names
+// so that nested calls to QueryChunks don't conflict.
+var cursorCounter uint64
+
+// queryChunksWithServerCursor implements the ServerCursor option of
+// QueryChunks, it must be called inside a transaction since Postgres
+// cursors only exist until the end of the transaction.
+func (c DB) queryChunksWithServerCursor(
+	ctx context.Context,
+	parser ChunkParser,
+	fnValue reflect.Value,
+	chunkType reflect.Type,
+	structType reflect.Type,
+	isSliceOfPtrs bool,
+) error {
+	if parser.ChunkSize <= 0 {
+		return fmt.Errorf("ksql: expected ChunkSize to be a positive number when using a ServerCursor, but got: %d", parser.ChunkSize)
+	}
+
+	chunk := reflect.MakeSlice(chunkType, parser.ChunkSize, parser.ChunkSize)
+	if isSliceOfPtrs {
+		for i := 0; i < chunk.Len(); i++ {
+			chunk.Index(i).Set(reflect.New(structType))
+		}
+	}
+
+	cursorName := fmt.Sprintf("ksql_cursor_%d", atomic.AddUint64(&cursorCounter, 1))
+
+	_, err := c.execContext(ctx, "DECLARE "+cursorName+" NO SCROLL CURSOR FOR "+parser.Query, parser.Params...)
+	if err != nil {
+		return fmt.Errorf("error declaring server cursor: %w", err)
+	}
+
+	fetchQuery := fmt.Sprintf("FETCH FORWARD %d FROM %s", parser.ChunkSize, cursorName)
+	rowsSoFar := 0
+	for {
+		idx, err := c.fetchChunk(ctx, fetchQuery, chunk)
+		if err != nil {
+			return err
+		}
+
+		if idx > 0 {
+			err, _ = fnValue.Call([]reflect.Value{chunk.Slice(0, idx)})[0].Interface().(error)
+			if err != nil {
+				if err == ErrAbortIteration {
+					break
+				}
+				return err
+			}
+
+			rowsSoFar += idx
+			parser.reportProgress(rowsSoFar)
+		}
+
+		if idx < parser.ChunkSize {
+			break
+		}
+	}
+
+	_, err = c.execContext(ctx, "CLOSE "+cursorName)
+	return err
+}
+
+// fetchChunk runs the FETCH query and scans the returned rows
+// into the first positions of the chunk, returning the number of rows read.
+func (c DB) fetchChunk(ctx context.Context, fetchQuery string, chunk reflect.Value) (idx int, _ error) {
+	rows, err := c.queryContext(ctx, fetchQuery)
+	if err != nil {
+		return 0, fmt.Errorf("error fetching from server cursor: %w", err)
+	}
+	defer rows.Close()
+
+	for ; rows.Next(); idx++ {
+		if idx >= chunk.Len() {
+			return 0, fmt.Errorf("ksql: unexpected error: the server cursor returned more than %d rows", chunk.Len())
+		}
+
+		err = c.scanRows(rows, chunk.Index(idx).Addr().Interface())
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := rows.Close(); err != nil {
+		return 0, err
+	}
+
+	return idx, rows.Err()
+}
+
+// QueryChan queries several rows from the database and sends each
+// of them on the returned channel, e.g.:
+//
+//	usersCh, errCh := c.QueryChan(ctx, User{}, "FROM users WHERE age > $1", 18)
+//	for u := range usersCh {
+//		fmt.Println(u.(User).Name)
+//	}
+//	if err := <-errCh; err != nil {
+//		// ...
+//	}
+//
+// The elemType argument should be a struct or a pointer to struct
+// and defines the type of the values sent on the records channel.
+// The query should use the placeholders of the dialect, e.g. `$1`
+// on postgres.
+//
+// Both channels are closed when all the rows were sent, when an error
+// occurs or when the context is canceled, and at most one error is
+// sent on the error channel before it is closed.
+func (c DB) QueryChan(
+	ctx context.Context,
+	elemType interface{},
+	query string,
+	params ...interface{},
+) (<-chan interface{}, <-chan error) {
+	recordsCh := make(chan interface{})
+	errCh := make(chan error, 1)
+
+	t := reflect.TypeOf(elemType)
+	isPtr := t != nil && t.Kind() == reflect.Ptr
+	if isPtr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		errCh <- fmt.Errorf("ksql: expected elemType to be a struct or a pointer to struct, but got: %T", elemType)
+		close(recordsCh)
+		close(errCh)
+		return recordsCh, errCh
+	}
+
+	errType := reflect.TypeOf((*error)(nil)).Elem()
+	errValue := func(err error) []reflect.Value {
+		v := reflect.New(errType).Elem()
+		if err != nil {
+			v.Set(reflect.ValueOf(err))
+		}
+		return []reflect.Value{v}
+	}
+
+	forEachChunk := reflect.MakeFunc(
+		reflect.FuncOf([]reflect.Type{reflect.SliceOf(t)}, []reflect.Type{errType}, false),
+		func(args []reflect.Value) []reflect.Value {
+			chunk := args[0]
+			for i := 0; i < chunk.Len(); i++ {
+				var record interface{} = chunk.Index(i).Interface()
+				if isPtr {
+					recordPtr := reflect.New(t)
+					recordPtr.Elem().Set(chunk.Index(i))
+					record = recordPtr.Interface()
+				}
+
+				// The chunk is reused between calls so we reset it to
+				// avoid sharing maps or slices with the records sent:
+				chunk.Index(i).Set(reflect.Zero(t))
+
+				select {
+				case recordsCh <- record:
+				case <-ctx.Done():
+					return errValue(ctx.Err())
+				}
+			}
+			return errValue(nil)
+		},
+	)
+
+	go func() {
+		defer close(errCh)
+		defer close(recordsCh)
+
+		queryCtx, finish := c.observe(ctx, "query_chan", "")
+		err := c.QueryChunks(queryCtx, ChunkParser{
+			Query:        query,
+			Params:       params,
+			ChunkSize:    100,
+			ForEachChunk: forEachChunk.Interface(),
+		})
+		finish(err)
+		if err != nil {
+			errCh <- err
+		}
+	}()
+
+	return recordsCh, errCh
+}
+
+// Insert one or more instances on the database
+//
+// If the original instances have been passed by reference
+// the ID is automatically updated after insertion is completed,
+// unless the DB was configured with WithSkipIDWriteBack(true).
+//
+// The IDs are written to the attributes whose `ksql` tags match
+// the ID columns of the table, regardless of the attribute names,
+// e.g. an attribute `UserID int` tagged with `ksql:"id"`. If one of
+// the ID columns is not tagged on the struct no ID is written back.
+//
+// Attributes tagged with the default option, e.g. `ksql:"status,default=new"`,
+// are set to their default values before the insert if they are zero or nil,
+// and since the option is split on commas the default can't contain commas.
+func (c DB) Insert(
+	ctx context.Context,
+	table Table,
+	record interface{},
+) (err error) {
+	ctx, finish := c.observe(ctx, "insert", table.name)
+	defer func() { finish(err) }()
+
+	v := reflect.ValueOf(record)
+	t := v.Type()
+	if err := assertStructPtr(t); err != nil {
+		return fmt.Errorf(
+			"ksql: expected record to be a pointer to struct, but got: %T",
+			record,
+		)
+	}
+
+	if v.IsNil() {
+		return fmt.Errorf("ksql: expected a valid pointer to struct as argument but received a nil pointer: %v", record)
+	}
+
+	if err := table.validate(); err != nil {
+		return fmt.Errorf("can't insert in ksql.Table: %s", err)
+	}
+
+	info, err := c.getTagInfo(t.Elem())
+	if err != nil {
+		return err
+	}
+	err = structs.ApplyDefaults(v.Elem(), info)
+	if err != nil {
 		return err
 	}
 
-	err = rows.Scan(scanValues...)
+	err = c.setUUIDKey(v.Elem(), info)
+	if err != nil {
+		return err
+	}
+
+	insertMethod := table.insertMethodFor(c.dialect)
+	if c.skipIDWriteBack || !hasAllIDColumns(info, table.idColumns) {
+		// The IDs are written back to the attributes tagged with
+		// the names of the ID columns, so if one of these attributes
+		// is missing there is no place to write them to:
+		insertMethod = insertWithNoIDRetrieval
+	}
+	if insertMethod == insertWithLastInsertID && c.uuidKeyColumn == table.idColumns[0] {
+		// The UUID keys are not auto incremented,
+		// so there is no ID to retrieve:
+		insertMethod = insertWithNoIDRetrieval
+	}
+
+	query, params, scanValues, err := buildInsertQuery(c.dialect, table, insertMethod, t, v, info, record, c.getColumnResolver())
+	if err != nil {
+		return err
+	}
+	c.convertParamsToLocation(params)
+
+	switch insertMethod {
+	case insertWithReturning, insertWithOutput:
+		err = c.insertReturningIDs(ctx, query, params, scanValues, table.idColumns)
+	case insertWithLastInsertID:
+		err = c.insertWithLastInsertID(ctx, t, v, info, record, query, params, table.idColumns[0])
+	case insertWithNoIDRetrieval:
+		err = c.insertWithNoIDRetrieval(ctx, query, params)
+	default:
+		// Unsupported drivers should be detected on the New() function,
+		// So we don't expect the code to ever get into this default case.
+		err = fmt.Errorf("code error: unsupported driver `%s`", c.driver)
+	}
+	if err != nil {
+		return fmt.Errorf("ksql: Insert into %q failed: %w", table.name, err)
+	}
+
+	return nil
+}
+
+// InsertSlice inserts each of the records of the input slice
+// of structs (or *struct) on the database, e.g.:
+//
+//	err := c.InsertSlice(ctx, UsersTable, []User{{Name: "foo"}, {Name: "bar"}})
+//
+// Each record is inserted using Insert, so the IDs are written
+// back to the slice elements just like when calling Insert
+// with a pointer to each of them. The records are all inserted
+// inside a single transaction.
+//
+// For inserting large slices prefer InsertBatch, which
+// uses multi-row statements but doesn't write back the IDs.
+func (c DB) InsertSlice(ctx context.Context, table Table, records interface{}) (err error) {
+	ctx, finish := c.observe(ctx, "insert_slice", table.name)
+	defer func() { finish(err) }()
+
+	slice := reflect.ValueOf(records)
+	if slice.Kind() == reflect.Ptr {
+		slice = slice.Elem()
+	}
+	if slice.Kind() != reflect.Slice {
+		return fmt.Errorf("ksql: expected records to be a slice of structs, but got: %T", records)
+	}
+
+	_, isSliceOfPtrs, err := structs.DecodeAsSliceOfStructs(slice.Type())
+	if err != nil {
+		return fmt.Errorf("ksql: expected records to be a slice of structs, but got: %T", records)
+	}
+
+	if slice.Len() == 0 {
+		return nil
+	}
+
+	return c.Transaction(ctx, func(p Provider) error {
+		for i := 0; i < slice.Len(); i++ {
+			record := slice.Index(i)
+			if !isSliceOfPtrs {
+				record = record.Addr()
+			}
+
+			err := p.Insert(ctx, table, record.Interface())
+			if err != nil {
+				return fmt.Errorf("error inserting record on position %d: %w", i, err)
+			}
+		}
+		return nil
+	})
+}
+
+// InsertIfNotExists inserts the input record only if no row of the
+// table matches the input condition, and reports if it was inserted, e.g.:
+//
+//	inserted, err := c.InsertIfNotExists(ctx, UsersTable, &user, "email = $1", user.Email)
+//
+// The check and the insertion run on a single
+// `INSERT INTO ... SELECT ... WHERE NOT EXISTS (...)` statement,
+// so no unique constraint is required on the table. Note that
+// this doesn't prevent two concurrent transactions from both
+// inserting the record unless a unique constraint exists.
+//
+// The condition should use the placeholders of the dialect
+// starting with the first one, e.g. `$1` on postgres.
+//
+// Unlike Insert the IDs are not written back to the record.
+func (c DB) InsertIfNotExists(
+	ctx context.Context,
+	table Table,
+	record interface{},
+	condition string,
+	params ...interface{},
+) (inserted bool, err error) {
+	ctx, finish := c.observe(ctx, "insert_if_not_exists", table.name)
+	defer func() { finish(err) }()
+
+	v := reflect.ValueOf(record)
+	t := v.Type()
+	if err := assertStructPtr(t); err != nil {
+		return false, fmt.Errorf(
+			"ksql: expected record to be a pointer to struct, but got: %T",
+			record,
+		)
+	}
+
+	if v.IsNil() {
+		return false, fmt.Errorf("ksql: expected a valid pointer to struct as argument but received a nil pointer: %v", record)
+	}
+
+	if err := table.validate(); err != nil {
+		return false, fmt.Errorf("can't insert in ksql.Table: %s", err)
+	}
+
+	info, err := c.getTagInfo(t.Elem())
+	if err != nil {
+		return false, err
+	}
+
+	if strings.TrimSpace(condition) == "" {
+		return false, fmt.Errorf("ksql: InsertIfNotExists requires a non empty condition")
+	}
+
+	condition, params, err = bindNamedParams(c.dialect, condition, params, c.getColumnResolver())
+	if err != nil {
+		return false, err
+	}
+
+	query, params, err := buildInsertIfNotExistsQuery(c.dialect, table, t, info, record, condition, params, c.getColumnResolver())
+	if err != nil {
+		return false, err
+	}
+	c.convertParamsToLocation(params)
+
+	result, err := c.execContext(ctx, query, params...)
+	if err != nil {
+		return false, fmt.Errorf("ksql: InsertIfNotExists into %q failed: %w", table.name, err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf(
+			"ksql: unable to check if the record was inserted into %q: %w",
+			table.name, err,
+		)
+	}
+
+	return n > 0, nil
+}
+
+func buildInsertIfNotExistsQuery(
+	dialect Dialect,
+	table Table,
+	t reflect.Type,
+	info structs.StructInfo,
+	record interface{},
+	condition string,
+	conditionParams []interface{},
+	resolver *structs.ColumnResolver,
+) (query string, params []interface{}, err error) {
+	columnNames, recordParams, err := buildInsertColumnsAndParams(dialect, table, t, info, record, resolver)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(columnNames) == 0 {
+		return "", nil, fmt.Errorf("ksql: InsertIfNotExists requires at least one column to be set on the record")
+	}
+
+	// The condition uses the first placeholders, so on dialects with numbered
+	// placeholders the values of the record are numbered after it, and on
+	// dialects with positional placeholders, like `?`, the params must
+	// follow the order in which they appear on the query:
+	numbered := dialect.Placeholder(0) != dialect.Placeholder(1)
+	offset := 0
+	params = append(append([]interface{}{}, recordParams...), conditionParams...)
+	if numbered {
+		offset = len(conditionParams)
+		params = append(append([]interface{}{}, conditionParams...), recordParams...)
+	}
+
+	escapedColumnNames := make([]string, len(columnNames))
+	valuesQuery := make([]string, len(columnNames))
+	for i, col := range columnNames {
+		escapedColumnNames[i] = dialect.Escape(col)
+		valuesQuery[i] = dialect.Placeholder(offset + i)
+	}
+
+	// MySQL requires a FROM clause for using WHERE on a SELECT:
+	fromDual := ""
+	if dialect.DriverName() == "mysql" {
+		fromDual = " FROM DUAL"
+	}
+
+	tableName := dialect.Escape(table.name)
+	query = fmt.Sprintf(
+		"INSERT INTO %s (%s) SELECT %s%s WHERE NOT EXISTS (SELECT 1 FROM %s WHERE (%s))",
+		tableName,
+		strings.Join(escapedColumnNames, ", "),
+		strings.Join(valuesQuery, ", "),
+		fromDual,
+		tableName,
+		condition,
+	)
+
+	return query, params, nil
+}
+
+func (c DB) insertReturningIDs(
+	ctx context.Context,
+	query string,
+	params []interface{},
+	scanValues []interface{},
+	idNames []string,
+) error {
+	rows, err := c.queryContext(ctx, query, params...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		err := fmt.Errorf("unexpected error when retrieving the id columns from the database")
+		if rows.Err() != nil {
+			err = rows.Err()
+		}
+
+		return err
+	}
+
+	err = rows.Scan(scanValues...)
+	if err != nil {
+		return err
+	}
+
+	return rows.Close()
+}
+
+func (c DB) insertWithLastInsertID(
+	ctx context.Context,
+	t reflect.Type,
+	v reflect.Value,
+	info structs.StructInfo,
+	record interface{},
+	query string,
+	params []interface{},
+	idName string,
+) error {
+	result, err := c.execContext(ctx, query, params...)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	vID := reflect.ValueOf(id)
+	tID := vID.Type()
+
+	fieldAddr := v.Elem().Field(info.ByName(idName).Index).Addr()
+	fieldType := fieldAddr.Type().Elem()
+
+	if !tID.ConvertibleTo(fieldType) {
+		return fmt.Errorf(
+			"Can't convert last insert id of type int64 into field `%s` of type %v",
+			idName,
+			fieldType,
+		)
+	}
+
+	fieldAddr.Elem().Set(vID.Convert(fieldType))
+	return nil
+}
+
+// hasAllIDColumns reports if all the ID columns are tagged on the struct.
+func hasAllIDColumns(info structs.StructInfo, idColumns []string) bool {
+	for _, idColumn := range idColumns {
+		if !info.ByName(idColumn).Valid {
+			return false
+		}
+	}
+	return true
+}
+
+func (c DB) insertWithNoIDRetrieval(
+	ctx context.Context,
+	query string,
+	params []interface{},
+) error {
+	_, err := c.execContext(ctx, query, params...)
+	return err
+}
+
+func assertStructPtr(t reflect.Type) error {
+	if t.Kind() != reflect.Ptr {
+		return fmt.Errorf("expected a Kind of Ptr but got: %s", t)
+	}
+	if t.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("expected a Kind of Ptr to Struct but got: %s", t)
+	}
+	return nil
+}
+
+// Delete deletes one record from the database using the ID or IDs
+// defined on the ksql.Table passed as second argument.
+//
+// For tables with a single ID column you can pass the record
+// to be deleted as a struct, as a map or just pass the ID itself.
+//
+// For tables with composite keys you must pass the record
+// as a struct or a map so that ksql can read all the composite keys
+// from it.
+//
+// The examples below should work for both types of tables:
+//
+//     err := c.Delete(ctx, UsersTable, user)
+//
+//     err := c.Delete(ctx, UserPostsTable, map[string]interface{}{
+//         "user_id": user.ID,
+//         "post_id": post.ID,
+//     })
+//
+// The example below is shorter but will only work for tables with a single primary key:
+//
+//     err := c.Delete(ctx, UsersTable, user.ID)
+//
+func (c DB) Delete(
+	ctx context.Context,
+	table Table,
+	idOrRecord interface{},
+) (err error) {
+	ctx, finish := c.observe(ctx, "delete", table.name)
+	defer func() { finish(err) }()
+
+	if err := table.validate(); err != nil {
+		return fmt.Errorf("can't delete from ksql.Table: %s", err)
+	}
+
+	idMap, err := normalizeIDsAsMap(table.idColumns, idOrRecord, c.getColumnResolver())
+	if err != nil {
+		return err
+	}
+
+	var query string
+	var params []interface{}
+	query, params = buildDeleteQuery(c.dialect, table, idMap)
+	query, params, err = c.applyScopesToWhere(query, params)
+	if err != nil {
+		return err
+	}
+
+	result, err := c.execContext(ctx, query, params...)
+	if err != nil {
+		return fmt.Errorf("ksql: Delete from %q failed: %w", table.name, err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("unable to check if the record was succesfully deleted: %s", err)
+	}
+
+	if n == 0 {
+		return ErrRecordNotFound
+	}
+
+	return err
+}
+
+// CascadeChild describes a table whose rows reference a parent
+// table and should be deleted with it when using DeleteCascade.
+type CascadeChild struct {
+	Table Table
+
+	// FKColumn is the column of the child table
+	// that references the ID of the parent table.
+	FKColumn string
+}
+
+// DeleteCascade deletes one record and, before it, all the rows
+// of the children tables that reference it, e.g.:
+//
+//	err := c.DeleteCascade(ctx, UsersTable, user.ID, []ksql.CascadeChild{
+//		{Table: PostsTable, FKColumn: "user_id"},
+//		{Table: CommentsTable, FKColumn: "author_id"},
+//	})
+//
+// The children are deleted in the input order and everything runs
+// inside a single transaction, so if the parent is not found, in which
+// case ErrRecordNotFound is returned, no rows are deleted. The table
+// must have a single ID column and the record can be passed just like
+// when using Delete. Children of the children tables are not deleted.
+func (c DB) DeleteCascade(
+	ctx context.Context,
+	table Table,
+	idOrRecord interface{},
+	children []CascadeChild,
+) (err error) {
+	ctx, finish := c.observe(ctx, "delete_cascade", table.name)
+	defer func() { finish(err) }()
+
+	if err := table.validate(); err != nil {
+		return fmt.Errorf("can't delete from ksql.Table: %s", err)
+	}
+
+	if len(table.idColumns) != 1 {
+		return fmt.Errorf("ksql: DeleteCascade requires a table with a single ID column, but got: %v", table.idColumns)
+	}
+
+	for _, child := range children {
+		if err := child.Table.validate(); err != nil {
+			return fmt.Errorf("can't delete from ksql.Table: %s", err)
+		}
+
+		if err := ValidateIdentifier(child.FKColumn); err != nil {
+			return fmt.Errorf("ksql: invalid foreign key column for table %q: %s", child.Table.name, err)
+		}
+	}
+
+	idMap, err := normalizeIDsAsMap(table.idColumns, idOrRecord, c.getColumnResolver())
 	if err != nil {
 		return err
 	}
+	id := idMap[table.idColumns[0]]
 
-	return rows.Close()
+	return c.Transaction(ctx, func(p Provider) error {
+		tx := p.(DB)
+		for _, child := range children {
+			_, err := tx.execContext(ctx,
+				"DELETE FROM "+tx.dialect.Escape(child.Table.name)+
+					" WHERE "+tx.dialect.Escape(child.FKColumn)+" = "+tx.dialect.Placeholder(0),
+				id,
+			)
+			if err != nil {
+				return fmt.Errorf("ksql: Delete from %q failed: %w", child.Table.name, err)
+			}
+		}
+
+		return tx.Delete(ctx, table, idMap)
+	})
 }
 
-func (c DB) insertWithLastInsertID(
+// DeleteReturning deletes all the records matching the input condition
+// and loads the deleted records into the input pointer to slice, e.g.:
+//
+//	var deletedUsers []struct {
+//		ID int `ksql:"id"`
+//	}
+//	err := c.DeleteReturning(ctx, UsersTable, &deletedUsers, "age < $1", 18)
+//
+// Only the columns tagged on the struct are returned, so in the example
+// above only the IDs of the deleted records are loaded. Deleting no records
+// is not reported as an error, in this case the slice is just left empty.
+//
+// On postgres and sqlite3 it uses a `DELETE ... RETURNING` query and
+// on sqlserver an OUTPUT clause, on mysql, which supports neither, the
+// records are loaded with a `SELECT ... FOR UPDATE` query and then
+// deleted, both inside a single transaction.
+func (c DB) DeleteReturning(
 	ctx context.Context,
-	t reflect.Type,
-	v reflect.Value,
-	info structs.StructInfo,
-	record interface{},
-	query string,
-	params []interface{},
-	idName string,
-) error {
-	result, err := c.db.ExecContext(ctx, query, params...)
+	table Table,
+	deletedRecords interface{},
+	condition string,
+	params ...interface{},
+) (err error) {
+	ctx, finish := c.observe(ctx, "delete_returning", table.name)
+	defer func() { finish(err) }()
+
+	if err := table.validate(); err != nil {
+		return fmt.Errorf("can't delete from ksql.Table: %s", err)
+	}
+
+	slicePtr := reflect.ValueOf(deletedRecords)
+	if slicePtr.Kind() != reflect.Ptr || slicePtr.IsNil() {
+		return fmt.Errorf("ksql: expected to receive a pointer to slice of structs, but got: %T", deletedRecords)
+	}
+
+	structType, _, err := structs.DecodeAsSliceOfStructs(slicePtr.Type().Elem())
 	if err != nil {
 		return err
 	}
 
-	id, err := result.LastInsertId()
+	info, err := c.getTagInfo(structType)
 	if err != nil {
 		return err
 	}
 
-	vID := reflect.ValueOf(id)
-	tID := vID.Type()
+	if info.IsNestedStruct {
+		return fmt.Errorf("ksql: DeleteReturning doesn't support nested structs")
+	}
 
-	fieldAddr := v.Elem().Field(info.ByName(idName).Index).Addr()
-	fieldType := fieldAddr.Type().Elem()
+	condition, params, err = bindNamedParams(c.dialect, condition, params, c.getColumnResolver())
+	if err != nil {
+		return err
+	}
 
-	if !tID.ConvertibleTo(fieldType) {
-		return fmt.Errorf(
-			"Can't convert last insert id of type int64 into field `%s` of type %v",
-			idName,
-			fieldType,
+	whereQuery, params, err := c.applyScopesToWhere("WHERE ("+condition+")", params)
+	if err != nil {
+		return err
+	}
+
+	// The scopes were already applied to the WHERE clause:
+	unscoped := c
+	unscoped.scopes = nil
+
+	// Truncating the slice so no records are
+	// left on it if nothing is deleted:
+	slicePtr.Elem().Set(slicePtr.Elem().Slice(0, 0))
+
+	tableName := c.dialect.Escape(table.name)
+	var columns, outputColumns []string
+	for i := 0; i < structType.NumField(); i++ {
+		fieldInfo := info.ByIndex(i)
+		if !fieldInfo.Valid {
+			continue
+		}
+
+		column := c.dialect.Escape(fieldInfo.Name)
+		columns = append(columns, column)
+		outputColumns = append(outputColumns, "DELETED."+column)
+	}
+
+	switch c.dialect.DriverName() {
+	case "postgres", "sqlite3":
+		err = unscoped.Query(ctx, deletedRecords,
+			"DELETE FROM "+tableName+" "+whereQuery+" RETURNING "+strings.Join(columns, ", "),
+			params...,
 		)
+	case "sqlserver":
+		err = unscoped.Query(ctx, deletedRecords,
+			"DELETE FROM "+tableName+" OUTPUT "+strings.Join(outputColumns, ", ")+" "+whereQuery,
+			params...,
+		)
+	default:
+		err = unscoped.Transaction(ctx, func(p Provider) error {
+			tx := p.(DB)
+			err := tx.Query(ctx, deletedRecords,
+				"SELECT "+strings.Join(columns, ", ")+" FROM "+tableName+" "+whereQuery+" FOR UPDATE",
+				params...,
+			)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.execContext(ctx, "DELETE FROM "+tableName+" "+whereQuery, params...)
+			return err
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("ksql: DeleteReturning from %q failed: %w", table.name, err)
 	}
 
-	fieldAddr.Elem().Set(vID.Convert(fieldType))
 	return nil
 }
 
-func (c DB) insertWithNoIDRetrieval(
+func normalizeIDsAsMap(
+	idNames []string,
+	idOrMap interface{},
+	resolver *structs.ColumnResolver,
+) (idMap map[string]interface{}, err error) {
+	if len(idNames) == 0 {
+		return nil, fmt.Errorf("internal ksql error: missing idNames")
+	}
+
+	t := reflect.TypeOf(idOrMap)
+	if t.Kind() == reflect.Ptr {
+		v := reflect.ValueOf(idOrMap)
+		if v.IsNil() {
+			return nil, fmt.Errorf("ksql: expected a valid pointer to struct as argument but received a nil pointer: %v", idOrMap)
+		}
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		idMap, err = structs.StructToMapWithResolver(idOrMap, resolver)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not get ID(s) from input record")
+		}
+	case reflect.Map:
+		var ok bool
+		idMap, ok = idOrMap.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected map[string]interface{} but got %T", idOrMap)
+		}
+	default:
+		idMap = map[string]interface{}{
+			idNames[0]: idOrMap,
+		}
+	}
+
+	for _, idName := range idNames {
+		id, found := idMap[idName]
+		if !found {
+			return nil, fmt.Errorf("missing required id field `%s` on input record", idName)
+		}
+
+		if id == nil || reflect.ValueOf(id).IsZero() {
+			return nil, fmt.Errorf("invalid value '%v' received for id column: '%s'", id, idName)
+		}
+	}
+
+	return idMap, nil
+}
+
+// Update updates the given instances on the database by id.
+//
+// Partial updates are supported, i.e. it will ignore nil pointer attributes
+//
+// Deprecated: Use the Patch method instead
+func (c DB) Update(
 	ctx context.Context,
-	query string,
-	params []interface{},
-) error {
-	_, err := c.db.ExecContext(ctx, query, params...)
+	table Table,
+	record interface{},
+) (err error) {
+	ctx, finish := c.observe(ctx, "update", table.name)
+	defer func() { finish(err) }()
+
+	return c.Patch(ctx, table, record)
+}
+
+// Patch applies a partial update (explained below) to the given instance on the database by id.
+//
+// Partial updates will ignore any nil pointer attributes from the struct, updating only
+// the non nil pointers and non pointer attributes.
+//
+// If there are no attributes left to update ErrNoFieldsToUpdate is returned,
+// unless the DB was configured with WithSkipEmptyPatches(true).
+func (c DB) Patch(
+	ctx context.Context,
+	table Table,
+	record interface{},
+) (err error) {
+	ctx, finish := c.observe(ctx, "patch", table.name)
+	defer func() { finish(err) }()
+
+	_, err = c.patch(ctx, table, record, nil, nil, false)
 	return err
 }
 
-func assertStructPtr(t reflect.Type) error {
-	if t.Kind() != reflect.Ptr {
-		return fmt.Errorf("expected a Kind of Ptr but got: %s", t)
-	}
-	if t.Elem().Kind() != reflect.Struct {
-		return fmt.Errorf("expected a Kind of Ptr to Struct but got: %s", t)
+// PatchOnly works like Patch but only updates the input columns, e.g.:
+//
+//	err := c.PatchOnly(ctx, UsersTable, &user, "name", "age")
+//
+// Nil pointer attributes are still ignored even if listed on the columns.
+func (c DB) PatchOnly(
+	ctx context.Context,
+	table Table,
+	record interface{},
+	columns ...string,
+) (err error) {
+	ctx, finish := c.observe(ctx, "patch_only", table.name)
+	defer func() { finish(err) }()
+
+	if len(columns) == 0 {
+		return fmt.Errorf("ksql: expected at least one column to update")
 	}
-	return nil
+
+	_, err = c.patch(ctx, table, record, columns, nil, false)
+	return err
 }
 
-// Delete deletes one record from the database using the ID or IDs
-// defined on the ksql.Table passed as second argument.
+// PatchExcept works like Patch but never updates the input columns, e.g.:
 //
-// For tables with a single ID column you can pass the record
-// to be deleted as a struct, as a map or just pass the ID itself.
+//	err := c.PatchExcept(ctx, UsersTable, &user, "created_at")
+func (c DB) PatchExcept(
+	ctx context.Context,
+	table Table,
+	record interface{},
+	columns ...string,
+) (err error) {
+	ctx, finish := c.observe(ctx, "patch_except", table.name)
+	defer func() { finish(err) }()
+
+	_, err = c.patch(ctx, table, record, nil, columns, false)
+	return err
+}
+
+// PatchIfChanged works like Patch but reports whether the update
+// actually changed any of the values stored on the database, e.g.:
 //
-// For tables with composite keys you must pass the record
-// as a struct or a map so that ksql can read all the composite keys
-// from it.
+//	changed, err := c.PatchIfChanged(ctx, UsersTable, &user)
 //
-// The examples below should work for both types of tables:
+// The update only runs if at least one of the updated columns is
+// different from the input attributes, so updating a record with its
+// current values returns false and no error. This works the same on all
+// the drivers, including MySQL, since it doesn't depend on how each driver
+// counts the affected rows.
 //
-//     err := c.Delete(ctx, UsersTable, user)
+// If no record matches the ID columns ErrRecordNotFound is returned.
+func (c DB) PatchIfChanged(
+	ctx context.Context,
+	table Table,
+	record interface{},
+) (changed bool, err error) {
+	ctx, finish := c.observe(ctx, "patch_if_changed", table.name)
+	defer func() { finish(err) }()
+
+	return c.patch(ctx, table, record, nil, nil, true)
+}
+
+// PatchAndReload works like Patch but after the update it loads
+// the row back into the record, e.g.:
 //
-//     err := c.Delete(ctx, UserPostsTable, map[string]interface{}{
-//         "user_id": user.ID,
-//         "post_id": post.ID,
-//     })
+//	err := c.PatchAndReload(ctx, UsersTable, &user)
 //
-// The example below is shorter but will only work for tables with a single primary key:
+// So after it returns the record contains the values stored on the database,
+// including the ones set by triggers or by default values, and any nil
+// pointer attributes ignored by the update are also loaded. When the DB
+// is configured with WithUpdatedAtColumn the updated time is also reloaded.
 //
-//     err := c.Delete(ctx, UsersTable, user.ID)
+// The record must be a pointer to struct and both the update
+// and the reload run inside a single transaction.
+func (c DB) PatchAndReload(
+	ctx context.Context,
+	table Table,
+	record interface{},
+) (err error) {
+	ctx, finish := c.observe(ctx, "patch_and_reload", table.name)
+	defer func() { finish(err) }()
+
+	v := reflect.ValueOf(record)
+	if err := assertStructPtr(v.Type()); err != nil {
+		return fmt.Errorf("ksql: expected record to be a pointer to struct, but got: %T", record)
+	}
+	if v.IsNil() {
+		return fmt.Errorf("ksql: expected a valid pointer to struct as argument but received a nil pointer: %v", record)
+	}
+
+	return c.Transaction(ctx, func(p Provider) error {
+		tx := p.(DB)
+		err := tx.Patch(ctx, table, record)
+		if err != nil {
+			return err
+		}
+
+		idMap, err := normalizeIDsAsMap(table.idColumns, record, c.getColumnResolver())
+		if err != nil {
+			return err
+		}
+
+		conditions := make([]string, len(table.idColumns))
+		params := make([]interface{}, len(table.idColumns))
+		for i, id := range table.idColumns {
+			conditions[i] = fmt.Sprintf("%s = %s", c.dialect.Escape(id), c.dialect.Placeholder(i))
+			params[i] = idMap[id]
+		}
+
+		return tx.QueryOne(ctx, record,
+			"FROM "+c.dialect.Escape(table.name)+" WHERE "+strings.Join(conditions, " AND "),
+			params...,
+		)
+	})
+}
+
+// PatchSlice applies Patch to each of the records of the
+// input slice of structs (or *struct), e.g.:
 //
-func (c DB) Delete(
+//	err := c.PatchSlice(ctx, UsersTable, users)
+//
+// The slice can also be passed by pointer and the records
+// are all updated inside a single transaction, so if any of
+// them fails, e.g. with ErrRecordNotFound, none is updated.
+//
+// Since the nil pointer attributes of each record are ignored
+// the records might update different sets of columns, so
+// each record is updated with its own UPDATE statement.
+func (c DB) PatchSlice(ctx context.Context, table Table, records interface{}) (err error) {
+	ctx, finish := c.observe(ctx, "patch_slice", table.name)
+	defer func() { finish(err) }()
+
+	slice := reflect.ValueOf(records)
+	if slice.Kind() == reflect.Ptr {
+		slice = slice.Elem()
+	}
+	if slice.Kind() != reflect.Slice {
+		return fmt.Errorf("ksql: expected records to be a slice of structs, but got: %T", records)
+	}
+
+	_, isSliceOfPtrs, err := structs.DecodeAsSliceOfStructs(slice.Type())
+	if err != nil {
+		return fmt.Errorf("ksql: expected records to be a slice of structs, but got: %T", records)
+	}
+
+	if slice.Len() == 0 {
+		return nil
+	}
+
+	return c.Transaction(ctx, func(p Provider) error {
+		for i := 0; i < slice.Len(); i++ {
+			record := slice.Index(i)
+			if !isSliceOfPtrs {
+				record = record.Addr()
+			}
+
+			err := p.Patch(ctx, table, record.Interface())
+			if err != nil {
+				return fmt.Errorf("error updating record on position %d: %w", i, err)
+			}
+		}
+		return nil
+	})
+}
+
+// IsUnique reports if no record of the table has the input value on
+// the column, which is useful for validating forms before an insert, e.g.:
+//
+//	unique, err := c.IsUnique(ctx, UsersTable, "email", email, nil)
+//
+// If the excludeID is not nil the record with this ID is ignored,
+// so that editing a record doesn't conflict with itself:
+//
+//	unique, err := c.IsUnique(ctx, UsersTable, "email", user.Email, user.ID)
+//
+// The table must have a single ID column and only the records visible
+// to the scopes of the DB are checked. Since the record might be inserted
+// by another request after this check, the unique constraints of the
+// database should still be used for guaranteeing the uniqueness.
+func (c DB) IsUnique(
 	ctx context.Context,
 	table Table,
-	idOrRecord interface{},
-) error {
+	column string,
+	value interface{},
+	excludeID interface{},
+) (unique bool, err error) {
+	ctx, finish := c.observe(ctx, "is_unique", table.name)
+	defer func() { finish(err) }()
+
 	if err := table.validate(); err != nil {
-		return fmt.Errorf("can't delete from ksql.Table: %s", err)
+		return false, fmt.Errorf("can't query ksql.Table: %s", err)
 	}
 
-	idMap, err := normalizeIDsAsMap(table.idColumns, idOrRecord)
-	if err != nil {
-		return err
+	if len(table.idColumns) != 1 {
+		return false, fmt.Errorf("ksql: IsUnique requires a table with a single ID column, but got: %v", table.idColumns)
 	}
 
-	var query string
-	var params []interface{}
-	query, params = buildDeleteQuery(c.dialect, table, idMap)
+	if err := ValidateIdentifier(column); err != nil {
+		return false, fmt.Errorf("ksql: invalid column: %s", err)
+	}
+
+	query := fmt.Sprintf(
+		"SELECT 1 FROM %s WHERE %s = %s",
+		c.dialect.Escape(table.name),
+		c.dialect.Escape(column),
+		c.dialect.Placeholder(0),
+	)
+	params := []interface{}{value}
+	if excludeID != nil {
+		query += fmt.Sprintf(" AND %s <> %s", c.dialect.Escape(table.idColumns[0]), c.dialect.Placeholder(1))
+		params = append(params, excludeID)
+	}
+	c.convertParamsToLocation(params)
 
-	result, err := c.db.ExecContext(ctx, query, params...)
+	query, params, err = c.applyScopesToWhere(query, params)
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	n, err := result.RowsAffected()
+	rows, err := c.queryContext(ctx, query, params...)
 	if err != nil {
-		return fmt.Errorf("unable to check if the record was succesfully deleted: %s", err)
+		return false, fmt.Errorf("error running query: %w", err)
 	}
+	defer rows.Close()
 
-	if n == 0 {
-		return ErrRecordNotFound
+	exists := rows.Next()
+	if rows.Err() != nil {
+		return false, rows.Err()
 	}
 
-	return err
+	return !exists, rows.Close()
 }
 
-func normalizeIDsAsMap(idNames []string, idOrMap interface{}) (idMap map[string]interface{}, err error) {
-	if len(idNames) == 0 {
-		return nil, fmt.Errorf("internal ksql error: missing idNames")
+// ExistingIDs returns the subset of the input IDs that are
+// present on the table, e.g. for deduplicating records before
+// inserting them:
+//
+//	existing, err := c.ExistingIDs(ctx, UsersTable, []interface{}{1, 2, 3})
+//
+// The IDs are returned in the same order and with the same types of the
+// input, so all the input IDs must have the same type, and the table must
+// have a single ID column. Only the records visible to the scopes of the
+// DB are checked, and no query is sent if the input is empty.
+func (c DB) ExistingIDs(ctx context.Context, table Table, ids []interface{}) (existingIDs []interface{}, err error) {
+	ctx, finish := c.observe(ctx, "existing_ids", table.name)
+	defer func() { finish(err) }()
+
+	if err := table.validate(); err != nil {
+		return nil, fmt.Errorf("can't query ksql.Table: %s", err)
 	}
 
-	t := reflect.TypeOf(idOrMap)
-	if t.Kind() == reflect.Ptr {
-		v := reflect.ValueOf(idOrMap)
-		if v.IsNil() {
-			return nil, fmt.Errorf("ksql: expected a valid pointer to struct as argument but received a nil pointer: %v", idOrMap)
+	if len(table.idColumns) != 1 {
+		return nil, fmt.Errorf("ksql: ExistingIDs requires a table with a single ID column, but got: %v", table.idColumns)
+	}
+
+	if len(ids) == 0 {
+		return []interface{}{}, nil
+	}
+
+	idType := reflect.TypeOf(ids[0])
+	if idType == nil || !idType.Comparable() {
+		return nil, fmt.Errorf("ksql: expected the IDs to be comparable values, but got: %T", ids[0])
+	}
+
+	placeholders := make([]string, len(ids))
+	for i, id := range ids {
+		if reflect.TypeOf(id) != idType {
+			return nil, fmt.Errorf("ksql: expected all the IDs to have type %v, but got: %T", idType, id)
 		}
-		t = t.Elem()
+		placeholders[i] = c.dialect.Placeholder(i)
 	}
 
-	switch t.Kind() {
-	case reflect.Struct:
-		idMap, err = ksqltest.StructToMap(idOrMap)
+	escapedID := c.dialect.Escape(table.idColumns[0])
+	query := fmt.Sprintf(
+		"SELECT %s FROM %s WHERE %s IN (%s)",
+		escapedID,
+		c.dialect.Escape(table.name),
+		escapedID,
+		strings.Join(placeholders, ", "),
+	)
+	query, params, err := c.applyScopesToWhere(query, append([]interface{}{}, ids...))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := c.queryContext(ctx, query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("error running query: %w", err)
+	}
+	defer rows.Close()
+
+	found := map[interface{}]bool{}
+	for rows.Next() {
+		id := reflect.New(idType)
+		err := rows.Scan(id.Interface())
 		if err != nil {
-			return nil, errors.Wrapf(err, "could not get ID(s) from input record")
-		}
-	case reflect.Map:
-		var ok bool
-		idMap, ok = idOrMap.(map[string]interface{})
-		if !ok {
-			return nil, fmt.Errorf("expected map[string]interface{} but got %T", idOrMap)
-		}
-	default:
-		idMap = map[string]interface{}{
-			idNames[0]: idOrMap,
+			return nil, fmt.Errorf("ksql: error scanning the ID column `%s`: %w", table.idColumns[0], err)
 		}
+		found[id.Elem().Interface()] = true
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
 	}
 
-	for _, idName := range idNames {
-		id, found := idMap[idName]
-		if !found {
-			return nil, fmt.Errorf("missing required id field `%s` on input record", idName)
-		}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
 
-		if id == nil || reflect.ValueOf(id).IsZero() {
-			return nil, fmt.Errorf("invalid value '%v' received for id column: '%s'", id, idName)
+	existing := []interface{}{}
+	for _, id := range ids {
+		if found[id] {
+			existing = append(existing, id)
 		}
 	}
 
-	return idMap, nil
+	return existing, nil
 }
 
-// Update updates the given instances on the database by id.
-//
-// Partial updates are supported, i.e. it will ignore nil pointer attributes
-//
-// Deprecated: Use the Patch method instead
-func (c DB) Update(
-	ctx context.Context,
-	table Table,
-	record interface{},
-) error {
-	return c.Patch(ctx, table, record)
+// recordExists checks if there is a record (visible to the
+// scopes of the DB) with the input ID values.
+func (c DB) recordExists(ctx context.Context, table Table, idValues []interface{}) (bool, error) {
+	conditions := make([]string, len(table.idColumns))
+	for i, id := range table.idColumns {
+		conditions[i] = fmt.Sprintf("%s = %s", c.dialect.Escape(id), c.dialect.Placeholder(i))
+	}
+
+	query := fmt.Sprintf(
+		"SELECT 1 FROM %s WHERE %s",
+		c.dialect.Escape(table.name),
+		strings.Join(conditions, " AND "),
+	)
+	query, params, err := c.applyScopesToWhere(query, append([]interface{}{}, idValues...))
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := c.queryContext(ctx, query, params...)
+	if err != nil {
+		return false, fmt.Errorf("error running query: %w", err)
+	}
+	defer rows.Close()
+
+	exists := rows.Next()
+	if rows.Err() != nil {
+		return false, rows.Err()
+	}
+
+	return exists, rows.Close()
 }
 
-// Patch applies a partial update (explained below) to the given instance on the database by id.
-//
-// Partial updates will ignore any nil pointer attributes from the struct, updating only
-// the non nil pointers and non pointer attributes.
-func (c DB) Patch(
+func (c DB) patch(
 	ctx context.Context,
 	table Table,
 	record interface{},
-) error {
+	onlyColumns []string,
+	exceptColumns []string,
+	onlyIfChanged bool,
+) (changed bool, _ error) {
+	if err := table.validate(); err != nil {
+		return false, fmt.Errorf("can't update ksql.Table: %s", err)
+	}
+
 	v := reflect.ValueOf(record)
 	t := v.Type()
 	tStruct := t
 	if t.Kind() == reflect.Ptr {
 		if v.IsNil() {
-			return fmt.Errorf("ksql: expected a valid pointer to struct as argument but received a nil pointer: %v", record)
+			return false, fmt.Errorf("ksql: expected a valid pointer to struct as argument but received a nil pointer: %v", record)
 		}
 		tStruct = t.Elem()
 	}
-	info, err := structs.GetTagInfo(tStruct)
+	info, err := c.getTagInfo(tStruct)
 	if err != nil {
-		return err
+		return false, err
+	}
+
+	if onlyColumns == nil && exceptColumns == nil && !onlyIfChanged && c.updatedAtColumn != "" {
+		// Records passed by value are copied so the time can be set:
+		if t.Kind() != reflect.Ptr {
+			ptr := reflect.New(tStruct)
+			ptr.Elem().Set(v)
+			v = ptr
+			record = ptr.Interface()
+		}
+
+		err = c.setUpdatedAt(v.Elem(), info)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	var includeColumn func(column string) bool
+	if onlyColumns != nil || exceptColumns != nil {
+		includeColumn, err = buildColumnFilter(table, info, tStruct, onlyColumns, exceptColumns)
+		if err != nil {
+			return false, err
+		}
 	}
 
-	query, params, err := buildUpdateQuery(c.dialect, table.name, info, record, table.idColumns...)
+	query, params, setColumns, err := buildUpdateQueryAndColumns(c.dialect, table.name, info, record, includeColumn, table.idColumns...)
+	if errors.Is(err, ErrNoFieldsToUpdate) && c.skipEmptyPatches {
+		return false, nil
+	}
 	if err != nil {
-		return err
+		return false, err
+	}
+	c.convertParamsToLocation(params)
+
+	idParams := params[len(setColumns):]
+	if onlyIfChanged {
+		var changedCondition string
+		changedCondition, params = buildChangedCondition(c.dialect, info, setColumns, params)
+		query += " AND (" + changedCondition + ")"
 	}
 
-	result, err := c.db.ExecContext(ctx, query, params...)
+	query, params, err = c.applyScopesToWhere(query, params)
 	if err != nil {
-		return err
+		return false, err
+	}
+
+	result, err := c.execContext(ctx, query, params...)
+	if err != nil {
+		return false, fmt.Errorf("ksql: Patch on %q failed: %w", table.name, err)
 	}
 
 	n, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf(
+		return false, fmt.Errorf(
 			"unexpected error: unable to fetch how many rows were affected by the update: %s",
 			err,
 		)
 	}
+	if n < 1 && onlyIfChanged {
+		// The record might exist with the same values:
+		exists, err := c.recordExists(ctx, table, idParams)
+		if err != nil {
+			return false, err
+		}
+		if exists {
+			return false, nil
+		}
+	}
 	if n < 1 {
-		return ErrRecordNotFound
+		return false, ErrRecordNotFound
 	}
 
-	return nil
+	return true, nil
 }
 
 func buildInsertQuery(
 	dialect Dialect,
 	table Table,
+	insertMethod insertMethod,
 	t reflect.Type,
 	v reflect.Value,
 	info structs.StructInfo,
 	record interface{},
+	resolver *structs.ColumnResolver,
 ) (query string, params []interface{}, scanValues []interface{}, err error) {
-	recordMap, err := ksqltest.StructToMap(record)
+	columnNames, params, err := buildInsertColumnsAndParams(dialect, table, t, info, record, resolver)
 	if err != nil {
 		return "", nil, nil, err
 	}
 
+	valuesQuery := make([]string, len(columnNames))
+	for i := range columnNames {
+		valuesQuery[i] = dialect.Placeholder(i)
+	}
+
+	// Escape all cols to be sure they will be interpreted as column names:
+	escapedColumnNames := []string{}
+	for _, col := range columnNames {
+		escapedColumnNames = append(escapedColumnNames, dialect.Escape(col))
+	}
+
+	var returningQuery, outputQuery string
+	switch insertMethod {
+	case insertWithReturning:
+		escapedIDNames := []string{}
+		for _, id := range table.idColumns {
+			escapedIDNames = append(escapedIDNames, dialect.Escape(id))
+		}
+		returningQuery = " RETURNING " + strings.Join(escapedIDNames, ", ")
+
+		for _, id := range table.idColumns {
+			scanValues = append(
+				scanValues,
+				v.Elem().Field(info.ByName(id).Index).Addr().Interface(),
+			)
+		}
+	case insertWithOutput:
+		escapedIDNames := []string{}
+		for _, id := range table.idColumns {
+			escapedIDNames = append(escapedIDNames, "INSERTED."+dialect.Escape(id))
+		}
+		outputQuery = " OUTPUT " + strings.Join(escapedIDNames, ", ")
+
+		for _, id := range table.idColumns {
+			scanValues = append(
+				scanValues,
+				v.Elem().Field(info.ByName(id).Index).Addr().Interface(),
+			)
+		}
+	}
+
+	// Note that the outputQuery and the returningQuery depend
+	// on the selected driver, thus, they might be empty strings.
+	query = fmt.Sprintf(
+		"INSERT INTO %s (%s)%s VALUES (%s)%s",
+		dialect.Escape(table.name),
+		strings.Join(escapedColumnNames, ", "),
+		outputQuery,
+		strings.Join(valuesQuery, ", "),
+		returningQuery,
+	)
+
+	return query, params, scanValues, nil
+}
+
+// buildInsertColumnsAndParams returns the names of the columns that should
+// be inserted, in the struct declaration order, and their values.
+func buildInsertColumnsAndParams(
+	dialect Dialect,
+	table Table,
+	t reflect.Type,
+	info structs.StructInfo,
+	record interface{},
+	resolver *structs.ColumnResolver,
+) (columnNames []string, params []interface{}, err error) {
+	recordMap, err := structs.StructToMapWithResolver(record, resolver)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	for _, fieldName := range table.idColumns {
 		field, found := recordMap[fieldName]
 		if !found {
@@ -712,13 +2783,17 @@ func buildInsertQuery(
 		}
 	}
 
-	columnNames := []string{}
-	for col := range recordMap {
-		columnNames = append(columnNames, col)
+	// Using the struct declaration order so the generated query is deterministic:
+	columnNames = []string{}
+	for i := 0; i < t.Elem().NumField(); i++ {
+		fieldInfo := info.ByIndex(i)
+		if _, found := recordMap[fieldInfo.Name]; !fieldInfo.Valid || !found || fieldInfo.ReadOnly {
+			continue
+		}
+		columnNames = append(columnNames, fieldInfo.Name)
 	}
 
-	params = make([]interface{}, len(recordMap))
-	valuesQuery := make([]string, len(recordMap))
+	params = make([]interface{}, len(columnNames))
 	for i, col := range columnNames {
 		recordValue := recordMap[col]
 		params[i] = recordValue
@@ -728,130 +2803,411 @@ func buildInsertQuery(
 				Attr:       recordValue,
 			}
 		}
+	}
+
+	return columnNames, params, nil
+}
+
+// buildColumnFilter returns a function that reports if a column
+// should be updated, either because it is one of the onlyColumns
+// or because it is not one of the exceptColumns.
+func buildColumnFilter(
+	table Table,
+	info structs.StructInfo,
+	structType reflect.Type,
+	onlyColumns []string,
+	exceptColumns []string,
+) (func(column string) bool, error) {
+	listed := map[string]bool{}
+	for _, column := range append(onlyColumns, exceptColumns...) {
+		if !info.ByName(column).Valid {
+			return nil, fmt.Errorf("ksql: the column `%s` is not tagged on type %v", column, structType)
+		}
+
+		for _, id := range table.idColumns {
+			if column == id {
+				return nil, fmt.Errorf("ksql: the ID column `%s` can't be used for filtering the updated columns", column)
+			}
+		}
+
+		listed[column] = true
+	}
+
+	isOnly := onlyColumns != nil
+	return func(column string) bool {
+		return listed[column] == isOnly
+	}, nil
+}
+
+func buildUpdateQuery(
+	dialect Dialect,
+	tableName string,
+	info structs.StructInfo,
+	record interface{},
+	includeColumn func(column string) bool,
+	idFieldNames ...string,
+) (query string, args []interface{}, err error) {
+	query, args, _, err = buildUpdateQueryAndColumns(dialect, tableName, info, record, includeColumn, idFieldNames...)
+	return query, args, err
+}
+
+// buildUpdateQueryAndColumns works like buildUpdateQuery but also returns
+// the updated columns in the same order of their values on the args.
+//
+// Since this runs on every update it reads the attributes directly
+// from the struct instead of using StructToMap, which would allocate
+// an intermediary map on every call, but with structs.ReadField so
+// they are validated and dereferenced the same way.
+func buildUpdateQueryAndColumns(
+	dialect Dialect,
+	tableName string,
+	info structs.StructInfo,
+	record interface{},
+	includeColumn func(column string) bool,
+	idFieldNames ...string,
+) (query string, args []interface{}, setColumns []string, err error) {
+	v := reflect.ValueOf(record)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return "", nil, nil, fmt.Errorf("input must be a struct or struct pointer")
+	}
+
+	idArgs := make([]interface{}, len(idFieldNames))
+	args = make([]interface{}, 0, info.NumFields())
+	setColumns = make([]string, 0, info.NumFields())
+
+	var b strings.Builder
+	b.WriteString("UPDATE ")
+	b.WriteString(dialect.Escape(tableName))
+	b.WriteString(" SET ")
+
+	// Using the struct declaration order so the generated query is deterministic:
+	for i := 0; i < v.NumField(); i++ {
+		fieldInfo := info.ByIndex(i)
+		if !fieldInfo.Valid {
+			continue
+		}
+
+		field, found, err := structs.ReadField(v, fieldInfo)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		if !found {
+			continue
+		}
+
+		if idx := indexOfString(idFieldNames, fieldInfo.Name); idx != -1 {
+			idArgs[idx] = field.Interface()
+			continue
+		}
+
+		if fieldInfo.ReadOnly {
+			continue
+		}
+
+		if includeColumn != nil && !includeColumn(fieldInfo.Name) {
+			continue
+		}
+
+		recordValue := getUnixTimestampParam(fieldInfo, field.Interface())
+		if fieldInfo.SerializeAsJSON {
+			recordValue = jsonSerializable{
+				DriverName: dialect.DriverName(),
+				Attr:       recordValue,
+			}
+		}
 
-		valuesQuery[i] = dialect.Placeholder(i)
-	}
+		if len(args) > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(dialect.Escape(fieldInfo.Name))
+		b.WriteString(" = ")
+		b.WriteString(dialect.Placeholder(len(args)))
 
-	// Escape all cols to be sure they will be interpreted as column names:
-	escapedColumnNames := []string{}
-	for _, col := range columnNames {
-		escapedColumnNames = append(escapedColumnNames, dialect.Escape(col))
+		args = append(args, recordValue)
+		setColumns = append(setColumns, fieldInfo.Name)
 	}
 
-	var returningQuery, outputQuery string
-	switch dialect.InsertMethod() {
-	case insertWithReturning:
-		escapedIDNames := []string{}
-		for _, id := range table.idColumns {
-			escapedIDNames = append(escapedIDNames, dialect.Escape(id))
+	if len(setColumns) == 0 {
+		if includeColumn != nil {
+			return "", nil, nil, fmt.Errorf("%w: no attributes left to update on type %T", ErrNoFieldsToUpdate, record)
 		}
-		returningQuery = " RETURNING " + strings.Join(escapedIDNames, ", ")
+		return "", nil, nil, fmt.Errorf("%w on type %T", ErrNoFieldsToUpdate, record)
+	}
 
-		for _, id := range table.idColumns {
-			scanValues = append(
-				scanValues,
-				v.Elem().Field(info.ByName(id).Index).Addr().Interface(),
-			)
+	b.WriteString(" WHERE ")
+	for i, fieldName := range idFieldNames {
+		if i > 0 {
+			b.WriteString(" AND ")
 		}
-	case insertWithOutput:
-		escapedIDNames := []string{}
-		for _, id := range table.idColumns {
-			escapedIDNames = append(escapedIDNames, "INSERTED."+dialect.Escape(id))
+		b.WriteString(dialect.Escape(fieldName))
+		b.WriteString(" = ")
+		b.WriteString(dialect.Placeholder(len(setColumns) + i))
+	}
+
+	return b.String(), append(args, idArgs...), setColumns, nil
+}
+
+func indexOfString(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
 		}
-		outputQuery = " OUTPUT " + strings.Join(escapedIDNames, ", ")
+	}
+	return -1
+}
 
-		for _, id := range table.idColumns {
-			scanValues = append(
-				scanValues,
-				v.Elem().Field(info.ByName(id).Index).Addr().Interface(),
+// buildChangedCondition returns a condition that is only true if
+// at least one of the columns is different from its value on the
+// args, which are expected to start with the values of the columns.
+//
+// The values of the columns are appended again to the args since
+// not all dialects allow reusing the same placeholder twice.
+func buildChangedCondition(
+	dialect Dialect,
+	info structs.StructInfo,
+	columns []string,
+	args []interface{},
+) (condition string, _ []interface{}) {
+	conditions := make([]string, len(columns))
+	for i, column := range columns {
+		escapedName := dialect.Escape(column)
+		placeholder := dialect.Placeholder(len(args))
+		args = append(args, args[i])
+
+		switch dialect.DriverName() {
+		case "postgres":
+			conditions[i] = fmt.Sprintf("%s IS DISTINCT FROM %s", escapedName, placeholder)
+		case "sqlite3":
+			conditions[i] = fmt.Sprintf("%s IS NOT %s", escapedName, placeholder)
+		case "mysql":
+			if info.ByName(column).SerializeAsJSON {
+				placeholder = "CAST(" + placeholder + " AS JSON)"
+			}
+			conditions[i] = fmt.Sprintf("NOT (%s <=> %s)", escapedName, placeholder)
+		default:
+			conditions[i] = fmt.Sprintf(
+				"(%[1]s <> %[2]s OR (%[1]s IS NULL AND %[2]s IS NOT NULL) OR (%[1]s IS NOT NULL AND %[2]s IS NULL))",
+				escapedName, placeholder,
 			)
 		}
 	}
 
-	// Note that the outputQuery and the returningQuery depend
-	// on the selected driver, thus, they might be empty strings.
-	query = fmt.Sprintf(
-		"INSERT INTO %s (%s)%s VALUES (%s)%s",
-		dialect.Escape(table.name),
-		strings.Join(escapedColumnNames, ", "),
-		outputQuery,
-		strings.Join(valuesQuery, ", "),
-		returningQuery,
-	)
+	return strings.Join(conditions, " OR "), args
+}
 
-	return query, params, scanValues, nil
+// Exec just runs an SQL command on the database returning no rows.
+func (c DB) Exec(ctx context.Context, query string, params ...interface{}) (result Result, err error) {
+	ctx, finish := c.observe(ctx, "exec", "")
+	defer func() { finish(err) }()
+
+	query, params, err = bindNamedParams(c.dialect, query, params, c.getColumnResolver())
+	if err != nil {
+		return nil, err
+	}
+
+	return c.execContext(ctx, query, params...)
 }
 
-func buildUpdateQuery(
-	dialect Dialect,
-	tableName string,
-	info structs.StructInfo,
-	record interface{},
-	idFieldNames ...string,
-) (query string, args []interface{}, err error) {
-	recordMap, err := ksqltest.StructToMap(record)
+// Explain runs the driver specific version of the EXPLAIN command
+// for the input query and returns the query plan as text.
+//
+// On Postgres the plan is the output of `EXPLAIN`, on MySQL the tabular
+// output of `EXPLAIN` formatted with one line per row and on SQLite the
+// output of `EXPLAIN QUERY PLAN`. SQLServer is not supported.
+func (c DB) Explain(ctx context.Context, query string, params ...interface{}) (plan string, err error) {
+	ctx, finish := c.observe(ctx, "explain", "")
+	defer func() { finish(err) }()
+
+	return c.explain(ctx, false, query, params...)
+}
+
+// ExplainAnalyze works like Explain but actually runs the query
+// collecting the execution statistics with `EXPLAIN ANALYZE`.
+//
+// It is only supported on Postgres and MySQL, and since the query is
+// executed it is not recommended to use it with data modifying queries.
+func (c DB) ExplainAnalyze(ctx context.Context, query string, params ...interface{}) (plan string, err error) {
+	ctx, finish := c.observe(ctx, "explain_analyze", "")
+	defer func() { finish(err) }()
+
+	return c.explain(ctx, true, query, params...)
+}
+
+func (c DB) explain(ctx context.Context, analyze bool, query string, params ...interface{}) (string, error) {
+	var explainQuery string
+	switch c.dialect.DriverName() {
+	case "postgres", "mysql":
+		explainQuery = "EXPLAIN "
+		if analyze {
+			explainQuery = "EXPLAIN ANALYZE "
+		}
+	case "sqlite3":
+		if analyze {
+			return "", fmt.Errorf("ksql: EXPLAIN ANALYZE is not supported by the sqlite3 driver")
+		}
+		explainQuery = "EXPLAIN QUERY PLAN "
+	default:
+		return "", fmt.Errorf("ksql: EXPLAIN is not supported by the `%s` driver", c.dialect.DriverName())
+	}
+
+	rows, err := c.queryContext(ctx, explainQuery+query, params...)
 	if err != nil {
-		return "", nil, err
+		return "", fmt.Errorf("error running query: %w", err)
 	}
-	numAttrs := len(recordMap)
-	args = make([]interface{}, numAttrs)
-	numNonIDArgs := numAttrs - len(idFieldNames)
-	whereArgs := args[numNonIDArgs:]
+	defer rows.Close()
 
-	whereQuery := make([]string, len(idFieldNames))
-	for i, fieldName := range idFieldNames {
-		whereArgs[i] = recordMap[fieldName]
-		whereQuery[i] = fmt.Sprintf(
-			"%s = %s",
-			dialect.Escape(fieldName),
-			dialect.Placeholder(i+numNonIDArgs),
-		)
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
 
-		delete(recordMap, fieldName)
+	var lines []string
+	// For tabular outputs such as the one from MySQL we add a header:
+	if len(columns) > 1 {
+		lines = append(lines, strings.Join(columns, "\t"))
 	}
 
-	keys := []string{}
-	for key := range recordMap {
-		keys = append(keys, key)
+	values := make([]interface{}, len(columns))
+	scanArgs := make([]interface{}, len(columns))
+	for i := range values {
+		scanArgs[i] = &values[i]
 	}
+	for rows.Next() {
+		err := rows.Scan(scanArgs...)
+		if err != nil {
+			return "", err
+		}
 
-	var setQuery []string
-	for i, k := range keys {
-		recordValue := recordMap[k]
-		if info.ByName(k).SerializeAsJSON {
-			recordValue = jsonSerializable{
-				DriverName: dialect.DriverName(),
-				Attr:       recordValue,
+		fields := make([]string, len(values))
+		for i, value := range values {
+			switch v := value.(type) {
+			case nil:
+				fields[i] = "NULL"
+			case []byte:
+				fields[i] = string(v)
+			default:
+				fields[i] = fmt.Sprint(v)
 			}
 		}
-		args[i] = recordValue
-		setQuery = append(setQuery, fmt.Sprintf(
-			"%s = %s",
-			dialect.Escape(k),
-			dialect.Placeholder(i),
-		))
+		lines = append(lines, strings.Join(fields, "\t"))
 	}
 
-	query = fmt.Sprintf(
-		"UPDATE %s SET %s WHERE %s",
-		dialect.Escape(tableName),
-		strings.Join(setQuery, ", "),
-		strings.Join(whereQuery, ", "),
-	)
+	if rows.Err() != nil {
+		return "", rows.Err()
+	}
 
-	return query, args, nil
-}
+	if err := rows.Close(); err != nil {
+		return "", err
+	}
 
-// Exec just runs an SQL command on the database returning no rows.
-func (c DB) Exec(ctx context.Context, query string, params ...interface{}) (Result, error) {
-	return c.db.ExecContext(ctx, query, params...)
+	return strings.Join(lines, "\n"), nil
 }
 
 // Transaction just runs an SQL command on the database returning no rows.
-func (c DB) Transaction(ctx context.Context, fn func(Provider) error) error {
+//
+// If the DB has a RetryPolicy and the transaction fails with a
+// retryable error, e.g. a deadlock, the fn is called again in a
+// new transaction until it succeeds or the attempts are exhausted.
+//
+// If the Commit itself fails it is only retried for deadlocks and
+// serialization failures, which are always rolled back, since after
+// other errors, e.g. a lost connection, the commit might have been
+// applied. DBs returned by Idempotent also retry these errors.
+func (c DB) Transaction(ctx context.Context, fn func(Provider) error) (err error) {
+	ctx, finish := c.observe(ctx, "transaction", "")
+	defer func() { finish(err) }()
+
 	switch txBeginner := c.db.(type) {
 	case Tx:
 		return fn(c)
+	case TxBeginner:
+		var failedOnCommit bool
+		failedOnCommit, err = c.runTransaction(ctx, txBeginner, fn)
+		for attempt := 1; err != nil && c.shouldRetryTransaction(attempt, err, failedOnCommit); attempt++ {
+			if !sleepContext(ctx, c.retryPolicy.delay(attempt)) {
+				break
+			}
+			failedOnCommit, err = c.runTransaction(ctx, txBeginner, fn)
+		}
+		return err
+
+	default:
+		return fmt.Errorf("can't start transaction: The DBAdapter doesn't implement the TxBegginner interface")
+	}
+}
+
+// shouldRetryTransaction reports if a transaction that failed
+// with the input error should run again, see Transaction.
+func (c DB) shouldRetryTransaction(attempt int, err error, failedOnCommit bool) bool {
+	if failedOnCommit && !c.idempotentWrites && !isDeadlockError(err) && !isSerializationError(err) {
+		return false
+	}
+	return c.retryPolicy.shouldRetry(attempt, err)
+}
+
+// runTransaction runs the fn inside a new transaction and
+// reports if it failed while committing the transaction.
+func (c DB) runTransaction(
+	ctx context.Context,
+	txBeginner TxBeginner,
+	fn func(Provider) error,
+) (failedOnCommit bool, err error) {
+	tx, err := txBeginner.BeginTx(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			rollbackErr := tx.Rollback(ctx)
+			if rollbackErr != nil {
+				r = errors.Wrap(rollbackErr,
+					fmt.Sprintf("unable to rollback after panic with value: %v", r),
+				)
+			}
+			panic(r)
+		}
+	}()
+
+	dbCopy := c
+	dbCopy.db = tx
+
+	err = fn(dbCopy)
+	if err != nil {
+		rollbackErr := tx.Rollback(ctx)
+		if rollbackErr != nil {
+			err = errors.Wrap(rollbackErr,
+				fmt.Sprintf("unable to rollback after error: %s", err.Error()),
+			)
+		}
+		return false, err
+	}
+
+	err = wrapDeadlockError(tx.Commit(ctx))
+	return err != nil, err
+}
+
+// TransactionRollback works like Transaction but the transaction is
+// always rolled back, even if fn returns no error, e.g.:
+//
+//	err := c.TransactionRollback(ctx, func(p ksql.Provider) error {
+//		// Only visible inside this function:
+//		return p.Insert(ctx, UsersTable, &user)
+//	})
+//
+// It is meant as a testing aid for running real write operations
+// without changing the database, and the error returned by fn, if
+// any, is returned. Since the changes are discarded it can't be
+// used inside another transaction.
+func (c DB) TransactionRollback(ctx context.Context, fn func(Provider) error) (err error) {
+	ctx, finish := c.observe(ctx, "transaction_rollback", "")
+	defer func() { finish(err) }()
+
+	switch txBeginner := c.db.(type) {
+	case Tx:
+		return fmt.Errorf("ksql: TransactionRollback can't be used inside a transaction")
 	case TxBeginner:
 		tx, err := txBeginner.BeginTx(ctx)
 		if err != nil {
@@ -873,23 +3229,42 @@ func (c DB) Transaction(ctx context.Context, fn func(Provider) error) error {
 		dbCopy.db = tx
 
 		err = fn(dbCopy)
-		if err != nil {
-			rollbackErr := tx.Rollback(ctx)
-			if rollbackErr != nil {
-				err = errors.Wrap(rollbackErr,
+
+		rollbackErr := tx.Rollback(ctx)
+		if rollbackErr != nil {
+			if err != nil {
+				return errors.Wrap(rollbackErr,
 					fmt.Sprintf("unable to rollback after error: %s", err.Error()),
 				)
 			}
-			return err
+			return rollbackErr
 		}
 
-		return tx.Commit(ctx)
+		return err
 
 	default:
 		return fmt.Errorf("can't start transaction: The DBAdapter doesn't implement the TxBegginner interface")
 	}
 }
 
+// Stats returns the statistics of the connection pool used by the DB,
+// e.g. the number of open, idle and in use connections, which
+// is useful for monitoring:
+//
+//	stats, err := c.Stats()
+//	fmt.Println(stats.OpenConnections, stats.Idle, stats.WaitCount)
+//
+// It returns an error if the DBAdapter doesn't implement the StatsGetter
+// interface, which is also the case inside transactions.
+func (c DB) Stats() (sql.DBStats, error) {
+	statsGetter, ok := c.db.(StatsGetter)
+	if !ok {
+		return sql.DBStats{}, fmt.Errorf("ksql: can't get the pool stats: the DBAdapter doesn't implement the StatsGetter interface")
+	}
+
+	return statsGetter.Stats(), nil
+}
+
 type nopScanner struct{}
 
 var nopScannerValue = reflect.ValueOf(&nopScanner{}).Interface()
@@ -898,14 +3273,13 @@ func (nopScanner) Scan(value interface{}) error {
 	return nil
 }
 
-func scanRows(dialect Dialect, rows Rows, record interface{}) error {
+func (c DB) scanRows(rows Rows, record interface{}) error {
 	v := reflect.ValueOf(record)
 	t := v.Type()
-	return scanRowsFromType(dialect, rows, record, t, v)
+	return c.scanRowsFromType(rows, record, t, v)
 }
 
-func scanRowsFromType(
-	dialect Dialect,
+func (c DB) scanRowsFromType(
 	rows Rows,
 	record interface{},
 	t reflect.Type,
@@ -922,17 +3296,19 @@ func scanRowsFromType(
 		return fmt.Errorf("ksql: expected record to be a pointer to struct, but got: %T", record)
 	}
 
-	info, err := structs.GetTagInfo(t)
+	info, err := c.getTagInfo(t)
 	if err != nil {
 		return err
 	}
 
 	var scanArgs []interface{}
+	var nullableArgs []nullableScanArg
+	var fields []reflect.StructField
 	if info.IsNestedStruct {
 		// This version is positional meaning that it expect the arguments
 		// to follow an specific order. It's ok because we don't allow the
 		// user to type the "SELECT" part of the query for nested ksqltest.
-		scanArgs, err = getScanArgsForNestedStructs(dialect, rows, t, v, info)
+		scanArgs, nullableArgs, fields, err = c.getScanArgsForNestedStructs(rows, t, v, info)
 		if err != nil {
 			return err
 		}
@@ -943,70 +3319,254 @@ func scanRowsFromType(
 		}
 		// Since this version uses the names of the columns it works
 		// with any order of attributes/columns.
-		scanArgs = getScanArgsFromNames(dialect, names, v, info)
+		scanArgs, nullableArgs, fields, err = c.getScanArgsFromNames(names, v, info)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = rows.Scan(scanArgs...)
+	if err != nil {
+		return newScanError(rows, scanArgs, fields, err)
+	}
+
+	for _, arg := range nullableArgs {
+		arg.fill()
+	}
+
+	if c.location != nil {
+		return convertTimesToLocation(v, info, c.location, c.getColumnResolver())
 	}
 
-	return rows.Scan(scanArgs...)
+	return nil
 }
 
-func getScanArgsForNestedStructs(dialect Dialect, rows Rows, t reflect.Type, v reflect.Value, info structs.StructInfo) ([]interface{}, error) {
+func (c DB) getScanArgsForNestedStructs(
+	rows Rows,
+	t reflect.Type,
+	v reflect.Value,
+	info structs.StructInfo,
+) ([]interface{}, []nullableScanArg, []reflect.StructField, error) {
 	scanArgs := []interface{}{}
+	nullableArgs := []nullableScanArg{}
+	fields := []reflect.StructField{}
 	for i := 0; i < v.NumField(); i++ {
 		if !info.ByIndex(i).Valid {
 			continue
 		}
 
-		// TODO(vingarcia00): Handle case where type is pointer
-		nestedStructInfo, err := structs.GetTagInfo(t.Field(i).Type)
+		nestedStructValue := v.Field(i)
+		if nestedStructValue.Kind() == reflect.Ptr {
+			// Nil pointers to nested structs are allocated before scanning:
+			if nestedStructValue.IsNil() {
+				nestedStructValue.Set(reflect.New(nestedStructValue.Type().Elem()))
+			}
+			nestedStructValue = nestedStructValue.Elem()
+		}
+
+		nestedStructInfo, err := c.getTagInfo(nestedStructValue.Type())
 		if err != nil {
-			return nil, err
+			return nil, nil, nil, err
 		}
 
-		nestedStructValue := v.Field(i)
 		for j := 0; j < nestedStructValue.NumField(); j++ {
 			fieldInfo := nestedStructInfo.ByIndex(j)
 			if !fieldInfo.Valid {
 				continue
 			}
 
-			valueScanner := nopScannerValue
-			if fieldInfo.Valid {
-				valueScanner = nestedStructValue.Field(fieldInfo.Index).Addr().Interface()
-				if fieldInfo.SerializeAsJSON {
-					valueScanner = &jsonSerializable{
-						DriverName: dialect.DriverName(),
-						Attr:       valueScanner,
-					}
-				}
+			valueScanner, nullableArg := c.getScanArgForField(nestedStructValue.Field(fieldInfo.Index), fieldInfo)
+			if nullableArg != nil {
+				nullableArgs = append(nullableArgs, *nullableArg)
 			}
 
 			scanArgs = append(scanArgs, valueScanner)
+
+			field := nestedStructValue.Type().Field(fieldInfo.Index)
+			field.Name = t.Field(i).Name + "." + field.Name
+			fields = append(fields, field)
 		}
 	}
 
-	return scanArgs, nil
+	return scanArgs, nullableArgs, fields, nil
 }
 
-func getScanArgsFromNames(dialect Dialect, names []string, v reflect.Value, info structs.StructInfo) []interface{} {
+func (c DB) getScanArgsFromNames(
+	names []string,
+	v reflect.Value,
+	info structs.StructInfo,
+) ([]interface{}, []nullableScanArg, []reflect.StructField, error) {
 	scanArgs := []interface{}{}
+	nullableArgs := []nullableScanArg{}
+	fields := []reflect.StructField{}
+	var unmappedColumns []string
 	for _, name := range names {
+		if c.columnPrefix != "" && !strings.HasPrefix(name, c.columnPrefix) {
+			// Set by QueryOneWithPrefix, the other columns are just ignored:
+			scanArgs = append(scanArgs, nopScannerValue)
+			fields = append(fields, reflect.StructField{})
+			continue
+		}
+		name = strings.TrimPrefix(name, c.columnPrefix)
+
 		fieldInfo := info.ByName(name)
+		if !fieldInfo.Valid && c.caseInsensitiveColumns {
+			fieldInfo = info.ByNameCaseInsensitive(name)
+		}
 
 		valueScanner := nopScannerValue
+		var field reflect.StructField
 		if fieldInfo.Valid {
-			valueScanner = v.Field(fieldInfo.Index).Addr().Interface()
-			if fieldInfo.SerializeAsJSON {
-				valueScanner = &jsonSerializable{
-					DriverName: dialect.DriverName(),
-					Attr:       valueScanner,
-				}
+			var nullableArg *nullableScanArg
+			valueScanner, nullableArg = c.getScanArgForField(v.Field(fieldInfo.Index), fieldInfo)
+			if nullableArg != nil {
+				nullableArgs = append(nullableArgs, *nullableArg)
 			}
+			field = v.Type().Field(fieldInfo.Index)
+		} else if c.strictColumns {
+			unmappedColumns = append(unmappedColumns, name)
 		}
 
 		scanArgs = append(scanArgs, valueScanner)
+		fields = append(fields, field)
+	}
+
+	if len(unmappedColumns) > 0 {
+		return nil, nil, nil, fmt.Errorf("ksql: unmapped columns: %v", unmappedColumns)
+	}
+
+	return scanArgs, nullableArgs, fields, nil
+}
+
+// getScanArgForField returns the value that should be passed to
+// rows.Scan() for filling the input field.
+//
+// When the nullAsZero option is enabled non-pointer fields are scanned
+// through a pointer intermediary so NULL values don't cause errors,
+// in this case a nullableScanArg is also returned and should be
+// filled after the scan.
+func (c DB) getScanArgForField(field reflect.Value, fieldInfo *structs.FieldInfo) (interface{}, *nullableScanArg) {
+	valueScanner := field.Addr().Interface()
+	if fieldInfo.SerializeAsJSON {
+		return &jsonSerializable{
+			DriverName: c.dialect.DriverName(),
+			Attr:       valueScanner,
+		}, nil
+	}
+
+	if fieldInfo.UnixTimestampUnit != 0 {
+		return &unixTimestamp{
+			Unit: fieldInfo.UnixTimestampUnit,
+			Attr: valueScanner,
+		}, nil
+	}
+
+	if !c.nullAsZero || !isNonNullableType(field.Type()) {
+		return valueScanner, nil
+	}
+
+	ptr := reflect.New(reflect.PtrTo(field.Type()))
+	return ptr.Interface(), &nullableScanArg{
+		field: field,
+		ptr:   ptr,
 	}
+}
+
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// convertTimesToLocation converts all the time.Time and *time.Time
+// attributes of the input struct (and of its nested structs, including
+// the non-nil pointers to nested structs) to loc.
+func convertTimesToLocation(
+	v reflect.Value,
+	info structs.StructInfo,
+	loc *time.Location,
+	resolver *structs.ColumnResolver,
+) error {
+	for i := 0; i < v.NumField(); i++ {
+		if !info.ByIndex(i).Valid {
+			continue
+		}
+
+		field := v.Field(i)
+		switch {
+		case field.Type() == timeType:
+			field.Set(reflect.ValueOf(field.Interface().(time.Time).In(loc)))
+		case field.Type() == reflect.PtrTo(timeType):
+			if !field.IsNil() {
+				field.Elem().Set(reflect.ValueOf(field.Elem().Interface().(time.Time).In(loc)))
+			}
+		case info.IsNestedStruct && field.Kind() == reflect.Struct,
+			info.IsNestedStruct && field.Kind() == reflect.Ptr && field.Type().Elem().Kind() == reflect.Struct:
+			if field.Kind() == reflect.Ptr {
+				if field.IsNil() {
+					continue
+				}
+				field = field.Elem()
+			}
+
+			nestedInfo, err := structs.GetTagInfoWithResolver(field.Type(), resolver)
+			if err != nil {
+				return err
+			}
+			err = convertTimesToLocation(field, nestedInfo, loc, resolver)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// convertParamsToLocation converts the time values on the
+// input params to the location configured on the DB, if any.
+func (c DB) convertParamsToLocation(params []interface{}) {
+	if c.location == nil {
+		return
+	}
+
+	for i, param := range params {
+		switch value := param.(type) {
+		case time.Time:
+			params[i] = value.In(c.location)
+		case *time.Time:
+			if value != nil {
+				params[i] = value.In(c.location)
+			}
+		}
+	}
+}
+
+// isNonNullableType returns true for the types that can't
+// represent NULL values, e.g. int, string and time.Time.
+func isNonNullableType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		return false
+	}
+
+	return !reflect.PtrTo(t).Implements(scannerType)
+}
+
+// nullableScanArg keeps the intermediary pointer used for
+// scanning a field that doesn't accept NULL values.
+type nullableScanArg struct {
+	field reflect.Value
+	ptr   reflect.Value
+}
 
-	return scanArgs
+// fill copies the scanned value into the field or
+// sets it to its zero value if NULL was scanned.
+func (n nullableScanArg) fill() {
+	value := n.ptr.Elem()
+	if value.IsNil() {
+		n.field.Set(reflect.Zero(n.field.Type()))
+		return
+	}
+	n.field.Set(value.Elem())
 }
 
 func buildDeleteQuery(
@@ -1045,18 +3605,48 @@ func getFirstToken(s string) string {
 	return token.String()
 }
 
+// buildSelectPrefixIfOmitted generates the SELECT part of the query
+// if the user omitted it and started the query with the FROM clause.
+func (c DB) buildSelectPrefixIfOmitted(
+	query string,
+	structType reflect.Type,
+	info structs.StructInfo,
+) (string, error) {
+	firstToken := strings.ToUpper(getFirstToken(query))
+	if info.IsNestedStruct && firstToken == "SELECT" {
+		// This error check is necessary, since if we can't build the select part of the query this feature won't work.
+		return "", fmt.Errorf("can't generate SELECT query for nested struct: when using this feature omit the SELECT part of the query")
+	}
+
+	if firstToken == "FROM" {
+		selectPrefix, err := buildSelectQuery(c.dialect, structType, info, selectQueryCache[c.dialect.DriverName()], c.getColumnResolver())
+		if err != nil {
+			return "", err
+		}
+		query = selectPrefix + query
+	}
+
+	return query, nil
+}
+
 func buildSelectQuery(
 	dialect Dialect,
 	structType reflect.Type,
 	info structs.StructInfo,
-	selectQueryCache map[reflect.Type]string,
+	selectQueryCache map[selectQueryCacheKey]string,
+	resolver *structs.ColumnResolver,
 ) (query string, err error) {
-	if selectQuery, found := selectQueryCache[structType]; found {
+	cacheKey := selectQueryCacheKey{structType: structType, resolver: resolver}
+
+	selectQueryCacheMutex.RLock()
+	selectQuery, found := selectQueryCache[cacheKey]
+	selectQueryCacheMutex.RUnlock()
+	if found {
 		return selectQuery, nil
 	}
 
 	if info.IsNestedStruct {
-		query, err = buildSelectQueryForNestedStructs(dialect, structType, info)
+		query, err = buildSelectQueryForNestedStructs(dialect, structType, info, resolver)
 		if err != nil {
 			return "", err
 		}
@@ -1064,7 +3654,18 @@ func buildSelectQuery(
 		query = buildSelectQueryForPlainStructs(dialect, structType, info)
 	}
 
-	selectQueryCache[structType] = query
+	selectQueryCacheMutex.Lock()
+	defer selectQueryCacheMutex.Unlock()
+
+	if len(selectQueryCache) >= maxSelectQueryCacheSize {
+		// Since the map iteration order is random this evicts a random entry:
+		for k := range selectQueryCache {
+			delete(selectQueryCache, k)
+			break
+		}
+	}
+
+	selectQueryCache[cacheKey] = query
 	return query, nil
 }
 
@@ -1090,6 +3691,7 @@ func buildSelectQueryForNestedStructs(
 	dialect Dialect,
 	structType reflect.Type,
 	info structs.StructInfo,
+	resolver *structs.ColumnResolver,
 ) (string, error) {
 	var fields []string
 	for i := 0; i < structType.NumField(); i++ {
@@ -1100,6 +3702,9 @@ func buildSelectQueryForNestedStructs(
 
 		nestedStructName := nestedStructInfo.Name
 		nestedStructType := structType.Field(i).Type
+		if nestedStructType.Kind() == reflect.Ptr {
+			nestedStructType = nestedStructType.Elem()
+		}
 		if nestedStructType.Kind() != reflect.Struct {
 			return "", fmt.Errorf(
 				"expected nested struct with `tablename:\"%s\"` to be a kind of Struct, but got %v",
@@ -1107,12 +3712,12 @@ func buildSelectQueryForNestedStructs(
 			)
 		}
 
-		nestedStructTagInfo, err := structs.GetTagInfo(nestedStructType)
+		nestedStructTagInfo, err := structs.GetTagInfoWithResolver(nestedStructType, resolver)
 		if err != nil {
 			return "", err
 		}
 
-		for j := 0; j < structType.Field(i).Type.NumField(); j++ {
+		for j := 0; j < nestedStructType.NumField(); j++ {
 			fieldInfo := nestedStructTagInfo.ByIndex(j)
 			if !fieldInfo.Valid {
 				continue
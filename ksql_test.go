@@ -1,10 +1,20 @@
 package ksql
 
 import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/ditointernet/go-assert"
 
+	"github.com/vingarcia/ksql/internal/structs"
 	tt "github.com/vingarcia/ksql/internal/testtools"
 )
 
@@ -40,3 +50,1477 @@ func TestNewAdapterWith(t *testing.T) {
 		assert.NotEqual(t, nil, err)
 	})
 }
+
+func TestEmptyTableName(t *testing.T) {
+	db, err := NewWithAdapter(DBAdapter(nil), "sqlite3")
+	tt.AssertNoErr(t, err)
+
+	ctx := context.Background()
+	for _, table := range []Table{NewTable(""), {}} {
+		err := db.Insert(ctx, table, &struct {
+			ID   int    `ksql:"id"`
+			Name string `ksql:"name"`
+		}{Name: "fake-name"})
+		tt.AssertErrContains(t, err, "can't insert", "no table name configured")
+
+		err = db.Patch(ctx, table, &struct {
+			ID   int    `ksql:"id"`
+			Name string `ksql:"name"`
+		}{ID: 1, Name: "fake-name"})
+		tt.AssertErrContains(t, err, "can't update", "no table name configured")
+
+		err = db.Delete(ctx, table, 1)
+		tt.AssertErrContains(t, err, "can't delete", "no table name configured")
+	}
+}
+
+func TestBuildInsertQuery(t *testing.T) {
+	type record struct {
+		ID    int    `ksql:"id"`
+		Name  string `ksql:"name"`
+		Age   int    `ksql:"age"`
+		Email string `ksql:"email"`
+		Score int    `ksql:"score"`
+	}
+
+	t.Run("should generate the same query on every call", func(t *testing.T) {
+		dialect := supportedDialects["postgres"]
+		table := NewTable("records")
+		r := &record{Name: "fake-name", Age: 42, Email: "fake@email.com", Score: 7}
+		info, err := structs.GetTagInfo(reflect.TypeOf(r).Elem())
+		tt.AssertNoErr(t, err)
+
+		for i := 0; i < 100; i++ {
+			query, params, _, err := buildInsertQuery(dialect, table, dialect.InsertMethod(), reflect.TypeOf(r), reflect.ValueOf(r), info, r, nil)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, query, `INSERT INTO "records" ("name", "age", "email", "score") VALUES ($1, $2, $3, $4) RETURNING "id"`)
+			tt.AssertEqual(t, params, []interface{}{"fake-name", 42, "fake@email.com", 7})
+		}
+	})
+
+	t.Run("should ignore readonly columns", func(t *testing.T) {
+		type recordWithReadOnly struct {
+			ID       int    `ksql:"id"`
+			Name     string `ksql:"name"`
+			FullName string `ksql:"full_name,readonly"`
+		}
+
+		dialect := supportedDialects["postgres"]
+		r := &recordWithReadOnly{Name: "fake-name", FullName: "fake-full-name"}
+		info, err := structs.GetTagInfo(reflect.TypeOf(r).Elem())
+		tt.AssertNoErr(t, err)
+
+		query, params, _, err := buildInsertQuery(dialect, NewTable("records"), dialect.InsertMethod(), reflect.TypeOf(r), reflect.ValueOf(r), info, r, nil)
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, query, `INSERT INTO "records" ("name") VALUES ($1) RETURNING "id"`)
+		tt.AssertEqual(t, params, []interface{}{"fake-name"})
+	})
+}
+
+func TestBuildUpdateQuery(t *testing.T) {
+	type record struct {
+		ID    int    `ksql:"id"`
+		Name  string `ksql:"name"`
+		Age   int    `ksql:"age"`
+		Email string `ksql:"email"`
+		Score int    `ksql:"score"`
+	}
+
+	t.Run("should generate the same query on every call", func(t *testing.T) {
+		dialect := supportedDialects["postgres"]
+		r := record{ID: 1, Name: "fake-name", Age: 42, Email: "fake@email.com", Score: 7}
+		info, err := structs.GetTagInfo(reflect.TypeOf(r))
+		tt.AssertNoErr(t, err)
+
+		for i := 0; i < 100; i++ {
+			query, params, err := buildUpdateQuery(dialect, "records", info, r, nil, "id")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, query, `UPDATE "records" SET "name" = $1, "age" = $2, "email" = $3, "score" = $4 WHERE "id" = $5`)
+			tt.AssertEqual(t, params, []interface{}{"fake-name", 42, "fake@email.com", 7, 1})
+		}
+	})
+
+	t.Run("should AND the conditions of composite keys", func(t *testing.T) {
+		dialect := supportedDialects["postgres"]
+		r := record{ID: 1, Name: "fake-name", Age: 42, Email: "fake@email.com", Score: 7}
+		info, err := structs.GetTagInfo(reflect.TypeOf(r))
+		tt.AssertNoErr(t, err)
+
+		query, params, err := buildUpdateQuery(dialect, "records", info, r, nil, "id", "email")
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, query, `UPDATE "records" SET "name" = $1, "age" = $2, "score" = $3 WHERE "id" = $4 AND "email" = $5`)
+		tt.AssertEqual(t, params, []interface{}{"fake-name", 42, 7, 1, "fake@email.com"})
+	})
+
+	t.Run("should ignore readonly columns", func(t *testing.T) {
+		type recordWithReadOnly struct {
+			ID       int    `ksql:"id,readonly"`
+			Name     string `ksql:"name"`
+			FullName string `ksql:"full_name,readonly"`
+		}
+
+		dialect := supportedDialects["postgres"]
+		r := recordWithReadOnly{ID: 1, Name: "fake-name", FullName: "fake-full-name"}
+		info, err := structs.GetTagInfo(reflect.TypeOf(r))
+		tt.AssertNoErr(t, err)
+
+		query, params, err := buildUpdateQuery(dialect, "records", info, r, nil, "id")
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, query, `UPDATE "records" SET "name" = $1 WHERE "id" = $2`)
+		tt.AssertEqual(t, params, []interface{}{"fake-name", 1})
+	})
+
+	t.Run("should report error if there are no attributes to update", func(t *testing.T) {
+		type partialRecord struct {
+			ID   int     `ksql:"id"`
+			Name *string `ksql:"name"`
+		}
+
+		dialect := supportedDialects["postgres"]
+		r := partialRecord{ID: 1}
+		info, err := structs.GetTagInfo(reflect.TypeOf(r))
+		tt.AssertNoErr(t, err)
+
+		_, _, err = buildUpdateQuery(dialect, "records", info, r, nil, "id")
+		tt.AssertEqual(t, errors.Is(err, ErrNoFieldsToUpdate), true)
+		tt.AssertErrContains(t, err, "partialRecord")
+	})
+
+	t.Run("should fully dereference pointers to pointers", func(t *testing.T) {
+		type recordWithPtrs struct {
+			ID    int      `ksql:"id"`
+			Name  **string `ksql:"name"`
+			Age   **int    `ksql:"age"`
+			Email *string  `ksql:"email"`
+		}
+
+		dialect := supportedDialects["postgres"]
+		name := "fake-name"
+		namePtr := &name
+		var nilAge *int
+		r := recordWithPtrs{ID: 1, Name: &namePtr, Age: &nilAge}
+		info, err := structs.GetTagInfo(reflect.TypeOf(r))
+		tt.AssertNoErr(t, err)
+
+		query, params, err := buildUpdateQuery(dialect, "records", info, r, nil, "id")
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, query, `UPDATE "records" SET "name" = $1 WHERE "id" = $2`)
+		tt.AssertEqual(t, params, []interface{}{"fake-name", 1})
+	})
+
+	t.Run("should report an error for unexported attributes tagged with ksql", func(t *testing.T) {
+		type recordWithUnexported struct {
+			ID   int    `ksql:"id"`
+			name string `ksql:"name"`
+		}
+
+		dialect := supportedDialects["postgres"]
+		r := recordWithUnexported{ID: 1, name: "fake-name"}
+		info, err := structs.GetTagInfo(reflect.TypeOf(r))
+		tt.AssertNoErr(t, err)
+
+		_, _, err = buildUpdateQuery(dialect, "records", info, r, nil, "id")
+		tt.AssertErrContains(t, err, "name", "not exported")
+	})
+}
+
+func TestBuildMergeQuery(t *testing.T) {
+	type record struct {
+		ID    int    `ksql:"id"`
+		Name  string `ksql:"name"`
+		Email string `ksql:"email"`
+	}
+
+	r := &record{ID: 1, Name: "fake-name", Email: "fake@email.com"}
+	info, err := structs.GetTagInfo(reflect.TypeOf(r).Elem())
+	tt.AssertNoErr(t, err)
+
+	tests := []struct {
+		driver        string
+		expectedQuery string
+	}{
+		{
+			driver:        "postgres",
+			expectedQuery: `INSERT INTO "records" ("id", "name", "email") VALUES ($1, $2, $3) ON CONFLICT ("email") DO UPDATE SET "name" = EXCLUDED."name"`,
+		},
+		{
+			driver:        "sqlite3",
+			expectedQuery: "INSERT INTO `records` (`id`, `name`, `email`) VALUES (?, ?, ?) ON CONFLICT (`email`) DO UPDATE SET `name` = EXCLUDED.`name`",
+		},
+		{
+			driver:        "mysql",
+			expectedQuery: "INSERT INTO `records` (`id`, `name`, `email`) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE `name` = VALUES(`name`)",
+		},
+		{
+			driver: "sqlserver",
+			expectedQuery: `MERGE INTO [records] WITH (HOLDLOCK) AS ksql_target` +
+				` USING (VALUES (@p1, @p2, @p3)) AS ksql_source ([id], [name], [email]) ON ksql_target.[email] = ksql_source.[email]` +
+				` WHEN MATCHED THEN UPDATE SET [name] = ksql_source.[name]` +
+				` WHEN NOT MATCHED THEN INSERT ([id], [name], [email]) VALUES (ksql_source.[id], ksql_source.[name], ksql_source.[email]);`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.driver, func(t *testing.T) {
+			dialect := supportedDialects[test.driver]
+			query, params, err := buildMergeQuery(dialect, NewTable("records"), reflect.TypeOf(r), info, r, []string{"email"}, []string{"name"}, nil)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, query, test.expectedQuery)
+			tt.AssertEqual(t, params, []interface{}{1, "fake-name", "fake@email.com"})
+		})
+	}
+
+	t.Run("should report error for invalid columns", func(t *testing.T) {
+		dialect := supportedDialects["postgres"]
+		table := NewTable("records")
+
+		_, _, err := buildMergeQuery(dialect, table, reflect.TypeOf(r), info, r, nil, []string{"name"}, nil)
+		tt.AssertErrContains(t, err, "conflict column")
+
+		_, _, err = buildMergeQuery(dialect, table, reflect.TypeOf(r), info, r, []string{"email"}, nil, nil)
+		tt.AssertErrContains(t, err, "update column")
+
+		_, _, err = buildMergeQuery(dialect, table, reflect.TypeOf(r), info, r, []string{"not_tagged"}, []string{"name"}, nil)
+		tt.AssertErrContains(t, err, "not_tagged", "not tagged")
+
+		_, _, err = buildMergeQuery(dialect, table, reflect.TypeOf(r), info, r, []string{"email"}, []string{"not_tagged"}, nil)
+		tt.AssertErrContains(t, err, "not_tagged", "not tagged")
+	})
+
+	t.Run("should require the columns to be set on sqlserver", func(t *testing.T) {
+		r := &record{Name: "fake-name", Email: "fake@email.com"}
+		_, _, err := buildMergeQuery(supportedDialects["sqlserver"], NewTable("records"), reflect.TypeOf(r), info, r, []string{"id"}, []string{"name"}, nil)
+		tt.AssertErrContains(t, err, "id", "must be set")
+	})
+}
+
+func TestBuildInsertIfNotExistsQuery(t *testing.T) {
+	type record struct {
+		ID    int    `ksql:"id"`
+		Name  string `ksql:"name"`
+		Email string `ksql:"email"`
+	}
+
+	r := &record{Name: "fake-name", Email: "fake@email.com"}
+	info, err := structs.GetTagInfo(reflect.TypeOf(r).Elem())
+	tt.AssertNoErr(t, err)
+
+	tests := []struct {
+		driver         string
+		condition      string
+		expectedQuery  string
+		expectedParams []interface{}
+	}{
+		{
+			driver:         "postgres",
+			condition:      "email = $1",
+			expectedQuery:  `INSERT INTO "records" ("name", "email") SELECT $2, $3 WHERE NOT EXISTS (SELECT 1 FROM "records" WHERE (email = $1))`,
+			expectedParams: []interface{}{"cond-email", "fake-name", "fake@email.com"},
+		},
+		{
+			driver:         "sqlite3",
+			condition:      "email = ?",
+			expectedQuery:  "INSERT INTO `records` (`name`, `email`) SELECT ?, ? WHERE NOT EXISTS (SELECT 1 FROM `records` WHERE (email = ?))",
+			expectedParams: []interface{}{"fake-name", "fake@email.com", "cond-email"},
+		},
+		{
+			driver:         "mysql",
+			condition:      "email = ?",
+			expectedQuery:  "INSERT INTO `records` (`name`, `email`) SELECT ?, ? FROM DUAL WHERE NOT EXISTS (SELECT 1 FROM `records` WHERE (email = ?))",
+			expectedParams: []interface{}{"fake-name", "fake@email.com", "cond-email"},
+		},
+		{
+			driver:         "sqlserver",
+			condition:      "email = @p1",
+			expectedQuery:  `INSERT INTO [records] ([name], [email]) SELECT @p2, @p3 WHERE NOT EXISTS (SELECT 1 FROM [records] WHERE (email = @p1))`,
+			expectedParams: []interface{}{"cond-email", "fake-name", "fake@email.com"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.driver, func(t *testing.T) {
+			dialect := supportedDialects[test.driver]
+			query, params, err := buildInsertIfNotExistsQuery(
+				dialect, NewTable("records"), reflect.TypeOf(r), info, r,
+				test.condition, []interface{}{"cond-email"},
+				nil,
+			)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, query, test.expectedQuery)
+			tt.AssertEqual(t, params, test.expectedParams)
+		})
+	}
+}
+
+func TestBuildChangedCondition(t *testing.T) {
+	type record struct {
+		ID   int               `ksql:"id"`
+		Name string            `ksql:"name"`
+		Meta map[string]string `ksql:"meta,json"`
+	}
+	info, err := structs.GetTagInfo(reflect.TypeOf(record{}))
+	tt.AssertNoErr(t, err)
+
+	tests := []struct {
+		driver            string
+		expectedCondition string
+	}{
+		{
+			driver:            "postgres",
+			expectedCondition: `"name" IS DISTINCT FROM $4 OR "meta" IS DISTINCT FROM $5`,
+		},
+		{
+			driver:            "sqlite3",
+			expectedCondition: "`name` IS NOT ? OR `meta` IS NOT ?",
+		},
+		{
+			driver:            "mysql",
+			expectedCondition: "NOT (`name` <=> ?) OR NOT (`meta` <=> CAST(? AS JSON))",
+		},
+		{
+			driver: "sqlserver",
+			expectedCondition: `([name] <> @p4 OR ([name] IS NULL AND @p4 IS NOT NULL) OR ([name] IS NOT NULL AND @p4 IS NULL))` +
+				` OR ([meta] <> @p5 OR ([meta] IS NULL AND @p5 IS NOT NULL) OR ([meta] IS NOT NULL AND @p5 IS NULL))`,
+		},
+	}
+	for _, test := range tests {
+		t.Run("should build the condition for "+test.driver, func(t *testing.T) {
+			condition, args := buildChangedCondition(
+				supportedDialects[test.driver],
+				info,
+				[]string{"name", "meta"},
+				[]interface{}{"fake-name", "fake-meta", 42},
+			)
+			tt.AssertEqual(t, condition, test.expectedCondition)
+			tt.AssertEqual(t, args, []interface{}{"fake-name", "fake-meta", 42, "fake-name", "fake-meta"})
+		})
+	}
+}
+
+func TestBuildWhereByExample(t *testing.T) {
+	t.Run("should use the non nil attributes in declaration order", func(t *testing.T) {
+		dialect := supportedDialects["postgres"]
+		name := "fake-name"
+		age := 42
+		query, params, err := buildWhereByExample(dialect, struct {
+			ID   *int    `ksql:"id"`
+			Name *string `ksql:"name"`
+			Age  *int    `ksql:"age"`
+		}{
+			Name: &name,
+			Age:  &age,
+		}, nil)
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, query, `"name" = $1 AND "age" = $2`)
+		tt.AssertEqual(t, params, []interface{}{"fake-name", 42})
+	})
+
+	t.Run("should include zero values of non pointer attributes", func(t *testing.T) {
+		dialect := supportedDialects["sqlite3"]
+		query, params, err := buildWhereByExample(dialect, struct {
+			Name string `ksql:"name"`
+			Age  int    `ksql:"age,omitempty"`
+		}{}, nil)
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, query, "`name` = ?")
+		tt.AssertEqual(t, params, []interface{}{""})
+	})
+
+	t.Run("should report error if no attributes are set", func(t *testing.T) {
+		dialect := supportedDialects["postgres"]
+		_, _, err := buildWhereByExample(dialect, struct {
+			Name *string `ksql:"name"`
+		}{}, nil)
+		tt.AssertErrContains(t, err, "no attributes set")
+	})
+
+	t.Run("should report error if the example is not a struct", func(t *testing.T) {
+		dialect := supportedDialects["postgres"]
+		_, _, err := buildWhereByExample(dialect, 42, nil)
+		tt.AssertErrContains(t, err, "expected example to be a struct", "int")
+	})
+
+	t.Run("should report error for json attributes", func(t *testing.T) {
+		dialect := supportedDialects["postgres"]
+		_, _, err := buildWhereByExample(dialect, struct {
+			Address map[string]interface{} `ksql:"address,json"`
+		}{
+			Address: map[string]interface{}{"country": "BR"},
+		}, nil)
+		tt.AssertErrContains(t, err, "json", "address")
+	})
+}
+
+func TestBuildCursorPageQuery(t *testing.T) {
+	t.Run("should load the first page when there is no cursor", func(t *testing.T) {
+		query, params := buildCursorPageQuery(supportedDialects["postgres"], `SELECT * FROM users WHERE age > $1;`, []interface{}{18}, "id", nil, 10)
+		tt.AssertEqual(t, query, `SELECT * FROM (SELECT * FROM users WHERE age > $1) AS ksql_page ORDER BY "id" LIMIT $2`)
+		tt.AssertEqual(t, params, []interface{}{18, 10})
+	})
+
+	t.Run("should filter by the cursor when it is set", func(t *testing.T) {
+		query, params := buildCursorPageQuery(supportedDialects["postgres"], `SELECT * FROM users WHERE age > $1`, []interface{}{18}, "id", 42, 10)
+		tt.AssertEqual(t, query, `SELECT * FROM (SELECT * FROM users WHERE age > $1) AS ksql_page WHERE "id" > $2 ORDER BY "id" LIMIT $3`)
+		tt.AssertEqual(t, params, []interface{}{18, 42, 10})
+	})
+
+	t.Run("should use OFFSET FETCH on sqlserver", func(t *testing.T) {
+		query, params := buildCursorPageQuery(supportedDialects["sqlserver"], `SELECT * FROM users`, nil, "id", 42, 10)
+		tt.AssertEqual(t, query, `SELECT * FROM (SELECT * FROM users) AS ksql_page WHERE [id] > @p1 ORDER BY [id] OFFSET 0 ROWS FETCH NEXT @p2 ROWS ONLY`)
+		tt.AssertEqual(t, params, []interface{}{42, 10})
+	})
+}
+
+func TestBuildLimitOneQuery(t *testing.T) {
+	tests := []struct {
+		driver   string
+		query    string
+		expected string
+	}{
+		{driver: "postgres", query: "SELECT * FROM users ORDER BY id", expected: "SELECT * FROM users ORDER BY id LIMIT 1"},
+		{driver: "mysql", query: "SELECT * FROM users", expected: "SELECT * FROM users LIMIT 1"},
+		{driver: "sqlserver", query: "SELECT * FROM users ORDER BY id", expected: "SELECT * FROM users ORDER BY id OFFSET 0 ROWS FETCH NEXT 1 ROWS ONLY"},
+		{driver: "sqlserver", query: "SELECT * FROM users", expected: "SELECT * FROM users ORDER BY (SELECT NULL) OFFSET 0 ROWS FETCH NEXT 1 ROWS ONLY"},
+	}
+	for _, test := range tests {
+		t.Run(test.driver+": "+test.query, func(t *testing.T) {
+			tt.AssertEqual(t, buildLimitOneQuery(supportedDialects[test.driver], test.query), test.expected)
+		})
+	}
+}
+
+func TestBuildScopesCondition(t *testing.T) {
+	t.Run("should use the dialect placeholders starting at the offset", func(t *testing.T) {
+		c := DB{dialect: supportedDialects["postgres"]}.
+			Where("tenant_id = ?", 42).
+			Where("deleted_at IS NULL").
+			Where("age BETWEEN ? AND ?", 18, 65)
+
+		query, params, err := c.buildScopesCondition(2)
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, query, `(tenant_id = $3) AND (deleted_at IS NULL) AND (age BETWEEN $4 AND $5)`)
+		tt.AssertEqual(t, params, []interface{}{42, 18, 65})
+	})
+
+	t.Run("should not share scopes between copies of the DB", func(t *testing.T) {
+		base := DB{dialect: supportedDialects["sqlite3"]}.Where("a = ?", 1)
+		c1 := base.Where("b = ?", 2)
+		c2 := base.Where("c = ?", 3)
+
+		query, _, err := c1.buildScopesCondition(0)
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, query, `(a = ?) AND (b = ?)`)
+
+		query, _, err = c2.buildScopesCondition(0)
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, query, `(a = ?) AND (c = ?)`)
+	})
+}
+
+func TestApplyScopesToQuery(t *testing.T) {
+	t.Run("should move the trailing ORDER BY outside of the subquery", func(t *testing.T) {
+		c := DB{dialect: supportedDialects["sqlserver"]}.Where("age > ?", 18)
+
+		query, params, err := c.applyScopesToQuery("SELECT * FROM users WHERE name <> @p1 ORDER BY name DESC;", []interface{}{"Alan"}, structs.StructInfo{})
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, query, `SELECT * FROM (SELECT * FROM users WHERE name <> @p1) AS ksql_scope WHERE (age > @p2) ORDER BY name DESC`)
+		tt.AssertEqual(t, params, []interface{}{"Alan", 18})
+	})
+
+	t.Run("should keep ORDER BY clauses followed by a FETCH inside the subquery", func(t *testing.T) {
+		c := DB{dialect: supportedDialects["sqlserver"]}.Where("age > ?", 18)
+
+		query, _, err := c.applyScopesToQuery("SELECT * FROM users ORDER BY name OFFSET 0 ROWS FETCH NEXT 10 ROWS ONLY", nil, structs.StructInfo{})
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, query, `SELECT * FROM (SELECT * FROM users ORDER BY name OFFSET 0 ROWS FETCH NEXT 10 ROWS ONLY) AS ksql_scope WHERE (age > @p1)`)
+	})
+}
+
+func TestStripTrailingOrderBy(t *testing.T) {
+	tests := []struct {
+		desc     string
+		query    string
+		expected string
+	}{
+		{
+			desc:     "should keep queries without ORDER BY",
+			query:    "SELECT * FROM users WHERE age > ?",
+			expected: "SELECT * FROM users WHERE age > ?",
+		},
+		{
+			desc:     "should remove a trailing ORDER BY",
+			query:    "SELECT * FROM users WHERE age > ? order by name, age DESC",
+			expected: "SELECT * FROM users WHERE age > ?",
+		},
+		{
+			desc:     "should keep ORDER BY clauses followed by LIMIT",
+			query:    "SELECT * FROM users ORDER BY name LIMIT 10",
+			expected: "SELECT * FROM users ORDER BY name LIMIT 10",
+		},
+		{
+			desc:     "should keep ORDER BY clauses followed by OFFSET",
+			query:    "SELECT * FROM users ORDER BY name OFFSET 0 ROWS FETCH NEXT 10 ROWS ONLY",
+			expected: "SELECT * FROM users ORDER BY name OFFSET 0 ROWS FETCH NEXT 10 ROWS ONLY",
+		},
+		{
+			desc:     "should ignore ORDER BY inside parenthesis and quotes",
+			query:    "SELECT * FROM (SELECT * FROM users ORDER BY id) AS u WHERE name = 'x ORDER BY y'",
+			expected: "SELECT * FROM (SELECT * FROM users ORDER BY id) AS u WHERE name = 'x ORDER BY y'",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			tt.AssertEqual(t, stripTrailingOrderBy(test.query), test.expected)
+		})
+	}
+}
+
+func TestAddDefaultOrderBy(t *testing.T) {
+	c := DB{defaultOrderBy: "id"}
+	tests := []struct {
+		desc     string
+		query    string
+		expected string
+	}{
+		{
+			desc:     "should add the ORDER BY to queries without one",
+			query:    "SELECT * FROM users WHERE age > ?;",
+			expected: "SELECT * FROM users WHERE age > ? ORDER BY id",
+		},
+		{
+			desc:     "should keep queries with ORDER BY",
+			query:    "SELECT * FROM users order by name",
+			expected: "SELECT * FROM users order by name",
+		},
+		{
+			desc:     "should keep queries with LIMIT",
+			query:    "SELECT * FROM users LIMIT 10",
+			expected: "SELECT * FROM users LIMIT 10",
+		},
+		{
+			desc:     "should ignore ORDER BY inside parenthesis and quotes",
+			query:    "SELECT * FROM (SELECT * FROM users ORDER BY name) AS u WHERE name <> 'ORDER BY'",
+			expected: "SELECT * FROM (SELECT * FROM users ORDER BY name) AS u WHERE name <> 'ORDER BY' ORDER BY id",
+		},
+		{
+			desc:     "should not match clauses inside other words",
+			query:    "SELECT limited FROM users",
+			expected: "SELECT limited FROM users ORDER BY id",
+		},
+		{
+			desc:     "should keep queries that are not SELECTs",
+			query:    "DELETE FROM users RETURNING id",
+			expected: "DELETE FROM users RETURNING id",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			tt.AssertEqual(t, c.addDefaultOrderBy(test.query), test.expected)
+		})
+	}
+
+	t.Run("should do nothing if no default is configured", func(t *testing.T) {
+		tt.AssertEqual(t, DB{}.addDefaultOrderBy("SELECT * FROM users"), "SELECT * FROM users")
+	})
+}
+
+func TestExpandQueryTemplate(t *testing.T) {
+	dialect := supportedDialects["postgres"]
+
+	t.Run("should quote the identifiers and keep the value placeholders", func(t *testing.T) {
+		query, err := expandQueryTemplate(dialect,
+			"SELECT ${column} FROM ${table} WHERE ${column} = $1 AND note <> '${column}'",
+			map[string]string{"table": "public.users", "column": "name"},
+		)
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, query, `SELECT "name" FROM "public"."users" WHERE "name" = $1 AND note <> '${column}'`)
+	})
+
+	t.Run("should report errors", func(t *testing.T) {
+		tests := []struct {
+			desc               string
+			tmpl               string
+			idents             map[string]string
+			expectErrToContain []string
+		}{
+			{
+				desc:               "invalid identifier",
+				tmpl:               "FROM ${table}",
+				idents:             map[string]string{"table": `users"; DROP TABLE users; --`},
+				expectErrToContain: []string{"${table}", "invalid identifier", "unexpected character"},
+			},
+			{
+				desc:               "too many identifier parts",
+				tmpl:               "FROM ${table}",
+				idents:             map[string]string{"table": "db.public.users"},
+				expectErrToContain: []string{"${table}", "db.public.users"},
+			},
+			{
+				desc:               "missing identifier",
+				tmpl:               "FROM ${table}",
+				idents:             map[string]string{},
+				expectErrToContain: []string{"missing identifier", "${table}"},
+			},
+			{
+				desc:               "unclosed placeholder",
+				tmpl:               "FROM ${table",
+				idents:             map[string]string{"table": "users"},
+				expectErrToContain: []string{"unclosed", "${table"},
+			},
+		}
+		for _, test := range tests {
+			t.Run(test.desc, func(t *testing.T) {
+				_, err := expandQueryTemplate(dialect, test.tmpl, test.idents)
+				tt.AssertErrContains(t, err, test.expectErrToContain...)
+			})
+		}
+	})
+}
+
+func TestBuildUpdateJSONFieldQuery(t *testing.T) {
+	table := NewTable("users")
+	idMap := map[string]interface{}{"id": 42}
+
+	t.Run("should merge nested keys with jsonb_set on postgres", func(t *testing.T) {
+		query, params, err := buildUpdateJSONFieldQuery(supportedDialects["postgres"], table, idMap, "doc", map[string]interface{}{
+			"name":    "Bia",
+			"removed": nil,
+			"address": map[string]interface{}{
+				"city": "Rio",
+			},
+		})
+		tt.AssertNoErr(t, err)
+
+		doc := `CASE jsonb_typeof("doc") WHEN 'object' THEN "doc" ELSE '{}'::jsonb END`
+		address := `CASE jsonb_typeof(("doc" -> $3::text)) WHEN 'object' THEN ("doc" -> $3::text) ELSE '{}'::jsonb END`
+		tt.AssertEqual(t, query, `UPDATE "users" SET "doc" = jsonb_set(((`+doc+` || $1::jsonb) - $2::text), ARRAY[$3::text], (`+address+` || $4::jsonb)) WHERE "id" = $5`)
+		tt.AssertEqual(t, params, []interface{}{`{"name":"Bia"}`, "removed", "address", `{"city":"Rio"}`, 42})
+	})
+
+	t.Run("should use JSON_MERGE_PATCH on mysql", func(t *testing.T) {
+		query, params, err := buildUpdateJSONFieldQuery(supportedDialects["mysql"], table, idMap, "doc", map[string]interface{}{
+			"name": "Bia",
+		})
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, query, "UPDATE `users` SET `doc` = JSON_MERGE_PATCH(COALESCE(`doc`, '{}'), ?) WHERE `id` = ?")
+		tt.AssertEqual(t, params, []interface{}{`{"name":"Bia"}`, 42})
+	})
+
+	t.Run("should report an error on sqlserver", func(t *testing.T) {
+		_, _, err := buildUpdateJSONFieldQuery(supportedDialects["sqlserver"], table, idMap, "doc", map[string]interface{}{
+			"name": "Bia",
+		})
+		tt.AssertErrContains(t, err, "UpdateJSONField", "not supported", "sqlserver")
+	})
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	t.Run("should double the delay on each attempt", func(t *testing.T) {
+		policy := RetryPolicy{BaseDelay: 10 * time.Millisecond}
+		tt.AssertEqual(t, policy.delay(1), 10*time.Millisecond)
+		tt.AssertEqual(t, policy.delay(2), 20*time.Millisecond)
+		tt.AssertEqual(t, policy.delay(4), 80*time.Millisecond)
+	})
+
+	t.Run("should limit the delay to the MaxDelay", func(t *testing.T) {
+		policy := RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: 30 * time.Millisecond}
+		tt.AssertEqual(t, policy.delay(2), 20*time.Millisecond)
+		tt.AssertEqual(t, policy.delay(3), 30*time.Millisecond)
+		tt.AssertEqual(t, policy.delay(100), 30*time.Millisecond)
+	})
+
+	t.Run("should randomize the delay with the jitter", func(t *testing.T) {
+		policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, Jitter: 0.2}
+		for i := 0; i < 100; i++ {
+			d := policy.delay(1)
+			tt.AssertEqual(t, d >= 80*time.Millisecond && d <= 100*time.Millisecond, true)
+		}
+	})
+}
+
+func TestIsTransientError(t *testing.T) {
+	tt.AssertEqual(t, IsTransientError(nil), false)
+	tt.AssertEqual(t, IsTransientError(errors.New("fake syntax error")), false)
+	tt.AssertEqual(t, IsTransientError(fmt.Errorf("read tcp: %w", syscall.ECONNRESET)), true)
+	tt.AssertEqual(t, IsTransientError(fakeSQLStateError{code: "40P01"}), true)
+	tt.AssertEqual(t, IsTransientError(fakeSQLStateError{code: "40001"}), true)
+	tt.AssertEqual(t, IsTransientError(fakeSQLStateError{code: "23505"}), false)
+}
+
+func TestBuildSelectQuery(t *testing.T) {
+	newStructType := func(i int) reflect.Type {
+		return reflect.StructOf([]reflect.StructField{{
+			Name: "Name",
+			Type: reflect.TypeOf(""),
+			Tag:  reflect.StructTag(fmt.Sprintf(`ksql:"name_%d"`, i)),
+		}})
+	}
+
+	t.Run("should keep the cache bounded when many types are created", func(t *testing.T) {
+		defer func(maxSize int) {
+			maxSelectQueryCacheSize = maxSize
+		}(maxSelectQueryCacheSize)
+		maxSelectQueryCacheSize = 100
+
+		dialect := supportedDialects["postgres"]
+		cache := map[selectQueryCacheKey]string{}
+		for i := 0; i < 1000; i++ {
+			structType := newStructType(i)
+			info, err := structs.GetTagInfo(structType)
+			tt.AssertNoErr(t, err)
+
+			query, err := buildSelectQuery(dialect, structType, info, cache, nil)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, query, fmt.Sprintf(`SELECT "name_%d" `, i))
+
+			tt.AssertEqual(t, len(cache) <= 100, true)
+		}
+
+		// The last type should still be cached:
+		_, found := cache[selectQueryCacheKey{structType: newStructType(999)}]
+		tt.AssertEqual(t, found, true)
+	})
+
+	t.Run("should be safe for concurrent use", func(t *testing.T) {
+		dialect := supportedDialects["postgres"]
+		cache := map[selectQueryCacheKey]string{}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					structType := newStructType(i*100 + j)
+					info, err := structs.GetTagInfo(structType)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+
+					_, err = buildSelectQuery(dialect, structType, info, cache, nil)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		tt.AssertEqual(t, len(cache), 1000)
+	})
+}
+
+func TestIsCompatibleColumnType(t *testing.T) {
+	tests := []struct {
+		desc       string
+		value      interface{}
+		isJSON     bool
+		columnType string
+		expected   bool
+	}{
+		{desc: "int and integer", value: 0, columnType: "integer", expected: true},
+		{desc: "uint and bigint", value: uint(0), columnType: "bigint", expected: true},
+		{desc: "int and text", value: 0, columnType: "text", expected: false},
+		{desc: "string and varchar", value: "", columnType: "character varying", expected: true},
+		{desc: "string and int", value: "", columnType: "int", expected: false},
+		{desc: "float and numeric", value: 0.0, columnType: "numeric", expected: true},
+		{desc: "bool and boolean", value: false, columnType: "boolean", expected: true},
+		{desc: "time and timestamp", value: time.Time{}, columnType: "timestamp with time zone", expected: true},
+		{desc: "time and int", value: time.Time{}, columnType: "int", expected: false},
+		{desc: "bytes and bytea", value: []byte{}, columnType: "bytea", expected: true},
+		{desc: "json map and jsonb", value: map[string]interface{}{}, isJSON: true, columnType: "jsonb", expected: true},
+		{desc: "json struct and int", value: struct{}{}, isJSON: true, columnType: "int", expected: false},
+		{desc: "unknown types", value: map[string]interface{}{}, columnType: "int", expected: true},
+		{desc: "untyped sqlite3 columns", value: 0, columnType: "", expected: true},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			tt.AssertEqual(t, isCompatibleColumnType(reflect.TypeOf(test.value), test.isJSON, test.columnType), test.expected)
+		})
+	}
+}
+
+func TestBuildBatchInsertQuery(t *testing.T) {
+	type record struct {
+		ID   int     `ksql:"id"`
+		Name string  `ksql:"name"`
+		Age  *int    `ksql:"age"`
+		Tags []byte  `ksql:"tags"`
+		Nick *string `ksql:"nick"`
+	}
+
+	t.Run("should build a multi-row INSERT ignoring unset IDs", func(t *testing.T) {
+		dialect := supportedDialects["postgres"]
+		table := NewTable("records")
+		info, err := structs.GetTagInfo(reflect.TypeOf(record{}))
+		tt.AssertNoErr(t, err)
+
+		age := 42
+		values := []reflect.Value{
+			reflect.ValueOf(record{Name: "foo", Age: &age}),
+			reflect.ValueOf(record{Name: "bar"}),
+		}
+		columns, err := getBatchInsertColumns(table, reflect.TypeOf(record{}), info, values)
+		tt.AssertNoErr(t, err)
+
+		query, params, err := buildBatchInsertQuery(dialect, table, columns, values)
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, query, `INSERT INTO "records" ("name", "age", "tags", "nick") VALUES ($1, $2, $3, $4), ($5, $6, $7, $8)`)
+		tt.AssertEqual(t, params, []interface{}{"foo", 42, []byte(nil), nil, "bar", nil, []byte(nil), nil})
+	})
+
+	t.Run("should fully dereference pointers to pointers", func(t *testing.T) {
+		type recordWithPtrs struct {
+			Name **string `ksql:"name"`
+			Age  **int    `ksql:"age"`
+		}
+
+		dialect := supportedDialects["postgres"]
+		table := NewTable("records")
+		info, err := structs.GetTagInfo(reflect.TypeOf(recordWithPtrs{}))
+		tt.AssertNoErr(t, err)
+
+		name := "foo"
+		namePtr := &name
+		var nilAge *int
+		values := []reflect.Value{
+			reflect.ValueOf(recordWithPtrs{Name: &namePtr, Age: &nilAge}),
+			reflect.ValueOf(recordWithPtrs{}),
+		}
+		columns, err := getBatchInsertColumns(table, reflect.TypeOf(recordWithPtrs{}), info, values)
+		tt.AssertNoErr(t, err)
+
+		query, params, err := buildBatchInsertQuery(dialect, table, columns, values)
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, query, `INSERT INTO "records" ("name", "age") VALUES ($1, $2), ($3, $4)`)
+		tt.AssertEqual(t, params, []interface{}{"foo", nil, nil, nil})
+	})
+
+	t.Run("should omit the omitempty columns not set on any record", func(t *testing.T) {
+		type recordWithOmitEmpty struct {
+			Name string `ksql:"name"`
+			Age  int    `ksql:"age,omitempty"`
+		}
+
+		dialect := supportedDialects["postgres"]
+		table := NewTable("records")
+		info, err := structs.GetTagInfo(reflect.TypeOf(recordWithOmitEmpty{}))
+		tt.AssertNoErr(t, err)
+
+		values := []reflect.Value{
+			reflect.ValueOf(recordWithOmitEmpty{Name: "foo"}),
+			reflect.ValueOf(recordWithOmitEmpty{Name: "bar"}),
+		}
+		columns, err := getBatchInsertColumns(table, reflect.TypeOf(recordWithOmitEmpty{}), info, values)
+		tt.AssertNoErr(t, err)
+
+		query, params, err := buildBatchInsertQuery(dialect, table, columns, values)
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, query, `INSERT INTO "records" ("name") VALUES ($1), ($2)`)
+		tt.AssertEqual(t, params, []interface{}{"foo", "bar"})
+
+		values = append(values, reflect.ValueOf(recordWithOmitEmpty{Name: "baz", Age: 42}))
+		_, err = getBatchInsertColumns(table, reflect.TypeOf(recordWithOmitEmpty{}), info, values)
+		tt.AssertErrContains(t, err, "omitempty", "age", "all records")
+	})
+
+	t.Run("should report an error for unexported attributes tagged with ksql", func(t *testing.T) {
+		type recordWithUnexported struct {
+			Name string `ksql:"name"`
+			age  int    `ksql:"age"`
+		}
+
+		dialect := supportedDialects["postgres"]
+		table := NewTable("records")
+		info, err := structs.GetTagInfo(reflect.TypeOf(recordWithUnexported{}))
+		tt.AssertNoErr(t, err)
+
+		values := []reflect.Value{
+			reflect.ValueOf(recordWithUnexported{Name: "foo", age: 42}),
+		}
+		columns, err := getBatchInsertColumns(table, reflect.TypeOf(recordWithUnexported{}), info, values)
+		tt.AssertNoErr(t, err)
+
+		_, _, err = buildBatchInsertQuery(dialect, table, columns, values)
+		tt.AssertErrContains(t, err, "age", "not exported")
+	})
+}
+
+func TestBuildOrderByQuery(t *testing.T) {
+	t.Run("should escape the columns and normalize the directions", func(t *testing.T) {
+		query, err := buildOrderByQuery(supportedDialects["postgres"], []string{"name", "age desc", " id  ASC "})
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, query, `"name", "age" DESC, "id" ASC`)
+	})
+
+	t.Run("should report error for invalid terms", func(t *testing.T) {
+		for _, term := range []string{"", "name; DROP TABLE users", "age DESCENDING", "name ASC NULLS"} {
+			_, err := buildOrderByQuery(supportedDialects["postgres"], []string{term})
+			tt.AssertErrContains(t, err, "ORDER BY")
+		}
+	})
+}
+
+func TestAssertModel(t *testing.T) {
+	t.Run("should accept valid models", func(t *testing.T) {
+		err := AssertModel(&struct {
+			ID      int               `ksql:"id"`
+			Name    string            `ksql:"name,omitempty"`
+			Address map[string]string `ksql:"address,json"`
+			Ignored string
+		}{})
+		tt.AssertNoErr(t, err)
+
+		err = AssertModel(&struct {
+			ID       int    `ksql:"id"`
+			FullName string `ksql:"full_name,readonly"`
+		}{})
+		tt.AssertNoErr(t, err)
+
+		err = AssertModel(&struct {
+			ID        int    `ksql:"id"`
+			Status    string `ksql:"status,default=new"`
+			CreatedAt int64  `ksql:"created_at,unix"`
+			UpdatedAt int64  `ksql:"updated_at,unixmilli"`
+		}{})
+		tt.AssertNoErr(t, err)
+
+		type user struct {
+			ID int `ksql:"id"`
+		}
+		type post struct {
+			ID int `ksql:"id"`
+		}
+		err = AssertModel(struct {
+			User user  `tablename:"u"`
+			Post *post `tablename:"p"`
+		}{})
+		tt.AssertNoErr(t, err)
+	})
+
+	t.Run("should report attributes tagged with the same column", func(t *testing.T) {
+		err := AssertModel(&struct {
+			ID       int    `ksql:"id"`
+			Name     string `ksql:"name"`
+			Nickname string `ksql:"name"`
+		}{})
+		tt.AssertErrContains(t, err, "attributes `Name` and `Nickname` are both tagged with the column name `name`")
+	})
+
+	t.Run("should report empty names and unknown options", func(t *testing.T) {
+		err := AssertModel(struct {
+			ID      int               `ksql:"id,omitemtpy"`
+			Address map[string]string `ksql:",json"`
+		}{})
+		tt.AssertErrContains(t, err,
+			"attribute `ID` has an unknown ksql tag option: `omitemtpy`",
+			"attribute `Address` has an empty ksql tag name",
+		)
+	})
+
+	t.Run("should report unexported tagged attributes", func(t *testing.T) {
+		err := AssertModel(struct {
+			ID   int    `ksql:"id"`
+			name string `ksql:"name"`
+		}{})
+		tt.AssertErrContains(t, err, "attribute `name` is tagged but not exported")
+	})
+
+	t.Run("should report problems on nested structs", func(t *testing.T) {
+		type user struct {
+			ID    int `ksql:"id"`
+			OldID int `ksql:"id"`
+		}
+		err := AssertModel(struct {
+			User  user `tablename:"u"`
+			Count int  `tablename:"count"`
+		}{})
+		tt.AssertErrContains(t, err,
+			"on attribute `User`: attributes `ID` and `OldID` are both tagged with the column name `id`",
+			"attribute `Count` has a tablename tag but is not a struct",
+		)
+	})
+
+	t.Run("should report tablename tags mixed with ksql tags", func(t *testing.T) {
+		type user struct {
+			ID int `ksql:"id"`
+		}
+		err := AssertModel(struct {
+			ID   int  `ksql:"id"`
+			User user `tablename:"u"`
+		}{})
+		tt.AssertErrContains(t, err, "attribute `User` has a tablename tag, which is ignored")
+	})
+
+	t.Run("should report structs without tags", func(t *testing.T) {
+		err := AssertModel(struct {
+			ID int
+		}{})
+		tt.AssertErrContains(t, err, "at least one attribute with the ksql tag")
+	})
+
+	t.Run("should report error for non struct models", func(t *testing.T) {
+		err := AssertModel(42)
+		tt.AssertErrContains(t, err, "expected model to be a struct", "int")
+
+		err = AssertModel(nil)
+		tt.AssertErrContains(t, err, "expected model to be a struct")
+	})
+}
+
+func BenchmarkBuildUpdateQuery(b *testing.B) {
+	type record struct {
+		ID    int     `ksql:"id"`
+		Name  string  `ksql:"name"`
+		Age   *int    `ksql:"age"`
+		Email *string `ksql:"email"`
+		Score int     `ksql:"score"`
+	}
+
+	dialect := supportedDialects["postgres"]
+	age := 42
+	r := &record{ID: 1, Name: "fake-name", Age: &age, Score: 7}
+	info, err := structs.GetTagInfo(reflect.TypeOf(record{}))
+	if err != nil {
+		b.Fatalf("unexpected error: %s", err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _, err := buildUpdateQuery(dialect, "records", info, r, nil, "id")
+		if err != nil {
+			b.Fatalf("unexpected error: %s", err)
+		}
+	}
+}
+
+func TestBindNamedParams(t *testing.T) {
+	type filter struct {
+		TenantID int     `ksql:"tenant_id"`
+		Name     *string `ksql:"name"`
+		MinAge   int     `ksql:"min_age"`
+	}
+
+	t.Run("should replace the named placeholders in order", func(t *testing.T) {
+		query, params, err := bindNamedParams(
+			supportedDialects["postgres"],
+			"FROM users WHERE tenant_id = @tenant_id AND (age >= @min_age OR @min_age = 0) AND name = @name",
+			[]interface{}{Named(filter{TenantID: 42, MinAge: 18})},
+			nil,
+		)
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, query, "FROM users WHERE tenant_id = $1 AND (age >= $2 OR $3 = 0) AND name = $4")
+		tt.AssertEqual(t, params, []interface{}{42, 18, 18, nil})
+	})
+
+	t.Run("should ignore names inside quotes and operators using @", func(t *testing.T) {
+		name := "fake-name"
+		query, params, err := bindNamedParams(
+			supportedDialects["sqlite3"],
+			"FROM users WHERE email = 'foo@tenant_id.com' AND tags @> '{}' AND doc @@ q AND name = @name",
+			[]interface{}{Named(&filter{Name: &name})},
+			nil,
+		)
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, query, "FROM users WHERE email = 'foo@tenant_id.com' AND tags @> '{}' AND doc @@ q AND name = ?")
+		tt.AssertEqual(t, params, []interface{}{"fake-name"})
+	})
+
+	t.Run("should keep the query unchanged without named params", func(t *testing.T) {
+		query, params, err := bindNamedParams(supportedDialects["sqlserver"], "FROM users WHERE id = @p1", []interface{}{1}, nil)
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, query, "FROM users WHERE id = @p1")
+		tt.AssertEqual(t, params, []interface{}{1})
+	})
+
+	t.Run("should report error for names not tagged on the struct", func(t *testing.T) {
+		_, _, err := bindNamedParams(supportedDialects["postgres"], "FROM users WHERE age = @age", []interface{}{Named(filter{})}, nil)
+		tt.AssertErrContains(t, err, "named param `@age` is not tagged")
+	})
+
+	t.Run("should report error if mixed with other params", func(t *testing.T) {
+		_, _, err := bindNamedParams(supportedDialects["postgres"], "FROM users WHERE age = @min_age", []interface{}{Named(filter{}), 42}, nil)
+		tt.AssertErrContains(t, err, "must be the only param")
+	})
+
+	t.Run("should report error if the input is not a struct", func(t *testing.T) {
+		_, _, err := bindNamedParams(supportedDialects["postgres"], "FROM users", []interface{}{Named(42)}, nil)
+		tt.AssertErrContains(t, err, "expected ksql.Named() to receive a struct", "int")
+	})
+}
+
+func TestIsConnectionLossError(t *testing.T) {
+	tests := []struct {
+		desc     string
+		err      error
+		expected bool
+	}{
+		{desc: "nil error", err: nil, expected: false},
+		{desc: "bad connection", err: driver.ErrBadConn, expected: true},
+		{desc: "wrapped broken pipe", err: fmt.Errorf("write tcp: %w", syscall.EPIPE), expected: true},
+		{desc: "wrapped connection reset", err: fmt.Errorf("read tcp: %w", syscall.ECONNRESET), expected: true},
+		{desc: "unexpected EOF", err: io.ErrUnexpectedEOF, expected: true},
+		{desc: "unwrapped message", err: fmt.Errorf("read tcp 10.0.0.1:3306: connection reset by peer"), expected: true},
+		{desc: "syntax error", err: fmt.Errorf(`syntax error at or near "FORM"`), expected: false},
+		{desc: "record not found", err: ErrRecordNotFound, expected: false},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			tt.AssertEqual(t, isConnectionLossError(test.err), test.expected)
+		})
+	}
+}
+
+// fakeSQLStateError mimics the errors of the postgres drivers,
+// which report their error codes with a SQLState method.
+type fakeSQLStateError struct {
+	code string
+}
+
+func (f fakeSQLStateError) Error() string {
+	return "ERROR: fake error (SQLSTATE " + f.code + ")"
+}
+
+func (f fakeSQLStateError) SQLState() string {
+	return f.code
+}
+
+func TestIsDeadlockError(t *testing.T) {
+	tests := []struct {
+		desc     string
+		err      error
+		expected bool
+	}{
+		{desc: "nil error", err: nil, expected: false},
+		{desc: "postgres deadlock", err: fakeSQLStateError{code: "40P01"}, expected: true},
+		{desc: "wrapped postgres deadlock", err: fmt.Errorf("error running query: %w", fakeSQLStateError{code: "40P01"}), expected: true},
+		{desc: "postgres unique violation", err: fakeSQLStateError{code: "23505"}, expected: false},
+		{desc: "mysql deadlock", err: fmt.Errorf("Error 1213: Deadlock found when trying to get lock; try restarting transaction"), expected: true},
+		{desc: "mysql deadlock with SQLSTATE", err: fmt.Errorf("Error 1213 (40001): Deadlock found when trying to get lock; try restarting transaction"), expected: true},
+		{desc: "wrapped mysql deadlock", err: fmt.Errorf("error running query: %w", fmt.Errorf("Error 1213: Deadlock found")), expected: true},
+		{desc: "mysql lock wait timeout", err: fmt.Errorf("Error 1205: Lock wait timeout exceeded; try restarting transaction"), expected: false},
+		{desc: "record not found", err: ErrRecordNotFound, expected: false},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			tt.AssertEqual(t, isDeadlockError(test.err), test.expected)
+		})
+	}
+}
+
+func TestErrDeadlock(t *testing.T) {
+	deadlockErr := fakeSQLStateError{code: "40P01"}
+
+	var users []user
+	c := newTestDB(mockDBAdapter{
+		ExecContextFn: func(ctx context.Context, query string, args ...interface{}) (Result, error) {
+			return nil, deadlockErr
+		},
+		QueryContextFn: func(ctx context.Context, query string, args ...interface{}) (Rows, error) {
+			return nil, deadlockErr
+		},
+	}, "postgres")
+
+	t.Run("should wrap deadlock errors returned by queries", func(t *testing.T) {
+		err := c.Query(context.Background(), &users, "FROM users")
+		tt.AssertEqual(t, errors.Is(err, ErrDeadlock), true)
+
+		var driverErr fakeSQLStateError
+		tt.AssertEqual(t, errors.As(err, &driverErr), true)
+		tt.AssertEqual(t, driverErr.code, "40P01")
+	})
+
+	t.Run("should wrap deadlock errors returned by exec", func(t *testing.T) {
+		_, err := c.Exec(context.Background(), "UPDATE users SET age = 42")
+		tt.AssertEqual(t, errors.Is(err, ErrDeadlock), true)
+	})
+
+	t.Run("should keep the deadlock errors of InsertSlice", func(t *testing.T) {
+		c := newTestDB(mockTxAdapter{mockDBAdapter{
+			QueryContextFn: func(ctx context.Context, query string, args ...interface{}) (Rows, error) {
+				return nil, deadlockErr
+			},
+		}}, "postgres")
+
+		err := c.InsertSlice(context.Background(), usersTable, []user{{Name: "Bia"}})
+		tt.AssertErrContains(t, err, "position 0")
+		tt.AssertEqual(t, errors.Is(err, ErrDeadlock), true)
+	})
+
+	t.Run("should not wrap other errors", func(t *testing.T) {
+		otherErr := fakeSQLStateError{code: "23505"}
+		c := newTestDB(mockDBAdapter{
+			ExecContextFn: func(ctx context.Context, query string, args ...interface{}) (Result, error) {
+				return nil, otherErr
+			},
+		}, "postgres")
+
+		_, err := c.Exec(context.Background(), "UPDATE users SET age = 42")
+		tt.AssertEqual(t, errors.Is(err, ErrDeadlock), false)
+		tt.AssertEqual(t, err, error(otherErr))
+	})
+}
+
+// mockTxAdapter is a mockDBAdapter that works as a transaction,
+// so the functions that use Transaction can run on it.
+type mockTxAdapter struct {
+	mockDBAdapter
+}
+
+func (m mockTxAdapter) Rollback(ctx context.Context) error {
+	return nil
+}
+
+func (m mockTxAdapter) Commit(ctx context.Context) error {
+	return nil
+}
+
+func TestTransactionRetries(t *testing.T) {
+	connResetErr := fmt.Errorf("read tcp: %w", syscall.ECONNRESET)
+	policy := RetryPolicy{MaxAttempts: 3}
+
+	newTxDB := func(commitErrs ...error) DB {
+		c := newTestDB(mockTxBeginnerAdapter{
+			BeginTxFn: func(ctx context.Context) (Tx, error) {
+				var commitErr error
+				if len(commitErrs) > 0 {
+					commitErr, commitErrs = commitErrs[0], commitErrs[1:]
+				}
+				return mockCommitTxAdapter{commitErr: commitErr}, nil
+			},
+		}, "postgres")
+		return c.WithRetryPolicy(policy)
+	}
+
+	t.Run("should not run the fn again when the connection is lost while committing", func(t *testing.T) {
+		c := newTxDB(connResetErr)
+
+		var numCalls int
+		err := c.Transaction(context.Background(), func(p Provider) error {
+			numCalls++
+			return nil
+		})
+		tt.AssertEqual(t, errors.Is(err, syscall.ECONNRESET), true)
+		tt.AssertEqual(t, numCalls, 1)
+	})
+
+	t.Run("should run the fn again when the commit fails with a deadlock", func(t *testing.T) {
+		c := newTxDB(fakeSQLStateError{code: "40P01"})
+
+		var numCalls int
+		err := c.Transaction(context.Background(), func(p Provider) error {
+			numCalls++
+			return nil
+		})
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, numCalls, 2)
+	})
+
+	t.Run("should run the fn again on lost connections while committing on idempotent DBs", func(t *testing.T) {
+		c := newTxDB(connResetErr).Idempotent()
+
+		var numCalls int
+		err := c.Transaction(context.Background(), func(p Provider) error {
+			numCalls++
+			return nil
+		})
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, numCalls, 2)
+	})
+
+	t.Run("should run the fn again when the connection is lost before committing", func(t *testing.T) {
+		c := newTxDB()
+
+		var numCalls int
+		err := c.Transaction(context.Background(), func(p Provider) error {
+			numCalls++
+			if numCalls == 1 {
+				return connResetErr
+			}
+			return nil
+		})
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, numCalls, 2)
+	})
+}
+
+type mockTxBeginnerAdapter struct {
+	mockDBAdapter
+	BeginTxFn func(ctx context.Context) (Tx, error)
+}
+
+func (m mockTxBeginnerAdapter) BeginTx(ctx context.Context) (Tx, error) {
+	return m.BeginTxFn(ctx)
+}
+
+type mockCommitTxAdapter struct {
+	mockDBAdapter
+	commitErr error
+}
+
+func (m mockCommitTxAdapter) Rollback(ctx context.Context) error {
+	return nil
+}
+
+func (m mockCommitTxAdapter) Commit(ctx context.Context) error {
+	return m.commitErr
+}
+
+func TestConvertTimesToLocation(t *testing.T) {
+	type event struct {
+		ID        int       `ksql:"id"`
+		CreatedAt time.Time `ksql:"created_at"`
+	}
+
+	loc := time.FixedZone("UTC-3", -3*60*60)
+	createdAt := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("should convert the times of nested structs and pointers to nested structs", func(t *testing.T) {
+		var row struct {
+			Event    event  `tablename:"events"`
+			Original *event `tablename:"original"`
+			Missing  *event `tablename:"missing"`
+		}
+		row.Event.CreatedAt = createdAt
+		row.Original = &event{CreatedAt: createdAt}
+
+		info, err := structs.GetTagInfo(reflect.TypeOf(row))
+		tt.AssertNoErr(t, err)
+
+		err = convertTimesToLocation(reflect.ValueOf(&row).Elem(), info, loc, nil)
+		tt.AssertNoErr(t, err)
+
+		tt.AssertEqual(t, row.Event.CreatedAt.Location(), loc)
+		tt.AssertEqual(t, row.Original.CreatedAt.Location(), loc)
+		tt.AssertEqual(t, row.Original.CreatedAt.Equal(createdAt), true)
+		tt.AssertEqual(t, row.Missing, (*event)(nil))
+	})
+}
+
+func TestSetUpdatedAt(t *testing.T) {
+	t.Run("should set time.Time and *time.Time attributes", func(t *testing.T) {
+		var record struct {
+			ID        int        `ksql:"id"`
+			UpdatedAt time.Time  `ksql:"updated_at"`
+			DeletedAt *time.Time `ksql:"deleted_at"`
+		}
+		info, err := structs.GetTagInfo(reflect.TypeOf(record))
+		tt.AssertNoErr(t, err)
+
+		loc := time.FixedZone("UTC-3", -3*60*60)
+		start := time.Now()
+		for _, column := range []string{"updated_at", "deleted_at"} {
+			c := DB{updatedAtColumn: column, location: loc}
+			err = c.setUpdatedAt(reflect.ValueOf(&record).Elem(), info)
+			tt.AssertNoErr(t, err)
+		}
+
+		tt.AssertEqual(t, record.UpdatedAt.Before(start), false)
+		tt.AssertEqual(t, record.UpdatedAt.Location(), loc)
+		tt.AssertNotEqual(t, record.DeletedAt, (*time.Time)(nil))
+		tt.AssertEqual(t, record.DeletedAt.Before(start), false)
+	})
+
+	t.Run("should report invalid attributes", func(t *testing.T) {
+		var record struct {
+			UpdatedAt string    `ksql:"updated_at"`
+			deletedAt time.Time `ksql:"deleted_at"`
+		}
+		info, err := structs.GetTagInfo(reflect.TypeOf(record))
+		tt.AssertNoErr(t, err)
+
+		c := DB{updatedAtColumn: "updated_at"}
+		err = c.setUpdatedAt(reflect.ValueOf(&record).Elem(), info)
+		tt.AssertErrContains(t, err, "`UpdatedAt`", "time.Time", "string")
+
+		c = DB{updatedAtColumn: "deleted_at"}
+		err = c.setUpdatedAt(reflect.ValueOf(&record).Elem(), info)
+		tt.AssertErrContains(t, err, "`deletedAt`", "not exported")
+	})
+}
+
+func TestGetKeysFromSlice(t *testing.T) {
+	t.Run("should dereference the keys and ignore the nil ones", func(t *testing.T) {
+		id1, id2 := 1, 2
+		id1Ptr, id2Ptr := &id1, &id2
+		type parent struct {
+			ID **int `ksql:"id"`
+		}
+		parents := []*parent{{ID: &id1Ptr}, nil, {ID: nil}, {ID: &id2Ptr}, {ID: &id1Ptr}}
+
+		keys, err := getKeysFromSlice(&parents, "id", nil)
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, keys, []interface{}{1, 2})
+	})
+
+	t.Run("should ignore the empty keys tagged with omitempty", func(t *testing.T) {
+		type parent struct {
+			ID int `ksql:"id,omitempty"`
+		}
+		parents := []parent{{ID: 0}, {ID: 3}}
+
+		keys, err := getKeysFromSlice(parents, "id", nil)
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, keys, []interface{}{3})
+	})
+
+	t.Run("should report unexported keys instead of panicking", func(t *testing.T) {
+		type parent struct {
+			id int `ksql:"id"`
+		}
+		parents := []parent{{id: 1}}
+
+		_, err := getKeysFromSlice(parents, "id", nil)
+		tt.AssertErrContains(t, err, "id", "not exported")
+	})
+}
+
+func TestSetUUIDKey(t *testing.T) {
+	type uuidValue [16]byte
+
+	t.Run("should generate the raw bytes of [16]byte keys", func(t *testing.T) {
+		var record struct {
+			ID   uuidValue `ksql:"id"`
+			Name string    `ksql:"name"`
+		}
+		info, err := structs.GetTagInfo(reflect.TypeOf(record))
+		tt.AssertNoErr(t, err)
+
+		c := DB{uuidKeyColumn: "id"}
+		err = c.setUUIDKey(reflect.ValueOf(&record).Elem(), info)
+		tt.AssertNoErr(t, err)
+
+		tt.AssertNotEqual(t, record.ID, uuidValue{})
+		tt.AssertEqual(t, record.ID[6]>>4, byte(4))
+		tt.AssertEqual(t, record.ID[8]>>6, byte(2))
+	})
+
+	t.Run("should ignore records without the key column", func(t *testing.T) {
+		var record struct {
+			Name string `ksql:"name"`
+		}
+		info, err := structs.GetTagInfo(reflect.TypeOf(record))
+		tt.AssertNoErr(t, err)
+
+		c := DB{uuidKeyColumn: "id"}
+		err = c.setUUIDKey(reflect.ValueOf(&record).Elem(), info)
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, record.Name, "")
+	})
+
+	t.Run("should report unexported keys instead of panicking", func(t *testing.T) {
+		var record struct {
+			id   string    `ksql:"id"`
+			uuid uuidValue `ksql:"uuid"`
+		}
+		info, err := structs.GetTagInfo(reflect.TypeOf(record))
+		tt.AssertNoErr(t, err)
+
+		for _, column := range []string{"id", "uuid"} {
+			c := DB{uuidKeyColumn: column}
+			err = c.setUUIDKey(reflect.ValueOf(&record).Elem(), info)
+			tt.AssertErrContains(t, err, "`"+column+"`", "not exported")
+		}
+	})
+}
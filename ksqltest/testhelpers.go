@@ -12,8 +12,10 @@ import (
 // StructToMap converts any struct type to a map based on
 // the tag named `ksql`, i.e. `ksql:"map_key_name"`
 //
-// Valid pointers are dereferenced and copied to the map,
-// null pointers are ignored.
+// Valid pointers, including pointers to pointers, are fully
+// dereferenced and copied to the map, null pointers are ignored,
+// and so are zero values for attributes tagged with the omitempty
+// option, e.g.: `ksql:"map_key_name,omitempty"`.
 //
 // This function is efficient in the fact that it caches
 // the slower steps of the reflection required to perform
@@ -39,33 +41,96 @@ func FillStructWith(record interface{}, dbRow map[string]interface{}) error {
 		)
 	}
 
-	t = t.Elem()
-	v = v.Elem()
-
-	if t.Kind() != reflect.Struct {
+	if t.Elem().Kind() != reflect.Struct {
 		return fmt.Errorf(
 			"FillStructWith: expected input to be a pointer to a struct, but got %T",
 			record,
 		)
 	}
 
-	info, err := structs.GetTagInfo(t)
+	return FillStructValue(v.Elem(), dbRow)
+}
+
+// FillStructValue works like FillStructWith but receives the struct as
+// a reflect.Value, which is useful for test tooling that obtains the
+// structs through reflection, e.g. from the attributes of other structs.
+//
+// The value must be an addressable struct, e.g. the result
+// of reflect.ValueOf(&record).Elem(), so it can be modified.
+func FillStructValue(v reflect.Value, dbRow map[string]interface{}) error {
+	if !v.IsValid() {
+		return fmt.Errorf("FillStructValue: expected input to be a struct value, but got an invalid reflect.Value")
+	}
+
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("FillStructValue: expected input to be a struct value, but got %v", v.Type())
+	}
+
+	if !v.CanSet() {
+		return fmt.Errorf("FillStructValue: expected input to be an addressable struct value of type %v", v.Type())
+	}
+
+	filler, err := newStructFiller(v.Type())
 	if err != nil {
 		return err
 	}
 
-	for colName, rawSrc := range dbRow {
-		fieldInfo := info.ByName(colName)
+	return filler.fill(v, dbRow)
+}
+
+// structFiller stores the attributes of a struct type indexed by
+// their `ksql` tags so that several structs of the same type can
+// be filled without repeating the tag lookups and type checks.
+type structFiller struct {
+	fieldsByColumn map[string]fieldFiller
+}
+
+type fieldFiller struct {
+	index     int
+	fieldType reflect.Type
+}
+
+func newStructFiller(t reflect.Type) (structFiller, error) {
+	info, err := structs.GetTagInfo(t)
+	if err != nil {
+		return structFiller{}, err
+	}
+
+	fieldsByColumn := map[string]fieldFiller{}
+	for i := 0; i < t.NumField(); i++ {
+		fieldInfo := info.ByIndex(i)
 		if !fieldInfo.Valid {
+			continue
+		}
+
+		fieldsByColumn[fieldInfo.Name] = fieldFiller{
+			index:     i,
+			fieldType: t.Field(i).Type,
+		}
+	}
+
+	return structFiller{
+		fieldsByColumn: fieldsByColumn,
+	}, nil
+}
+
+func (f structFiller) fill(v reflect.Value, dbRow map[string]interface{}) error {
+	for colName, rawSrc := range dbRow {
+		field, found := f.fieldsByColumn[colName]
+		if !found {
 			// Ignore columns not tagged with `ksql:"..."`
 			continue
 		}
 
-		src := structs.NewPtrConverter(rawSrc)
-		dest := v.Field(fieldInfo.Index)
-		destType := t.Field(fieldInfo.Index).Type
+		dest := v.Field(field.index)
 
-		destValue, err := src.Convert(destType)
+		// Values of the same type as the field need no conversion:
+		if rawSrc != nil && reflect.TypeOf(rawSrc) == field.fieldType {
+			dest.Set(reflect.ValueOf(rawSrc))
+			continue
+		}
+
+		destValue, err := structs.NewPtrConverter(rawSrc).Convert(field.fieldType)
 		if err != nil {
 			return errors.Wrap(err, fmt.Sprintf("FillStructWith: error on field `%s`", colName))
 		}
@@ -97,18 +162,27 @@ func FillSliceWith(entities interface{}, dbRows []map[string]interface{}) error
 		return errors.Wrap(err, "FillSliceWith")
 	}
 
+	filler, err := newStructFiller(structType)
+	if err != nil {
+		return errors.Wrap(err, "FillSliceWith")
+	}
+
 	slice := sliceRef.Elem()
+	if missing := len(dbRows) - slice.Len(); missing > 0 {
+		// Growing the slice only once for all the new rows:
+		slice = reflect.AppendSlice(slice, reflect.MakeSlice(slice.Type(), missing, missing))
+	}
+
 	for idx, row := range dbRows {
-		if slice.Len() <= idx {
-			var elemValue reflect.Value
-			elemValue = reflect.New(structType)
-			if !isSliceOfPtrs {
-				elemValue = elemValue.Elem()
+		elem := slice.Index(idx)
+		if isSliceOfPtrs {
+			if elem.IsNil() {
+				elem.Set(reflect.New(structType))
 			}
-			slice = reflect.Append(slice, elemValue)
+			elem = elem.Elem()
 		}
 
-		err := FillStructWith(slice.Index(idx).Addr().Interface(), row)
+		err := filler.fill(elem, row)
 		if err != nil {
 			return errors.Wrap(err, "FillSliceWith")
 		}
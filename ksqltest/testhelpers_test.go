@@ -2,7 +2,9 @@ package ksqltest
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/ditointernet/go-assert"
 	tt "github.com/vingarcia/ksql/internal/testtools"
@@ -70,6 +72,53 @@ func TestStructToMap(t *testing.T) {
 		assert.Equal(t, map[string]interface{}{}, m)
 	})
 
+	type S3 struct {
+		Name     string `ksql:"name,omitempty"`
+		Age      int    `ksql:"age,omitempty"`
+		Nickname string `ksql:"nickname"`
+	}
+
+	t.Run("should ignore zero values tagged with omitempty", func(t *testing.T) {
+		m, err := StructToMap(S3{
+			Name:     "",
+			Age:      0,
+			Nickname: "",
+		})
+
+		assert.Equal(t, nil, err)
+		assert.Equal(t, map[string]interface{}{
+			"nickname": "",
+		}, m)
+	})
+
+	t.Run("should not ignore non zero values tagged with omitempty", func(t *testing.T) {
+		m, err := StructToMap(S3{
+			Name: "fake-name",
+			Age:  42,
+		})
+
+		assert.Equal(t, nil, err)
+		assert.Equal(t, map[string]interface{}{
+			"name":     "fake-name",
+			"age":      42,
+			"nickname": "",
+		}, m)
+	})
+
+	t.Run("should work with omitempty and json options together", func(t *testing.T) {
+		m, err := StructToMap(struct {
+			Name    string                 `ksql:"name"`
+			Address map[string]interface{} `ksql:"address,json,omitempty"`
+		}{
+			Name: "fake-name",
+		})
+
+		assert.Equal(t, nil, err)
+		assert.Equal(t, map[string]interface{}{
+			"name": "fake-name",
+		}, m)
+	})
+
 	t.Run("should ignore fields not tagged with ksql", func(t *testing.T) {
 		m, err := StructToMap(struct {
 			Name              string `ksql:"name_attr"`
@@ -113,6 +162,85 @@ func TestStructToMap(t *testing.T) {
 
 		assert.NotEqual(t, nil, err)
 	})
+
+	t.Run("should fully dereference pointers to pointers", func(t *testing.T) {
+		age := 42
+		agePtr := &age
+		var nilAgePtr *int
+		m, err := StructToMap(struct {
+			Age     **int `ksql:"age"`
+			NilAge  **int `ksql:"nil_age"`
+			NilAge2 **int `ksql:"nil_age2"`
+		}{
+			Age:    &agePtr,
+			NilAge: &nilAgePtr,
+		})
+
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, m, map[string]interface{}{
+			"age": 42,
+		})
+	})
+
+	t.Run("should copy pointers to zero structs as zero structs", func(t *testing.T) {
+		type address struct {
+			Street string
+		}
+		m, err := StructToMap(struct {
+			Name    string   `ksql:"name"`
+			Address *address `ksql:"address,json"`
+		}{
+			Name:    "fake-name",
+			Address: &address{},
+		})
+
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, m, map[string]interface{}{
+			"name":    "fake-name",
+			"address": address{},
+		})
+	})
+
+	t.Run("should return error for tagged attributes that are not exported", func(t *testing.T) {
+		_, err := StructToMap(struct {
+			Name string `ksql:"name"`
+			age  int    `ksql:"age"`
+		}{
+			Name: "fake-name",
+			age:  42,
+		})
+
+		tt.AssertErrContains(t, err, "age", "not exported")
+	})
+
+	t.Run("should return error for nil inputs", func(t *testing.T) {
+		_, err := StructToMap(nil)
+		tt.AssertErrContains(t, err, "nil")
+
+		type user struct {
+			Name string `ksql:"name"`
+		}
+		_, err = StructToMap((*user)(nil))
+		tt.AssertErrContains(t, err, "nil pointer")
+	})
+
+	t.Run("should convert unix timestamps to time values", func(t *testing.T) {
+		createdAt := int64(1641092645)
+		updatedAt := int64(1641092645250)
+		m, err := StructToMap(struct {
+			CreatedAt int64  `ksql:"created_at,unix"`
+			UpdatedAt *int64 `ksql:"updated_at,unixmilli"`
+			DeletedAt *int64 `ksql:"deleted_at,unix"`
+		}{
+			CreatedAt: createdAt,
+			UpdatedAt: &updatedAt,
+		})
+
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, len(m), 2)
+		tt.AssertEqual(t, m["created_at"].(time.Time).Equal(time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)), true)
+		tt.AssertEqual(t, m["updated_at"].(time.Time).Equal(time.Date(2022, 1, 2, 3, 4, 5, 250*int(time.Millisecond), time.UTC)), true)
+	})
 }
 
 func TestFillStructWith(t *testing.T) {
@@ -277,6 +405,66 @@ func TestFillStructWith(t *testing.T) {
 	})
 }
 
+func TestFillStructValue(t *testing.T) {
+	type User struct {
+		Name string `ksql:"name"`
+		Age  *int   `ksql:"age"`
+	}
+
+	t.Run("should fill addressable struct values", func(t *testing.T) {
+		var users [2]User
+		err := FillStructValue(reflect.ValueOf(&users).Elem().Index(1), map[string]interface{}{
+			"name": "Breno",
+			"age":  22,
+		})
+
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, users[0], User{})
+		tt.AssertEqual(t, users[1].Name, "Breno")
+		tt.AssertEqual(t, users[1].Age, nullable.Int(22))
+	})
+
+	t.Run("should fill attributes obtained through reflection", func(t *testing.T) {
+		var parent struct {
+			User User
+		}
+		err := FillStructValue(reflect.ValueOf(&parent).Elem().Field(0), map[string]interface{}{
+			"name": "Breno",
+		})
+
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, parent.User.Name, "Breno")
+	})
+
+	t.Run("should report error if the value is not addressable", func(t *testing.T) {
+		err := FillStructValue(reflect.ValueOf(User{}), map[string]interface{}{
+			"name": "Breno",
+		})
+
+		tt.AssertErrContains(t, err, "FillStructValue", "addressable", "User")
+	})
+
+	t.Run("should report error if the value is not a struct", func(t *testing.T) {
+		var users []User
+		err := FillStructValue(reflect.ValueOf(&users).Elem(), map[string]interface{}{
+			"name": "Breno",
+		})
+		tt.AssertErrContains(t, err, "FillStructValue", "expected input to be a struct value")
+
+		err = FillStructValue(reflect.Value{}, map[string]interface{}{})
+		tt.AssertErrContains(t, err, "FillStructValue", "expected input to be a struct value")
+	})
+
+	t.Run("should report error if input and target types are incompatible", func(t *testing.T) {
+		var user User
+		err := FillStructValue(reflect.ValueOf(&user).Elem(), map[string]interface{}{
+			"age": "not compatible with integer type",
+		})
+
+		tt.AssertErrContains(t, err, "age", "string", "int")
+	})
+}
+
 func TestFillSliceWith(t *testing.T) {
 	t.Run("should fill a list correctly", func(t *testing.T) {
 		var users []struct {
@@ -302,6 +490,45 @@ func TestFillSliceWith(t *testing.T) {
 		tt.AssertEqual(t, users[2].Name, "Breno")
 	})
 
+	t.Run("should fill a list of pointers correctly", func(t *testing.T) {
+		var users []*struct {
+			Name string `ksql:"name"`
+			Age  int    `ksql:"age"`
+		}
+		err := FillSliceWith(&users, []map[string]interface{}{
+			{
+				"name": "Jorge",
+				"age":  22,
+			},
+			{
+				"name": "Luciana",
+			},
+		})
+
+		tt.AssertEqual(t, err, nil)
+		tt.AssertEqual(t, len(users), 2)
+		tt.AssertEqual(t, users[0].Name, "Jorge")
+		tt.AssertEqual(t, users[0].Age, 22)
+		tt.AssertEqual(t, users[1].Name, "Luciana")
+	})
+
+	t.Run("should report conversion errors with the position of the row", func(t *testing.T) {
+		var users []struct {
+			Name string `ksql:"name"`
+			Age  int    `ksql:"age"`
+		}
+		err := FillSliceWith(&users, []map[string]interface{}{
+			{
+				"age": 22,
+			},
+			{
+				"age": "not an int",
+			},
+		})
+
+		tt.AssertErrContains(t, err, "FillSliceWith", "age")
+	})
+
 	t.Run("should report error if input is not a pointer", func(t *testing.T) {
 		var users []struct {
 			Name string `ksql:"name"`
@@ -393,3 +620,36 @@ func TestCallFunctionWithRows(t *testing.T) {
 		tt.AssertErrContains(t, err)
 	})
 }
+
+func BenchmarkFillSliceWith(b *testing.B) {
+	type user struct {
+		ID      int     `ksql:"id"`
+		Name    string  `ksql:"name"`
+		Age     *int    `ksql:"age"`
+		Email   *string `ksql:"email"`
+		Score   int64   `ksql:"score"`
+		Ignored string
+	}
+
+	rows := make([]map[string]interface{}, 10000)
+	for i := range rows {
+		age := i % 100
+		rows[i] = map[string]interface{}{
+			"id":    i,
+			"name":  fmt.Sprintf("user-%d", i),
+			"age":   &age,
+			"email": nil,
+			"score": i * 10,
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var users []user
+		err := FillSliceWith(&users, rows)
+		if err != nil {
+			b.Fatalf("unexpected error: %s", err)
+		}
+	}
+}
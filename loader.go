@@ -0,0 +1,230 @@
+package ksql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Loader coalesces the records loaded by ID during a short window into a
+// single query, which avoids the N+1 queries problem on code that loads
+// each record independently, e.g. on GraphQL resolvers:
+//
+//	loader, err := ksql.NewLoader(db, UsersTable, User{}, time.Millisecond)
+//	...
+//	// On each resolver, possibly running concurrently:
+//	var u User
+//	err := loader.Load(ctx, authorID, &u)
+//
+// All the calls to Load made during the wait window after the first one
+// are sent as a single `WHERE id IN (...)` query, and each caller
+// receives the record with its ID, or ErrRecordNotFound if there is none.
+//
+// A Loader is safe for concurrent use, and since it doesn't cache the
+// loaded records it can be shared by all the requests of a process.
+type Loader struct {
+	db         DB
+	table      Table
+	structType reflect.Type
+	idType     reflect.Type
+	idIndex    int
+	wait       time.Duration
+	maxBatch   int
+
+	mu      sync.Mutex
+	pending *loaderBatch
+}
+
+// loaderBatch stores the IDs requested during one wait window,
+// the done channel is closed once its records were loaded.
+type loaderBatch struct {
+	ctx        context.Context
+	keys       []interface{}
+	seen       map[interface{}]bool
+	dispatched bool
+
+	done    chan struct{}
+	records map[interface{}]reflect.Value
+	err     error
+}
+
+// NewLoader creates a Loader for the records of the input table, whose
+// type should be the type of the record argument, which should be a
+// struct or a pointer to struct tagged with the single ID column of
+// the table.
+//
+// The wait argument is how long each batch waits for more IDs before
+// being sent to the database, and the batches are also sent as soon
+// as they reach the batch size of the DB, as configured with
+// WithBatchSize (500 by default).
+func NewLoader(db DB, table Table, record interface{}, wait time.Duration) (*Loader, error) {
+	if err := table.validate(); err != nil {
+		return nil, fmt.Errorf("can't create a Loader for ksql.Table: %s", err)
+	}
+
+	if len(table.idColumns) != 1 {
+		return nil, fmt.Errorf("ksql: Loader expects a table with a single ID column, but got: %v", table.idColumns)
+	}
+
+	t := reflect.TypeOf(record)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("ksql: expected record to be a struct or a pointer to struct, but got: %T", record)
+	}
+
+	info, err := db.getTagInfo(t)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsNestedStruct {
+		return nil, fmt.Errorf("ksql: Loader doesn't support nested structs")
+	}
+
+	idField := info.ByName(table.idColumns[0])
+	if !idField.Valid {
+		return nil, fmt.Errorf("ksql: the ID column `%s` is not tagged on type %v", table.idColumns[0], t)
+	}
+
+	maxBatch := db.batchSize
+	if maxBatch == 0 {
+		maxBatch = defaultBatchSize
+	}
+	if maxBatch < 0 {
+		return nil, fmt.Errorf("ksql: the batch size must be a positive number, but got: %d", maxBatch)
+	}
+
+	return &Loader{
+		db:         db,
+		table:      table,
+		structType: t,
+		idType:     t.Field(idField.Index).Type,
+		idIndex:    idField.Index,
+		wait:       wait,
+		maxBatch:   maxBatch,
+	}, nil
+}
+
+// Load loads the record with the input id into the dest struct, which
+// should be a pointer to the type of the record used on NewLoader.
+// It returns ErrRecordNotFound if there is no record with this id.
+//
+// The query of each batch runs with the values of the context of its
+// first call to Load, but not with its deadline or cancellation, so each
+// caller only stops waiting for the batch when its own context is done.
+func (l *Loader) Load(ctx context.Context, id interface{}, dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Type().Elem() != l.structType {
+		return fmt.Errorf("ksql: expected dest to be a non nil *%v, but got: %T", l.structType, dest)
+	}
+
+	key, err := convertIDToType(id, l.idType)
+	if err != nil {
+		return err
+	}
+
+	batch := l.addToBatch(ctx, key)
+
+	select {
+	case <-batch.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if batch.err != nil {
+		return batch.err
+	}
+
+	record, found := batch.records[key]
+	if !found {
+		return ErrRecordNotFound
+	}
+
+	v.Elem().Set(record)
+	return nil
+}
+
+// addToBatch adds the key to the pending batch, starting a new
+// one if necessary, and returns the batch that will load it.
+func (l *Loader) addToBatch(ctx context.Context, key interface{}) *loaderBatch {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	batch := l.pending
+	if batch == nil {
+		batch = &loaderBatch{
+			ctx:  detachedContext{parent: ctx},
+			seen: map[interface{}]bool{},
+			done: make(chan struct{}),
+		}
+		l.pending = batch
+		time.AfterFunc(l.wait, func() {
+			l.dispatch(batch)
+		})
+	}
+
+	if !batch.seen[key] {
+		batch.seen[key] = true
+		batch.keys = append(batch.keys, key)
+	}
+
+	if len(batch.keys) >= l.maxBatch {
+		l.pending = nil
+		batch.dispatched = true
+		go l.run(batch)
+	}
+
+	return batch
+}
+
+// dispatch sends the batch to the database
+// unless it was already sent for being full.
+func (l *Loader) dispatch(batch *loaderBatch) {
+	l.mu.Lock()
+	if l.pending == batch {
+		l.pending = nil
+	}
+	if batch.dispatched {
+		l.mu.Unlock()
+		return
+	}
+	batch.dispatched = true
+	l.mu.Unlock()
+
+	l.run(batch)
+}
+
+func (l *Loader) run(batch *loaderBatch) {
+	defer close(batch.done)
+
+	records := reflect.New(reflect.SliceOf(l.structType))
+	batch.err = l.db.QueryByIDs(batch.ctx, l.table, records.Interface(), batch.keys)
+	if batch.err != nil {
+		return
+	}
+
+	batch.records = make(map[interface{}]reflect.Value, records.Elem().Len())
+	for i := 0; i < records.Elem().Len(); i++ {
+		record := records.Elem().Index(i)
+		batch.records[record.Field(l.idIndex).Interface()] = record
+	}
+}
+
+// detachedContext keeps the values of its parent context, e.g. for
+// tracing and logging, without its deadline and cancellation, so the
+// batches are not canceled when their first caller gives up on them.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
@@ -0,0 +1,75 @@
+package ksql
+
+import "context"
+
+// LogValues contains the information about each
+// query that is sent to the QueryLogger.
+type LogValues struct {
+	Query  string
+	Params []interface{}
+	Err    error
+}
+
+// QueryLogger is called after each query sent to the database.
+//
+// The ctx argument is the same context passed by the user to the
+// ksql function, so it can be used for reading request scoped
+// values such as request IDs for correlating the logs.
+type QueryLogger func(ctx context.Context, values LogValues)
+
+// WithLogger returns a copy of the DB that calls
+// the input logger after each query it runs, e.g.:
+//
+//	db = db.WithLogger(func(ctx context.Context, values ksql.LogValues) {
+//		log.Println(ctx.Value(requestIDKey), values.Query, values.Err)
+//	})
+func (c DB) WithLogger(logger QueryLogger) DB {
+	c.logger = logger
+	return c
+}
+
+func (c DB) queryContext(ctx context.Context, query string, params ...interface{}) (Rows, error) {
+	db := c.getQueryAdapter(query)
+	rows, err := db.QueryContext(ctx, query, params...)
+	c.logQuery(ctx, query, params, err)
+	for attempt := 1; err != nil; attempt++ {
+		delay, retry := c.getRetryDelay(ctx, query, attempt, err)
+		if !retry || !sleepContext(ctx, delay) {
+			break
+		}
+
+		rows, err = db.QueryContext(ctx, query, params...)
+		c.logQuery(ctx, query, params, err)
+	}
+	if err != nil {
+		return nil, wrapDeadlockError(err)
+	}
+	return deadlockRows{rows}, nil
+}
+
+func (c DB) execContext(ctx context.Context, query string, params ...interface{}) (Result, error) {
+	result, err := c.db.ExecContext(ctx, query, params...)
+	c.logQuery(ctx, query, params, err)
+	for attempt := 1; err != nil; attempt++ {
+		delay, retry := c.getRetryDelay(ctx, query, attempt, err)
+		if !retry || !sleepContext(ctx, delay) {
+			break
+		}
+
+		result, err = c.db.ExecContext(ctx, query, params...)
+		c.logQuery(ctx, query, params, err)
+	}
+	return result, wrapDeadlockError(err)
+}
+
+func (c DB) logQuery(ctx context.Context, query string, params []interface{}, err error) {
+	if c.logger == nil {
+		return
+	}
+
+	c.logger(ctx, LogValues{
+		Query:  query,
+		Params: params,
+		Err:    err,
+	})
+}
@@ -0,0 +1,154 @@
+package ksql
+
+import (
+	"context"
+	"fmt"
+)
+
+// MapIterator streams the rows of a query as maps indexed by the
+// column names, it should be created with the QueryMapIter function.
+type MapIterator struct {
+	rows    Rows
+	columns []string
+	err     error
+	closed  bool
+}
+
+// QueryMapIter runs a query and returns an iterator over its rows
+// for the cases where the columns are not known in advance, e.g.:
+//
+//	it, err := c.QueryMapIter(ctx, "SELECT * FROM users WHERE age > $1", 18)
+//	if err != nil {
+//		return err
+//	}
+//	defer it.Close()
+//
+//	for row, ok := it.Next(); ok; row, ok = it.Next() {
+//		fmt.Println(row["name"])
+//	}
+//	if err := it.Err(); err != nil {
+//		return err
+//	}
+//
+// The rows are read from the database one at a time, so the
+// result is never fully loaded into memory, and the values are
+// stored on the maps as they are returned by the driver.
+//
+// The iterator holds a database connection until it is
+// exhausted or closed, so Close should always be called.
+func (c DB) QueryMapIter(ctx context.Context, query string, params ...interface{}) (it *MapIterator, err error) {
+	ctx, finish := c.observe(ctx, "query_map_iter", "")
+	defer func() { finish(err) }()
+
+	query, params, err = bindNamedParams(c.dialect, query, params, c.getColumnResolver())
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := c.queryContext(ctx, query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("error running query: %w", err)
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		rows.Close()
+		return nil, err
+	}
+
+	return &MapIterator{
+		rows:    rows,
+		columns: columns,
+	}, nil
+}
+
+// QueryOneMap runs a query that should return a single row and
+// returns it as a map indexed by the column names, e.g.:
+//
+//	row, err := c.QueryOneMap(ctx, "SELECT * FROM users WHERE id = $1", userID)
+//
+// NULL values are stored as nil entries on the map. If the query
+// returns no rows ErrRecordNotFound is returned, and unlike QueryOne
+// if it returns more than one row ErrMultipleRecordsFound is returned.
+func (c DB) QueryOneMap(ctx context.Context, query string, params ...interface{}) (row map[string]interface{}, err error) {
+	ctx, finish := c.observe(ctx, "query_one_map", "")
+	defer func() { finish(err) }()
+
+	it, err := c.QueryMapIter(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	row, ok := it.Next()
+	if !ok {
+		if it.Err() != nil {
+			return nil, it.Err()
+		}
+		return nil, ErrRecordNotFound
+	}
+
+	if _, ok := it.Next(); ok {
+		return nil, ErrMultipleRecordsFound
+	}
+	if it.Err() != nil {
+		return nil, it.Err()
+	}
+
+	return row, nil
+}
+
+// Next reads the next row of the query, returning false
+// when there are no more rows or an error has occurred,
+// in which case the error is available on Err.
+func (it *MapIterator) Next() (map[string]interface{}, bool) {
+	if it.closed {
+		return nil, false
+	}
+
+	if !it.rows.Next() {
+		it.err = it.rows.Err()
+		it.Close()
+		return nil, false
+	}
+
+	values := make([]interface{}, len(it.columns))
+	scanArgs := make([]interface{}, len(it.columns))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	err := it.rows.Scan(scanArgs...)
+	if err != nil {
+		it.err = fmt.Errorf("ksql: error scanning row: %w", err)
+		it.Close()
+		return nil, false
+	}
+
+	row := make(map[string]interface{}, len(it.columns))
+	for i, column := range it.columns {
+		row[column] = values[i]
+	}
+
+	return row, true
+}
+
+// Err returns the error that interrupted the iteration, if any.
+func (it *MapIterator) Err() error {
+	return it.err
+}
+
+// Close releases the rows of the query, it is safe to call
+// it more than once and after the iteration has finished.
+func (it *MapIterator) Close() error {
+	if it.closed {
+		return nil
+	}
+	it.closed = true
+
+	err := it.rows.Close()
+	if err != nil && it.err == nil {
+		it.err = err
+	}
+	return err
+}
@@ -0,0 +1,450 @@
+package ksql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/vingarcia/ksql/internal/structs"
+)
+
+// Merge inserts the input record or, if it conflicts with an existing
+// row on the conflictColumns, updates only the updateColumns of that
+// row, preserving all the others, e.g.:
+//
+//	err := c.Merge(ctx, UsersTable, &user, []string{"email"}, []string{"name", "updated_at"})
+//
+// All the columns must be tagged on the record struct and at least one
+// conflict and one update column are required. The update columns must
+// also be set on the record, i.e. they can't be nil pointers or unset
+// IDs, since their new values are the ones of the inserted record.
+// On mysql the conflicts are detected using the unique keys of the
+// table, so the conflictColumns are only validated, and on sqlserver
+// the conflict columns must also be set on the record since a MERGE
+// statement is used.
+//
+// Unlike Insert the IDs are not written back to the record.
+func (c DB) Merge(
+	ctx context.Context,
+	table Table,
+	record interface{},
+	conflictColumns []string,
+	updateColumns []string,
+) (err error) {
+	ctx, finish := c.observe(ctx, "merge", table.name)
+	defer func() { finish(err) }()
+
+	t, info, err := prepareMerge(table, record, c.getColumnResolver())
+	if err != nil {
+		return err
+	}
+
+	query, params, err := buildMergeQuery(c.dialect, table, t, info, record, conflictColumns, updateColumns, c.getColumnResolver())
+	if err != nil {
+		return err
+	}
+	c.convertParamsToLocation(params)
+
+	_, err = c.execContext(ctx, query, params...)
+	if err != nil {
+		return fmt.Errorf("ksql: Merge into %q failed: %w", table.name, err)
+	}
+
+	return nil
+}
+
+// MergeReturningInserted works like Merge but also reports
+// if the record was inserted or if an existing row was updated, e.g.:
+//
+//	inserted, err := c.MergeReturningInserted(ctx, UsersTable, &user, []string{"email"}, []string{"name"})
+//
+// The mechanism used for detecting the insertions depends on the driver:
+//
+//   - postgres: uses the `xmax = 0` check on the RETURNING clause,
+//     which relies on an implementation detail of postgres but
+//     is reliable on all the currently supported versions.
+//   - sqlserver: uses the `$action` column of the OUTPUT clause, which is reliable.
+//   - mysql: uses the number of affected rows, which is 1 for inserted rows.
+//     This is not reliable if the `clientFoundRows` option is enabled on
+//     the connection, since then updates that change no values are
+//     reported as insertions.
+//   - sqlite3: checks if a row with the conflict columns exists inside the
+//     same transaction of the Merge, which is reliable since sqlite
+//     doesn't allow concurrent writes.
+func (c DB) MergeReturningInserted(
+	ctx context.Context,
+	table Table,
+	record interface{},
+	conflictColumns []string,
+	updateColumns []string,
+) (inserted bool, err error) {
+	ctx, finish := c.observe(ctx, "merge_returning_inserted", table.name)
+	defer func() { finish(err) }()
+
+	t, info, err := prepareMerge(table, record, c.getColumnResolver())
+	if err != nil {
+		return false, err
+	}
+
+	query, params, err := buildMergeQuery(c.dialect, table, t, info, record, conflictColumns, updateColumns, c.getColumnResolver())
+	if err != nil {
+		return false, err
+	}
+	c.convertParamsToLocation(params)
+
+	switch c.dialect.DriverName() {
+	case "postgres":
+		err = c.queryMergeOutcome(ctx, query+" RETURNING (xmax = 0) AS inserted", params, &inserted)
+	case "sqlserver":
+		var action string
+		err = c.queryMergeOutcome(ctx, strings.TrimSuffix(query, ";")+" OUTPUT $action;", params, &action)
+		inserted = action == "INSERT"
+	case "mysql":
+		var result Result
+		result, err = c.execContext(ctx, query, params...)
+		if err != nil {
+			break
+		}
+
+		var n int64
+		n, err = result.RowsAffected()
+		inserted = n == 1
+	default:
+		err = c.Transaction(ctx, func(p Provider) error {
+			tx := p.(DB)
+
+			var err error
+			inserted, err = tx.checkMergeInsertion(ctx, table, t, info, record, conflictColumns)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.execContext(ctx, query, params...)
+			return err
+		})
+	}
+	if err != nil {
+		return false, fmt.Errorf("ksql: Merge into %q failed: %w", table.name, err)
+	}
+
+	return inserted, nil
+}
+
+// UpsertReturning inserts the input record or, if it conflicts
+// with an existing row on the conflictColumns, updates all the other
+// columns of that row, and then loads the row back into the record,
+// so after the call the record reflects the state of the database
+// whether it was inserted or updated, e.g.:
+//
+//	err := c.UpsertReturning(ctx, UsersTable, &user, []string{"email"})
+//
+// The updated columns are all the columns set on the record except
+// for the conflict columns, the ID columns and the readonly columns,
+// and at least one of them is required. The columns that are not set
+// on the record, e.g. nil pointers, are kept as they are on updates.
+//
+// On postgres and sqlite3 the row is loaded using the RETURNING clause
+// and on sqlserver the OUTPUT clause, on mysql, which supports neither,
+// the row is reloaded by the conflict columns inside the same transaction
+// of the upsert, so on mysql all the conflict columns must be set on the record.
+func (c DB) UpsertReturning(
+	ctx context.Context,
+	table Table,
+	record interface{},
+	conflictColumns []string,
+) (err error) {
+	ctx, finish := c.observe(ctx, "upsert_returning", table.name)
+	defer func() { finish(err) }()
+
+	t, info, err := prepareMerge(table, record, c.getColumnResolver())
+	if err != nil {
+		return err
+	}
+
+	if info.IsNestedStruct {
+		return fmt.Errorf("ksql: UpsertReturning doesn't support nested structs")
+	}
+
+	columnNames, values, err := buildInsertColumnsAndParams(c.dialect, table, t, info, record, c.getColumnResolver())
+	if err != nil {
+		return err
+	}
+
+	updateColumns := []string{}
+	for _, column := range columnNames {
+		if indexOfString(conflictColumns, column) == -1 &&
+			indexOfString(table.idColumns, column) == -1 &&
+			!info.ByName(column).ReadOnly {
+			updateColumns = append(updateColumns, column)
+		}
+	}
+
+	query, params, err := buildMergeQuery(c.dialect, table, t, info, record, conflictColumns, updateColumns, c.getColumnResolver())
+	if err != nil {
+		return err
+	}
+	c.convertParamsToLocation(params)
+
+	var columns, outputColumns []string
+	for i := 0; i < t.Elem().NumField(); i++ {
+		fieldInfo := info.ByIndex(i)
+		if !fieldInfo.Valid {
+			continue
+		}
+
+		column := c.dialect.Escape(fieldInfo.Name)
+		columns = append(columns, column)
+		outputColumns = append(outputColumns, "inserted."+column)
+	}
+
+	// The upsert queries can't run inside the subqueries used by the scopes:
+	unscoped := c
+	unscoped.scopes = nil
+
+	switch c.dialect.DriverName() {
+	case "postgres", "sqlite3":
+		err = unscoped.QueryOne(ctx, record, query+" RETURNING "+strings.Join(columns, ", "), params...)
+	case "sqlserver":
+		err = unscoped.QueryOne(ctx, record, strings.TrimSuffix(query, ";")+" OUTPUT "+strings.Join(outputColumns, ", ")+";", params...)
+	default:
+		conditions := []string{}
+		keyParams := []interface{}{}
+		for _, column := range conflictColumns {
+			i := indexOfString(columnNames, column)
+			if i == -1 {
+				return fmt.Errorf("ksql: the conflict column `%s` must be set on the record for running UpsertReturning on %s", column, c.dialect.DriverName())
+			}
+
+			conditions = append(conditions, c.dialect.Escape(column)+" = "+c.dialect.Placeholder(len(keyParams)))
+			keyParams = append(keyParams, values[i])
+		}
+		c.convertParamsToLocation(keyParams)
+
+		err = unscoped.Transaction(ctx, func(p Provider) error {
+			tx := p.(DB)
+			_, err := tx.execContext(ctx, query, params...)
+			if err != nil {
+				return err
+			}
+
+			return tx.QueryOne(ctx, record,
+				"SELECT "+strings.Join(columns, ", ")+" FROM "+c.dialect.Escape(table.name)+" WHERE "+strings.Join(conditions, " AND "),
+				keyParams...,
+			)
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("ksql: UpsertReturning into %q failed: %w", table.name, err)
+	}
+
+	return nil
+}
+
+// prepareMerge validates the input record of the Merge functions.
+func prepareMerge(
+	table Table,
+	record interface{},
+	resolver *structs.ColumnResolver,
+) (reflect.Type, structs.StructInfo, error) {
+	v := reflect.ValueOf(record)
+	t := v.Type()
+	if err := assertStructPtr(t); err != nil {
+		return nil, structs.StructInfo{}, fmt.Errorf(
+			"ksql: expected record to be a pointer to struct, but got: %T",
+			record,
+		)
+	}
+
+	if v.IsNil() {
+		return nil, structs.StructInfo{}, fmt.Errorf("ksql: expected a valid pointer to struct as argument but received a nil pointer: %v", record)
+	}
+
+	if err := table.validate(); err != nil {
+		return nil, structs.StructInfo{}, fmt.Errorf("can't insert in ksql.Table: %s", err)
+	}
+
+	info, err := structs.GetTagInfoWithResolver(t.Elem(), resolver)
+	if err != nil {
+		return nil, structs.StructInfo{}, err
+	}
+
+	return t, info, nil
+}
+
+// queryMergeOutcome runs a Merge query returning a single
+// row with a single column and scans it into the outcome.
+func (c DB) queryMergeOutcome(ctx context.Context, query string, params []interface{}, outcome interface{}) error {
+	rows, err := c.queryContext(ctx, query, params...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if rows.Err() != nil {
+			return rows.Err()
+		}
+		return fmt.Errorf("ksql: the Merge query returned no rows")
+	}
+
+	err = rows.Scan(outcome)
+	if err != nil {
+		return err
+	}
+
+	return rows.Close()
+}
+
+// checkMergeInsertion reports if no row matches the values
+// of the conflict columns of the record, meaning that
+// a Merge with this record would insert it.
+func (c DB) checkMergeInsertion(
+	ctx context.Context,
+	table Table,
+	t reflect.Type,
+	info structs.StructInfo,
+	record interface{},
+	conflictColumns []string,
+) (bool, error) {
+	columnNames, values, err := buildInsertColumnsAndParams(c.dialect, table, t, info, record, c.getColumnResolver())
+	if err != nil {
+		return false, err
+	}
+
+	conditions := []string{}
+	params := []interface{}{}
+	for _, column := range conflictColumns {
+		i := indexOfString(columnNames, column)
+		if i == -1 {
+			// Columns that are not set are inserted as NULL
+			// and NULL values never conflict with other rows:
+			return true, nil
+		}
+
+		conditions = append(conditions, c.dialect.Escape(column)+" = "+c.dialect.Placeholder(len(params)))
+		params = append(params, values[i])
+	}
+	c.convertParamsToLocation(params)
+
+	rows, err := c.queryContext(ctx,
+		"SELECT 1 FROM "+c.dialect.Escape(table.name)+" WHERE "+strings.Join(conditions, " AND "),
+		params...,
+	)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	exists := rows.Next()
+	if rows.Err() != nil {
+		return false, rows.Err()
+	}
+
+	return !exists, rows.Close()
+}
+
+func buildMergeQuery(
+	dialect Dialect,
+	table Table,
+	t reflect.Type,
+	info structs.StructInfo,
+	record interface{},
+	conflictColumns []string,
+	updateColumns []string,
+	resolver *structs.ColumnResolver,
+) (query string, params []interface{}, err error) {
+	if len(conflictColumns) == 0 {
+		return "", nil, fmt.Errorf("ksql: Merge requires at least one conflict column")
+	}
+	if len(updateColumns) == 0 {
+		return "", nil, fmt.Errorf("ksql: Merge requires at least one update column")
+	}
+
+	allColumns := append(append([]string{}, conflictColumns...), updateColumns...)
+	for _, column := range allColumns {
+		if !info.ByName(column).Valid {
+			return "", nil, fmt.Errorf("ksql: the column `%s` is not tagged on type %v", column, t.Elem())
+		}
+	}
+
+	for _, column := range updateColumns {
+		if info.ByName(column).ReadOnly {
+			return "", nil, fmt.Errorf("ksql: the column `%s` is tagged as readonly and can't be updated", column)
+		}
+	}
+
+	columnNames, params, err := buildInsertColumnsAndParams(dialect, table, t, info, record, resolver)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Otherwise the column would be updated with the value of the
+	// column on the row that failed to be inserted, i.e. NULL or
+	// its default value, instead of a value from the record:
+	for _, column := range updateColumns {
+		if indexOfString(columnNames, column) == -1 {
+			return "", nil, fmt.Errorf("ksql: the update column `%s` must be set on the record for running Merge", column)
+		}
+	}
+
+	escapedColumnNames := make([]string, len(columnNames))
+	valuesQuery := make([]string, len(columnNames))
+	for i, col := range columnNames {
+		escapedColumnNames[i] = dialect.Escape(col)
+		valuesQuery[i] = dialect.Placeholder(i)
+	}
+
+	// formatColumns escapes each column and replaces
+	// all the `%s` directives of the format with it:
+	formatColumns := func(columns []string, format string) []string {
+		formatted := make([]string, len(columns))
+		for i, col := range columns {
+			formatted[i] = strings.Replace(format, "%s", dialect.Escape(col), -1)
+		}
+		return formatted
+	}
+
+	tableName := dialect.Escape(table.name)
+	switch dialect.DriverName() {
+	case "mysql":
+		query = fmt.Sprintf(
+			"INSERT INTO %s (%s) VALUES (%s) ON DUPLICATE KEY UPDATE %s",
+			tableName,
+			strings.Join(escapedColumnNames, ", "),
+			strings.Join(valuesQuery, ", "),
+			strings.Join(formatColumns(updateColumns, "%s = VALUES(%s)"), ", "),
+		)
+	case "sqlserver":
+		for _, column := range allColumns {
+			if indexOfString(columnNames, column) == -1 {
+				return "", nil, fmt.Errorf("ksql: the column `%s` must be set on the record for running Merge on sqlserver", column)
+			}
+		}
+
+		query = fmt.Sprintf(
+			"MERGE INTO %s WITH (HOLDLOCK) AS ksql_target"+
+				" USING (VALUES (%s)) AS ksql_source (%s) ON %s"+
+				" WHEN MATCHED THEN UPDATE SET %s"+
+				" WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s);",
+			tableName,
+			strings.Join(valuesQuery, ", "),
+			strings.Join(escapedColumnNames, ", "),
+			strings.Join(formatColumns(conflictColumns, "ksql_target.%s = ksql_source.%s"), " AND "),
+			strings.Join(formatColumns(updateColumns, "%s = ksql_source.%s"), ", "),
+			strings.Join(escapedColumnNames, ", "),
+			strings.Join(formatColumns(columnNames, "ksql_source.%s"), ", "),
+		)
+	default:
+		query = fmt.Sprintf(
+			"INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO UPDATE SET %s",
+			tableName,
+			strings.Join(escapedColumnNames, ", "),
+			strings.Join(valuesQuery, ", "),
+			strings.Join(formatColumns(conflictColumns, "%s"), ", "),
+			strings.Join(formatColumns(updateColumns, "%s = EXCLUDED.%s"), ", "),
+		)
+	}
+
+	return query, params, nil
+}
@@ -0,0 +1,50 @@
+package ksql
+
+import (
+	"context"
+	"time"
+)
+
+// Metrics is the interface used by WithMetrics for reporting
+// the duration of the operations, e.g. for feeding a histogram
+// of latencies per operation and table.
+//
+// The op argument is the name of the DB method in snake case,
+// e.g. "query_one", "insert" or "patch", and the table argument
+// is the name of the table for the methods that receive a Table,
+// or an empty string otherwise.
+type Metrics interface {
+	ObserveQuery(op string, table string, d time.Duration, err error)
+}
+
+// WithMetrics returns a copy of the DB that reports the duration
+// and the error of each call to its methods to the input metrics,
+// passing nil disables it, which is the default, e.g.:
+//
+//	db = db.WithMetrics(myPrometheusMetrics)
+//
+// Only the method called by the user is observed, i.e. the operations
+// it runs internally, e.g. the QueryOne of a PatchAndReload, are not
+// reported separately. The operations run inside of a Transaction are
+// reported on their own as long as they don't use the context of
+// another observed operation.
+func (c DB) WithMetrics(metrics Metrics) DB {
+	c.metrics = metrics
+	return c
+}
+
+type metricsCtxKey struct{}
+
+// observe starts measuring an operation and returns the function that
+// should be called with its error once it finishes, the returned context
+// is marked so the nested operations using it are not observed.
+func (c DB) observe(ctx context.Context, op string, table string) (context.Context, func(err error)) {
+	if c.metrics == nil || ctx.Value(metricsCtxKey{}) != nil {
+		return ctx, func(error) {}
+	}
+
+	start := time.Now()
+	return context.WithValue(ctx, metricsCtxKey{}, true), func(err error) {
+		c.metrics.ObserveQuery(op, table, time.Since(start), err)
+	}
+}
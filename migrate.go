@@ -0,0 +1,164 @@
+//go:build go1.16
+// +build go1.16
+
+package ksql
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// migrationsTable is the table used by Migrate
+// for storing the versions already applied.
+const migrationsTable = "schema_migrations"
+
+type migration struct {
+	version  int64
+	filename string
+}
+
+// Migrate applies, in order, all the pending migrations stored as `.sql`
+// files on the input directory of the fsys, e.g. using an embed.FS:
+//
+//	//go:embed migrations/*.sql
+//	var migrations embed.FS
+//
+//	err := c.Migrate(ctx, migrations, "migrations")
+//
+// The name of each file must start with its version number, e.g.
+// `0001_create_users.sql`, and the applied versions are stored on the
+// `schema_migrations` table, which is created if it doesn't exist.
+//
+// Each migration runs inside its own transaction together with the
+// insertion of its version, so a failed migration is not marked as
+// applied. Only up migrations are supported.
+//
+// The content of each file is sent to the database in a single Exec call,
+// so files with multiple statements depend on the driver supporting it,
+// e.g. on mysql the `multiStatements=true` param is required.
+func (c DB) Migrate(ctx context.Context, fsys fs.FS, dir string) (err error) {
+	ctx, finish := c.observe(ctx, "migrate", "")
+	defer func() { finish(err) }()
+
+	migrations, err := readMigrations(fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	// The scopes and the default ORDER BY of the DB should not affect the migrations:
+	c.scopes = nil
+	c.defaultOrderBy = ""
+
+	err = c.createMigrationsTable(ctx)
+	if err != nil {
+		return err
+	}
+
+	var applied []struct {
+		Version int64 `ksql:"version"`
+	}
+	err = c.Query(ctx, &applied, "SELECT version FROM "+c.dialect.Escape(migrationsTable))
+	if err != nil {
+		return fmt.Errorf("ksql: error loading the applied migrations: %w", err)
+	}
+
+	appliedVersions := map[int64]bool{}
+	for _, a := range applied {
+		appliedVersions[a.Version] = true
+	}
+
+	for _, m := range migrations {
+		if appliedVersions[m.version] {
+			continue
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, m.filename))
+		if err != nil {
+			return fmt.Errorf("ksql: error reading migration %s: %w", m.filename, err)
+		}
+
+		err = c.Transaction(ctx, func(p Provider) error {
+			_, err := p.Exec(ctx, string(content))
+			if err != nil {
+				return err
+			}
+
+			_, err = p.Exec(ctx,
+				"INSERT INTO "+c.dialect.Escape(migrationsTable)+" (version) VALUES ("+c.dialect.Placeholder(0)+")",
+				m.version,
+			)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("ksql: error applying migration %s: %w", m.filename, err)
+		}
+	}
+
+	return nil
+}
+
+func (c DB) createMigrationsTable(ctx context.Context) error {
+	tableName := c.dialect.Escape(migrationsTable)
+	query := "CREATE TABLE IF NOT EXISTS " + tableName + " (version BIGINT PRIMARY KEY)"
+	if c.dialect.DriverName() == "sqlserver" {
+		query = fmt.Sprintf(
+			"IF OBJECT_ID('%s', 'U') IS NULL CREATE TABLE %s (version BIGINT PRIMARY KEY)",
+			migrationsTable, tableName,
+		)
+	}
+
+	_, err := c.Exec(ctx, query)
+	if err != nil {
+		return fmt.Errorf("ksql: error creating the %s table: %w", migrationsTable, err)
+	}
+
+	return nil
+}
+
+// readMigrations lists the `.sql` files of the directory
+// sorted by the version number on the start of their names.
+func readMigrations(fsys fs.FS, dir string) ([]migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("ksql: error reading the migrations directory: %w", err)
+	}
+
+	migrations := []migration{}
+	filenamesByVersion := map[int64]string{}
+	for _, entry := range entries {
+		filename := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(filename, ".sql") {
+			continue
+		}
+
+		digitsEnd := strings.IndexFunc(filename, func(r rune) bool {
+			return !unicode.IsDigit(r)
+		})
+		version, err := strconv.ParseInt(filename[:digitsEnd], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("ksql: the migration %s should start with its version number, e.g. 0001_create_users.sql", filename)
+		}
+
+		if otherFilename, found := filenamesByVersion[version]; found {
+			return nil, fmt.Errorf("ksql: the migrations %s and %s have the same version: %d", otherFilename, filename, version)
+		}
+		filenamesByVersion[version] = filename
+
+		migrations = append(migrations, migration{
+			version:  version,
+			filename: filename,
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+
+	return migrations, nil
+}
@@ -0,0 +1,52 @@
+//go:build go1.16
+// +build go1.16
+
+package ksql
+
+import (
+	"testing"
+	"testing/fstest"
+
+	tt "github.com/vingarcia/ksql/internal/testtools"
+)
+
+func TestReadMigrations(t *testing.T) {
+	t.Run("should sort the sql files by version", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"migrations/10_add_index.sql":       {Data: []byte("CREATE INDEX ...")},
+			"migrations/0002_create_posts.sql":  {Data: []byte("CREATE TABLE posts ...")},
+			"migrations/0001_create_users.sql":  {Data: []byte("CREATE TABLE users ...")},
+			"migrations/README.md":              {Data: []byte("not a migration")},
+			"migrations/old/0003_ignored.sql":   {Data: []byte("not a migration")},
+			"other_migrations/0004_ignored.sql": {Data: []byte("not a migration")},
+		}
+
+		migrations, err := readMigrations(fsys, "migrations")
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, migrations, []migration{
+			{version: 1, filename: "0001_create_users.sql"},
+			{version: 2, filename: "0002_create_posts.sql"},
+			{version: 10, filename: "10_add_index.sql"},
+		})
+	})
+
+	t.Run("should report error for files without a version", func(t *testing.T) {
+		_, err := readMigrations(fstest.MapFS{
+			"migrations/create_users.sql": {Data: []byte("CREATE TABLE users ...")},
+		}, "migrations")
+		tt.AssertErrContains(t, err, "create_users.sql", "version number")
+	})
+
+	t.Run("should report error for repeated versions", func(t *testing.T) {
+		_, err := readMigrations(fstest.MapFS{
+			"migrations/1_create_users.sql":   {Data: []byte("CREATE TABLE users ...")},
+			"migrations/001_create_posts.sql": {Data: []byte("CREATE TABLE posts ...")},
+		}, "migrations")
+		tt.AssertErrContains(t, err, "1_create_users.sql", "001_create_posts.sql", "same version")
+	})
+
+	t.Run("should report error if the directory doesn't exist", func(t *testing.T) {
+		_, err := readMigrations(fstest.MapFS{}, "migrations")
+		tt.AssertErrContains(t, err, "migrations directory")
+	})
+}
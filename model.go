@@ -0,0 +1,198 @@
+package ksql
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/vingarcia/ksql/internal/structs"
+)
+
+// AssertModel checks the `ksql` tags of the input struct (or pointer
+// to struct) for mistakes that would otherwise only be noticed at
+// query time, or not at all, e.g.:
+//
+//	if err := ksql.AssertModel(&User{}); err != nil {
+//		log.Fatal(err)
+//	}
+//
+// It reports attributes tagged with the same column name, tags
+// with an empty column name, e.g. `ksql:",json"`, unknown tag options,
+// tagged attributes that are not exported and `tablename` tags
+// that are ignored because the struct also has `ksql` tags.
+//
+// For structs used for JOINs, i.e. tagged with `tablename`,
+// each of the nested structs is also checked.
+//
+// It is meant to be called on startup or on tests, and the
+// returned error lists all the problems found on the struct.
+func AssertModel(model interface{}) error {
+	t := reflect.TypeOf(model)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return fmt.Errorf("ksql: expected model to be a struct or a pointer to struct, but got: %T", model)
+	}
+
+	problems := getModelProblems(t)
+	if len(problems) > 0 {
+		return fmt.Errorf("ksql: invalid model %v:\n- %s", t, strings.Join(problems, "\n- "))
+	}
+
+	// Checking any other error reported when the tags are parsed:
+	_, err := structs.GetTagInfo(t)
+	if err != nil {
+		return fmt.Errorf("ksql: invalid model %v: %s", t, err)
+	}
+
+	return nil
+}
+
+// UseSnakeCaseColumnNames enables or disables deriving the column names
+// of the attributes without a `ksql` tag by converting their names to
+// snake_case, e.g.:
+//
+//	ksql.UseSnakeCaseColumnNames(true)
+//
+//	type User struct {
+//		UserID    int    // column `user_id`
+//		AvatarURL string // column `avatar_url`
+//		Name      string `ksql:"full_name"`
+//		Password  string `ksql:"-"` // ignored
+//	}
+//
+// Acronyms are kept together, so `HTTPServerURL` becomes
+// `http_server_url`. The attributes that are not exported,
+// embedded structs and attributes tagged with `ksql:"-"` are ignored,
+// and structs with `tablename` tags, used for JOINs, are not affected.
+//
+// It is disabled by default and since it affects all the structs
+// used by ksql it should be called once on startup.
+func UseSnakeCaseColumnNames(enabled bool) {
+	structs.SetSnakeCaseUntaggedFields(enabled)
+}
+
+// WithColumnResolver returns a copy of the DB that works like
+// UseSnakeCaseColumnNames but derives the column names of the attributes
+// without a `ksql` tag with the input function, allowing any naming
+// convention, e.g.:
+//
+//	db = db.WithColumnResolver(strings.ToUpper)
+//
+//	type User struct {
+//		ID   int    // column `ID`
+//		Name string // column `NAME`
+//	}
+//
+// Attributes resolved to an empty string are ignored, and passing nil
+// makes the DB use only the tagged attributes even if UseSnakeCaseColumnNames
+// is enabled, which is the setting used by the DBs without a resolver.
+//
+// The column names of each type are cached by resolver, so it is called only
+// once per attribute. Since each call to WithColumnResolver creates a new
+// resolver, the returned DB should be created once and reused.
+func (c DB) WithColumnResolver(resolver func(fieldName string) string) DB {
+	c.columnResolver = structs.NewColumnResolver(resolver)
+	return c
+}
+
+// getColumnResolver returns the resolver set with WithColumnResolver
+// or the global one, set with UseSnakeCaseColumnNames.
+func (c DB) getColumnResolver() *structs.ColumnResolver {
+	if c.columnResolver != nil {
+		return c.columnResolver
+	}
+	return structs.DefaultColumnResolver()
+}
+
+// getTagInfo returns the information of the struct type
+// using the column resolver of the DB.
+func (c DB) getTagInfo(t reflect.Type) (structs.StructInfo, error) {
+	return structs.GetTagInfoWithResolver(t, c.getColumnResolver())
+}
+
+func getModelProblems(t reflect.Type) (problems []string) {
+	attrsByColumn := map[string]string{}
+	hasKsqlTags := false
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, found := field.Tag.Lookup("ksql")
+		if !found {
+			continue
+		}
+		hasKsqlTags = true
+
+		if field.PkgPath != "" {
+			problems = append(problems, fmt.Sprintf(
+				"attribute `%s` is tagged but not exported, so ksql can't read or write it",
+				field.Name,
+			))
+		}
+
+		options := strings.Split(tag, ",")
+		name := options[0]
+		if name == "" && !structs.HasColumnResolver() {
+			problems = append(problems, fmt.Sprintf("attribute `%s` has an empty ksql tag name", field.Name))
+		}
+
+		for _, option := range options[1:] {
+			switch {
+			case option == "json", option == "omitempty", option == "readonly":
+			case option == "unix", option == "unixmilli":
+			case strings.HasPrefix(option, "default="):
+			default:
+				problems = append(problems, fmt.Sprintf(
+					"attribute `%s` has an unknown ksql tag option: `%s`",
+					field.Name, option,
+				))
+			}
+		}
+
+		if name == "" {
+			continue
+		}
+
+		if otherAttr, found := attrsByColumn[name]; found {
+			problems = append(problems, fmt.Sprintf(
+				"attributes `%s` and `%s` are both tagged with the column name `%s`",
+				otherAttr, field.Name, name,
+			))
+			continue
+		}
+		attrsByColumn[name] = field.Name
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if _, found := field.Tag.Lookup("tablename"); !found {
+			continue
+		}
+
+		if hasKsqlTags {
+			problems = append(problems, fmt.Sprintf(
+				"attribute `%s` has a tablename tag, which is ignored since the struct also has ksql tags",
+				field.Name,
+			))
+			continue
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() != reflect.Struct {
+			problems = append(problems, fmt.Sprintf(
+				"attribute `%s` has a tablename tag but is not a struct: %v",
+				field.Name, field.Type,
+			))
+			continue
+		}
+
+		for _, problem := range getModelProblems(fieldType) {
+			problems = append(problems, fmt.Sprintf("on attribute `%s`: %s", field.Name, problem))
+		}
+	}
+
+	return problems
+}
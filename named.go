@@ -0,0 +1,143 @@
+package ksql
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/vingarcia/ksql/internal/structs"
+)
+
+// NamedParams stores a struct whose attributes should be used as
+// the values of the named placeholders of a query, it should
+// be created with the Named function.
+type NamedParams struct {
+	record interface{}
+}
+
+// Named allows the attributes of a struct to be used as the params
+// of a query, referencing them by their `ksql` tags with the
+// `@tag_name` syntax, e.g.:
+//
+//	type UsersFilter struct {
+//		TenantID int `ksql:"tenant_id"`
+//		MinAge   int `ksql:"min_age"`
+//	}
+//
+//	err := c.Query(ctx, &users,
+//		"FROM users WHERE tenant_id = @tenant_id AND age >= @min_age",
+//		ksql.Named(UsersFilter{TenantID: 42, MinAge: 18}),
+//	)
+//
+// The named placeholders are replaced with the placeholders
+// of the dialect, and the same name can be used more than once.
+// Nil pointer attributes are passed as NULL.
+//
+// It can be used with Query, QueryOne, QueryMap, QueryChunks
+// and Exec, and must be the only param of the query.
+func Named(record interface{}) NamedParams {
+	return NamedParams{record: record}
+}
+
+// bindNamedParams replaces the named placeholders of the query by the
+// dialect placeholders if the params contain a NamedParams value.
+func bindNamedParams(
+	dialect Dialect,
+	query string,
+	params []interface{},
+	resolver *structs.ColumnResolver,
+) (string, []interface{}, error) {
+	var named NamedParams
+	var found bool
+	for _, param := range params {
+		named, found = param.(NamedParams)
+		if found {
+			break
+		}
+	}
+
+	if !found {
+		return query, params, nil
+	}
+
+	if len(params) > 1 {
+		return "", nil, fmt.Errorf("ksql: ksql.Named() must be the only param of the query")
+	}
+
+	v := reflect.ValueOf(named.record)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return "", nil, fmt.Errorf("ksql: expected ksql.Named() to receive a struct or a pointer to struct, but got: %T", named.record)
+	}
+
+	info, err := structs.GetTagInfoWithResolver(v.Type(), resolver)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var b strings.Builder
+	boundParams := []interface{}{}
+	var quote rune
+	runes := []rune(query)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '@' && (i == 0 || runes[i-1] != '@'):
+			end := i + 1
+			for end < len(runes) && isIdentifierRune(runes[end], end == i+1) {
+				end++
+			}
+			if end == i+1 {
+				break
+			}
+
+			name := string(runes[i+1 : end])
+			fieldInfo := info.ByName(name)
+			if !fieldInfo.Valid {
+				return "", nil, fmt.Errorf("ksql: the named param `@%s` is not tagged on %v", name, v.Type())
+			}
+
+			b.WriteString(dialect.Placeholder(len(boundParams)))
+			boundParams = append(boundParams, getNamedParamValue(dialect, v.Field(fieldInfo.Index), fieldInfo))
+			i = end - 1
+			continue
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String(), boundParams, nil
+}
+
+func isIdentifierRune(r rune, isFirst bool) bool {
+	if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+		return true
+	}
+	return !isFirst && r >= '0' && r <= '9'
+}
+
+func getNamedParamValue(dialect Dialect, field reflect.Value, fieldInfo *structs.FieldInfo) interface{} {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return nil
+		}
+		field = field.Elem()
+	}
+
+	if fieldInfo.SerializeAsJSON {
+		return jsonSerializable{
+			DriverName: dialect.DriverName(),
+			Attr:       field.Interface(),
+		}
+	}
+
+	return getUnixTimestampParam(fieldInfo, field.Interface())
+}
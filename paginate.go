@@ -0,0 +1,474 @@
+package ksql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/vingarcia/ksql/internal/structs"
+)
+
+// PaginateCursor loads a page of results using keyset pagination,
+// i.e. instead of skipping rows with an OFFSET it loads only
+// the rows with afterColumn greater than afterValue, e.g.:
+//
+//	var users []User
+//	cursor, err := c.PaginateCursor(ctx, &users, "id", nil, 100, "FROM users WHERE age > $1", 18)
+//	// ... then for the next page:
+//	cursor, err = c.PaginateCursor(ctx, &users, "id", cursor, 100, "FROM users WHERE age > $1", 18)
+//
+// The afterColumn must be a unique and sortable column tagged on the
+// struct and the baseQuery should not contain ORDER BY or LIMIT clauses
+// since these are added by this function.
+//
+// Passing a nil afterValue loads the first page.
+//
+// The returned cursor is the afterColumn value of the last row loaded,
+// and should be used as the afterValue for loading the next page.
+// When there are no more pages the returned cursor is nil.
+func (c DB) PaginateCursor(
+	ctx context.Context,
+	records interface{},
+	afterColumn string,
+	afterValue interface{},
+	limit int,
+	baseQuery string,
+	params ...interface{},
+) (nextCursor interface{}, err error) {
+	ctx, finish := c.observe(ctx, "paginate_cursor", "")
+	defer func() { finish(err) }()
+
+	if err := ValidateIdentifier(afterColumn); err != nil {
+		return nil, fmt.Errorf("ksql: invalid cursor column: %s", err)
+	}
+
+	if limit <= 0 {
+		return nil, fmt.Errorf("ksql: expected limit to be a positive number, but got: %d", limit)
+	}
+
+	slicePtr := reflect.ValueOf(records)
+	if slicePtr.Kind() != reflect.Ptr {
+		return nil, fmt.Errorf("ksql: expected to receive a pointer to slice of structs, but got: %T", records)
+	}
+
+	structType, isSliceOfPtrs, err := structs.DecodeAsSliceOfStructs(slicePtr.Type().Elem())
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := c.getTagInfo(structType)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsNestedStruct {
+		return nil, fmt.Errorf("ksql: PaginateCursor doesn't support nested structs")
+	}
+
+	cursorField := info.ByName(afterColumn)
+	if !cursorField.Valid {
+		return nil, fmt.Errorf("ksql: the cursor column `%s` is not tagged on type %v", afterColumn, structType)
+	}
+
+	baseQuery, err = c.buildSelectPrefixIfOmitted(baseQuery, structType, info)
+	if err != nil {
+		return nil, err
+	}
+
+	// The scopes must be applied before the LIMIT,
+	// otherwise the pages could be shorter than expected:
+	baseQuery, params, err = c.applyScopesToQuery(baseQuery, params, info)
+	if err != nil {
+		return nil, err
+	}
+	unscoped := c
+	unscoped.scopes = nil
+
+	query, params := buildCursorPageQuery(c.dialect, baseQuery, params, afterColumn, afterValue, limit)
+
+	// Scanning into a new slice since Query overwrites the existing
+	// elements of slices of structs without truncating them, so a
+	// reused slice would keep the rows of the previous pages:
+	pagePtr := reflect.New(slicePtr.Type().Elem())
+	err = unscoped.Query(ctx, pagePtr.Interface(), query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	slice := pagePtr.Elem()
+	slicePtr.Elem().Set(slice)
+	if slice.Len() < limit {
+		return nil, nil
+	}
+
+	lastRecord := slice.Index(slice.Len() - 1)
+	if isSliceOfPtrs {
+		lastRecord = lastRecord.Elem()
+	}
+
+	return lastRecord.Field(cursorField.Index).Interface(), nil
+}
+
+func buildCursorPageQuery(
+	dialect Dialect,
+	baseQuery string,
+	params []interface{},
+	afterColumn string,
+	afterValue interface{},
+	limit int,
+) (query string, args []interface{}) {
+	args = append([]interface{}{}, params...)
+	baseQuery = strings.TrimRight(strings.TrimSpace(baseQuery), ";")
+	column := dialect.Escape(afterColumn)
+
+	var whereQuery string
+	if afterValue != nil {
+		whereQuery = fmt.Sprintf(" WHERE %s > %s", column, dialect.Placeholder(len(args)))
+		args = append(args, afterValue)
+	}
+
+	limitQuery := fmt.Sprintf("LIMIT %s", dialect.Placeholder(len(args)))
+	if dialect.DriverName() == "sqlserver" {
+		limitQuery = fmt.Sprintf("OFFSET 0 ROWS FETCH NEXT %s ROWS ONLY", dialect.Placeholder(len(args)))
+	}
+	args = append(args, limit)
+
+	query = fmt.Sprintf(
+		"SELECT * FROM (%s) AS ksql_page%s ORDER BY %s %s",
+		baseQuery, whereQuery, column, limitQuery,
+	)
+
+	return query, args
+}
+
+// Paginate loads a page of results using offset pagination,
+// also reporting if there is a next page without counting
+// all the results of the query, e.g.:
+//
+//	var users []User
+//	hasNext, err := c.Paginate(ctx, &users, 2, 20, "FROM users WHERE age > $1 ORDER BY name", 18)
+//
+// Pages are numbered starting at 1, and in order to check for the next
+// page pageSize+1 rows are loaded and the extra row is trimmed from the
+// records, so when hasNext is true the records contain exactly pageSize rows.
+//
+// The baseQuery should have an ORDER BY clause, otherwise the order of
+// the rows is not guaranteed to be the same across pages, and it should
+// not contain LIMIT or OFFSET clauses since these are added by this function.
+func (c DB) Paginate(
+	ctx context.Context,
+	records interface{},
+	page int,
+	pageSize int,
+	baseQuery string,
+	params ...interface{},
+) (hasNext bool, err error) {
+	ctx, finish := c.observe(ctx, "paginate", "")
+	defer func() { finish(err) }()
+
+	if page <= 0 {
+		return false, fmt.Errorf("ksql: expected page to be a positive number, but got: %d", page)
+	}
+
+	if pageSize <= 0 {
+		return false, fmt.Errorf("ksql: expected pageSize to be a positive number, but got: %d", pageSize)
+	}
+
+	slicePtr := reflect.ValueOf(records)
+	if slicePtr.Kind() != reflect.Ptr {
+		return false, fmt.Errorf("ksql: expected to receive a pointer to slice of structs, but got: %T", records)
+	}
+
+	structType, _, err := structs.DecodeAsSliceOfStructs(slicePtr.Type().Elem())
+	if err != nil {
+		return false, err
+	}
+
+	info, err := c.getTagInfo(structType)
+	if err != nil {
+		return false, err
+	}
+
+	baseQuery, err = c.buildSelectPrefixIfOmitted(baseQuery, structType, info)
+	if err != nil {
+		return false, err
+	}
+
+	baseQuery = c.addDefaultOrderBy(baseQuery)
+
+	// The scopes must be applied before the LIMIT:
+	baseQuery, params, err = c.applyScopesBeforeLimit(baseQuery, params, info)
+	if err != nil {
+		return false, err
+	}
+	unscoped := c
+	unscoped.scopes = nil
+
+	query, params := buildOffsetPageQuery(c.dialect, baseQuery, params, (page-1)*pageSize, pageSize+1)
+
+	// Scanning into a new slice so only the rows of this page are
+	// counted, even if the records slice is reused across pages:
+	pagePtr := reflect.New(slicePtr.Type().Elem())
+	err = unscoped.Query(ctx, pagePtr.Interface(), query, params...)
+	if err != nil {
+		return false, err
+	}
+
+	slice := pagePtr.Elem()
+	hasNext = slice.Len() > pageSize
+	if hasNext {
+		slice = slice.Slice(0, pageSize)
+	}
+
+	slicePtr.Elem().Set(slice)
+	return hasNext, nil
+}
+
+// applyScopesBeforeLimit applies the scopes to a query that will receive
+// a LIMIT clause. The trailing semicolon of the query is removed
+// even without scopes, so the LIMIT can be appended to it.
+func (c DB) applyScopesBeforeLimit(
+	query string,
+	params []interface{},
+	info structs.StructInfo,
+) (string, []interface{}, error) {
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+	return c.applyScopesToQuery(query, params, info)
+}
+
+func buildOffsetPageQuery(
+	dialect Dialect,
+	baseQuery string,
+	params []interface{},
+	offset int,
+	limit int,
+) (query string, args []interface{}) {
+	args = append([]interface{}{}, params...)
+
+	if dialect.DriverName() == "sqlserver" {
+		query = fmt.Sprintf(
+			"%s OFFSET %s ROWS FETCH NEXT %s ROWS ONLY",
+			baseQuery, dialect.Placeholder(len(args)), dialect.Placeholder(len(args)+1),
+		)
+		return query, append(args, offset, limit)
+	}
+
+	query = fmt.Sprintf(
+		"%s LIMIT %s OFFSET %s",
+		baseQuery, dialect.Placeholder(len(args)), dialect.Placeholder(len(args)+1),
+	)
+	return query, append(args, limit, offset)
+}
+
+// CountOf returns the number of rows returned by the input query
+// without loading them, e.g.:
+//
+//	total, err := c.CountOf(ctx, "FROM users WHERE age > $1 ORDER BY name", 18)
+//
+// will run `SELECT count(*) FROM (SELECT 1 FROM users WHERE age > $1) AS ksql_count`.
+//
+// The SELECT part of the query can be omitted, and a trailing ORDER BY
+// is removed since it doesn't affect the count and some drivers don't
+// accept it inside subqueries.
+func (c DB) CountOf(ctx context.Context, baseQuery string, params ...interface{}) (count int64, err error) {
+	ctx, finish := c.observe(ctx, "count_of", "")
+	defer func() { finish(err) }()
+
+	baseQuery = strings.TrimRight(strings.TrimSpace(baseQuery), ";")
+	if strings.ToUpper(getFirstToken(baseQuery)) == "FROM" {
+		selectPrefix := "SELECT 1 "
+		if len(c.scopes) > 0 {
+			// The scopes need the columns to be selected:
+			selectPrefix = "SELECT * "
+		}
+		baseQuery = selectPrefix + baseQuery
+	}
+	baseQuery = stripTrailingOrderBy(baseQuery)
+
+	baseQuery, params, err = c.applyScopesToQuery(baseQuery, params, structs.StructInfo{})
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := c.queryContext(ctx, "SELECT count(*) FROM ("+baseQuery+") AS ksql_count", params...)
+	if err != nil {
+		return 0, fmt.Errorf("error running query: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if rows.Err() != nil {
+			return 0, rows.Err()
+		}
+		return 0, fmt.Errorf("ksql: unexpected error: the count query returned no rows")
+	}
+
+	err = rows.Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, rows.Close()
+}
+
+// CountByGroup runs an aggregate query returning exactly two columns per
+// row, a key and a count, and returns the counts indexed by key, e.g.:
+//
+//	counts, err := c.CountByGroup(ctx, "SELECT status, count(*) FROM orders GROUP BY status")
+//
+// Keys that are not strings are converted with fmt.Sprint,
+// and NULL keys are stored as empty strings.
+//
+// If the same key is returned more than once the counts are summed.
+func (c DB) CountByGroup(ctx context.Context, query string, params ...interface{}) (counts map[string]int64, err error) {
+	ctx, finish := c.observe(ctx, "count_by_group", "")
+	defer func() { finish(err) }()
+
+	rows, err := c.queryContext(ctx, query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("error running query: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) != 2 {
+		return nil, fmt.Errorf(
+			"ksql: expected CountByGroup query to return 2 columns (key, count), but got %d: %v",
+			len(columns), columns,
+		)
+	}
+
+	counts = map[string]int64{}
+	for rows.Next() {
+		var key interface{}
+		var count int64
+		err := rows.Scan(&key, &count)
+		if err != nil {
+			return nil, fmt.Errorf("ksql: error scanning CountByGroup row: %w", err)
+		}
+
+		counts[groupKeyToString(key)] += count
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return counts, rows.Close()
+}
+
+func groupKeyToString(key interface{}) string {
+	switch k := key.(type) {
+	case nil:
+		return ""
+	case string:
+		return k
+	case []byte:
+		return string(k)
+	default:
+		return fmt.Sprint(k)
+	}
+}
+
+// stripTrailingOrderBy removes the ORDER BY clause at the end of the
+// query, ORDER BY clauses inside parenthesis, quotes or followed
+// by a LIMIT, OFFSET or FETCH clause are kept.
+func stripTrailingOrderBy(query string) string {
+	orderByIdx := findTopLevelClause(query, "ORDER BY")
+	if orderByIdx == -1 {
+		return query
+	}
+
+	for _, token := range strings.Fields(strings.ToUpper(query[orderByIdx:])) {
+		switch token {
+		case "LIMIT", "OFFSET", "FETCH":
+			return query
+		}
+	}
+
+	return strings.TrimRightFunc(query[:orderByIdx], unicode.IsSpace)
+}
+
+// findTopLevelClause returns the index of the last occurrence of the
+// input clause on the query, e.g. "ORDER BY", ignoring the occurrences
+// inside parenthesis or quotes, or -1 if there are none.
+//
+// The clause must be written in upper case, and the match is case insensitive.
+func findTopLevelClause(query string, clause string) int {
+	upperQuery := strings.ToUpper(query)
+
+	clauseIdx := -1
+	depth := 0
+	var quote rune
+	for i, r := range upperQuery {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case depth == 0 && strings.HasPrefix(upperQuery[i:], clause) &&
+			(i == 0 || unicode.IsSpace(rune(upperQuery[i-1]))) &&
+			(i+len(clause) == len(upperQuery) || unicode.IsSpace(rune(upperQuery[i+len(clause)]))):
+			clauseIdx = i
+		}
+	}
+
+	return clauseIdx
+}
+
+// WithDefaultOrderBy returns a copy of the DB configured to sort the
+// results of the queries that don't have an ORDER BY clause, which
+// keeps the listings and the pages in a stable order, e.g.:
+//
+//	db := c.WithDefaultOrderBy("id")
+//	err := db.Query(ctx, &users, "FROM users WHERE age > $1", 18)
+//
+// will run `SELECT ... FROM users WHERE age > $1 ORDER BY id`.
+//
+// The clause is written as it is after the ORDER BY keyword, so it must not
+// be built from user input. It is applied by Query, QueryAll, Paginate and
+// First, and queries with LIMIT, OFFSET or FETCH clauses but no ORDER BY
+// are kept as they are, since the ORDER BY would be invalid after them.
+// On Query, when the DB has scopes the clause is applied outside of
+// the subquery used by the scopes, so it can only use the selected columns.
+func (c DB) WithDefaultOrderBy(clause string) DB {
+	c.defaultOrderBy = clause
+	return c
+}
+
+// needsDefaultOrderBy reports if the default ORDER BY configured with
+// WithDefaultOrderBy should be added to the input SELECT query.
+func (c DB) needsDefaultOrderBy(query string) bool {
+	if c.defaultOrderBy == "" || strings.ToUpper(getFirstToken(query)) != "SELECT" {
+		return false
+	}
+
+	for _, clause := range []string{"ORDER BY", "LIMIT", "OFFSET", "FETCH"} {
+		if findTopLevelClause(query, clause) != -1 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// addDefaultOrderBy adds the default ORDER BY configured with
+// WithDefaultOrderBy to the input query if it needs one.
+func (c DB) addDefaultOrderBy(query string) string {
+	if !c.needsDefaultOrderBy(query) {
+		return query
+	}
+
+	return strings.TrimRight(strings.TrimSpace(query), ";") + " ORDER BY " + c.defaultOrderBy
+}
@@ -0,0 +1,147 @@
+package ksql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Pluck reads a single column of each row of a
+// query into a slice of primitive values, e.g.:
+//
+//	var ids []int
+//	err := c.Pluck(ctx, &ids, "id", "FROM users WHERE age > $1", 18)
+//
+// If the query starts with FROM only the input column is selected,
+// otherwise the column is read, by name, from the rows returned by
+// the query and all the other columns are ignored.
+//
+// The dest argument should be a pointer to a slice of a type that
+// is not a struct, except for time.Time and types implementing the
+// sql.Scanner interface. For nullable columns use a slice of pointers.
+func (c DB) Pluck(ctx context.Context, dest interface{}, column string, query string, params ...interface{}) (err error) {
+	ctx, finish := c.observe(ctx, "pluck", "")
+	defer func() { finish(err) }()
+
+	return c.pluck(ctx, dest, column, false, query, params)
+}
+
+// PluckDistinct works like Pluck but each
+// distinct value is only returned once, e.g.:
+//
+//	var cities []string
+//	err := c.PluckDistinct(ctx, &cities, "city", "FROM addresses")
+//
+// If the query doesn't start with FROM it runs as a subquery of the
+// `SELECT DISTINCT` statement, so the column must be selected by it.
+func (c DB) PluckDistinct(ctx context.Context, dest interface{}, column string, query string, params ...interface{}) (err error) {
+	ctx, finish := c.observe(ctx, "pluck_distinct", "")
+	defer func() { finish(err) }()
+
+	return c.pluck(ctx, dest, column, true, query, params)
+}
+
+func (c DB) pluck(
+	ctx context.Context,
+	dest interface{},
+	column string,
+	distinct bool,
+	query string,
+	params []interface{},
+) error {
+	slicePtr := reflect.ValueOf(dest)
+	if slicePtr.Kind() != reflect.Ptr || slicePtr.IsNil() || slicePtr.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("ksql: expected to receive a pointer to slice of values, but got: %T", dest)
+	}
+
+	elemType := slicePtr.Elem().Type().Elem()
+	if !isPluckableType(elemType) {
+		return fmt.Errorf("ksql: expected to receive a pointer to slice of values, but got: %T", dest)
+	}
+
+	if err := ValidateIdentifier(column); err != nil {
+		return fmt.Errorf("ksql: invalid column: %s", err)
+	}
+
+	query, params, err := bindNamedParams(c.dialect, query, params, c.getColumnResolver())
+	if err != nil {
+		return err
+	}
+
+	selectPrefix := "SELECT "
+	if distinct {
+		selectPrefix = "SELECT DISTINCT "
+	}
+
+	escapedColumn := c.dialect.Escape(column)
+	trimmedQuery := strings.TrimSpace(query)
+	if strings.HasPrefix(strings.ToUpper(trimmedQuery), "FROM") {
+		query = selectPrefix + escapedColumn + " " + trimmedQuery
+	} else if distinct {
+		query = fmt.Sprintf(
+			"%s%s FROM (%s) AS ksql_pluck",
+			selectPrefix, escapedColumn, strings.TrimRight(trimmedQuery, ";"),
+		)
+	}
+
+	rows, err := c.queryContext(ctx, query, params...)
+	if err != nil {
+		return fmt.Errorf("error running query: %w", err)
+	}
+	defer rows.Close()
+
+	names, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	columnIdx := indexOfString(names, column)
+	if columnIdx == -1 {
+		return fmt.Errorf("ksql: the column `%s` was not returned by the query, got: %v", column, names)
+	}
+
+	scanArgs := make([]interface{}, len(names))
+	for i := range scanArgs {
+		scanArgs[i] = nopScannerValue
+	}
+
+	slice := slicePtr.Elem().Slice(0, 0)
+	for rows.Next() {
+		value := reflect.New(elemType)
+		scanArgs[columnIdx] = value.Interface()
+
+		err := rows.Scan(scanArgs...)
+		if err != nil {
+			return fmt.Errorf("ksql: error scanning column `%s`: %w", column, err)
+		}
+
+		slice = reflect.Append(slice, value.Elem())
+	}
+
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	slicePtr.Elem().Set(slice)
+
+	return nil
+}
+
+// isPluckableType reports if values of the input type
+// can be scanned directly from a single column.
+func isPluckableType(t reflect.Type) bool {
+	if reflect.PtrTo(t).Implements(scannerType) {
+		return true
+	}
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t.Kind() != reflect.Struct || t == timeType || reflect.PtrTo(t).Implements(scannerType)
+}
@@ -0,0 +1,327 @@
+package ksql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/vingarcia/ksql/internal/structs"
+)
+
+// Preload loads in a single query all the rows of the childrenTable
+// that are related to one of the input parents avoiding the N+1 problem.
+//
+// The parents argument should be a slice of structs (or *struct) and
+// the parentKeyColumn the `ksql` tag of the attribute the children refer to.
+//
+// The children argument should be a pointer to a map of slices of
+// structs, e.g. `*map[int][]Post`, where the fkColumn is both the name of
+// the foreign key column on the childrenTable and the `ksql` tag of the
+// attribute used for grouping the children by parent, e.g.:
+//
+//	var postsByUserID map[int][]Post
+//	err := c.Preload(ctx, PostsTable, &postsByUserID, "user_id", users, "id")
+//
+// Parents without children will have no entries on the resulting map.
+func (c DB) Preload(
+	ctx context.Context,
+	childrenTable Table,
+	children interface{},
+	fkColumn string,
+	parents interface{},
+	parentKeyColumn string,
+) (err error) {
+	ctx, finish := c.observe(ctx, "preload", childrenTable.name)
+	defer func() { finish(err) }()
+
+	if err := childrenTable.validate(); err != nil {
+		return fmt.Errorf("can't preload from ksql.Table: %s", err)
+	}
+
+	if err := ValidateIdentifier(fkColumn); err != nil {
+		return fmt.Errorf("ksql: invalid foreign key column: %s", err)
+	}
+
+	mapPtr := reflect.ValueOf(children)
+	mapPtrType := mapPtr.Type()
+	if mapPtrType.Kind() != reflect.Ptr || mapPtrType.Elem().Kind() != reflect.Map {
+		return fmt.Errorf("ksql: expected children to be a pointer to a map of slices of structs, but got: %T", children)
+	}
+
+	mapType := mapPtrType.Elem()
+	keyType := mapType.Key()
+	childStructType, isSliceOfPtrs, err := structs.DecodeAsSliceOfStructs(mapType.Elem())
+	if err != nil {
+		return fmt.Errorf("ksql: expected children to be a pointer to a map of slices of structs, but got: %T", children)
+	}
+
+	childInfo, err := c.getTagInfo(childStructType)
+	if err != nil {
+		return err
+	}
+
+	if childInfo.IsNestedStruct {
+		return fmt.Errorf("ksql: Preload doesn't support nested structs")
+	}
+
+	fkField := childInfo.ByName(fkColumn)
+	if !fkField.Valid {
+		return fmt.Errorf("ksql: the foreign key column `%s` is not tagged on type %v", fkColumn, childStructType)
+	}
+
+	fkFieldType := childStructType.Field(fkField.Index).Type
+	if !fkFieldType.ConvertibleTo(keyType) {
+		return fmt.Errorf(
+			"ksql: can't use field `%s` of type %v as a key for %v",
+			fkColumn, fkFieldType, mapType,
+		)
+	}
+
+	parentKeys, err := getKeysFromSlice(parents, parentKeyColumn, c.getColumnResolver())
+	if err != nil {
+		return err
+	}
+
+	m := reflect.MakeMap(mapType)
+	if len(parentKeys) == 0 {
+		mapPtr.Elem().Set(m)
+		return nil
+	}
+
+	selectQuery, err := buildSelectQuery(c.dialect, childStructType, childInfo, selectQueryCache[c.dialect.DriverName()], c.getColumnResolver())
+	if err != nil {
+		return err
+	}
+
+	placeholders := make([]string, len(parentKeys))
+	for i := range parentKeys {
+		placeholders[i] = c.dialect.Placeholder(i)
+	}
+
+	query := fmt.Sprintf(
+		"%sFROM %s WHERE %s IN (%s)",
+		selectQuery,
+		c.dialect.Escape(childrenTable.name),
+		c.dialect.Escape(fkColumn),
+		strings.Join(placeholders, ", "),
+	)
+
+	query, params, err := c.applyScopesToWhere(query, parentKeys)
+	if err != nil {
+		return err
+	}
+
+	rows, err := c.queryContext(ctx, query, params...)
+	if err != nil {
+		return fmt.Errorf("error running query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		elemPtr := reflect.New(childStructType)
+		err = c.scanRows(rows, elemPtr.Interface())
+		if err != nil {
+			return err
+		}
+
+		key := elemPtr.Elem().Field(fkField.Index).Convert(keyType)
+
+		elemValue := elemPtr
+		if !isSliceOfPtrs {
+			elemValue = elemPtr.Elem()
+		}
+
+		group := m.MapIndex(key)
+		if !group.IsValid() {
+			group = reflect.MakeSlice(mapType.Elem(), 0, 1)
+		}
+		m.SetMapIndex(key, reflect.Append(group, elemValue))
+	}
+
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	mapPtr.Elem().Set(m)
+
+	return nil
+}
+
+// getKeysFromSlice reads the attribute tagged as keyColumn
+// from each of the records of a slice (or a pointer to a slice)
+// of structs ignoring duplicated keys, nil pointers and
+// the empty keys of attributes tagged with omitempty.
+func getKeysFromSlice(
+	records interface{},
+	keyColumn string,
+	resolver *structs.ColumnResolver,
+) ([]interface{}, error) {
+	slice := reflect.ValueOf(records)
+	if slice.Kind() == reflect.Ptr {
+		slice = slice.Elem()
+	}
+
+	structType, isSliceOfPtrs, err := structs.DecodeAsSliceOfStructs(slice.Type())
+	if err != nil {
+		return nil, fmt.Errorf("ksql: expected a slice of structs, but got: %T", records)
+	}
+
+	info, err := structs.GetTagInfoWithResolver(structType, resolver)
+	if err != nil {
+		return nil, err
+	}
+
+	keyField := info.ByName(keyColumn)
+	if !keyField.Valid {
+		return nil, fmt.Errorf("ksql: the key column `%s` is not tagged on type %v", keyColumn, structType)
+	}
+
+	keyType := structType.Field(keyField.Index).Type
+	for keyType.Kind() == reflect.Ptr {
+		keyType = keyType.Elem()
+	}
+	if !keyType.Comparable() {
+		return nil, fmt.Errorf("ksql: can't use field `%s` of type %v as a key", keyColumn, keyType)
+	}
+
+	keys := []interface{}{}
+	alreadyAdded := map[interface{}]bool{}
+	for i := 0; i < slice.Len(); i++ {
+		record := slice.Index(i)
+		if isSliceOfPtrs {
+			if record.IsNil() {
+				continue
+			}
+			record = record.Elem()
+		}
+
+		field, found, err := structs.ReadField(record, keyField)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+
+		key := field.Interface()
+		if alreadyAdded[key] {
+			continue
+		}
+		alreadyAdded[key] = true
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// LoadChildren works like Preload but instead of returning a map it
+// assigns the children of each parent to one of its attributes, e.g.:
+//
+//	type User struct {
+//		ID    int    `ksql:"id"`
+//		Name  string `ksql:"name"`
+//		Posts []Post
+//	}
+//
+//	err := c.LoadChildren(ctx, PostsTable, users, "Posts", "user_id", "id")
+//
+// The childrenField is the name of the attribute of the parent struct,
+// it must be a slice of structs (or *struct) and must not be tagged with
+// `ksql`, except for `ksql:"-"`. The fkColumn and parentKeyColumn work just like on Preload.
+//
+// The parents argument should be a slice of structs (or *struct) or
+// a pointer to one, and all the children are loaded in a single query.
+// Parents without children will have the attribute set to nil.
+func (c DB) LoadChildren(
+	ctx context.Context,
+	childrenTable Table,
+	parents interface{},
+	childrenField string,
+	fkColumn string,
+	parentKeyColumn string,
+) (err error) {
+	ctx, finish := c.observe(ctx, "load_children", childrenTable.name)
+	defer func() { finish(err) }()
+
+	slice := reflect.ValueOf(parents)
+	if slice.Kind() == reflect.Ptr {
+		slice = slice.Elem()
+	}
+
+	parentType, isSliceOfPtrs, err := structs.DecodeAsSliceOfStructs(slice.Type())
+	if err != nil {
+		return fmt.Errorf("ksql: expected parents to be a slice of structs, but got: %T", parents)
+	}
+
+	field, found := parentType.FieldByName(childrenField)
+	if !found || len(field.Index) > 1 || field.PkgPath != "" {
+		return fmt.Errorf("ksql: the children field `%s` is not an exported attribute of %v", childrenField, parentType)
+	}
+
+	if tag, found := field.Tag.Lookup("ksql"); found && tag != "-" {
+		return fmt.Errorf("ksql: the children field `%s` of %v must not be tagged with `ksql`", childrenField, parentType)
+	}
+
+	if _, _, err := structs.DecodeAsSliceOfStructs(field.Type); err != nil {
+		return fmt.Errorf(
+			"ksql: expected the children field `%s` to be a slice of structs, but got: %v",
+			childrenField, field.Type,
+		)
+	}
+
+	parentInfo, err := c.getTagInfo(parentType)
+	if err != nil {
+		return err
+	}
+
+	parentKeyField := parentInfo.ByName(parentKeyColumn)
+	if !parentKeyField.Valid {
+		return fmt.Errorf("ksql: the key column `%s` is not tagged on type %v", parentKeyColumn, parentType)
+	}
+
+	keyType := parentType.Field(parentKeyField.Index).Type
+	if keyType.Kind() == reflect.Ptr {
+		keyType = keyType.Elem()
+	}
+
+	childrenByKey := reflect.New(reflect.MapOf(keyType, field.Type))
+	err = c.Preload(ctx, childrenTable, childrenByKey.Interface(), fkColumn, parents, parentKeyColumn)
+	if err != nil {
+		return err
+	}
+
+	m := childrenByKey.Elem()
+	for i := 0; i < slice.Len(); i++ {
+		parent := slice.Index(i)
+		if isSliceOfPtrs {
+			if parent.IsNil() {
+				continue
+			}
+			parent = parent.Elem()
+		}
+
+		childrenValue := parent.Field(field.Index[0])
+
+		key := parent.Field(parentKeyField.Index)
+		if key.Kind() == reflect.Ptr {
+			if key.IsNil() {
+				childrenValue.Set(reflect.Zero(field.Type))
+				continue
+			}
+			key = key.Elem()
+		}
+
+		children := m.MapIndex(key)
+		if !children.IsValid() {
+			children = reflect.Zero(field.Type)
+		}
+		childrenValue.Set(children)
+	}
+
+	return nil
+}
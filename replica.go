@@ -0,0 +1,50 @@
+package ksql
+
+import "strings"
+
+// WithReadReplica returns a copy of the DB that sends the read queries,
+// i.e. the ones starting with SELECT, to the input replica adapter, e.g.:
+//
+//	db = db.WithReadReplica(replicaAdapter)
+//
+// All the other queries, and all the queries inside transactions,
+// keep running on the primary adapter of the DB.
+//
+// Since replicas usually lag behind the primary, a read that must see
+// the results of a previous write should use the copy of the DB
+// returned by ReadFromPrimary.
+func (c DB) WithReadReplica(replica DBAdapter) DB {
+	c.replica = replica
+	return c
+}
+
+// ReadFromPrimary returns a copy of the DB that sends all its
+// queries to the primary adapter, even if a read replica was
+// configured with WithReadReplica, e.g.:
+//
+//	err := db.Insert(ctx, UsersTable, &user)
+//	...
+//	err = db.ReadFromPrimary().QueryOne(ctx, &user, "FROM users WHERE id = $1", user.ID)
+//
+// By default the reads are sent to the replica if one is configured.
+func (c DB) ReadFromPrimary() DB {
+	c.readFromPrimary = true
+	return c
+}
+
+// getQueryAdapter returns the adapter that should run the input query.
+func (c DB) getQueryAdapter(query string) DBAdapter {
+	if c.replica == nil || c.readFromPrimary {
+		return c.db
+	}
+
+	if _, isTx := c.db.(Tx); isTx {
+		return c.db
+	}
+
+	if strings.ToUpper(getFirstToken(query)) != "SELECT" {
+		return c.db
+	}
+
+	return c.replica
+}
@@ -0,0 +1,225 @@
+package ksql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// WithRetryOnConnectionLoss returns a copy of the DB configured to
+// retry a read query once if it fails because the connection was lost,
+// e.g. when an idle connection is killed by a load balancer and the
+// next query fails with a "broken pipe" or "connection reset" error.
+//
+// Only queries starting with SELECT that fail before returning any
+// rows are retried, and never inside transactions, since the transaction
+// is lost together with the connection. Writes are never retried, since
+// it is not possible to know if they were applied before the failure.
+//
+// Note that database/sql already retries the queries that fail
+// with driver.ErrBadConn before being sent to the database.
+func (c DB) WithRetryOnConnectionLoss(enabled bool) DB {
+	c.retryOnConnectionLoss = enabled
+	return c
+}
+
+// RetryPolicy configures how the queries that fail with transient
+// errors are retried, it should be set with WithRetryPolicy.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times each query is
+	// sent to the database, including the first one, values
+	// smaller than 2 disable the retries.
+	MaxAttempts int
+
+	// BaseDelay is the time waited before the first retry,
+	// which is doubled for each of the following retries.
+	BaseDelay time.Duration
+
+	// MaxDelay limits the time waited between two attempts, it
+	// is optional and the delays are unlimited if it's zero.
+	MaxDelay time.Duration
+
+	// Jitter is the fraction of each delay that is randomized,
+	// between 0 and 1, e.g. with a Jitter of 0.2 a delay of 100ms
+	// becomes a random delay between 80ms and 100ms, so clients
+	// that failed at the same time don't retry at the same time.
+	Jitter float64
+
+	// IsRetryable reports if a query that failed with the input
+	// error should be retried, it is optional and by default
+	// IsTransientError is used.
+	IsRetryable func(err error) bool
+}
+
+// WithRetryPolicy returns a copy of the DB that retries the queries
+// that fail with transient errors according to the input policy,
+// with an exponential backoff between the attempts, e.g.:
+//
+//	db = db.WithRetryPolicy(ksql.RetryPolicy{
+//		MaxAttempts: 3,
+//		BaseDelay:   50 * time.Millisecond,
+//		Jitter:      0.2,
+//	})
+//
+// Only the queries starting with SELECT that fail before returning
+// any rows are retried, which covers the QueryOne, Query, QueryAll,
+// CountOf and IsUnique functions among others. Writes are only retried
+// on DBs returned by Idempotent, since otherwise it is not possible to
+// know if they were applied before the failure.
+//
+// The queries are never retried individually inside transactions, since a
+// failed query might abort the whole transaction. Instead the Transaction
+// function runs its callback again in a new transaction if it fails with
+// a retryable error, e.g. a deadlock, so when using a RetryPolicy the
+// callbacks should not have side effects outside of the database. Failed
+// commits are only retried if they were certainly rolled back, see Transaction.
+func (c DB) WithRetryPolicy(policy RetryPolicy) DB {
+	c.retryPolicy = policy
+	return c
+}
+
+// Idempotent returns a copy of the DB whose writes are also retried
+// according to the RetryPolicy of the DB, e.g.:
+//
+//	err := db.Idempotent().Patch(ctx, UsersTable, &user)
+//
+// It should only be used for writes that produce the same result if
+// applied more than once, e.g. a Patch setting absolute values.
+func (c DB) Idempotent() DB {
+	c.idempotentWrites = true
+	return c
+}
+
+// IsTransientError reports if the error is likely to be solved by
+// retrying the query, i.e. if it was caused by a lost connection, a
+// deadlock or a serialization failure. It is the default check used
+// by the RetryPolicy.
+func IsTransientError(err error) bool {
+	return isConnectionLossError(err) || isDeadlockError(err) || isSerializationError(err)
+}
+
+// postgresSerializationFailureCode is the SQLSTATE code used by Postgres
+// for transactions that can't be serialized with the concurrent ones.
+const postgresSerializationFailureCode = "40001"
+
+func isSerializationError(err error) bool {
+	var sqlStateErr interface {
+		SQLState() string
+	}
+	return errors.As(err, &sqlStateErr) && sqlStateErr.SQLState() == postgresSerializationFailureCode
+}
+
+// getRetryDelay reports if a query that failed with the input error
+// on its nth attempt should be retried and how long to wait before it.
+func (c DB) getRetryDelay(ctx context.Context, query string, attempt int, err error) (time.Duration, bool) {
+	if ctx.Err() != nil {
+		return 0, false
+	}
+
+	if _, isTx := c.db.(Tx); isTx {
+		return 0, false
+	}
+
+	isRead := strings.ToUpper(getFirstToken(query)) == "SELECT"
+
+	if c.retryPolicy.MaxAttempts > 1 {
+		if !isRead && !c.idempotentWrites {
+			return 0, false
+		}
+		if !c.retryPolicy.shouldRetry(attempt, err) {
+			return 0, false
+		}
+		return c.retryPolicy.delay(attempt), true
+	}
+
+	// The retries of WithRetryOnConnectionLoss:
+	return 0, c.retryOnConnectionLoss && attempt == 1 && isRead && isConnectionLossError(err)
+}
+
+func (p RetryPolicy) shouldRetry(attempt int, err error) bool {
+	if attempt >= p.MaxAttempts {
+		return false
+	}
+
+	isRetryable := p.IsRetryable
+	if isRetryable == nil {
+		isRetryable = IsTransientError
+	}
+	return isRetryable(err)
+}
+
+// delay returns the time to wait after the nth failed attempt.
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay == 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+
+	if p.Jitter > 0 {
+		d -= time.Duration(p.Jitter * rand.Float64() * float64(d))
+	}
+	return d
+}
+
+// sleepContext waits for the input duration and reports
+// false if the context was canceled before it finished.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// connectionLossMessages are used for detecting the connection
+// errors of drivers that don't wrap the underlying network errors.
+var connectionLossMessages = []string{
+	"broken pipe",
+	"connection reset by peer",
+	"bad connection",
+	"invalid connection",
+	"conn closed",
+	"unexpected eof",
+}
+
+// isConnectionLossError reports if the error was caused by a lost
+// connection to the database, as opposed to an application error
+// such as a syntax error or a constraint violation.
+func isConnectionLossError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, connectionLossMsg := range connectionLossMessages {
+		if strings.Contains(msg, connectionLossMsg) {
+			return true
+		}
+	}
+
+	return false
+}
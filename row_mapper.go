@@ -0,0 +1,114 @@
+package ksql
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/vingarcia/ksql/internal/structs"
+)
+
+// RowMapper scans rows obtained from other libraries, e.g. *sql.Rows,
+// into structs using the same `ksql` tags used by the DB functions, e.g.:
+//
+//	mapper, err := ksql.NewRowMapper(User{})
+//	...
+//	rows, err := sqlDB.QueryContext(ctx, "SELECT id, name FROM users")
+//	...
+//	for rows.Next() {
+//		var u User
+//		err := mapper.Map(rows, &u)
+//		...
+//	}
+//
+// The columns are matched to the attributes by name, columns without a
+// matching attribute are ignored, and the matching is only resolved once
+// for the rows being read, so reusing the same mapper for all the rows
+// avoids repeating this work.
+//
+// A RowMapper is not safe for concurrent use.
+type RowMapper struct {
+	structType reflect.Type
+	info       structs.StructInfo
+
+	// The fields of each column of the lastRows,
+	// columns with no attributes are stored as nil.
+	lastRows     Rows
+	columnFields []*structs.FieldInfo
+}
+
+// NewRowMapper creates a RowMapper for the type of the input
+// record, which should be a struct or a pointer to struct.
+//
+// Nested structs, i.e. structs using the `tablename` tag, are not supported.
+func NewRowMapper(record interface{}) (*RowMapper, error) {
+	t := reflect.TypeOf(record)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("ksql: expected record to be a struct or a pointer to struct, but got: %T", record)
+	}
+
+	info, err := structs.GetTagInfo(t)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsNestedStruct {
+		return nil, fmt.Errorf("ksql: RowMapper doesn't support nested structs")
+	}
+
+	return &RowMapper{
+		structType: t,
+		info:       info,
+	}, nil
+}
+
+// Map scans the current row of the input rows into the dest
+// struct, so rows.Next() should be called before each call.
+func (m *RowMapper) Map(rows Rows, dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Type().Elem() != m.structType {
+		return fmt.Errorf("ksql: expected dest to be a non nil *%v, but got: %T", m.structType, dest)
+	}
+	v = v.Elem()
+
+	if rows != m.lastRows {
+		names, err := rows.Columns()
+		if err != nil {
+			return err
+		}
+
+		columnFields := make([]*structs.FieldInfo, len(names))
+		for i, name := range names {
+			if fieldInfo := m.info.ByName(name); fieldInfo.Valid {
+				columnFields[i] = fieldInfo
+			}
+		}
+
+		m.lastRows = rows
+		m.columnFields = columnFields
+	}
+
+	scanArgs := make([]interface{}, len(m.columnFields))
+	for i, fieldInfo := range m.columnFields {
+		if fieldInfo == nil {
+			scanArgs[i] = nopScannerValue
+			continue
+		}
+
+		valueScanner := v.Field(fieldInfo.Index).Addr().Interface()
+		switch {
+		case fieldInfo.SerializeAsJSON:
+			valueScanner = &jsonSerializable{Attr: valueScanner}
+		case fieldInfo.UnixTimestampUnit != 0:
+			valueScanner = &unixTimestamp{
+				Unit: fieldInfo.UnixTimestampUnit,
+				Attr: valueScanner,
+			}
+		}
+		scanArgs[i] = valueScanner
+	}
+
+	return rows.Scan(scanArgs...)
+}
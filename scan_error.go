@@ -0,0 +1,61 @@
+package ksql
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ScanError is returned when the value of a column can't be scanned
+// into the attribute of the struct, e.g. because their types are
+// incompatible, and the driver error can be read with errors.As.
+type ScanError struct {
+	Column    string
+	Field     string
+	FieldType reflect.Type
+	Err       error
+}
+
+func (s ScanError) Error() string {
+	return fmt.Sprintf("ksql: scanning column %q into field %s (%v): %s", s.Column, s.Field, s.FieldType, s.Err)
+}
+
+func (s ScanError) Unwrap() error {
+	return s.Err
+}
+
+// newScanError finds which column caused the input error returned
+// by rows.Scan() and wraps the error with a ScanError describing it.
+//
+// Since the Scan errors of the drivers don't report the column in a
+// structured way, the row is scanned again one column at a time, which
+// is supported by database/sql and pgx, and if the failing column can't
+// be found this way the original error is returned as it is.
+func newScanError(rows Rows, scanArgs []interface{}, fields []reflect.StructField, err error) error {
+	names, columnsErr := rows.Columns()
+	if columnsErr != nil || len(names) != len(scanArgs) || len(fields) != len(scanArgs) {
+		return err
+	}
+
+	args := make([]interface{}, len(scanArgs))
+	for i := range scanArgs {
+		if fields[i].Type == nil {
+			continue
+		}
+
+		for j := range args {
+			args[j] = nopScannerValue
+		}
+		args[i] = scanArgs[i]
+
+		if columnErr := rows.Scan(args...); columnErr != nil {
+			return ScanError{
+				Column:    names[i],
+				Field:     fields[i].Name,
+				FieldType: fields[i].Type,
+				Err:       columnErr,
+			}
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,200 @@
+package ksql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ValidateSchema checks that each of the attributes of the input struct
+// has a matching column on the table with a compatible type, e.g.:
+//
+//	err := c.ValidateSchema(ctx, UsersTable, &User{})
+//
+// It is meant to be used on startup for detecting differences between
+// the structs and the database schema early, and returns an error
+// listing all the attributes that don't match the table columns.
+//
+// The type check is only done for attributes of basic types, i.e.
+// strings, numbers, booleans, []byte, time.Time and attributes
+// tagged with `json`, for other types only the name is checked.
+func (c DB) ValidateSchema(ctx context.Context, table Table, record interface{}) (err error) {
+	ctx, finish := c.observe(ctx, "validate_schema", table.name)
+	defer func() { finish(err) }()
+
+	if err := table.validate(); err != nil {
+		return fmt.Errorf("can't validate ksql.Table: %s", err)
+	}
+
+	t := reflect.TypeOf(record)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return fmt.Errorf("ksql: expected record to be a struct or a pointer to struct, but got: %T", record)
+	}
+
+	info, err := c.getTagInfo(t)
+	if err != nil {
+		return err
+	}
+
+	if info.IsNestedStruct {
+		return fmt.Errorf("ksql: ValidateSchema doesn't support nested structs")
+	}
+
+	columnTypes, err := c.getColumnTypes(ctx, table.name)
+	if err != nil {
+		return err
+	}
+
+	if len(columnTypes) == 0 {
+		return fmt.Errorf("ksql: table `%s` not found or has no columns", table.name)
+	}
+
+	mismatches := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		fieldInfo := info.ByIndex(i)
+		if !fieldInfo.Valid {
+			continue
+		}
+
+		field := t.Field(i)
+		columnType, found := columnTypes[strings.ToLower(fieldInfo.Name)]
+		if !found {
+			mismatches = append(mismatches, fmt.Sprintf(
+				"attribute `%s` (%v): column `%s` not found",
+				field.Name, field.Type, fieldInfo.Name,
+			))
+			continue
+		}
+
+		compatible := isCompatibleColumnType(field.Type, fieldInfo.SerializeAsJSON, columnType)
+		if fieldInfo.UnixTimestampUnit != 0 {
+			// Unix timestamps might be stored both on time and integer columns:
+			compatible = compatible || isCompatibleColumnType(timeType, false, columnType)
+		}
+
+		if !compatible {
+			mismatches = append(mismatches, fmt.Sprintf(
+				"attribute `%s` (%v): column `%s` has incompatible type `%s`",
+				field.Name, field.Type, fieldInfo.Name, columnType,
+			))
+		}
+	}
+
+	if len(mismatches) > 0 {
+		return fmt.Errorf(
+			"ksql: struct %v doesn't match the schema of table `%s`:\n- %s",
+			t, table.name, strings.Join(mismatches, "\n- "),
+		)
+	}
+
+	return nil
+}
+
+// getColumnTypes returns the types of the columns of
+// the input table indexed by the lower case column names.
+func (c DB) getColumnTypes(ctx context.Context, tableName string) (map[string]string, error) {
+	var query string
+	switch c.dialect.DriverName() {
+	case "postgres":
+		query = `SELECT column_name, data_type FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = $1`
+	case "mysql":
+		query = `SELECT column_name, data_type FROM information_schema.columns
+			WHERE table_schema = DATABASE() AND table_name = ?`
+	case "sqlserver":
+		query = `SELECT column_name, data_type FROM information_schema.columns
+			WHERE table_schema = SCHEMA_NAME() AND table_name = @p1`
+	case "sqlite3":
+		query = `SELECT name, type FROM pragma_table_info(?)`
+	default:
+		return nil, fmt.Errorf("ksql: ValidateSchema is not supported by the `%s` driver", c.dialect.DriverName())
+	}
+
+	rows, err := c.queryContext(ctx, query, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("error running query: %w", err)
+	}
+	defer rows.Close()
+
+	columnTypes := map[string]string{}
+	for rows.Next() {
+		var name, columnType string
+		err := rows.Scan(&name, &columnType)
+		if err != nil {
+			return nil, err
+		}
+		columnTypes[strings.ToLower(name)] = strings.ToLower(columnType)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return columnTypes, rows.Close()
+}
+
+var (
+	integerColumnTypes = []string{"int", "serial"}
+	floatColumnTypes   = []string{"float", "double", "real", "numeric", "decimal", "money"}
+	boolColumnTypes    = []string{"bool", "bit", "tinyint"}
+	textColumnTypes    = []string{"char", "text", "clob", "uuid", "enum", "set", "xml", "json"}
+	binaryColumnTypes  = []string{"blob", "bytea", "binary"}
+	timeColumnTypes    = []string{"date", "time"}
+)
+
+// isCompatibleColumnType checks if the column type is one of the
+// types expected for the attribute type, attributes of unknown types
+// are always considered compatible.
+func isCompatibleColumnType(t reflect.Type, isJSON bool, columnType string) bool {
+	// Some sqlite3 columns might have no declared type:
+	if columnType == "" {
+		return true
+	}
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var expectedTypes [][]string
+	switch {
+	case isJSON:
+		expectedTypes = [][]string{textColumnTypes, binaryColumnTypes}
+	case t == reflect.TypeOf(time.Time{}):
+		expectedTypes = [][]string{timeColumnTypes}
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		expectedTypes = [][]string{binaryColumnTypes, textColumnTypes}
+	case reflect.PtrTo(t).Implements(scannerType):
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		expectedTypes = [][]string{integerColumnTypes}
+	case reflect.Float32, reflect.Float64:
+		expectedTypes = [][]string{floatColumnTypes, integerColumnTypes}
+	case reflect.Bool:
+		expectedTypes = [][]string{boolColumnTypes}
+	case reflect.String:
+		expectedTypes = [][]string{textColumnTypes}
+	}
+
+	if expectedTypes == nil {
+		return true
+	}
+
+	for _, types := range expectedTypes {
+		for _, expected := range types {
+			if strings.Contains(columnType, expected) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,132 @@
+package ksql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vingarcia/ksql/internal/structs"
+)
+
+type scope struct {
+	clause string
+	params []interface{}
+}
+
+// Where returns a copy of the DB that will add the input
+// condition to all the following queries, e.g.:
+//
+//	tenantDB := db.Where("tenant_id = ?", tenantID)
+//
+//	// Will only load users with the tenant_id above:
+//	err := tenantDB.Query(ctx, &users, "FROM users WHERE age > $1", 18)
+//
+// The clause must use `?` as placeholder regardless of the dialect,
+// these will be replaced by the dialect specific placeholders.
+//
+// Calling Where several times will AND all the conditions.
+//
+// The scope is applied to the following operations:
+//
+//   - Query, QueryOne, QueryMap and QueryChunks: the input query runs as a
+//     subquery and the scope filters its results, so the columns used on the
+//     scope must be selected by the query. Since the scope is applied on the
+//     results of the user-provided WHERE it works as if both were ANDed.
+//     Nested structs are not supported.
+//   - Delete, Update and Patch: the scope is ANDed with the ID conditions,
+//     so records outside the scope are reported as ErrRecordNotFound.
+//
+// Insert and Exec are not affected.
+func (c DB) Where(clause string, params ...interface{}) DB {
+	scopes := make([]scope, len(c.scopes), len(c.scopes)+1)
+	copy(scopes, c.scopes)
+	c.scopes = append(scopes, scope{
+		clause: clause,
+		params: params,
+	})
+	return c
+}
+
+// buildScopesCondition returns the conditions of all the scopes
+// ANDed together with placeholders starting at the input offset.
+func (c DB) buildScopesCondition(offset int) (query string, params []interface{}, err error) {
+	conditions := []string{}
+	for _, s := range c.scopes {
+		numPlaceholders := strings.Count(s.clause, "?")
+		if numPlaceholders != len(s.params) {
+			return "", nil, fmt.Errorf(
+				"ksql: the scope `%s` has %d placeholders but received %d params",
+				s.clause, numPlaceholders, len(s.params),
+			)
+		}
+
+		var clause strings.Builder
+		i := 0
+		for _, r := range s.clause {
+			if r != '?' {
+				clause.WriteRune(r)
+				continue
+			}
+
+			clause.WriteString(c.dialect.Placeholder(offset + len(params) + i))
+			i++
+		}
+
+		conditions = append(conditions, "("+clause.String()+")")
+		params = append(params, s.params...)
+	}
+
+	return strings.Join(conditions, " AND "), params, nil
+}
+
+// applyScopesToQuery wraps the input query so that only
+// the rows matching the scopes of the DB are returned.
+//
+// The trailing ORDER BY of the query is moved outside of the
+// subquery, since some databases, e.g. sqlserver, don't accept
+// ORDER BY clauses inside subqueries.
+func (c DB) applyScopesToQuery(
+	query string,
+	params []interface{},
+	info structs.StructInfo,
+) (string, []interface{}, error) {
+	if len(c.scopes) == 0 {
+		return query, params, nil
+	}
+
+	if info.IsNestedStruct {
+		return "", nil, fmt.Errorf("ksql: scopes created with Where() don't support nested structs")
+	}
+
+	condition, scopeParams, err := c.buildScopesCondition(len(params))
+	if err != nil {
+		return "", nil, err
+	}
+
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+	queryWithoutOrderBy := stripTrailingOrderBy(query)
+	orderBy := query[len(queryWithoutOrderBy):]
+
+	query = fmt.Sprintf(
+		"SELECT * FROM (%s) AS ksql_scope WHERE %s%s",
+		queryWithoutOrderBy,
+		condition,
+		orderBy,
+	)
+
+	return query, append(append([]interface{}{}, params...), scopeParams...), nil
+}
+
+// applyScopesToWhere ANDs the scopes of the DB to a query
+// ending with a WHERE clause, e.g. `DELETE FROM users WHERE id = $1`.
+func (c DB) applyScopesToWhere(query string, params []interface{}) (string, []interface{}, error) {
+	if len(c.scopes) == 0 {
+		return query, params, nil
+	}
+
+	condition, scopeParams, err := c.buildScopesCondition(len(params))
+	if err != nil {
+		return "", nil, err
+	}
+
+	return query + " AND " + condition, append(params, scopeParams...), nil
+}
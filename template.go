@@ -0,0 +1,102 @@
+package ksql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// QueryTemplate works like Query but first replaces the `${name}`
+// placeholders of the template with the identifiers stored on the
+// idents map, e.g.:
+//
+//	err := c.QueryTemplate(ctx, &users,
+//		"FROM ${table} WHERE ${column} = $1",
+//		map[string]string{"table": "users", "column": "name"},
+//		"Alice",
+//	)
+//
+// This is meant for table and column names that can't be sent as params,
+// so each identifier is validated with ValidateIdentifier and escaped
+// with the quotes of the dialect before being inserted on the query.
+// Identifiers of the form `schema.table` have each part quoted separately.
+//
+// The values of the query should still be passed as params, using the
+// regular placeholders of the dialect. Placeholders without a matching
+// entry on the idents map cause an error, and placeholders inside
+// quoted strings are left untouched.
+func (c DB) QueryTemplate(
+	ctx context.Context,
+	records interface{},
+	tmpl string,
+	idents map[string]string,
+	params ...interface{},
+) (err error) {
+	ctx, finish := c.observe(ctx, "query_template", "")
+	defer func() { finish(err) }()
+
+	query, err := expandQueryTemplate(c.dialect, tmpl, idents)
+	if err != nil {
+		return err
+	}
+
+	return c.Query(ctx, records, query, params...)
+}
+
+func expandQueryTemplate(dialect Dialect, tmpl string, idents map[string]string) (string, error) {
+	var b strings.Builder
+	var quote byte
+	for i := 0; i < len(tmpl); i++ {
+		ch := tmpl[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+		case ch == '$' && strings.HasPrefix(tmpl[i+1:], "{"):
+			end := strings.IndexByte(tmpl[i:], '}')
+			if end == -1 {
+				return "", fmt.Errorf("ksql: unclosed template placeholder starting at: `%s`", tmpl[i:])
+			}
+			end += i
+
+			name := tmpl[i+2 : end]
+			ident, found := idents[name]
+			if !found {
+				return "", fmt.Errorf("ksql: missing identifier for the template placeholder `${%s}`", name)
+			}
+
+			escaped, err := escapeTemplateIdentifier(dialect, ident)
+			if err != nil {
+				return "", fmt.Errorf("ksql: invalid identifier for the template placeholder `${%s}`: %w", name, err)
+			}
+
+			b.WriteString(escaped)
+			i = end
+			continue
+		}
+
+		b.WriteByte(ch)
+	}
+
+	return b.String(), nil
+}
+
+func escapeTemplateIdentifier(dialect Dialect, ident string) (string, error) {
+	parts := strings.Split(ident, ".")
+	if len(parts) > 2 {
+		return "", fmt.Errorf("expected `name` or `schema.name` but got `%s`", ident)
+	}
+
+	for i, part := range parts {
+		err := ValidateIdentifier(part)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = dialect.Escape(part)
+	}
+
+	return strings.Join(parts, "."), nil
+}
@@ -6,8 +6,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/ditointernet/go-assert"
 	"github.com/pkg/errors"
@@ -69,12 +74,82 @@ func RunTestsForAdapter(
 	t.Run(adapterName+"."+driver, func(t *testing.T) {
 		QueryTest(t, driver, connStr, newDBAdapter)
 		QueryOneTest(t, driver, connStr, newDBAdapter)
+		QueryMapTest(t, driver, connStr, newDBAdapter)
+		QueryOneByExampleTest(t, driver, connStr, newDBAdapter)
+		QueryByIDsTest(t, driver, connStr, newDBAdapter)
+		QueryAllTest(t, driver, connStr, newDBAdapter)
 		InsertTest(t, driver, connStr, newDBAdapter)
 		DeleteTest(t, driver, connStr, newDBAdapter)
 		UpdateTest(t, driver, connStr, newDBAdapter)
 		QueryChunksTest(t, driver, connStr, newDBAdapter)
 		TransactionTest(t, driver, connStr, newDBAdapter)
 		ScanRowsTest(t, driver, connStr, newDBAdapter)
+		ExplainTest(t, driver, connStr, newDBAdapter)
+		PreloadTest(t, driver, connStr, newDBAdapter)
+		NullAsZeroTest(t, driver, connStr, newDBAdapter)
+		PaginateCursorTest(t, driver, connStr, newDBAdapter)
+		PaginateTest(t, driver, connStr, newDBAdapter)
+		WithLocationTest(t, driver, connStr, newDBAdapter)
+		WhereTest(t, driver, connStr, newDBAdapter)
+		CountOfTest(t, driver, connStr, newDBAdapter)
+		QueryChanTest(t, driver, connStr, newDBAdapter)
+		ValidateSchemaTest(t, driver, connStr, newDBAdapter)
+		InsertBatchTest(t, driver, connStr, newDBAdapter)
+		LoggerTest(t, driver, connStr, newDBAdapter)
+		PatchOnlyAndExceptTest(t, driver, connStr, newDBAdapter)
+		ServerCursorTest(t, driver, connStr, newDBAdapter)
+		InsertSliceTest(t, driver, connStr, newDBAdapter)
+		InsertBatchReturningTest(t, driver, connStr, newDBAdapter)
+		ErrorWrappingTest(t, driver, connStr, newDBAdapter)
+		PatchIfChangedTest(t, driver, connStr, newDBAdapter)
+		InsertStreamTest(t, driver, connStr, newDBAdapter)
+		CaseInsensitiveColumnsTest(t, driver, connStr, newDBAdapter)
+		PatchAndReloadTest(t, driver, connStr, newDBAdapter)
+		DefinedTypesTest(t, driver, connStr, newDBAdapter)
+		NamedParamsTest(t, driver, connStr, newDBAdapter)
+		StatsTest(t, driver, connStr, newDBAdapter)
+		CountByGroupTest(t, driver, connStr, newDBAdapter)
+		SnakeCaseColumnNamesTest(t, driver, connStr, newDBAdapter)
+		ColumnResolverTest(t, driver, connStr, newDBAdapter)
+		PatchSliceTest(t, driver, connStr, newDBAdapter)
+		EscapeLikeTest(t, driver, connStr, newDBAdapter)
+		DeleteReturningTest(t, driver, connStr, newDBAdapter)
+		MergeTest(t, driver, connStr, newDBAdapter)
+		LoadChildrenTest(t, driver, connStr, newDBAdapter)
+		StrictColumnsTest(t, driver, connStr, newDBAdapter)
+		QueryOneIntoTest(t, driver, connStr, newDBAdapter)
+		ReadOnlyColumnsTest(t, driver, connStr, newDBAdapter)
+		QueryMapIterTest(t, driver, connStr, newDBAdapter)
+		InsertIfNotExistsTest(t, driver, connStr, newDBAdapter)
+		CustomIDFieldTest(t, driver, connStr, newDBAdapter)
+		PluckTest(t, driver, connStr, newDBAdapter)
+		MergeReturningInsertedTest(t, driver, connStr, newDBAdapter)
+		TransactionRollbackTest(t, driver, connStr, newDBAdapter)
+		DeleteCascadeTest(t, driver, connStr, newDBAdapter)
+		RetryOnConnectionLossTest(t, driver, connStr, newDBAdapter)
+		RetryPolicyTest(t, driver, connStr, newDBAdapter)
+		UnixTimestampTest(t, driver, connStr, newDBAdapter)
+		QueryToCSVTest(t, driver, connStr, newDBAdapter)
+		ReadReplicaTest(t, driver, connStr, newDBAdapter)
+		CachedQueryTest(t, driver, connStr, newDBAdapter)
+		IsUniqueTest(t, driver, connStr, newDBAdapter)
+		QueryOneWithPrefixTest(t, driver, connStr, newDBAdapter)
+		InsertStreamReturningIDsTest(t, driver, connStr, newDBAdapter)
+		QueryOneMapTest(t, driver, connStr, newDBAdapter)
+		ExistingIDsTest(t, driver, connStr, newDBAdapter)
+		RowMapperTest(t, driver, connStr, newDBAdapter)
+		InsertDefaultsTest(t, driver, connStr, newDBAdapter)
+		UpsertReturningTest(t, driver, connStr, newDBAdapter)
+		ScanErrorTest(t, driver, connStr, newDBAdapter)
+		BeginTest(t, driver, connStr, newDBAdapter)
+		UUIDKeyTest(t, driver, connStr, newDBAdapter)
+		FirstTest(t, driver, connStr, newDBAdapter)
+		DefaultOrderByTest(t, driver, connStr, newDBAdapter)
+		QueryTemplateTest(t, driver, connStr, newDBAdapter)
+		MetricsTest(t, driver, connStr, newDBAdapter)
+		AggregateColumnsTest(t, driver, connStr, newDBAdapter)
+		UpdateJSONFieldTest(t, driver, connStr, newDBAdapter)
+		LoaderTest(t, driver, connStr, newDBAdapter)
 	})
 }
 
@@ -171,6 +246,33 @@ func QueryTest(
 						tt.AssertEqual(t, users[1].Address.Country, "BR")
 					})
 
+					t.Run("should not leak conditions between queries on the same client", func(t *testing.T) {
+						db, closer := newDBAdapter(t)
+						defer closer.Close()
+
+						_, err := db.ExecContext(context.TODO(), `INSERT INTO users (name, age, address) VALUES ('Leak Test 1', 71, '{}')`)
+						tt.AssertNoErr(t, err)
+
+						_, err = db.ExecContext(context.TODO(), `INSERT INTO users (name, age, address) VALUES ('Leak Test 2', 72, '{}')`)
+						tt.AssertNoErr(t, err)
+
+						ctx := context.Background()
+						c := newTestDB(db, driver)
+
+						var users []user
+						err = c.Query(ctx, &users, variation.queryPrefix+`FROM users WHERE age = `+c.dialect.Placeholder(0), 71)
+						tt.AssertNoErr(t, err)
+						tt.AssertEqual(t, len(users), 1)
+						tt.AssertEqual(t, users[0].Name, "Leak Test 1")
+
+						users = nil
+						err = c.Query(ctx, &users, variation.queryPrefix+`FROM users WHERE name = `+c.dialect.Placeholder(0), "Leak Test 2")
+						tt.AssertNoErr(t, err)
+						tt.AssertEqual(t, len(users), 1)
+						tt.AssertEqual(t, users[0].Name, "Leak Test 2")
+						tt.AssertEqual(t, users[0].Age, 72)
+					})
+
 					t.Run("should query joined tables correctly", func(t *testing.T) {
 						db, closer := newDBAdapter(t)
 						defer closer.Close()
@@ -230,6 +332,60 @@ func QueryTest(
 						tt.AssertEqual(t, rows[2].User.Name, "Bia Ribeiro")
 						tt.AssertEqual(t, rows[2].Post.Title, "Bia Post2")
 					})
+
+					t.Run("should load JSON aggregates into slices of structs", func(t *testing.T) {
+						// This test only makes sense with the SELECT part written by the user
+						if variation.queryPrefix == "" {
+							return
+						}
+
+						db, closer := newDBAdapter(t)
+						defer closer.Close()
+
+						_, err := db.ExecContext(context.TODO(), `INSERT INTO users (name, age, address) VALUES ('Ana Json', 0, '{"country":"BR"}')`)
+						tt.AssertNoErr(t, err)
+						var ana user
+						err = getUserByName(db, driver, &ana, "Ana Json")
+						tt.AssertNoErr(t, err)
+
+						_, err = db.ExecContext(context.TODO(), fmt.Sprint(`INSERT INTO posts (user_id, title) VALUES (`, ana.ID, `, 'Ana Post1')`))
+						tt.AssertNoErr(t, err)
+						_, err = db.ExecContext(context.TODO(), fmt.Sprint(`INSERT INTO posts (user_id, title) VALUES (`, ana.ID, `, 'Ana Post2')`))
+						tt.AssertNoErr(t, err)
+
+						var postsQuery string
+						switch driver {
+						case "postgres":
+							postsQuery = `(SELECT json_agg(json_build_object('id', p.id, 'title', p.title) ORDER BY p.id) FROM posts p WHERE p.user_id = u.id)`
+						case "sqlite3":
+							postsQuery = `(SELECT json_group_array(json_object('id', p.id, 'title', p.title)) FROM (SELECT * FROM posts ORDER BY id) p WHERE p.user_id = u.id)`
+						case "mysql":
+							postsQuery = `(SELECT JSON_ARRAYAGG(JSON_OBJECT('id', p.id, 'title', p.title)) FROM (SELECT * FROM posts ORDER BY id) p WHERE p.user_id = u.id)`
+						case "sqlserver":
+							postsQuery = `(SELECT p.id, p.title FROM posts p WHERE p.user_id = u.id ORDER BY p.id FOR JSON PATH)`
+						}
+
+						ctx := context.Background()
+						c := newTestDB(db, driver)
+						var rows []struct {
+							ID    uint   `ksql:"id"`
+							Name  string `ksql:"name"`
+							Posts []struct {
+								ID    int    `json:"id"`
+								Title string `json:"title"`
+							} `ksql:"posts,json"`
+						}
+						err = c.Query(ctx, &rows, `SELECT u.id, u.name, `+postsQuery+` AS posts FROM users u WHERE u.name = `+c.dialect.Placeholder(0), "Ana Json")
+						tt.AssertNoErr(t, err)
+						tt.AssertEqual(t, len(rows), 1)
+						tt.AssertEqual(t, rows[0].ID, ana.ID)
+						tt.AssertEqual(t, rows[0].Name, "Ana Json")
+						tt.AssertEqual(t, len(rows[0].Posts), 2)
+						tt.AssertNotEqual(t, rows[0].Posts[0].ID, 0)
+						tt.AssertEqual(t, rows[0].Posts[0].Title, "Ana Post1")
+						tt.AssertNotEqual(t, rows[0].Posts[1].ID, 0)
+						tt.AssertEqual(t, rows[0].Posts[1].Title, "Ana Post2")
+					})
 				})
 
 				t.Run("using slice of pointers to structs", func(t *testing.T) {
@@ -438,14 +594,14 @@ func QueryTest(
 					tt.AssertErrContains(t, err, "foo", "int")
 				})
 
-				t.Run("*struct", func(t *testing.T) {
+				t.Run("**struct", func(t *testing.T) {
 					db, closer := newDBAdapter(t)
 					defer closer.Close()
 
 					ctx := context.Background()
 					c := newTestDB(db, driver)
 					var rows []struct {
-						Foo *user `tablename:"foo"`
+						Foo **user `tablename:"foo"`
 					}
 					err := c.Query(ctx, &rows, fmt.Sprint(
 						`FROM users u JOIN posts p ON p.user_id = u.id`,
@@ -538,6 +694,31 @@ func QueryOneTest(
 					})
 				})
 
+				t.Run("should allocate pointers to nil pointers only if a row is found", func(t *testing.T) {
+					db, closer := newDBAdapter(t)
+					defer closer.Close()
+
+					ctx := context.Background()
+
+					_, err := db.ExecContext(ctx, `INSERT INTO users (name, age, address) VALUES ('Nil Pointer User', 22, '{"country":"BR"}')`)
+					tt.AssertNoErr(t, err)
+
+					c := newTestDB(db, driver)
+
+					var u *user
+					err = c.QueryOne(ctx, &u, variation.queryPrefix+`FROM users WHERE name = `+c.dialect.Placeholder(0), "Nil Pointer User")
+					tt.AssertNoErr(t, err)
+					tt.AssertNotEqual(t, u, (*user)(nil))
+					tt.AssertNotEqual(t, u.ID, uint(0))
+					tt.AssertEqual(t, u.Name, "Nil Pointer User")
+					tt.AssertEqual(t, u.Age, 22)
+
+					var missing *user
+					err = c.QueryOne(ctx, &missing, variation.queryPrefix+`FROM users WHERE name = `+c.dialect.Placeholder(0), "Missing User")
+					tt.AssertEqual(t, err, ErrRecordNotFound)
+					tt.AssertEqual(t, missing, (*user)(nil))
+				})
+
 				t.Run("should return only the first result on multiples matches", func(t *testing.T) {
 					db, closer := newDBAdapter(t)
 					defer closer.Close()
@@ -562,6 +743,31 @@ func QueryOneTest(
 					})
 				})
 
+				t.Run("should return ErrMultipleRecordsFound on multiple matches if strict", func(t *testing.T) {
+					db, closer := newDBAdapter(t)
+					defer closer.Close()
+
+					ctx := context.Background()
+
+					_, err := db.ExecContext(ctx, `INSERT INTO users (name, age, address) VALUES ('Diego Strict', 0, '{"country":"US"}')`)
+					tt.AssertNoErr(t, err)
+
+					_, err = db.ExecContext(ctx, `INSERT INTO users (name, age, address) VALUES ('Elis Strict', 0, '{"country":"BR"}')`)
+					tt.AssertNoErr(t, err)
+
+					c := newTestDB(db, driver).WithStrictQueryOne(true)
+
+					var u user
+					err = c.QueryOne(ctx, &u, variation.queryPrefix+`FROM users WHERE name like `+c.dialect.Placeholder(0)+` ORDER BY id ASC`, "% Strict")
+					tt.AssertEqual(t, err, ErrMultipleRecordsFound)
+
+					// It should still work normally if there is a single match:
+					u = user{}
+					err = c.QueryOne(ctx, &u, variation.queryPrefix+`FROM users WHERE name = `+c.dialect.Placeholder(0), "Elis Strict")
+					tt.AssertNoErr(t, err)
+					tt.AssertEqual(t, u.Name, "Elis Strict")
+				})
+
 				t.Run("should query joined tables correctly", func(t *testing.T) {
 					// This test only makes sense with no query prefix
 					if variation.queryPrefix != "" {
@@ -597,6 +803,70 @@ func QueryOneTest(
 					tt.AssertEqual(t, row.User.Name, "João Ribeiro")
 					tt.AssertEqual(t, row.Post.Title, "João Post1")
 				})
+
+				t.Run("should allocate nil pointers to nested structs", func(t *testing.T) {
+					// This test only makes sense with no query prefix
+					if variation.queryPrefix != "" {
+						return
+					}
+
+					db, closer := newDBAdapter(t)
+					defer closer.Close()
+
+					ctx := context.Background()
+
+					_, err := db.ExecContext(ctx, `INSERT INTO users (name, age, address) VALUES ('Pointer Ribeiro', 0, '{"country":"US"}')`)
+					tt.AssertNoErr(t, err)
+					var pointerUser user
+					getUserByName(db, driver, &pointerUser, "Pointer Ribeiro")
+
+					_, err = db.ExecContext(ctx, fmt.Sprint(`INSERT INTO posts (user_id, title) VALUES (`, pointerUser.ID, `, 'Pointer Post1')`))
+					tt.AssertNoErr(t, err)
+
+					c := newTestDB(db, driver)
+					var row struct {
+						User *user `tablename:"u"`
+						Post *post `tablename:"p"`
+					}
+					err = c.QueryOne(ctx, &row, fmt.Sprint(
+						`FROM users u JOIN posts p ON p.user_id = u.id`,
+						` WHERE u.name = `, c.dialect.Placeholder(0),
+					), "Pointer Ribeiro")
+
+					tt.AssertNoErr(t, err)
+					tt.AssertNotEqual(t, row.User, (*user)(nil))
+					tt.AssertNotEqual(t, row.Post, (*post)(nil))
+					tt.AssertEqual(t, row.User.ID, pointerUser.ID)
+					tt.AssertEqual(t, row.User.Name, "Pointer Ribeiro")
+					tt.AssertEqual(t, row.Post.Title, "Pointer Post1")
+				})
+
+				t.Run("should work with pointers to anonymous structs", func(t *testing.T) {
+					db, closer := newDBAdapter(t)
+					defer closer.Close()
+
+					ctx := context.Background()
+
+					_, err := db.ExecContext(ctx, `INSERT INTO users (name, age, address) VALUES ('Anonymous User', 42, '{"country":"BR"}')`)
+					tt.AssertNoErr(t, err)
+
+					c := newTestDB(db, driver)
+
+					// Running it twice for making sure the cached
+					// info of the anonymous struct is reused correctly:
+					for i := 0; i < 2; i++ {
+						var row struct {
+							Name    string  `ksql:"name"`
+							Age     int     `ksql:"age"`
+							Address address `ksql:"address,json"`
+						}
+						err = c.QueryOne(ctx, &row, variation.queryPrefix+`FROM users WHERE name = `+c.dialect.Placeholder(0), "Anonymous User")
+						tt.AssertNoErr(t, err)
+						tt.AssertEqual(t, row.Name, "Anonymous User")
+						tt.AssertEqual(t, row.Age, 42)
+						tt.AssertEqual(t, row.Address.Country, "BR")
+					}
+				})
 			})
 		}
 
@@ -659,1559 +929,8221 @@ func QueryOneTest(
 	})
 }
 
-// InsertTest runs all tests for making sure the Insert function is
-// working for a given adapter and driver.
-func InsertTest(
+// QueryOneByExampleTest runs all tests for making sure the QueryOneByExample
+// function is working correctly.
+func QueryOneByExampleTest(
 	t *testing.T,
 	driver string,
 	connStr string,
 	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
 ) {
-	t.Run("Insert", func(t *testing.T) {
-		t.Run("success cases", func(t *testing.T) {
-			t.Run("single primary key tables", func(t *testing.T) {
-				err := createTables(driver, connStr)
-				if err != nil {
-					t.Fatal("could not create test table!, reason:", err.Error())
-				}
+	t.Run("QueryOneByExample", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
 
-				t.Run("should insert one user correctly", func(t *testing.T) {
-					db, closer := newDBAdapter(t)
-					defer closer.Close()
+		type userFilter struct {
+			ID   *uint   `ksql:"id"`
+			Name *string `ksql:"name"`
+			Age  *int    `ksql:"age"`
+		}
 
-					ctx := context.Background()
-					c := newTestDB(db, driver)
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
 
-					u := user{
-						Name: "Fernanda",
-						Address: address{
-							Country: "Brazil",
-						},
-					}
+		ctx := context.Background()
+		c := newTestDB(db, driver)
 
-					err := c.Insert(ctx, usersTable, &u)
-					assert.Equal(t, nil, err)
-					assert.NotEqual(t, 0, u.ID)
+		_, err = db.ExecContext(ctx, `INSERT INTO users (name, age, address) VALUES ('Example User', 22, '{"country":"BR"}')`)
+		tt.AssertNoErr(t, err)
+		_, err = db.ExecContext(ctx, `INSERT INTO users (name, age, address) VALUES ('Example User', 33, '{"country":"US"}')`)
+		tt.AssertNoErr(t, err)
 
-					result := user{}
-					err = getUserByID(c.db, c.dialect, &result, u.ID)
-					assert.Equal(t, nil, err)
+		t.Run("should filter by all attributes set on the example", func(t *testing.T) {
+			name := "Example User"
+			age := 33
+			var u user
+			err := c.QueryOneByExample(ctx, usersTable, &u, userFilter{
+				Name: &name,
+				Age:  &age,
+			})
+			tt.AssertNoErr(t, err)
+			tt.AssertNotEqual(t, u.ID, uint(0))
+			tt.AssertEqual(t, u.Name, "Example User")
+			tt.AssertEqual(t, u.Age, 33)
+			tt.AssertEqual(t, u.Address.Country, "US")
+		})
 
-					assert.Equal(t, u.Name, result.Name)
-					assert.Equal(t, u.Address, result.Address)
-				})
+		t.Run("should return ErrRecordNotFound when nothing matches", func(t *testing.T) {
+			name := "Example User"
+			age := 44
+			var u user
+			err := c.QueryOneByExample(ctx, usersTable, &u, userFilter{
+				Name: &name,
+				Age:  &age,
+			})
+			tt.AssertEqual(t, err, ErrRecordNotFound)
+		})
 
-				t.Run("should insert ignoring the ID with multiple ids", func(t *testing.T) {
-					if supportedDialects[driver].InsertMethod() != insertWithLastInsertID {
-						return
-					}
+		t.Run("should report error if the example has no attributes set", func(t *testing.T) {
+			var u user
+			err := c.QueryOneByExample(ctx, usersTable, &u, userFilter{})
+			tt.AssertErrContains(t, err, "no attributes set")
+		})
 
-					// Using columns "id" and "name" as IDs:
-					table := NewTable("users", "id", "name")
+		t.Run("should report error if the table is invalid", func(t *testing.T) {
+			name := "Example User"
+			var u user
+			err := c.QueryOneByExample(ctx, NewTable(""), &u, userFilter{Name: &name})
+			tt.AssertErrContains(t, err, "table name")
+		})
+	})
+}
 
-					db, closer := newDBAdapter(t)
-					defer closer.Close()
+// QueryByIDsTest runs all tests for making sure the QueryByIDs
+// function is working correctly.
+func QueryByIDsTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("QueryByIDs", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
 
-					ctx := context.Background()
-					c := newTestDB(db, driver)
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
 
-					u := user{
-						Name: "No ID returned",
-						Age:  3434, // Random number to avoid false positives on this test
+		ctx := context.Background()
+		c := newTestDB(db, driver)
 
-						Address: address{
-							Country: "Brazil 3434",
-						},
-					}
+		var inserted []user
+		for i := 1; i <= 4; i++ {
+			u := user{Name: fmt.Sprintf("User %d", i)}
+			err := c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+			inserted = append(inserted, u)
+		}
 
-					err = c.Insert(ctx, table, &u)
-					assert.Equal(t, nil, err)
-					assert.Equal(t, uint(0), u.ID)
+		t.Run("should return the records in the same order as the input ids", func(t *testing.T) {
+			var users []user
+			err := c.QueryByIDs(ctx, usersTable, &users, []interface{}{
+				inserted[2].ID, inserted[0].ID, inserted[3].ID, inserted[1].ID,
+			})
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(users), 4)
+			tt.AssertEqual(t, users[0].Name, "User 3")
+			tt.AssertEqual(t, users[1].Name, "User 1")
+			tt.AssertEqual(t, users[2].Name, "User 4")
+			tt.AssertEqual(t, users[3].Name, "User 2")
+		})
 
-					result := user{}
-					err = getUserByName(c.db, driver, &result, "No ID returned")
-					assert.Equal(t, nil, err)
+		t.Run("should leave missing ids out and accept convertible id types", func(t *testing.T) {
+			var users []*user
+			err := c.QueryByIDs(ctx, usersTable, &users, []interface{}{
+				int(inserted[3].ID), 4242, int64(inserted[1].ID),
+			})
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(users), 2)
+			tt.AssertEqual(t, users[0].Name, "User 4")
+			tt.AssertEqual(t, users[1].Name, "User 2")
+		})
 
-					assert.Equal(t, u.Age, result.Age)
-					assert.Equal(t, u.Address, result.Address)
-				})
+		t.Run("should return an empty slice without querying if no ids are given", func(t *testing.T) {
+			numQueries := 0
+			c := newTestDB(mockDBAdapter{
+				QueryContextFn: func(ctx context.Context, query string, params ...interface{}) (Rows, error) {
+					numQueries++
+					return db.QueryContext(ctx, query, params...)
+				},
+			}, driver)
 
-				t.Run("should work with anonymous structs", func(t *testing.T) {
-					db, closer := newDBAdapter(t)
-					defer closer.Close()
+			users := []user{{Name: "should be removed"}}
+			err := c.QueryByIDs(ctx, usersTable, &users, []interface{}{})
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, users, []user{})
+			tt.AssertEqual(t, numQueries, 0)
+		})
 
-					ctx := context.Background()
-					c := newTestDB(db, driver)
-					err = c.Insert(ctx, usersTable, &struct {
-						ID      int                    `ksql:"id"`
-						Name    string                 `ksql:"name"`
-						Address map[string]interface{} `ksql:"address,json"`
-					}{Name: "fake-name", Address: map[string]interface{}{"city": "bar"}})
-					assert.Equal(t, nil, err)
-				})
+		t.Run("should report error for tables with composite keys", func(t *testing.T) {
+			var users []user
+			err := c.QueryByIDs(ctx, NewTable("users", "id", "name"), &users, []interface{}{1})
+			tt.AssertErrContains(t, err, "single ID column")
+		})
 
-				t.Run("should work with preset IDs", func(t *testing.T) {
-					db, closer := newDBAdapter(t)
-					defer closer.Close()
+		t.Run("should report error for ids of incompatible types", func(t *testing.T) {
+			var users []user
+			err := c.QueryByIDs(ctx, usersTable, &users, []interface{}{"not an id"})
+			tt.AssertErrContains(t, err, "not an id", "string")
+		})
+	})
+}
 
-					ctx := context.Background()
-					c := newTestDB(db, driver)
+// QueryAllTest runs all tests for making sure the QueryAll
+// function is working correctly.
+func QueryAllTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("QueryAll", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
 
-					usersByName := NewTable("users", "name")
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
 
-					err = c.Insert(ctx, usersByName, &struct {
-						Name string `ksql:"name"`
-						Age  int    `ksql:"age"`
-					}{Name: "Preset Name", Age: 5455})
-					assert.Equal(t, nil, err)
+		ctx := context.Background()
+		c := newTestDB(db, driver)
 
-					var inserted user
-					err := getUserByName(db, driver, &inserted, "Preset Name")
-					assert.Equal(t, nil, err)
-					assert.Equal(t, 5455, inserted.Age)
-				})
-			})
+		t.Run("should return an empty slice for empty tables", func(t *testing.T) {
+			var users []user
+			err := c.QueryAll(ctx, usersTable, &users)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(users), 0)
+		})
 
-			t.Run("composite key tables", func(t *testing.T) {
-				err := createTables(driver, connStr)
-				if err != nil {
-					t.Fatal("could not create test table!, reason:", err.Error())
-				}
+		for _, u := range []user{
+			{Name: "Bia", Age: 20},
+			{Name: "Ana", Age: 30},
+			{Name: "Caio", Age: 20},
+		} {
+			u := u
+			err := c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+		}
 
-				t.Run("should insert in composite key tables correctly", func(t *testing.T) {
+		t.Run("should load all the rows of the table", func(t *testing.T) {
+			var users []user
+			err := c.QueryAll(ctx, usersTable, &users)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(users), 3)
+		})
+
+		t.Run("should sort the rows by the orderBy columns", func(t *testing.T) {
+			var users []*user
+			err := c.QueryAll(ctx, usersTable, &users, "age DESC", "name")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(users), 3)
+			tt.AssertEqual(t, users[0].Name, "Ana")
+			tt.AssertEqual(t, users[1].Name, "Bia")
+			tt.AssertEqual(t, users[2].Name, "Caio")
+		})
+
+		t.Run("should report error for invalid orderBy columns", func(t *testing.T) {
+			var users []user
+			err := c.QueryAll(ctx, usersTable, &users, "name; DROP TABLE users")
+			tt.AssertErrContains(t, err, "invalid ORDER BY")
+		})
+	})
+}
+
+// QueryMapTest runs all tests for making sure the QueryMap function is
+// working for a given adapter and driver.
+func QueryMapTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("QueryMap", func(t *testing.T) {
+		variations := []struct {
+			desc        string
+			queryPrefix string
+		}{
+			{
+				desc:        "with select *",
+				queryPrefix: "SELECT * ",
+			},
+			{
+				desc:        "building the SELECT part of the query internally",
+				queryPrefix: "",
+			},
+		}
+		for _, variation := range variations {
+			t.Run(variation.desc, func(t *testing.T) {
+				err := createTables(driver, connStr)
+				if err != nil {
+					t.Fatal("could not create test table!, reason:", err.Error())
+				}
+
+				t.Run("should return an empty map when there are no results", func(t *testing.T) {
 					db, closer := newDBAdapter(t)
 					defer closer.Close()
 
 					ctx := context.Background()
 					c := newTestDB(db, driver)
-
-					table := NewTable("user_permissions", "id", "user_id", "perm_id")
-					err = c.Insert(ctx, table, &userPermission{
-						UserID: 1,
-						PermID: 42,
-					})
+					var users map[uint]user
+					err := c.QueryMap(ctx, &users, "id", variation.queryPrefix+`FROM users WHERE id=1;`)
 					tt.AssertNoErr(t, err)
-
-					userPerms, err := getUserPermissionsByUser(db, driver, 1)
-					tt.AssertNoErr(t, err)
-					tt.AssertEqual(t, len(userPerms), 1)
-					tt.AssertEqual(t, userPerms[0].UserID, 1)
-					tt.AssertEqual(t, userPerms[0].PermID, 42)
+					tt.AssertEqual(t, users, map[uint]user{})
 				})
 
-				t.Run("should accept partially provided values for composite key tables", func(t *testing.T) {
+				t.Run("should return the users indexed by the key column", func(t *testing.T) {
 					db, closer := newDBAdapter(t)
 					defer closer.Close()
 
 					ctx := context.Background()
 					c := newTestDB(db, driver)
 
-					// Table defined with 3 values, but we'll provide only 2,
-					// the third will be generated for the purposes of this test:
-					table := NewTable("user_permissions", "id", "user_id", "perm_id")
-					permission := userPermission{
-						UserID: 2,
-						PermID: 42,
-					}
-					err = c.Insert(ctx, table, &permission)
+					_, err := db.ExecContext(ctx, `INSERT INTO users (name, age, address) VALUES ('Ana Map', 20, '{"country":"BR"}')`)
 					tt.AssertNoErr(t, err)
-
-					userPerms, err := getUserPermissionsByUser(db, driver, 2)
+					_, err = db.ExecContext(ctx, `INSERT INTO users (name, age, address) VALUES ('Beto Map', 30, '{"country":"US"}')`)
 					tt.AssertNoErr(t, err)
 
-					// Should retrieve the generated ID from the database,
-					// only if the database supports returning multiple values:
-					switch c.dialect.InsertMethod() {
-					case insertWithNoIDRetrieval, insertWithLastInsertID:
-						tt.AssertEqual(t, permission.ID, 0)
-						tt.AssertEqual(t, len(userPerms), 1)
-						tt.AssertEqual(t, userPerms[0].UserID, 2)
-						tt.AssertEqual(t, userPerms[0].PermID, 42)
-					case insertWithReturning, insertWithOutput:
-						tt.AssertNotEqual(t, permission.ID, 0)
-						tt.AssertEqual(t, len(userPerms), 1)
-						tt.AssertEqual(t, userPerms[0].ID, permission.ID)
-						tt.AssertEqual(t, userPerms[0].UserID, 2)
-						tt.AssertEqual(t, userPerms[0].PermID, 42)
+					var ana, beto user
+					tt.AssertNoErr(t, getUserByName(db, driver, &ana, "Ana Map"))
+					tt.AssertNoErr(t, getUserByName(db, driver, &beto, "Beto Map"))
+
+					var users map[int]struct {
+						ID   int    `ksql:"id"`
+						Name string `ksql:"name"`
+						Age  int    `ksql:"age"`
 					}
+					err = c.QueryMap(ctx, &users, "id", variation.queryPrefix+`FROM users WHERE name like `+c.dialect.Placeholder(0), "% Map")
+					tt.AssertNoErr(t, err)
+					tt.AssertEqual(t, len(users), 2)
+					tt.AssertEqual(t, users[int(ana.ID)].Name, "Ana Map")
+					tt.AssertEqual(t, users[int(ana.ID)].Age, 20)
+					tt.AssertEqual(t, users[int(beto.ID)].Name, "Beto Map")
+					tt.AssertEqual(t, users[int(beto.ID)].Age, 30)
 				})
-			})
-		})
-
-		t.Run("testing error cases", func(t *testing.T) {
-			err := createTables(driver, connStr)
-			if err != nil {
-				t.Fatal("could not create test table!, reason:", err.Error())
-			}
 
-			t.Run("should report error for invalid input types", func(t *testing.T) {
-				db, closer := newDBAdapter(t)
-				defer closer.Close()
-
-				ctx := context.Background()
-				c := newTestDB(db, driver)
+				t.Run("should work with maps of pointers and non id columns", func(t *testing.T) {
+					db, closer := newDBAdapter(t)
+					defer closer.Close()
 
-				err = c.Insert(ctx, usersTable, "foo")
-				assert.NotEqual(t, nil, err)
+					ctx := context.Background()
+					c := newTestDB(db, driver)
 
-				err = c.Insert(ctx, usersTable, nullable.String("foo"))
-				assert.NotEqual(t, nil, err)
+					_, err := db.ExecContext(ctx, `INSERT INTO users (name, age, address) VALUES ('Caio PtrMap', 40, '{"country":"BR"}')`)
+					tt.AssertNoErr(t, err)
 
-				err = c.Insert(ctx, usersTable, map[string]interface{}{
-					"name": "foo",
-					"age":  12,
+					var users map[string]*user
+					err = c.QueryMap(ctx, &users, "name", variation.queryPrefix+`FROM users WHERE name = `+c.dialect.Placeholder(0), "Caio PtrMap")
+					tt.AssertNoErr(t, err)
+					tt.AssertEqual(t, len(users), 1)
+					tt.AssertNotEqual(t, users["Caio PtrMap"], (*user)(nil))
+					tt.AssertEqual(t, users["Caio PtrMap"].Age, 40)
+					tt.AssertEqual(t, users["Caio PtrMap"].Address.Country, "BR")
 				})
-				assert.NotEqual(t, nil, err)
-
-				cantInsertSlice := []interface{}{
-					&user{Name: "foo", Age: 22},
-					&user{Name: "bar", Age: 32},
-				}
-				err = c.Insert(ctx, usersTable, cantInsertSlice)
-				assert.NotEqual(t, nil, err)
-
-				// We might want to support this in the future, but not for now:
-				err = c.Insert(ctx, usersTable, user{Name: "not a ptr to user", Age: 42})
-				assert.NotEqual(t, nil, err)
-			})
-
-			t.Run("should report error if for some reason the insertMethod is invalid", func(t *testing.T) {
-				db, closer := newDBAdapter(t)
-				defer closer.Close()
-
-				ctx := context.Background()
-				c := newTestDB(db, driver)
-
-				// This is an invalid value:
-				c.dialect = brokenDialect{}
-
-				err = c.Insert(ctx, usersTable, &user{Name: "foo"})
-				assert.NotEqual(t, nil, err)
-			})
-
-			t.Run("should report error if it receives a nil pointer to a struct", func(t *testing.T) {
-				db, closer := newDBAdapter(t)
-				defer closer.Close()
-
-				ctx := context.Background()
-				c := newTestDB(db, driver)
 
-				var u *user
-				err := c.Insert(ctx, usersTable, u)
-				assert.NotEqual(t, nil, err)
-			})
+				t.Run("should report error if two rows have the same key", func(t *testing.T) {
+					db, closer := newDBAdapter(t)
+					defer closer.Close()
 
-			t.Run("should report error if table contains an empty ID name", func(t *testing.T) {
-				db, closer := newDBAdapter(t)
-				defer closer.Close()
+					ctx := context.Background()
+					c := newTestDB(db, driver)
 
-				ctx := context.Background()
-				c := newTestDB(db, driver)
+					_, err := db.ExecContext(ctx, `INSERT INTO users (name, age, address) VALUES ('Dani Dup', 50, '{"country":"BR"}')`)
+					tt.AssertNoErr(t, err)
+					_, err = db.ExecContext(ctx, `INSERT INTO users (name, age, address) VALUES ('Edu Dup', 50, '{"country":"BR"}')`)
+					tt.AssertNoErr(t, err)
 
-				err := c.Insert(ctx, NewTable("users", ""), &user{Name: "fake-name"})
-				tt.AssertErrContains(t, err, "ksql.Table", "ID", "empty string")
+					var users map[int]user
+					err = c.QueryMap(ctx, &users, "age", variation.queryPrefix+`FROM users WHERE name like `+c.dialect.Placeholder(0), "% Dup")
+					tt.AssertErrContains(t, err, "more than one row", "age", "50")
+				})
 			})
+		}
 
-			t.Run("should report error if ksql.Table.name is empty", func(t *testing.T) {
-				db, closer := newDBAdapter(t)
-				defer closer.Close()
+		t.Run("should report error if input is not a pointer to a map of structs", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
 
-				ctx := context.Background()
-				c := newTestDB(db, driver)
+			ctx := context.Background()
+			c := newTestDB(db, driver)
 
-				err := c.Insert(ctx, NewTable("", "id"), &user{Name: "fake-name"})
-				tt.AssertErrContains(t, err, "ksql.Table", "table name", "empty string")
-			})
+			err := c.QueryMap(ctx, map[int]user{}, "id", `SELECT * FROM users`)
+			tt.AssertErrContains(t, err, "pointer to map of structs")
 
-			t.Run("should not panic if a column doesn't exist in the database", func(t *testing.T) {
-				db, closer := newDBAdapter(t)
-				defer closer.Close()
+			err = c.QueryMap(ctx, &[]user{}, "id", `SELECT * FROM users`)
+			tt.AssertErrContains(t, err, "pointer to map of structs")
 
-				ctx := context.Background()
-				c := newTestDB(db, driver)
+			var ids map[int]int
+			err = c.QueryMap(ctx, &ids, "id", `SELECT * FROM users`)
+			tt.AssertErrContains(t, err, "pointer to map of structs")
+		})
 
-				err = c.Insert(ctx, usersTable, &struct {
-					ID                string `ksql:"id"`
-					NonExistingColumn int    `ksql:"non_existing"`
-					Name              string `ksql:"name"`
-				}{NonExistingColumn: 42, Name: "fake-name"})
-				assert.NotEqual(t, nil, err)
-				msg := err.Error()
-				assert.Equal(t, true, strings.Contains(msg, "column"))
-				assert.Equal(t, true, strings.Contains(msg, "non_existing"))
-			})
+		t.Run("should report error if the key column is not tagged on the struct", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
 
-			t.Run("should not panic if the ID column doesn't exist in the database", func(t *testing.T) {
-				db, closer := newDBAdapter(t)
-				defer closer.Close()
+			ctx := context.Background()
+			c := newTestDB(db, driver)
 
-				ctx := context.Background()
-				c := newTestDB(db, driver)
+			var users map[int]user
+			err := c.QueryMap(ctx, &users, "non_existing_column", `SELECT * FROM users`)
+			tt.AssertErrContains(t, err, "key column", "non_existing_column")
+		})
 
-				brokenTable := NewTable("users", "non_existing_id")
+		t.Run("should report error if the key type doesn't match the column type", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
 
-				_ = c.Insert(ctx, brokenTable, &struct {
-					ID   string `ksql:"non_existing_id"`
-					Age  int    `ksql:"age"`
-					Name string `ksql:"name"`
-				}{Age: 42, Name: "fake-name"})
-			})
+			ctx := context.Background()
+			c := newTestDB(db, driver)
 
-			t.Run("should not panic if the ID column is missing in the struct", func(t *testing.T) {
-				db, closer := newDBAdapter(t)
-				defer closer.Close()
+			var users map[int]user
+			err := c.QueryMap(ctx, &users, "name", `SELECT * FROM users`)
+			tt.AssertErrContains(t, err, "can't use field", "name")
+		})
 
-				ctx := context.Background()
-				c := newTestDB(db, driver)
+		t.Run("should report error if the query is not valid", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
 
-				err = c.Insert(ctx, usersTable, &struct {
-					Age  int    `ksql:"age"`
-					Name string `ksql:"name"`
-				}{Age: 42, Name: "Inserted With no ID"})
-				assert.Equal(t, nil, err)
+			ctx := context.Background()
+			c := newTestDB(db, driver)
 
-				var u user
-				err = getUserByName(db, driver, &u, "Inserted With no ID")
-				assert.Equal(t, nil, err)
-				assert.NotEqual(t, uint(0), u.ID)
-				assert.Equal(t, 42, u.Age)
-			})
+			var users map[int]user
+			err := c.QueryMap(ctx, &users, "id", `SELECT * FROM not a valid query`)
+			tt.AssertErrContains(t, err, "error running query")
 		})
 	})
 }
 
-type brokenDialect struct{}
-
-func (brokenDialect) InsertMethod() insertMethod {
-	return insertMethod(42)
-}
-
-func (brokenDialect) Escape(str string) string {
-	return str
-}
-
-func (brokenDialect) Placeholder(idx int) string {
-	return "?"
-}
-
-func (brokenDialect) DriverName() string {
-	return "fake-driver-name"
-}
-
-// DeleteTest runs all tests for making sure the Delete function is
+// InsertTest runs all tests for making sure the Insert function is
 // working for a given adapter and driver.
-func DeleteTest(
+func InsertTest(
 	t *testing.T,
 	driver string,
 	connStr string,
 	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
 ) {
-	t.Run("Delete", func(t *testing.T) {
-		err := createTables(driver, connStr)
-		if err != nil {
-			t.Fatal("could not create test table!, reason:", err.Error())
-		}
-
-		t.Run("should delete from tables with a single primary key correctly", func(t *testing.T) {
-			tests := []struct {
-				desc               string
-				deletionKeyForUser func(u user) interface{}
-			}{
-				{
-					desc: "passing only the ID as key",
-					deletionKeyForUser: func(u user) interface{} {
-						return u.ID
-					},
-				},
-				{
-					desc: "passing only the entire user",
-					deletionKeyForUser: func(u user) interface{} {
-						return u
-					},
-				},
-				{
-					desc: "passing the address of the user",
-					deletionKeyForUser: func(u user) interface{} {
-						return &u
-					},
-				},
-			}
+	t.Run("Insert", func(t *testing.T) {
+		t.Run("success cases", func(t *testing.T) {
+			t.Run("single primary key tables", func(t *testing.T) {
+				err := createTables(driver, connStr)
+				if err != nil {
+					t.Fatal("could not create test table!, reason:", err.Error())
+				}
 
-			for _, test := range tests {
-				t.Run(test.desc, func(t *testing.T) {
+				t.Run("should insert one user correctly", func(t *testing.T) {
 					db, closer := newDBAdapter(t)
 					defer closer.Close()
 
 					ctx := context.Background()
 					c := newTestDB(db, driver)
 
-					u1 := user{
+					u := user{
 						Name: "Fernanda",
+						Address: address{
+							Country: "Brazil",
+						},
 					}
 
-					err := c.Insert(ctx, usersTable, &u1)
+					err := c.Insert(ctx, usersTable, &u)
 					assert.Equal(t, nil, err)
-					assert.NotEqual(t, uint(0), u1.ID)
+					assert.NotEqual(t, 0, u.ID)
 
 					result := user{}
-					err = getUserByID(c.db, c.dialect, &result, u1.ID)
-					assert.Equal(t, nil, err)
-					assert.Equal(t, u1.ID, result.ID)
-
-					u2 := user{
-						Name: "Won't be deleted",
-					}
-
-					err = c.Insert(ctx, usersTable, &u2)
-					assert.Equal(t, nil, err)
-					assert.NotEqual(t, uint(0), u2.ID)
-
-					result = user{}
-					err = getUserByID(c.db, c.dialect, &result, u2.ID)
-					assert.Equal(t, nil, err)
-					assert.Equal(t, u2.ID, result.ID)
-
-					err = c.Delete(ctx, usersTable, test.deletionKeyForUser(u1))
-					assert.Equal(t, nil, err)
-
-					result = user{}
-					err = getUserByID(c.db, c.dialect, &result, u1.ID)
-					assert.Equal(t, sql.ErrNoRows, err)
-
-					result = user{}
-					err = getUserByID(c.db, c.dialect, &result, u2.ID)
+					err = getUserByID(c.db, c.dialect, &result, u.ID)
 					assert.Equal(t, nil, err)
 
-					assert.NotEqual(t, uint(0), result.ID)
-					assert.Equal(t, "Won't be deleted", result.Name)
+					assert.Equal(t, u.Name, result.Name)
+					assert.Equal(t, u.Address, result.Address)
 				})
-			}
-		})
 
-		t.Run("should delete from tables with composite primary keys correctly", func(t *testing.T) {
-			t.Run("using structs", func(t *testing.T) {
-				db, closer := newDBAdapter(t)
-				defer closer.Close()
-
-				ctx := context.Background()
-				c := newTestDB(db, driver)
-
-				// This permission should not be deleted, we'll use the id to check it:
-				p0 := userPermission{
-					UserID: 1,
-					PermID: 44,
-				}
-				err = c.Insert(ctx, NewTable("user_permissions", "id"), &p0)
-				tt.AssertNoErr(t, err)
-				tt.AssertNotEqual(t, p0.ID, 0)
+				t.Run("should insert ignoring the ID with multiple ids", func(t *testing.T) {
+					if supportedDialects[driver].InsertMethod() != insertWithLastInsertID {
+						return
+					}
 
-				p1 := userPermission{
-					UserID: 1,
-					PermID: 42,
-				}
-				err = c.Insert(ctx, NewTable("user_permissions", "id"), &p1)
-				tt.AssertNoErr(t, err)
+					// Using columns "id" and "name" as IDs:
+					table := NewTable("users", "id", "name")
 
-				err = c.Delete(ctx, userPermissionsTable, p1)
-				tt.AssertNoErr(t, err)
+					db, closer := newDBAdapter(t)
+					defer closer.Close()
 
-				userPerms, err := getUserPermissionsByUser(db, driver, 1)
-				tt.AssertNoErr(t, err)
-				tt.AssertEqual(t, len(userPerms), 1)
-				tt.AssertEqual(t, userPerms[0].UserID, 1)
-				tt.AssertEqual(t, userPerms[0].PermID, 44)
-			})
+					ctx := context.Background()
+					c := newTestDB(db, driver)
 
-			t.Run("using maps", func(t *testing.T) {
-				db, closer := newDBAdapter(t)
-				defer closer.Close()
+					u := user{
+						Name: "No ID returned",
+						Age:  3434, // Random number to avoid false positives on this test
 
-				ctx := context.Background()
-				c := newTestDB(db, driver)
+						Address: address{
+							Country: "Brazil 3434",
+						},
+					}
 
-				// This permission should not be deleted, we'll use the id to check it:
-				p0 := userPermission{
-					UserID: 2,
-					PermID: 44,
-				}
-				err = c.Insert(ctx, NewTable("user_permissions", "id"), &p0)
-				tt.AssertNoErr(t, err)
-				tt.AssertNotEqual(t, p0.ID, 0)
+					err = c.Insert(ctx, table, &u)
+					assert.Equal(t, nil, err)
+					assert.Equal(t, uint(0), u.ID)
 
-				p1 := userPermission{
-					UserID: 2,
-					PermID: 42,
-				}
-				err = c.Insert(ctx, NewTable("user_permissions", "id"), &p1)
-				tt.AssertNoErr(t, err)
+					result := user{}
+					err = getUserByName(c.db, driver, &result, "No ID returned")
+					assert.Equal(t, nil, err)
 
-				err = c.Delete(ctx, userPermissionsTable, map[string]interface{}{
-					"user_id": 2,
-					"perm_id": 42,
+					assert.Equal(t, u.Age, result.Age)
+					assert.Equal(t, u.Address, result.Address)
 				})
-				tt.AssertNoErr(t, err)
-
-				userPerms, err := getUserPermissionsByUser(db, driver, 2)
-				tt.AssertNoErr(t, err)
-				tt.AssertEqual(t, len(userPerms), 1)
-				tt.AssertEqual(t, userPerms[0].UserID, 2)
-				tt.AssertEqual(t, userPerms[0].PermID, 44)
-			})
-		})
-
-		t.Run("should return ErrRecordNotFound if no rows were deleted", func(t *testing.T) {
-			db, closer := newDBAdapter(t)
-			defer closer.Close()
-
-			ctx := context.Background()
-			c := newTestDB(db, driver)
-
-			err = c.Delete(ctx, usersTable, 4200)
-			assert.Equal(t, ErrRecordNotFound, err)
-		})
-
-		t.Run("should report error if it receives a nil pointer to a struct", func(t *testing.T) {
-			db, closer := newDBAdapter(t)
-			defer closer.Close()
-
-			ctx := context.Background()
-			c := newTestDB(db, driver)
 
-			var u *user
-			err := c.Delete(ctx, usersTable, u)
-			assert.NotEqual(t, nil, err)
-		})
-
-		t.Run("should report error if one of the ids is missing from the input", func(t *testing.T) {
-			t.Run("single id", func(t *testing.T) {
-				t.Run("struct with missing attr", func(t *testing.T) {
+				t.Run("should work with anonymous structs", func(t *testing.T) {
 					db, closer := newDBAdapter(t)
 					defer closer.Close()
 
 					ctx := context.Background()
 					c := newTestDB(db, driver)
-
-					err := c.Delete(ctx, NewTable("users", "id"), &struct {
-						// Missing ID
-						Name string `ksql:"name"`
-					}{Name: "fake-name"})
-					tt.AssertErrContains(t, err, "missing required", "id")
+					err = c.Insert(ctx, usersTable, &struct {
+						ID      int                    `ksql:"id"`
+						Name    string                 `ksql:"name"`
+						Address map[string]interface{} `ksql:"address,json"`
+					}{Name: "fake-name", Address: map[string]interface{}{"city": "bar"}})
+					assert.Equal(t, nil, err)
 				})
 
-				t.Run("struct with NULL attr", func(t *testing.T) {
+				t.Run("should work with preset IDs", func(t *testing.T) {
 					db, closer := newDBAdapter(t)
 					defer closer.Close()
 
 					ctx := context.Background()
 					c := newTestDB(db, driver)
 
-					err := c.Delete(ctx, NewTable("users", "id"), &struct {
-						// Null ID
-						ID   *int   `ksql:"id"`
+					usersByName := NewTable("users", "name")
+
+					err = c.Insert(ctx, usersByName, &struct {
 						Name string `ksql:"name"`
-					}{Name: "fake-name"})
-					tt.AssertErrContains(t, err, "missing required", "id")
+						Age  int    `ksql:"age"`
+					}{Name: "Preset Name", Age: 5455})
+					assert.Equal(t, nil, err)
+
+					var inserted user
+					err := getUserByName(db, driver, &inserted, "Preset Name")
+					assert.Equal(t, nil, err)
+					assert.Equal(t, 5455, inserted.Age)
 				})
 
-				t.Run("struct with zero attr", func(t *testing.T) {
+				t.Run("should not write the ID back if configured to skip it", func(t *testing.T) {
 					db, closer := newDBAdapter(t)
 					defer closer.Close()
 
 					ctx := context.Background()
-					c := newTestDB(db, driver)
+					c := newTestDB(db, driver).WithSkipIDWriteBack(true)
 
-					err := c.Delete(ctx, NewTable("users", "id"), &struct {
-						// Uninitialized ID
-						ID   int    `ksql:"id"`
-						Name string `ksql:"name"`
-					}{Name: "fake-name"})
-					tt.AssertErrContains(t, err, "invalid value", "0", "id")
+					u := user{
+						Name: "Skip ID Write Back",
+						Age:  7878,
+					}
+
+					err := c.Insert(ctx, usersTable, &u)
+					tt.AssertNoErr(t, err)
+					tt.AssertEqual(t, u.ID, uint(0))
+
+					var inserted user
+					err = getUserByName(db, driver, &inserted, "Skip ID Write Back")
+					tt.AssertNoErr(t, err)
+					tt.AssertNotEqual(t, inserted.ID, uint(0))
+					tt.AssertEqual(t, inserted.Age, 7878)
 				})
 			})
 
-			t.Run("multiple ids", func(t *testing.T) {
-				t.Run("struct with missing attr", func(t *testing.T) {
+			t.Run("composite key tables", func(t *testing.T) {
+				err := createTables(driver, connStr)
+				if err != nil {
+					t.Fatal("could not create test table!, reason:", err.Error())
+				}
+
+				t.Run("should insert in composite key tables correctly", func(t *testing.T) {
 					db, closer := newDBAdapter(t)
 					defer closer.Close()
 
 					ctx := context.Background()
 					c := newTestDB(db, driver)
 
-					err := c.Delete(ctx, NewTable("user_permissions", "user_id", "perm_id"), map[string]interface{}{
-						// Missing PermID
-						"user_id": 1,
-						"name":    "fake-name",
+					table := NewTable("user_permissions", "id", "user_id", "perm_id")
+					err = c.Insert(ctx, table, &userPermission{
+						UserID: 1,
+						PermID: 42,
 					})
-					tt.AssertErrContains(t, err, "missing required", "perm_id")
+					tt.AssertNoErr(t, err)
+
+					userPerms, err := getUserPermissionsByUser(db, driver, 1)
+					tt.AssertNoErr(t, err)
+					tt.AssertEqual(t, len(userPerms), 1)
+					tt.AssertEqual(t, userPerms[0].UserID, 1)
+					tt.AssertEqual(t, userPerms[0].PermID, 42)
 				})
 
-				t.Run("struct with NULL attr", func(t *testing.T) {
+				t.Run("should accept partially provided values for composite key tables", func(t *testing.T) {
 					db, closer := newDBAdapter(t)
 					defer closer.Close()
 
 					ctx := context.Background()
 					c := newTestDB(db, driver)
 
-					err := c.Delete(ctx, NewTable("user_permissions", "user_id", "perm_id"), map[string]interface{}{
-						// Null Perm ID
-						"user_id": 1,
-						"perm_id": nil,
-						"name":    "fake-name",
-					})
-					tt.AssertErrContains(t, err, "invalid value", "nil", "perm_id")
-				})
-
-				t.Run("struct with zero attr", func(t *testing.T) {
-					db, closer := newDBAdapter(t)
-					defer closer.Close()
+					// Table defined with 3 values, but we'll provide only 2,
+					// the third will be generated for the purposes of this test:
+					table := NewTable("user_permissions", "id", "user_id", "perm_id")
+					permission := userPermission{
+						UserID: 2,
+						PermID: 42,
+					}
+					err = c.Insert(ctx, table, &permission)
+					tt.AssertNoErr(t, err)
 
-					ctx := context.Background()
-					c := newTestDB(db, driver)
+					userPerms, err := getUserPermissionsByUser(db, driver, 2)
+					tt.AssertNoErr(t, err)
 
-					err := c.Delete(ctx, NewTable("user_permissions", "user_id", "perm_id"), map[string]interface{}{
-						// Zero Perm ID
-						"user_id": 1,
-						"perm_id": 0,
-						"name":    "fake-name",
-					})
-					tt.AssertErrContains(t, err, "invalid value", "0", "perm_id")
+					// Should retrieve the generated ID from the database,
+					// only if the database supports returning multiple values:
+					switch c.dialect.InsertMethod() {
+					case insertWithNoIDRetrieval, insertWithLastInsertID:
+						tt.AssertEqual(t, permission.ID, 0)
+						tt.AssertEqual(t, len(userPerms), 1)
+						tt.AssertEqual(t, userPerms[0].UserID, 2)
+						tt.AssertEqual(t, userPerms[0].PermID, 42)
+					case insertWithReturning, insertWithOutput:
+						tt.AssertNotEqual(t, permission.ID, 0)
+						tt.AssertEqual(t, len(userPerms), 1)
+						tt.AssertEqual(t, userPerms[0].ID, permission.ID)
+						tt.AssertEqual(t, userPerms[0].UserID, 2)
+						tt.AssertEqual(t, userPerms[0].PermID, 42)
+					}
 				})
 			})
 		})
 
-		t.Run("should report error if table contains an empty ID name", func(t *testing.T) {
-			db, closer := newDBAdapter(t)
-			defer closer.Close()
+		t.Run("testing error cases", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
 
-			ctx := context.Background()
-			c := newTestDB(db, driver)
+			t.Run("should report error for invalid input types", func(t *testing.T) {
+				db, closer := newDBAdapter(t)
+				defer closer.Close()
 
-			err := c.Delete(ctx, NewTable("users", ""), &user{ID: 42, Name: "fake-name"})
-			tt.AssertErrContains(t, err, "ksql.Table", "ID", "empty string")
-		})
+				ctx := context.Background()
+				c := newTestDB(db, driver)
 
-		t.Run("should report error if ksql.Table.name is empty", func(t *testing.T) {
-			db, closer := newDBAdapter(t)
-			defer closer.Close()
+				err = c.Insert(ctx, usersTable, "foo")
+				assert.NotEqual(t, nil, err)
 
-			ctx := context.Background()
-			c := newTestDB(db, driver)
+				err = c.Insert(ctx, usersTable, nullable.String("foo"))
+				assert.NotEqual(t, nil, err)
 
-			err := c.Delete(ctx, NewTable("", "id"), &user{Name: "fake-name"})
-			tt.AssertErrContains(t, err, "ksql.Table", "table name", "empty string")
-		})
-	})
-}
+				err = c.Insert(ctx, usersTable, map[string]interface{}{
+					"name": "foo",
+					"age":  12,
+				})
+				assert.NotEqual(t, nil, err)
 
-// UpdateTest runs all tests for making sure the Update function is
-// working for a given adapter and driver.
-func UpdateTest(
-	t *testing.T,
-	driver string,
-	connStr string,
-	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
-) {
-	t.Run("Update", func(t *testing.T) {
-		err := createTables(driver, connStr)
-		if err != nil {
-			t.Fatal("could not create test table!, reason:", err.Error())
-		}
+				cantInsertSlice := []interface{}{
+					&user{Name: "foo", Age: 22},
+					&user{Name: "bar", Age: 32},
+				}
+				err = c.Insert(ctx, usersTable, cantInsertSlice)
+				assert.NotEqual(t, nil, err)
 
-		t.Run("should update one user{} correctly", func(t *testing.T) {
-			db, closer := newDBAdapter(t)
-			defer closer.Close()
+				// We might want to support this in the future, but not for now:
+				err = c.Insert(ctx, usersTable, user{Name: "not a ptr to user", Age: 42})
+				assert.NotEqual(t, nil, err)
+			})
 
-			ctx := context.Background()
-			c := newTestDB(db, driver)
+			t.Run("should report error if for some reason the insertMethod is invalid", func(t *testing.T) {
+				db, closer := newDBAdapter(t)
+				defer closer.Close()
 
-			u := user{
-				Name: "Letícia",
-			}
-			_, err := db.ExecContext(ctx, `INSERT INTO users (name, age) VALUES ('Letícia', 0)`)
-			assert.Equal(t, nil, err)
+				ctx := context.Background()
+				c := newTestDB(db, driver)
 
-			err = getUserByName(db, driver, &u, "Letícia")
-			assert.Equal(t, nil, err)
-			assert.NotEqual(t, uint(0), u.ID)
+				// This is an invalid value:
+				c.dialect = brokenDialect{}
 
-			err = c.Update(ctx, usersTable, user{
-				ID:   u.ID,
-				Name: "Thayane",
+				err = c.Insert(ctx, usersTable, &user{Name: "foo"})
+				assert.NotEqual(t, nil, err)
 			})
-			assert.Equal(t, nil, err)
 
-			var result user
-			err = getUserByID(c.db, c.dialect, &result, u.ID)
-			assert.Equal(t, nil, err)
-			assert.Equal(t, "Thayane", result.Name)
-		})
+			t.Run("should report error if it receives a nil pointer to a struct", func(t *testing.T) {
+				db, closer := newDBAdapter(t)
+				defer closer.Close()
 
-		t.Run("should update one &user{} correctly", func(t *testing.T) {
-			db, closer := newDBAdapter(t)
-			defer closer.Close()
+				ctx := context.Background()
+				c := newTestDB(db, driver)
 
-			ctx := context.Background()
-			c := newTestDB(db, driver)
+				var u *user
+				err := c.Insert(ctx, usersTable, u)
+				assert.NotEqual(t, nil, err)
+			})
 
-			u := user{
-				Name: "Letícia",
-			}
-			_, err := db.ExecContext(ctx, `INSERT INTO users (name, age) VALUES ('Letícia', 0)`)
-			assert.Equal(t, nil, err)
+			t.Run("should report error if table contains an empty ID name", func(t *testing.T) {
+				db, closer := newDBAdapter(t)
+				defer closer.Close()
 
-			err = getUserByName(db, driver, &u, "Letícia")
-			assert.Equal(t, nil, err)
-			assert.NotEqual(t, uint(0), u.ID)
+				ctx := context.Background()
+				c := newTestDB(db, driver)
 
-			err = c.Update(ctx, usersTable, &user{
-				ID:   u.ID,
-				Name: "Thayane",
+				err := c.Insert(ctx, NewTable("users", ""), &user{Name: "fake-name"})
+				tt.AssertErrContains(t, err, "ksql.Table", "ID", "empty string")
 			})
-			assert.Equal(t, nil, err)
-
-			var result user
-			err = getUserByID(c.db, c.dialect, &result, u.ID)
-			assert.Equal(t, nil, err)
-			assert.Equal(t, "Thayane", result.Name)
-		})
 
-		t.Run("should ignore null pointers on partial updates", func(t *testing.T) {
-			db, closer := newDBAdapter(t)
-			defer closer.Close()
+			t.Run("should report error if ksql.Table.name is empty", func(t *testing.T) {
+				db, closer := newDBAdapter(t)
+				defer closer.Close()
 
-			ctx := context.Background()
-			c := newTestDB(db, driver)
+				ctx := context.Background()
+				c := newTestDB(db, driver)
 
-			type partialUser struct {
-				ID   uint   `ksql:"id"`
-				Name string `ksql:"name"`
-				Age  *int   `ksql:"age"`
-			}
+				err := c.Insert(ctx, NewTable("", "id"), &user{Name: "fake-name"})
+				tt.AssertErrContains(t, err, "ksql.Table", "table name", "empty string")
+			})
 
-			_, err := db.ExecContext(ctx, `INSERT INTO users (name, age) VALUES ('Letícia', 22)`)
-			assert.Equal(t, nil, err)
+			t.Run("should report error if ksql.Table.name is not a valid identifier", func(t *testing.T) {
+				db, closer := newDBAdapter(t)
+				defer closer.Close()
 
-			var u user
-			err = getUserByName(db, driver, &u, "Letícia")
-			assert.Equal(t, nil, err)
-			assert.NotEqual(t, uint(0), u.ID)
+				ctx := context.Background()
+				c := newTestDB(db, driver)
 
-			err = c.Update(ctx, usersTable, partialUser{
-				ID: u.ID,
-				// Should be updated because it is not null, just empty:
-				Name: "",
-				// Should not be updated because it is null:
-				Age: nil,
+				maliciousName := "users (name) VALUES ('fake-name'); DROP TABLE users; --"
+				err := c.Insert(ctx, NewTable(maliciousName), &user{Name: "fake-name"})
+				tt.AssertErrContains(t, err, "ksql.Table", "invalid table name", maliciousName)
 			})
-			assert.Equal(t, nil, err)
 
-			var result user
-			err = getUserByID(c.db, c.dialect, &result, u.ID)
-			assert.Equal(t, nil, err)
-			assert.Equal(t, "", result.Name)
-			assert.Equal(t, 22, result.Age)
-		})
+			t.Run("should not panic if a column doesn't exist in the database", func(t *testing.T) {
+				db, closer := newDBAdapter(t)
+				defer closer.Close()
 
-		t.Run("should update valid pointers on partial updates", func(t *testing.T) {
-			db, closer := newDBAdapter(t)
-			defer closer.Close()
+				ctx := context.Background()
+				c := newTestDB(db, driver)
 
-			ctx := context.Background()
-			c := newTestDB(db, driver)
+				err = c.Insert(ctx, usersTable, &struct {
+					ID                string `ksql:"id"`
+					NonExistingColumn int    `ksql:"non_existing"`
+					Name              string `ksql:"name"`
+				}{NonExistingColumn: 42, Name: "fake-name"})
+				assert.NotEqual(t, nil, err)
+				msg := err.Error()
+				assert.Equal(t, true, strings.Contains(msg, "column"))
+				assert.Equal(t, true, strings.Contains(msg, "non_existing"))
+			})
 
-			type partialUser struct {
-				ID   uint   `ksql:"id"`
-				Name string `ksql:"name"`
-				Age  *int   `ksql:"age"`
-			}
+			t.Run("should not panic if the ID column doesn't exist in the database", func(t *testing.T) {
+				db, closer := newDBAdapter(t)
+				defer closer.Close()
 
-			_, err := db.ExecContext(ctx, `INSERT INTO users (name, age) VALUES ('Letícia', 22)`)
-			assert.Equal(t, nil, err)
+				ctx := context.Background()
+				c := newTestDB(db, driver)
 
-			var u user
-			err = getUserByName(db, driver, &u, "Letícia")
-			assert.Equal(t, nil, err)
-			assert.NotEqual(t, uint(0), u.ID)
+				brokenTable := NewTable("users", "non_existing_id")
 
-			// Should update all fields:
-			err = c.Update(ctx, usersTable, partialUser{
-				ID:   u.ID,
-				Name: "Thay",
-				Age:  nullable.Int(42),
+				_ = c.Insert(ctx, brokenTable, &struct {
+					ID   string `ksql:"non_existing_id"`
+					Age  int    `ksql:"age"`
+					Name string `ksql:"name"`
+				}{Age: 42, Name: "fake-name"})
 			})
-			assert.Equal(t, nil, err)
-
-			var result user
-			err = getUserByID(c.db, c.dialect, &result, u.ID)
-			assert.Equal(t, nil, err)
 
-			assert.Equal(t, "Thay", result.Name)
-			assert.Equal(t, 42, result.Age)
-		})
+			t.Run("should not panic if the ID column is missing in the struct", func(t *testing.T) {
+				db, closer := newDBAdapter(t)
+				defer closer.Close()
 
-		t.Run("should return ErrRecordNotFound when asked to update an inexistent user", func(t *testing.T) {
-			db, closer := newDBAdapter(t)
-			defer closer.Close()
+				ctx := context.Background()
+				c := newTestDB(db, driver)
 
-			ctx := context.Background()
-			c := newTestDB(db, driver)
+				err = c.Insert(ctx, usersTable, &struct {
+					Age  int    `ksql:"age"`
+					Name string `ksql:"name"`
+				}{Age: 42, Name: "Inserted With no ID"})
+				assert.Equal(t, nil, err)
 
-			err = c.Update(ctx, usersTable, user{
-				ID:   4200,
-				Name: "Thayane",
+				var u user
+				err = getUserByName(db, driver, &u, "Inserted With no ID")
+				assert.Equal(t, nil, err)
+				assert.NotEqual(t, uint(0), u.ID)
+				assert.Equal(t, 42, u.Age)
 			})
-			assert.Equal(t, ErrRecordNotFound, err)
 		})
+	})
+}
 
-		t.Run("should report database errors correctly", func(t *testing.T) {
-			db, closer := newDBAdapter(t)
-			defer closer.Close()
-
-			ctx := context.Background()
-			c := newTestDB(db, driver)
+type brokenDialect struct{}
 
-			err = c.Update(ctx, NewTable("non_existing_table"), user{
-				ID:   1,
-				Name: "Thayane",
-			})
-			assert.NotEqual(t, nil, err)
-		})
+func (brokenDialect) InsertMethod() insertMethod {
+	return insertMethod(42)
+}
 
-		t.Run("should report error if it receives a nil pointer to a struct", func(t *testing.T) {
-			db, closer := newDBAdapter(t)
-			defer closer.Close()
+func (brokenDialect) Escape(str string) string {
+	return str
+}
 
-			ctx := context.Background()
-			c := newTestDB(db, driver)
+func (brokenDialect) Placeholder(idx int) string {
+	return "?"
+}
 
-			var u *user
-			err := c.Update(ctx, usersTable, u)
-			assert.NotEqual(t, nil, err)
-		})
-	})
+func (brokenDialect) DriverName() string {
+	return "fake-driver-name"
 }
 
-// QueryChunksTest runs all tests for making sure the QueryChunks function is
+// DeleteTest runs all tests for making sure the Delete function is
 // working for a given adapter and driver.
-func QueryChunksTest(
+func DeleteTest(
 	t *testing.T,
 	driver string,
 	connStr string,
 	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
 ) {
-	t.Run("QueryChunks", func(t *testing.T) {
-		variations := []struct {
-			desc        string
-			queryPrefix string
-		}{
-			{
-				desc:        "with select *",
-				queryPrefix: "SELECT * ",
-			},
-			{
-				desc:        "building the SELECT part of the query internally",
-				queryPrefix: "",
-			},
+	t.Run("Delete", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
 		}
-		for _, variation := range variations {
-			t.Run(variation.desc, func(t *testing.T) {
-				t.Run("should query a single row correctly", func(t *testing.T) {
-					err := createTables(driver, connStr)
-					if err != nil {
-						t.Fatal("could not create test table!, reason:", err.Error())
-					}
 
+		t.Run("should delete from tables with a single primary key correctly", func(t *testing.T) {
+			tests := []struct {
+				desc               string
+				deletionKeyForUser func(u user) interface{}
+			}{
+				{
+					desc: "passing only the ID as key",
+					deletionKeyForUser: func(u user) interface{} {
+						return u.ID
+					},
+				},
+				{
+					desc: "passing only the entire user",
+					deletionKeyForUser: func(u user) interface{} {
+						return u
+					},
+				},
+				{
+					desc: "passing the address of the user",
+					deletionKeyForUser: func(u user) interface{} {
+						return &u
+					},
+				},
+			}
+
+			for _, test := range tests {
+				t.Run(test.desc, func(t *testing.T) {
 					db, closer := newDBAdapter(t)
 					defer closer.Close()
 
 					ctx := context.Background()
 					c := newTestDB(db, driver)
 
-					_ = c.Insert(ctx, usersTable, &user{
-						Name:    "User1",
-						Address: address{Country: "BR"},
-					})
-
-					var length int
-					var u user
-					err = c.QueryChunks(ctx, ChunkParser{
-						Query:  variation.queryPrefix + `FROM users WHERE name = ` + c.dialect.Placeholder(0),
-						Params: []interface{}{"User1"},
+					u1 := user{
+						Name: "Fernanda",
+					}
 
-						ChunkSize: 100,
-						ForEachChunk: func(users []user) error {
-							length = len(users)
-							if length > 0 {
-								u = users[0]
-							}
-							return nil
-						},
-					})
+					err := c.Insert(ctx, usersTable, &u1)
+					assert.Equal(t, nil, err)
+					assert.NotEqual(t, uint(0), u1.ID)
 
+					result := user{}
+					err = getUserByID(c.db, c.dialect, &result, u1.ID)
 					assert.Equal(t, nil, err)
-					assert.Equal(t, 1, length)
-					assert.NotEqual(t, uint(0), u.ID)
-					assert.Equal(t, "User1", u.Name)
-					assert.Equal(t, "BR", u.Address.Country)
-				})
+					assert.Equal(t, u1.ID, result.ID)
 
-				t.Run("should query one chunk correctly", func(t *testing.T) {
-					err := createTables(driver, connStr)
-					if err != nil {
-						t.Fatal("could not create test table!, reason:", err.Error())
+					u2 := user{
+						Name: "Won't be deleted",
 					}
 
-					db, closer := newDBAdapter(t)
-					defer closer.Close()
-
-					ctx := context.Background()
-					c := newTestDB(db, driver)
+					err = c.Insert(ctx, usersTable, &u2)
+					assert.Equal(t, nil, err)
+					assert.NotEqual(t, uint(0), u2.ID)
 
-					_ = c.Insert(ctx, usersTable, &user{Name: "User1", Address: address{Country: "US"}})
-					_ = c.Insert(ctx, usersTable, &user{Name: "User2", Address: address{Country: "BR"}})
+					result = user{}
+					err = getUserByID(c.db, c.dialect, &result, u2.ID)
+					assert.Equal(t, nil, err)
+					assert.Equal(t, u2.ID, result.ID)
 
-					var lengths []int
-					var users []user
-					err = c.QueryChunks(ctx, ChunkParser{
-						Query:  variation.queryPrefix + `from users where name like ` + c.dialect.Placeholder(0) + ` order by name asc;`,
-						Params: []interface{}{"User%"},
+					err = c.Delete(ctx, usersTable, test.deletionKeyForUser(u1))
+					assert.Equal(t, nil, err)
 
-						ChunkSize: 2,
-						ForEachChunk: func(buffer []user) error {
-							users = append(users, buffer...)
-							lengths = append(lengths, len(buffer))
-							return nil
-						},
-					})
+					result = user{}
+					err = getUserByID(c.db, c.dialect, &result, u1.ID)
+					assert.Equal(t, sql.ErrNoRows, err)
 
+					result = user{}
+					err = getUserByID(c.db, c.dialect, &result, u2.ID)
 					assert.Equal(t, nil, err)
-					assert.Equal(t, 1, len(lengths))
-					assert.Equal(t, 2, lengths[0])
 
-					assert.NotEqual(t, uint(0), users[0].ID)
-					assert.Equal(t, "User1", users[0].Name)
-					assert.Equal(t, "US", users[0].Address.Country)
-
-					assert.NotEqual(t, uint(0), users[1].ID)
-					assert.Equal(t, "User2", users[1].Name)
-					assert.Equal(t, "BR", users[1].Address.Country)
+					assert.NotEqual(t, uint(0), result.ID)
+					assert.Equal(t, "Won't be deleted", result.Name)
 				})
+			}
+		})
 
-				t.Run("should query chunks of 1 correctly", func(t *testing.T) {
-					err := createTables(driver, connStr)
-					if err != nil {
-						t.Fatal("could not create test table!, reason:", err.Error())
-					}
-
-					db, closer := newDBAdapter(t)
-					defer closer.Close()
+		t.Run("should delete from tables with composite primary keys correctly", func(t *testing.T) {
+			t.Run("using structs", func(t *testing.T) {
+				db, closer := newDBAdapter(t)
+				defer closer.Close()
 
-					ctx := context.Background()
-					c := newTestDB(db, driver)
+				ctx := context.Background()
+				c := newTestDB(db, driver)
 
-					_ = c.Insert(ctx, usersTable, &user{Name: "User1", Address: address{Country: "US"}})
-					_ = c.Insert(ctx, usersTable, &user{Name: "User2", Address: address{Country: "BR"}})
+				// This permission should not be deleted, we'll use the id to check it:
+				p0 := userPermission{
+					UserID: 1,
+					PermID: 44,
+				}
+				err = c.Insert(ctx, NewTable("user_permissions", "id"), &p0)
+				tt.AssertNoErr(t, err)
+				tt.AssertNotEqual(t, p0.ID, 0)
 
-					var lengths []int
-					var users []user
-					err = c.QueryChunks(ctx, ChunkParser{
-						Query:  variation.queryPrefix + `from users where name like ` + c.dialect.Placeholder(0) + ` order by name asc;`,
-						Params: []interface{}{"User%"},
+				p1 := userPermission{
+					UserID: 1,
+					PermID: 42,
+				}
+				err = c.Insert(ctx, NewTable("user_permissions", "id"), &p1)
+				tt.AssertNoErr(t, err)
 
-						ChunkSize: 1,
-						ForEachChunk: func(buffer []user) error {
-							lengths = append(lengths, len(buffer))
-							users = append(users, buffer...)
-							return nil
-						},
-					})
+				err = c.Delete(ctx, userPermissionsTable, p1)
+				tt.AssertNoErr(t, err)
 
-					assert.Equal(t, nil, err)
-					assert.Equal(t, 2, len(users))
-					assert.Equal(t, []int{1, 1}, lengths)
+				userPerms, err := getUserPermissionsByUser(db, driver, 1)
+				tt.AssertNoErr(t, err)
+				tt.AssertEqual(t, len(userPerms), 1)
+				tt.AssertEqual(t, userPerms[0].UserID, 1)
+				tt.AssertEqual(t, userPerms[0].PermID, 44)
+			})
 
-					assert.NotEqual(t, uint(0), users[0].ID)
-					assert.Equal(t, "User1", users[0].Name)
-					assert.Equal(t, "US", users[0].Address.Country)
+			t.Run("using maps", func(t *testing.T) {
+				db, closer := newDBAdapter(t)
+				defer closer.Close()
 
-					assert.NotEqual(t, uint(0), users[1].ID)
-					assert.Equal(t, "User2", users[1].Name)
-					assert.Equal(t, "BR", users[1].Address.Country)
-				})
+				ctx := context.Background()
+				c := newTestDB(db, driver)
 
-				t.Run("should load partially filled chunks correctly", func(t *testing.T) {
-					err := createTables(driver, connStr)
-					if err != nil {
-						t.Fatal("could not create test table!, reason:", err.Error())
-					}
+				// This permission should not be deleted, we'll use the id to check it:
+				p0 := userPermission{
+					UserID: 2,
+					PermID: 44,
+				}
+				err = c.Insert(ctx, NewTable("user_permissions", "id"), &p0)
+				tt.AssertNoErr(t, err)
+				tt.AssertNotEqual(t, p0.ID, 0)
 
-					db, closer := newDBAdapter(t)
-					defer closer.Close()
+				p1 := userPermission{
+					UserID: 2,
+					PermID: 42,
+				}
+				err = c.Insert(ctx, NewTable("user_permissions", "id"), &p1)
+				tt.AssertNoErr(t, err)
 
-					ctx := context.Background()
-					c := newTestDB(db, driver)
+				err = c.Delete(ctx, userPermissionsTable, map[string]interface{}{
+					"user_id": 2,
+					"perm_id": 42,
+				})
+				tt.AssertNoErr(t, err)
 
-					_ = c.Insert(ctx, usersTable, &user{Name: "User1"})
-					_ = c.Insert(ctx, usersTable, &user{Name: "User2"})
-					_ = c.Insert(ctx, usersTable, &user{Name: "User3"})
+				userPerms, err := getUserPermissionsByUser(db, driver, 2)
+				tt.AssertNoErr(t, err)
+				tt.AssertEqual(t, len(userPerms), 1)
+				tt.AssertEqual(t, userPerms[0].UserID, 2)
+				tt.AssertEqual(t, userPerms[0].PermID, 44)
+			})
+		})
 
-					var lengths []int
-					var users []user
-					err = c.QueryChunks(ctx, ChunkParser{
-						Query:  variation.queryPrefix + `from users where name like ` + c.dialect.Placeholder(0) + ` order by name asc;`,
-						Params: []interface{}{"User%"},
+		t.Run("should return ErrRecordNotFound if no rows were deleted", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
 
-						ChunkSize: 2,
-						ForEachChunk: func(buffer []user) error {
-							lengths = append(lengths, len(buffer))
-							users = append(users, buffer...)
-							return nil
-						},
-					})
+			ctx := context.Background()
+			c := newTestDB(db, driver)
 
-					assert.Equal(t, nil, err)
-					assert.Equal(t, 3, len(users))
-					assert.NotEqual(t, uint(0), users[0].ID)
-					assert.Equal(t, "User1", users[0].Name)
-					assert.NotEqual(t, uint(0), users[1].ID)
-					assert.Equal(t, "User2", users[1].Name)
-					assert.NotEqual(t, uint(0), users[2].ID)
-					assert.Equal(t, "User3", users[2].Name)
-					assert.Equal(t, []int{2, 1}, lengths)
-				})
+			err = c.Delete(ctx, usersTable, 4200)
+			assert.Equal(t, ErrRecordNotFound, err)
+		})
 
-				// xxx
-				t.Run("should query joined tables correctly", func(t *testing.T) {
-					// This test only makes sense with no query prefix
-					if variation.queryPrefix != "" {
-						return
-					}
+		t.Run("should report error if it receives a nil pointer to a struct", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			var u *user
+			err := c.Delete(ctx, usersTable, u)
+			assert.NotEqual(t, nil, err)
+		})
 
+		t.Run("should report error if one of the ids is missing from the input", func(t *testing.T) {
+			t.Run("single id", func(t *testing.T) {
+				t.Run("struct with missing attr", func(t *testing.T) {
 					db, closer := newDBAdapter(t)
 					defer closer.Close()
 
-					joao := user{
-						Name: "Thiago Ribeiro",
-						Age:  24,
-					}
-					thatiana := user{
-						Name: "Thatiana Ribeiro",
-						Age:  20,
-					}
-
 					ctx := context.Background()
 					c := newTestDB(db, driver)
-					_ = c.Insert(ctx, usersTable, &joao)
-					_ = c.Insert(ctx, usersTable, &thatiana)
 
-					_, err := db.ExecContext(ctx, fmt.Sprint(`INSERT INTO posts (user_id, title) VALUES (`, thatiana.ID, `, 'Thatiana Post1')`))
-					assert.Equal(t, nil, err)
-					_, err = db.ExecContext(ctx, fmt.Sprint(`INSERT INTO posts (user_id, title) VALUES (`, thatiana.ID, `, 'Thatiana Post2')`))
-					assert.Equal(t, nil, err)
-					_, err = db.ExecContext(ctx, fmt.Sprint(`INSERT INTO posts (user_id, title) VALUES (`, joao.ID, `, 'Thiago Post1')`))
-					assert.Equal(t, nil, err)
+					err := c.Delete(ctx, NewTable("users", "id"), &struct {
+						// Missing ID
+						Name string `ksql:"name"`
+					}{Name: "fake-name"})
+					tt.AssertErrContains(t, err, "missing required", "id")
+				})
 
-					var lengths []int
-					var users []user
-					var posts []post
-					err = c.QueryChunks(ctx, ChunkParser{
-						Query: fmt.Sprint(
-							`FROM users u JOIN posts p ON p.user_id = u.id`,
-							` WHERE u.name like `, c.dialect.Placeholder(0),
-							` ORDER BY u.id, p.id`,
-						),
-						Params: []interface{}{"% Ribeiro"},
+				t.Run("struct with NULL attr", func(t *testing.T) {
+					db, closer := newDBAdapter(t)
+					defer closer.Close()
 
-						ChunkSize: 2,
-						ForEachChunk: func(chunk []struct {
-							User user `tablename:"u"`
-							Post post `tablename:"p"`
-						}) error {
-							lengths = append(lengths, len(chunk))
-							for _, row := range chunk {
-								users = append(users, row.User)
-								posts = append(posts, row.Post)
-							}
-							return nil
-						},
-					})
+					ctx := context.Background()
+					c := newTestDB(db, driver)
 
-					assert.Equal(t, nil, err)
-					assert.Equal(t, 3, len(posts))
+					err := c.Delete(ctx, NewTable("users", "id"), &struct {
+						// Null ID
+						ID   *int   `ksql:"id"`
+						Name string `ksql:"name"`
+					}{Name: "fake-name"})
+					tt.AssertErrContains(t, err, "missing required", "id")
+				})
 
-					assert.Equal(t, joao.ID, users[0].ID)
-					assert.Equal(t, "Thiago Ribeiro", users[0].Name)
-					assert.Equal(t, "Thiago Post1", posts[0].Title)
+				t.Run("struct with zero attr", func(t *testing.T) {
+					db, closer := newDBAdapter(t)
+					defer closer.Close()
 
-					assert.Equal(t, thatiana.ID, users[1].ID)
-					assert.Equal(t, "Thatiana Ribeiro", users[1].Name)
-					assert.Equal(t, "Thatiana Post1", posts[1].Title)
+					ctx := context.Background()
+					c := newTestDB(db, driver)
 
-					assert.Equal(t, thatiana.ID, users[2].ID)
-					assert.Equal(t, "Thatiana Ribeiro", users[2].Name)
-					assert.Equal(t, "Thatiana Post2", posts[2].Title)
+					err := c.Delete(ctx, NewTable("users", "id"), &struct {
+						// Uninitialized ID
+						ID   int    `ksql:"id"`
+						Name string `ksql:"name"`
+					}{Name: "fake-name"})
+					tt.AssertErrContains(t, err, "invalid value", "0", "id")
 				})
+			})
 
-				t.Run("should abort the first iteration when the callback returns an ErrAbortIteration", func(t *testing.T) {
-					err := createTables(driver, connStr)
-					if err != nil {
-						t.Fatal("could not create test table!, reason:", err.Error())
-					}
-
+			t.Run("multiple ids", func(t *testing.T) {
+				t.Run("struct with missing attr", func(t *testing.T) {
 					db, closer := newDBAdapter(t)
 					defer closer.Close()
 
 					ctx := context.Background()
 					c := newTestDB(db, driver)
 
-					_ = c.Insert(ctx, usersTable, &user{Name: "User1"})
-					_ = c.Insert(ctx, usersTable, &user{Name: "User2"})
-					_ = c.Insert(ctx, usersTable, &user{Name: "User3"})
-
-					var lengths []int
-					var users []user
-					err = c.QueryChunks(ctx, ChunkParser{
-						Query:  variation.queryPrefix + `from users where name like ` + c.dialect.Placeholder(0) + ` order by name asc;`,
-						Params: []interface{}{"User%"},
-
-						ChunkSize: 2,
-						ForEachChunk: func(buffer []user) error {
-							lengths = append(lengths, len(buffer))
-							users = append(users, buffer...)
-							return ErrAbortIteration
-						},
+					err := c.Delete(ctx, NewTable("user_permissions", "user_id", "perm_id"), map[string]interface{}{
+						// Missing PermID
+						"user_id": 1,
+						"name":    "fake-name",
 					})
-
-					assert.Equal(t, nil, err)
-					assert.Equal(t, 2, len(users))
-					assert.NotEqual(t, uint(0), users[0].ID)
-					assert.Equal(t, "User1", users[0].Name)
-					assert.NotEqual(t, uint(0), users[1].ID)
-					assert.Equal(t, "User2", users[1].Name)
-					assert.Equal(t, []int{2}, lengths)
+					tt.AssertErrContains(t, err, "missing required", "perm_id")
 				})
 
-				t.Run("should abort the last iteration when the callback returns an ErrAbortIteration", func(t *testing.T) {
-					err := createTables(driver, connStr)
-					if err != nil {
-						t.Fatal("could not create test table!, reason:", err.Error())
-					}
-
+				t.Run("struct with NULL attr", func(t *testing.T) {
 					db, closer := newDBAdapter(t)
 					defer closer.Close()
 
 					ctx := context.Background()
 					c := newTestDB(db, driver)
 
-					_ = c.Insert(ctx, usersTable, &user{Name: "User1"})
-					_ = c.Insert(ctx, usersTable, &user{Name: "User2"})
-					_ = c.Insert(ctx, usersTable, &user{Name: "User3"})
-
-					returnVals := []error{nil, ErrAbortIteration}
+					err := c.Delete(ctx, NewTable("user_permissions", "user_id", "perm_id"), map[string]interface{}{
+						// Null Perm ID
+						"user_id": 1,
+						"perm_id": nil,
+						"name":    "fake-name",
+					})
+					tt.AssertErrContains(t, err, "invalid value", "nil", "perm_id")
+				})
+
+				t.Run("struct with zero attr", func(t *testing.T) {
+					db, closer := newDBAdapter(t)
+					defer closer.Close()
+
+					ctx := context.Background()
+					c := newTestDB(db, driver)
+
+					err := c.Delete(ctx, NewTable("user_permissions", "user_id", "perm_id"), map[string]interface{}{
+						// Zero Perm ID
+						"user_id": 1,
+						"perm_id": 0,
+						"name":    "fake-name",
+					})
+					tt.AssertErrContains(t, err, "invalid value", "0", "perm_id")
+				})
+			})
+		})
+
+		t.Run("should report error if table contains an empty ID name", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			err := c.Delete(ctx, NewTable("users", ""), &user{ID: 42, Name: "fake-name"})
+			tt.AssertErrContains(t, err, "ksql.Table", "ID", "empty string")
+		})
+
+		t.Run("should report error if ksql.Table.name is empty", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			err := c.Delete(ctx, NewTable("", "id"), &user{Name: "fake-name"})
+			tt.AssertErrContains(t, err, "ksql.Table", "table name", "empty string")
+		})
+
+		t.Run("should report error if ksql.Table.name is not a valid identifier", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			maliciousName := "users WHERE 1=1; --"
+			err := c.Delete(ctx, NewTable(maliciousName), &user{ID: 42, Name: "fake-name"})
+			tt.AssertErrContains(t, err, "ksql.Table", "invalid table name", maliciousName)
+		})
+	})
+}
+
+// UpdateTest runs all tests for making sure the Update function is
+// working for a given adapter and driver.
+func UpdateTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("Update", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		t.Run("should update one user{} correctly", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			u := user{
+				Name: "Letícia",
+			}
+			_, err := db.ExecContext(ctx, `INSERT INTO users (name, age) VALUES ('Letícia', 0)`)
+			assert.Equal(t, nil, err)
+
+			err = getUserByName(db, driver, &u, "Letícia")
+			assert.Equal(t, nil, err)
+			assert.NotEqual(t, uint(0), u.ID)
+
+			err = c.Update(ctx, usersTable, user{
+				ID:   u.ID,
+				Name: "Thayane",
+			})
+			assert.Equal(t, nil, err)
+
+			var result user
+			err = getUserByID(c.db, c.dialect, &result, u.ID)
+			assert.Equal(t, nil, err)
+			assert.Equal(t, "Thayane", result.Name)
+		})
+
+		t.Run("should update one &user{} correctly", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			u := user{
+				Name: "Letícia",
+			}
+			_, err := db.ExecContext(ctx, `INSERT INTO users (name, age) VALUES ('Letícia', 0)`)
+			assert.Equal(t, nil, err)
+
+			err = getUserByName(db, driver, &u, "Letícia")
+			assert.Equal(t, nil, err)
+			assert.NotEqual(t, uint(0), u.ID)
+
+			err = c.Update(ctx, usersTable, &user{
+				ID:   u.ID,
+				Name: "Thayane",
+			})
+			assert.Equal(t, nil, err)
+
+			var result user
+			err = getUserByID(c.db, c.dialect, &result, u.ID)
+			assert.Equal(t, nil, err)
+			assert.Equal(t, "Thayane", result.Name)
+		})
+
+		t.Run("should ignore null pointers on partial updates", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			type partialUser struct {
+				ID   uint   `ksql:"id"`
+				Name string `ksql:"name"`
+				Age  *int   `ksql:"age"`
+			}
+
+			_, err := db.ExecContext(ctx, `INSERT INTO users (name, age) VALUES ('Letícia', 22)`)
+			assert.Equal(t, nil, err)
+
+			var u user
+			err = getUserByName(db, driver, &u, "Letícia")
+			assert.Equal(t, nil, err)
+			assert.NotEqual(t, uint(0), u.ID)
+
+			err = c.Update(ctx, usersTable, partialUser{
+				ID: u.ID,
+				// Should be updated because it is not null, just empty:
+				Name: "",
+				// Should not be updated because it is null:
+				Age: nil,
+			})
+			assert.Equal(t, nil, err)
+
+			var result user
+			err = getUserByID(c.db, c.dialect, &result, u.ID)
+			assert.Equal(t, nil, err)
+			assert.Equal(t, "", result.Name)
+			assert.Equal(t, 22, result.Age)
+		})
+
+		t.Run("should update valid pointers on partial updates", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			type partialUser struct {
+				ID   uint   `ksql:"id"`
+				Name string `ksql:"name"`
+				Age  *int   `ksql:"age"`
+			}
+
+			_, err := db.ExecContext(ctx, `INSERT INTO users (name, age) VALUES ('Letícia', 22)`)
+			assert.Equal(t, nil, err)
+
+			var u user
+			err = getUserByName(db, driver, &u, "Letícia")
+			assert.Equal(t, nil, err)
+			assert.NotEqual(t, uint(0), u.ID)
+
+			// Should update all fields:
+			err = c.Update(ctx, usersTable, partialUser{
+				ID:   u.ID,
+				Name: "Thay",
+				Age:  nullable.Int(42),
+			})
+			assert.Equal(t, nil, err)
+
+			var result user
+			err = getUserByID(c.db, c.dialect, &result, u.ID)
+			assert.Equal(t, nil, err)
+
+			assert.Equal(t, "Thay", result.Name)
+			assert.Equal(t, 42, result.Age)
+		})
+
+		t.Run("should update tables with composite keys", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			u1 := user{Name: "Composite User", Age: 30}
+			err := c.Insert(ctx, usersTable, &u1)
+			tt.AssertNoErr(t, err)
+			u2 := user{Name: "Composite User", Age: 31}
+			err = c.Insert(ctx, usersTable, &u2)
+			tt.AssertNoErr(t, err)
+
+			err = c.Patch(ctx, NewTable("users", "name", "age"), struct {
+				Name    string  `ksql:"name"`
+				Age     int     `ksql:"age"`
+				Address address `ksql:"address,json"`
+			}{
+				Name:    "Composite User",
+				Age:     31,
+				Address: address{City: "Porto Alegre"},
+			})
+			tt.AssertNoErr(t, err)
+
+			var result user
+			err = getUserByID(c.db, c.dialect, &result, u1.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, result.Address.City, "")
+
+			err = getUserByID(c.db, c.dialect, &result, u2.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, result.Address.City, "Porto Alegre")
+		})
+
+		t.Run("should return ErrRecordNotFound when asked to update an inexistent user", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			err = c.Update(ctx, usersTable, user{
+				ID:   4200,
+				Name: "Thayane",
+			})
+			assert.Equal(t, ErrRecordNotFound, err)
+		})
+
+		t.Run("should report database errors correctly", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			err = c.Update(ctx, NewTable("non_existing_table"), user{
+				ID:   1,
+				Name: "Thayane",
+			})
+			assert.NotEqual(t, nil, err)
+		})
+
+		t.Run("should report error if it receives a nil pointer to a struct", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			var u *user
+			err := c.Update(ctx, usersTable, u)
+			assert.NotEqual(t, nil, err)
+		})
+
+		t.Run("should report error if all the non ID attributes are nil", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			type nilUser struct {
+				ID   uint    `ksql:"id"`
+				Name *string `ksql:"name"`
+				Age  *int    `ksql:"age"`
+			}
+
+			u := user{Name: "Letícia", Age: 22}
+			err := c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+
+			err = c.Patch(ctx, usersTable, nilUser{ID: u.ID})
+			tt.AssertEqual(t, errors.Is(err, ErrNoFieldsToUpdate), true)
+			tt.AssertErrContains(t, err, "no fields to update")
+
+			t.Run("should skip the update if configured with WithSkipEmptyPatches", func(t *testing.T) {
+				err := c.WithSkipEmptyPatches(true).Patch(ctx, usersTable, nilUser{ID: u.ID})
+				tt.AssertNoErr(t, err)
+
+				var result user
+				err = getUserByID(c.db, c.dialect, &result, u.ID)
+				tt.AssertNoErr(t, err)
+				tt.AssertEqual(t, result.Name, "Letícia")
+				tt.AssertEqual(t, result.Age, 22)
+			})
+		})
+
+		t.Run("should report error if ksql.Table.name is not a valid identifier", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			maliciousName := "users SET name = 'hacked'; --"
+			err := c.Update(ctx, NewTable(maliciousName), &user{ID: 1, Name: "fake-name"})
+			tt.AssertErrContains(t, err, "ksql.Table", "invalid table name", maliciousName)
+		})
+	})
+}
+
+// QueryChunksTest runs all tests for making sure the QueryChunks function is
+// working for a given adapter and driver.
+func QueryChunksTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("QueryChunks", func(t *testing.T) {
+		variations := []struct {
+			desc        string
+			queryPrefix string
+		}{
+			{
+				desc:        "with select *",
+				queryPrefix: "SELECT * ",
+			},
+			{
+				desc:        "building the SELECT part of the query internally",
+				queryPrefix: "",
+			},
+		}
+		for _, variation := range variations {
+			t.Run(variation.desc, func(t *testing.T) {
+				t.Run("should query a single row correctly", func(t *testing.T) {
+					err := createTables(driver, connStr)
+					if err != nil {
+						t.Fatal("could not create test table!, reason:", err.Error())
+					}
+
+					db, closer := newDBAdapter(t)
+					defer closer.Close()
+
+					ctx := context.Background()
+					c := newTestDB(db, driver)
+
+					_ = c.Insert(ctx, usersTable, &user{
+						Name:    "User1",
+						Address: address{Country: "BR"},
+					})
+
+					var length int
+					var u user
+					err = c.QueryChunks(ctx, ChunkParser{
+						Query:  variation.queryPrefix + `FROM users WHERE name = ` + c.dialect.Placeholder(0),
+						Params: []interface{}{"User1"},
+
+						ChunkSize: 100,
+						ForEachChunk: func(users []user) error {
+							length = len(users)
+							if length > 0 {
+								u = users[0]
+							}
+							return nil
+						},
+					})
+
+					assert.Equal(t, nil, err)
+					assert.Equal(t, 1, length)
+					assert.NotEqual(t, uint(0), u.ID)
+					assert.Equal(t, "User1", u.Name)
+					assert.Equal(t, "BR", u.Address.Country)
+				})
+
+				t.Run("should query one chunk correctly", func(t *testing.T) {
+					err := createTables(driver, connStr)
+					if err != nil {
+						t.Fatal("could not create test table!, reason:", err.Error())
+					}
+
+					db, closer := newDBAdapter(t)
+					defer closer.Close()
+
+					ctx := context.Background()
+					c := newTestDB(db, driver)
+
+					_ = c.Insert(ctx, usersTable, &user{Name: "User1", Address: address{Country: "US"}})
+					_ = c.Insert(ctx, usersTable, &user{Name: "User2", Address: address{Country: "BR"}})
+
+					var lengths []int
+					var users []user
+					err = c.QueryChunks(ctx, ChunkParser{
+						Query:  variation.queryPrefix + `from users where name like ` + c.dialect.Placeholder(0) + ` order by name asc;`,
+						Params: []interface{}{"User%"},
+
+						ChunkSize: 2,
+						ForEachChunk: func(buffer []user) error {
+							users = append(users, buffer...)
+							lengths = append(lengths, len(buffer))
+							return nil
+						},
+					})
+
+					assert.Equal(t, nil, err)
+					assert.Equal(t, 1, len(lengths))
+					assert.Equal(t, 2, lengths[0])
+
+					assert.NotEqual(t, uint(0), users[0].ID)
+					assert.Equal(t, "User1", users[0].Name)
+					assert.Equal(t, "US", users[0].Address.Country)
+
+					assert.NotEqual(t, uint(0), users[1].ID)
+					assert.Equal(t, "User2", users[1].Name)
+					assert.Equal(t, "BR", users[1].Address.Country)
+				})
+
+				t.Run("should query chunks of 1 correctly", func(t *testing.T) {
+					err := createTables(driver, connStr)
+					if err != nil {
+						t.Fatal("could not create test table!, reason:", err.Error())
+					}
+
+					db, closer := newDBAdapter(t)
+					defer closer.Close()
+
+					ctx := context.Background()
+					c := newTestDB(db, driver)
+
+					_ = c.Insert(ctx, usersTable, &user{Name: "User1", Address: address{Country: "US"}})
+					_ = c.Insert(ctx, usersTable, &user{Name: "User2", Address: address{Country: "BR"}})
+
+					var lengths []int
+					var users []user
+					err = c.QueryChunks(ctx, ChunkParser{
+						Query:  variation.queryPrefix + `from users where name like ` + c.dialect.Placeholder(0) + ` order by name asc;`,
+						Params: []interface{}{"User%"},
+
+						ChunkSize: 1,
+						ForEachChunk: func(buffer []user) error {
+							lengths = append(lengths, len(buffer))
+							users = append(users, buffer...)
+							return nil
+						},
+					})
+
+					assert.Equal(t, nil, err)
+					assert.Equal(t, 2, len(users))
+					assert.Equal(t, []int{1, 1}, lengths)
+
+					assert.NotEqual(t, uint(0), users[0].ID)
+					assert.Equal(t, "User1", users[0].Name)
+					assert.Equal(t, "US", users[0].Address.Country)
+
+					assert.NotEqual(t, uint(0), users[1].ID)
+					assert.Equal(t, "User2", users[1].Name)
+					assert.Equal(t, "BR", users[1].Address.Country)
+				})
+
+				t.Run("should load partially filled chunks correctly", func(t *testing.T) {
+					err := createTables(driver, connStr)
+					if err != nil {
+						t.Fatal("could not create test table!, reason:", err.Error())
+					}
+
+					db, closer := newDBAdapter(t)
+					defer closer.Close()
+
+					ctx := context.Background()
+					c := newTestDB(db, driver)
+
+					_ = c.Insert(ctx, usersTable, &user{Name: "User1"})
+					_ = c.Insert(ctx, usersTable, &user{Name: "User2"})
+					_ = c.Insert(ctx, usersTable, &user{Name: "User3"})
+
+					var lengths []int
+					var users []user
+					err = c.QueryChunks(ctx, ChunkParser{
+						Query:  variation.queryPrefix + `from users where name like ` + c.dialect.Placeholder(0) + ` order by name asc;`,
+						Params: []interface{}{"User%"},
+
+						ChunkSize: 2,
+						ForEachChunk: func(buffer []user) error {
+							lengths = append(lengths, len(buffer))
+							users = append(users, buffer...)
+							return nil
+						},
+					})
+
+					assert.Equal(t, nil, err)
+					assert.Equal(t, 3, len(users))
+					assert.NotEqual(t, uint(0), users[0].ID)
+					assert.Equal(t, "User1", users[0].Name)
+					assert.NotEqual(t, uint(0), users[1].ID)
+					assert.Equal(t, "User2", users[1].Name)
+					assert.NotEqual(t, uint(0), users[2].ID)
+					assert.Equal(t, "User3", users[2].Name)
+					assert.Equal(t, []int{2, 1}, lengths)
+				})
+
+				t.Run("should report the progress after each chunk", func(t *testing.T) {
+					err := createTables(driver, connStr)
+					if err != nil {
+						t.Fatal("could not create test table!, reason:", err.Error())
+					}
+
+					db, closer := newDBAdapter(t)
+					defer closer.Close()
+
+					ctx := context.Background()
+					c := newTestDB(db, driver)
+
+					for i := 1; i <= 5; i++ {
+						_ = c.Insert(ctx, usersTable, &user{Name: fmt.Sprintf("User%d", i)})
+					}
+
+					var progress []int
+					var totalRows int
+					err = c.QueryChunks(ctx, ChunkParser{
+						Query:  variation.queryPrefix + `FROM users WHERE name like ` + c.dialect.Placeholder(0),
+						Params: []interface{}{"User%"},
+
+						ChunkSize: 2,
+						ForEachChunk: func(buffer []user) error {
+							totalRows += len(buffer)
+							return nil
+						},
+						OnProgress: func(rowsSoFar int) {
+							// It should be called after the chunk is processed:
+							tt.AssertEqual(t, rowsSoFar, totalRows)
+							progress = append(progress, rowsSoFar)
+						},
+					})
+
+					tt.AssertNoErr(t, err)
+					tt.AssertEqual(t, progress, []int{2, 4, 5})
+				})
+
+				// xxx
+				t.Run("should query joined tables correctly", func(t *testing.T) {
+					// This test only makes sense with no query prefix
+					if variation.queryPrefix != "" {
+						return
+					}
+
+					db, closer := newDBAdapter(t)
+					defer closer.Close()
+
+					joao := user{
+						Name: "Thiago Ribeiro",
+						Age:  24,
+					}
+					thatiana := user{
+						Name: "Thatiana Ribeiro",
+						Age:  20,
+					}
+
+					ctx := context.Background()
+					c := newTestDB(db, driver)
+					_ = c.Insert(ctx, usersTable, &joao)
+					_ = c.Insert(ctx, usersTable, &thatiana)
+
+					_, err := db.ExecContext(ctx, fmt.Sprint(`INSERT INTO posts (user_id, title) VALUES (`, thatiana.ID, `, 'Thatiana Post1')`))
+					assert.Equal(t, nil, err)
+					_, err = db.ExecContext(ctx, fmt.Sprint(`INSERT INTO posts (user_id, title) VALUES (`, thatiana.ID, `, 'Thatiana Post2')`))
+					assert.Equal(t, nil, err)
+					_, err = db.ExecContext(ctx, fmt.Sprint(`INSERT INTO posts (user_id, title) VALUES (`, joao.ID, `, 'Thiago Post1')`))
+					assert.Equal(t, nil, err)
+
+					var lengths []int
+					var users []user
+					var posts []post
+					err = c.QueryChunks(ctx, ChunkParser{
+						Query: fmt.Sprint(
+							`FROM users u JOIN posts p ON p.user_id = u.id`,
+							` WHERE u.name like `, c.dialect.Placeholder(0),
+							` ORDER BY u.id, p.id`,
+						),
+						Params: []interface{}{"% Ribeiro"},
+
+						ChunkSize: 2,
+						ForEachChunk: func(chunk []struct {
+							User user `tablename:"u"`
+							Post post `tablename:"p"`
+						}) error {
+							lengths = append(lengths, len(chunk))
+							for _, row := range chunk {
+								users = append(users, row.User)
+								posts = append(posts, row.Post)
+							}
+							return nil
+						},
+					})
+
+					assert.Equal(t, nil, err)
+					assert.Equal(t, 3, len(posts))
+
+					assert.Equal(t, joao.ID, users[0].ID)
+					assert.Equal(t, "Thiago Ribeiro", users[0].Name)
+					assert.Equal(t, "Thiago Post1", posts[0].Title)
+
+					assert.Equal(t, thatiana.ID, users[1].ID)
+					assert.Equal(t, "Thatiana Ribeiro", users[1].Name)
+					assert.Equal(t, "Thatiana Post1", posts[1].Title)
+
+					assert.Equal(t, thatiana.ID, users[2].ID)
+					assert.Equal(t, "Thatiana Ribeiro", users[2].Name)
+					assert.Equal(t, "Thatiana Post2", posts[2].Title)
+				})
+
+				t.Run("should abort the first iteration when the callback returns an ErrAbortIteration", func(t *testing.T) {
+					err := createTables(driver, connStr)
+					if err != nil {
+						t.Fatal("could not create test table!, reason:", err.Error())
+					}
+
+					db, closer := newDBAdapter(t)
+					defer closer.Close()
+
+					ctx := context.Background()
+					c := newTestDB(db, driver)
+
+					_ = c.Insert(ctx, usersTable, &user{Name: "User1"})
+					_ = c.Insert(ctx, usersTable, &user{Name: "User2"})
+					_ = c.Insert(ctx, usersTable, &user{Name: "User3"})
+
+					var lengths []int
+					var users []user
+					err = c.QueryChunks(ctx, ChunkParser{
+						Query:  variation.queryPrefix + `from users where name like ` + c.dialect.Placeholder(0) + ` order by name asc;`,
+						Params: []interface{}{"User%"},
+
+						ChunkSize: 2,
+						ForEachChunk: func(buffer []user) error {
+							lengths = append(lengths, len(buffer))
+							users = append(users, buffer...)
+							return ErrAbortIteration
+						},
+					})
+
+					assert.Equal(t, nil, err)
+					assert.Equal(t, 2, len(users))
+					assert.NotEqual(t, uint(0), users[0].ID)
+					assert.Equal(t, "User1", users[0].Name)
+					assert.NotEqual(t, uint(0), users[1].ID)
+					assert.Equal(t, "User2", users[1].Name)
+					assert.Equal(t, []int{2}, lengths)
+				})
+
+				t.Run("should abort the last iteration when the callback returns an ErrAbortIteration", func(t *testing.T) {
+					err := createTables(driver, connStr)
+					if err != nil {
+						t.Fatal("could not create test table!, reason:", err.Error())
+					}
+
+					db, closer := newDBAdapter(t)
+					defer closer.Close()
+
+					ctx := context.Background()
+					c := newTestDB(db, driver)
+
+					_ = c.Insert(ctx, usersTable, &user{Name: "User1"})
+					_ = c.Insert(ctx, usersTable, &user{Name: "User2"})
+					_ = c.Insert(ctx, usersTable, &user{Name: "User3"})
+
+					returnVals := []error{nil, ErrAbortIteration}
+					var lengths []int
+					var users []user
+					err = c.QueryChunks(ctx, ChunkParser{
+						Query:  variation.queryPrefix + `from users where name like ` + c.dialect.Placeholder(0) + ` order by name asc;`,
+						Params: []interface{}{"User%"},
+
+						ChunkSize: 2,
+						ForEachChunk: func(buffer []user) error {
+							lengths = append(lengths, len(buffer))
+							users = append(users, buffer...)
+
+							return shiftErrSlice(&returnVals)
+						},
+					})
+
+					assert.Equal(t, nil, err)
+					assert.Equal(t, 3, len(users))
+					assert.NotEqual(t, uint(0), users[0].ID)
+					assert.Equal(t, "User1", users[0].Name)
+					assert.NotEqual(t, uint(0), users[1].ID)
+					assert.Equal(t, "User2", users[1].Name)
+					assert.NotEqual(t, uint(0), users[2].ID)
+					assert.Equal(t, "User3", users[2].Name)
+					assert.Equal(t, []int{2, 1}, lengths)
+				})
+
+				t.Run("should return error if the callback returns an error in the first iteration", func(t *testing.T) {
+					err := createTables(driver, connStr)
+					if err != nil {
+						t.Fatal("could not create test table!, reason:", err.Error())
+					}
+
+					db, closer := newDBAdapter(t)
+					defer closer.Close()
+
+					ctx := context.Background()
+					c := newTestDB(db, driver)
+
+					_ = c.Insert(ctx, usersTable, &user{Name: "User1"})
+					_ = c.Insert(ctx, usersTable, &user{Name: "User2"})
+					_ = c.Insert(ctx, usersTable, &user{Name: "User3"})
+
+					var lengths []int
+					var users []user
+					err = c.QueryChunks(ctx, ChunkParser{
+						Query:  variation.queryPrefix + `from users where name like ` + c.dialect.Placeholder(0) + ` order by name asc;`,
+						Params: []interface{}{"User%"},
+
+						ChunkSize: 2,
+						ForEachChunk: func(buffer []user) error {
+							lengths = append(lengths, len(buffer))
+							users = append(users, buffer...)
+							return errors.New("fake error msg")
+						},
+					})
+
+					assert.NotEqual(t, nil, err)
+					assert.Equal(t, 2, len(users))
+					assert.NotEqual(t, uint(0), users[0].ID)
+					assert.Equal(t, "User1", users[0].Name)
+					assert.NotEqual(t, uint(0), users[1].ID)
+					assert.Equal(t, "User2", users[1].Name)
+					assert.Equal(t, []int{2}, lengths)
+				})
+
+				t.Run("should return error if the callback returns an error in the last iteration", func(t *testing.T) {
+					err := createTables(driver, connStr)
+					if err != nil {
+						t.Fatal("could not create test table!, reason:", err.Error())
+					}
+
+					db, closer := newDBAdapter(t)
+					defer closer.Close()
+
+					ctx := context.Background()
+					c := newTestDB(db, driver)
+
+					_ = c.Insert(ctx, usersTable, &user{Name: "User1"})
+					_ = c.Insert(ctx, usersTable, &user{Name: "User2"})
+					_ = c.Insert(ctx, usersTable, &user{Name: "User3"})
+
+					returnVals := []error{nil, errors.New("fake error msg")}
 					var lengths []int
 					var users []user
-					err = c.QueryChunks(ctx, ChunkParser{
-						Query:  variation.queryPrefix + `from users where name like ` + c.dialect.Placeholder(0) + ` order by name asc;`,
-						Params: []interface{}{"User%"},
+					err = c.QueryChunks(ctx, ChunkParser{
+						Query:  variation.queryPrefix + `from users where name like ` + c.dialect.Placeholder(0) + ` order by name asc;`,
+						Params: []interface{}{"User%"},
+
+						ChunkSize: 2,
+						ForEachChunk: func(buffer []user) error {
+							lengths = append(lengths, len(buffer))
+							users = append(users, buffer...)
+
+							return shiftErrSlice(&returnVals)
+						},
+					})
+
+					assert.NotEqual(t, nil, err)
+					assert.Equal(t, 3, len(users))
+					assert.NotEqual(t, uint(0), users[0].ID)
+					assert.Equal(t, "User1", users[0].Name)
+					assert.NotEqual(t, uint(0), users[1].ID)
+					assert.Equal(t, "User2", users[1].Name)
+					assert.NotEqual(t, uint(0), users[2].ID)
+					assert.Equal(t, "User3", users[2].Name)
+					assert.Equal(t, []int{2, 1}, lengths)
+				})
+
+				t.Run("should report error if the input function is invalid", func(t *testing.T) {
+					db, closer := newDBAdapter(t)
+					defer closer.Close()
+
+					ctx := context.Background()
+					c := newTestDB(db, driver)
+
+					funcs := []interface{}{
+						nil,
+						"not a function",
+						func() error {
+							return nil
+						},
+						func(extraInputValue []user, extra []user) error {
+							return nil
+						},
+						func(invalidArgType string) error {
+							return nil
+						},
+						func(missingReturnType []user) {
+							return
+						},
+						func(users []user) string {
+							return ""
+						},
+						func(extraReturnValue []user) ([]user, error) {
+							return nil, nil
+						},
+						func(notSliceOfStructs []string) error {
+							return nil
+						},
+					}
+
+					for _, fn := range funcs {
+						err := c.QueryChunks(ctx, ChunkParser{
+							Query:  variation.queryPrefix + `FROM users`,
+							Params: []interface{}{},
+
+							ChunkSize:    2,
+							ForEachChunk: fn,
+						})
+						assert.NotEqual(t, nil, err)
+					}
+				})
+
+				t.Run("should report error if the query is not valid", func(t *testing.T) {
+					db, closer := newDBAdapter(t)
+					defer closer.Close()
+
+					ctx := context.Background()
+					c := newTestDB(db, driver)
+					err := c.QueryChunks(ctx, ChunkParser{
+						Query:  `SELECT * FROM not a valid query`,
+						Params: []interface{}{},
+
+						ChunkSize: 2,
+						ForEachChunk: func(buffer []user) error {
+							return nil
+						},
+					})
+					assert.NotEqual(t, nil, err)
+				})
+
+				t.Run("should report error if using nested struct and the query starts with SELECT", func(t *testing.T) {
+					db, closer := newDBAdapter(t)
+					defer closer.Close()
+
+					ctx := context.Background()
+					c := newTestDB(db, driver)
+
+					err := c.QueryChunks(ctx, ChunkParser{
+						Query:  `SELECT * FROM users u JOIN posts p ON u.id = p.user_id`,
+						Params: []interface{}{},
+
+						ChunkSize: 2,
+						ForEachChunk: func(buffer []struct {
+							User user `tablename:"users"`
+							Post post `tablename:"posts"`
+						}) error {
+							return nil
+						},
+					})
+
+					assert.NotEqual(t, nil, err)
+					assert.Equal(t, true, strings.Contains(err.Error(), "nested struct"), "unexpected error msg: "+err.Error())
+					assert.Equal(t, true, strings.Contains(err.Error(), "feature"), "unexpected error msg: "+err.Error())
+				})
+			})
+		}
+	})
+}
+
+// TransactionTest runs all tests for making sure the Transaction function is
+// working for a given adapter and driver.
+func TransactionTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("Transaction", func(t *testing.T) {
+		t.Run("should query a single row correctly", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			_ = c.Insert(ctx, usersTable, &user{Name: "User1"})
+			_ = c.Insert(ctx, usersTable, &user{Name: "User2"})
+
+			var users []user
+			err = c.Transaction(ctx, func(db Provider) error {
+				db.Query(ctx, &users, "SELECT * FROM users ORDER BY id ASC")
+				return nil
+			})
+			assert.Equal(t, nil, err)
+
+			assert.Equal(t, 2, len(users))
+			assert.Equal(t, "User1", users[0].Name)
+			assert.Equal(t, "User2", users[1].Name)
+		})
+
+		t.Run("should rollback when there are errors", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			u1 := user{Name: "User1", Age: 42}
+			u2 := user{Name: "User2", Age: 42}
+			_ = c.Insert(ctx, usersTable, &u1)
+			_ = c.Insert(ctx, usersTable, &u2)
+
+			err = c.Transaction(ctx, func(db Provider) error {
+				err = db.Insert(ctx, usersTable, &user{Name: "User3"})
+				assert.Equal(t, nil, err)
+				err = db.Insert(ctx, usersTable, &user{Name: "User4"})
+				assert.Equal(t, nil, err)
+				_, err = db.Exec(ctx, "UPDATE users SET age = 22")
+				assert.Equal(t, nil, err)
+
+				return errors.New("fake-error")
+			})
+			assert.NotEqual(t, nil, err)
+			assert.Equal(t, "fake-error", err.Error())
+
+			var users []user
+			err = c.Query(ctx, &users, "SELECT * FROM users ORDER BY id ASC")
+			assert.Equal(t, nil, err)
+
+			assert.Equal(t, []user{u1, u2}, users)
+		})
+	})
+}
+
+// ScanRowsTest runs all tests for making sure the ScanRows feature is
+// working for a given adapter and driver.
+func ScanRowsTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("ScanRows", func(t *testing.T) {
+		t.Run("should scan users correctly", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			ctx := context.TODO()
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+			c := newTestDB(db, driver)
+			_ = c.Insert(ctx, usersTable, &user{Name: "User1", Age: 22})
+			_ = c.Insert(ctx, usersTable, &user{Name: "User2", Age: 14})
+			_ = c.Insert(ctx, usersTable, &user{Name: "User3", Age: 43})
+
+			rows, err := db.QueryContext(ctx, "SELECT * FROM users WHERE name='User2'")
+			assert.Equal(t, nil, err)
+			defer rows.Close()
+
+			assert.Equal(t, true, rows.Next())
+
+			var u user
+			err = c.scanRows(rows, &u)
+			assert.Equal(t, nil, err)
+
+			assert.Equal(t, "User2", u.Name)
+			assert.Equal(t, 14, u.Age)
+		})
+
+		t.Run("should ignore extra columns from query", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			ctx := context.TODO()
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+			c := newTestDB(db, driver)
+			_ = c.Insert(ctx, usersTable, &user{Name: "User1", Age: 22})
+
+			rows, err := db.QueryContext(ctx, "SELECT * FROM users WHERE name='User1'")
+			assert.Equal(t, nil, err)
+			defer rows.Close()
+
+			assert.Equal(t, true, rows.Next())
+
+			var u struct {
+				ID  int `ksql:"id"`
+				Age int `ksql:"age"`
+
+				// Omitted for testing purposes:
+				// Name string `ksql:"name"`
+			}
+			err = c.scanRows(rows, &u)
+			assert.Equal(t, nil, err)
+
+			assert.Equal(t, 22, u.Age)
+		})
+
+		t.Run("should leave attributes missing from the query untouched", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			ctx := context.TODO()
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+			c := newTestDB(db, driver)
+			_ = c.Insert(ctx, usersTable, &user{Name: "User1", Age: 22})
+
+			createdAt := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+			u := struct {
+				ID        int       `ksql:"id"`
+				Name      string    `ksql:"name"`
+				Email     string    `ksql:"email"`
+				CreatedAt time.Time `ksql:"created_at"`
+			}{
+				Email:     "should@be.untouched",
+				CreatedAt: createdAt,
+			}
+
+			err = c.QueryOne(ctx, &u, "SELECT id, name FROM users WHERE name='User1'")
+			tt.AssertNoErr(t, err)
+
+			tt.AssertNotEqual(t, u.ID, 0)
+			tt.AssertEqual(t, u.Name, "User1")
+			tt.AssertEqual(t, u.Email, "should@be.untouched")
+			tt.AssertEqual(t, u.CreatedAt, createdAt)
+		})
+
+		t.Run("should report error for closed rows", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			ctx := context.TODO()
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+			c := newTestDB(db, driver)
+
+			rows, err := db.QueryContext(ctx, "SELECT * FROM users WHERE name='User2'")
+			assert.Equal(t, nil, err)
+
+			var u user
+			err = rows.Close()
+			assert.Equal(t, nil, err)
+			err = c.scanRows(rows, &u)
+			assert.NotEqual(t, nil, err)
+		})
+
+		t.Run("should report if record is not a pointer", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			ctx := context.TODO()
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+			c := newTestDB(db, driver)
+
+			rows, err := db.QueryContext(ctx, "SELECT * FROM users WHERE name='User2'")
+			tt.AssertNoErr(t, err)
+			defer rows.Close()
+
+			var u user
+			err = c.scanRows(rows, u)
+			tt.AssertErrContains(t, err, "ksql", "expected", "pointer to struct", "user")
+		})
+
+		t.Run("should report if record is not a pointer to struct", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			ctx := context.TODO()
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+			c := newTestDB(db, driver)
+
+			rows, err := db.QueryContext(ctx, "SELECT * FROM users WHERE name='User2'")
+			tt.AssertNoErr(t, err)
+			defer rows.Close()
+
+			var u map[string]interface{}
+			err = c.scanRows(rows, &u)
+			tt.AssertErrContains(t, err, "ksql", "expected", "pointer to struct", "map[string]interface")
+		})
+	})
+}
+
+// ExplainTest runs all tests for making sure the Explain function is
+// working for a given adapter and driver.
+func ExplainTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("Explain", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		t.Run("should return the query plan", func(t *testing.T) {
+			if driver == "sqlserver" {
+				return
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			plan, err := c.Explain(ctx, `SELECT * FROM users WHERE name = `+c.dialect.Placeholder(0), "fake-name")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, strings.Contains(plan, "users"), true, "unexpected query plan: %s", plan)
+		})
+
+		t.Run("should return the query plan with analyze", func(t *testing.T) {
+			if driver != "postgres" && driver != "mysql" {
+				return
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			plan, err := c.ExplainAnalyze(ctx, `SELECT * FROM users WHERE name = `+c.dialect.Placeholder(0), "fake-name")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, strings.Contains(plan, "actual time"), true, "unexpected query plan: %s", plan)
+		})
+
+		t.Run("should report error if the driver doesn't support it", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			switch driver {
+			case "sqlserver":
+				_, err := c.Explain(ctx, `SELECT * FROM users`)
+				tt.AssertErrContains(t, err, "EXPLAIN", "not supported", "sqlserver")
+			case "sqlite3":
+				_, err := c.ExplainAnalyze(ctx, `SELECT * FROM users`)
+				tt.AssertErrContains(t, err, "EXPLAIN ANALYZE", "not supported", "sqlite3")
+			}
+		})
+
+		t.Run("should report error if the query is not valid", func(t *testing.T) {
+			if driver == "sqlserver" {
+				return
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			_, err := c.Explain(ctx, `SELECT * FROM not a valid query`)
+			tt.AssertErrContains(t, err, "error running query")
+		})
+	})
+}
+
+// PreloadTest runs all tests for making sure the Preload function is
+// working for a given adapter and driver.
+func PreloadTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("Preload", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		t.Run("should load the children of all parents in a single query", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			var parents []user
+			for _, name := range []string{"Ana Preload", "Beto Preload", "Caio Preload"} {
+				u := user{Name: name}
+				tt.AssertNoErr(t, c.Insert(ctx, usersTable, &u))
+				parents = append(parents, u)
+			}
+
+			for _, p := range []post{
+				{UserID: parents[0].ID, Title: "Ana Post1"},
+				{UserID: parents[0].ID, Title: "Ana Post2"},
+				{UserID: parents[1].ID, Title: "Beto Post1"},
+			} {
+				tt.AssertNoErr(t, c.Insert(ctx, postsTable, &p))
+			}
+
+			var queries []string
+			c.db = mockDBAdapter{
+				ExecContextFn: db.ExecContext,
+				QueryContextFn: func(ctx context.Context, query string, params ...interface{}) (Rows, error) {
+					queries = append(queries, query)
+					return db.QueryContext(ctx, query, params...)
+				},
+			}
+
+			var postsByUserID map[uint][]post
+			err := c.Preload(ctx, postsTable, &postsByUserID, "user_id", parents, "id")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(queries), 1)
+
+			tt.AssertEqual(t, len(postsByUserID), 2)
+			tt.AssertEqual(t, getPostTitles(postsByUserID[parents[0].ID]), []string{"Ana Post1", "Ana Post2"})
+			tt.AssertEqual(t, getPostTitles(postsByUserID[parents[1].ID]), []string{"Beto Post1"})
+			tt.AssertEqual(t, len(postsByUserID[parents[2].ID]), 0)
+		})
+
+		t.Run("should work with slices of pointers", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			parent := &user{Name: "Dani Preload"}
+			tt.AssertNoErr(t, c.Insert(ctx, usersTable, parent))
+			tt.AssertNoErr(t, c.Insert(ctx, postsTable, &post{UserID: parent.ID, Title: "Dani Post1"}))
+
+			var postsByUserID map[uint][]*post
+			err := c.Preload(ctx, postsTable, &postsByUserID, "user_id", &[]*user{parent, nil}, "id")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(postsByUserID), 1)
+			tt.AssertEqual(t, len(postsByUserID[parent.ID]), 1)
+			tt.AssertEqual(t, postsByUserID[parent.ID][0].Title, "Dani Post1")
+		})
+
+		t.Run("should not query the database if there are no parents", func(t *testing.T) {
+			ctx := context.Background()
+			c := newTestDB(mockDBAdapter{}, driver)
+
+			var postsByUserID map[uint][]post
+			err := c.Preload(ctx, postsTable, &postsByUserID, "user_id", []user{}, "id")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, postsByUserID, map[uint][]post{})
+		})
+
+		t.Run("should report error for invalid arguments", func(t *testing.T) {
+			ctx := context.Background()
+			c := newTestDB(mockDBAdapter{}, driver)
+
+			var postsByUserID map[uint][]post
+			err := c.Preload(ctx, postsTable, postsByUserID, "user_id", []user{}, "id")
+			tt.AssertErrContains(t, err, "pointer to a map of slices of structs")
+
+			var posts []post
+			err = c.Preload(ctx, postsTable, &posts, "user_id", []user{}, "id")
+			tt.AssertErrContains(t, err, "pointer to a map of slices of structs")
+
+			err = c.Preload(ctx, postsTable, &postsByUserID, "non_existing_column", []user{}, "id")
+			tt.AssertErrContains(t, err, "foreign key column", "non_existing_column")
+
+			err = c.Preload(ctx, postsTable, &postsByUserID, "user_id", []user{}, "non_existing_column")
+			tt.AssertErrContains(t, err, "key column", "non_existing_column")
+
+			err = c.Preload(ctx, postsTable, &postsByUserID, "user_id", user{}, "id")
+			tt.AssertErrContains(t, err, "slice of structs")
+
+			var postsByTitle map[bool][]post
+			err = c.Preload(ctx, postsTable, &postsByTitle, "title", []user{}, "id")
+			tt.AssertErrContains(t, err, "can't use field", "title")
+
+			err = c.Preload(ctx, NewTable(""), &postsByUserID, "user_id", []user{}, "id")
+			tt.AssertErrContains(t, err, "ksql.Table", "table name")
+		})
+	})
+}
+
+// NullAsZeroTest runs all tests for making sure the nullAsZero
+// option controls how NULL values are scanned into non-pointer attributes.
+func NullAsZeroTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("WithNullAsZero", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		type userWithoutPtrs struct {
+			ID   uint   `ksql:"id"`
+			Name string `ksql:"name"`
+			Age  int    `ksql:"age"`
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		_, err = c.Exec(ctx, `INSERT INTO users (name) VALUES ('Bob')`)
+		tt.AssertNoErr(t, err)
+
+		t.Run("should report an error when scanning NULL into an int by default", func(t *testing.T) {
+			var u userWithoutPtrs
+			err := c.QueryOne(ctx, &u, `FROM users WHERE name = 'Bob'`)
+			tt.AssertErrContains(t, err, "age")
+		})
+
+		t.Run("should scan NULL into an int as 0 when enabled", func(t *testing.T) {
+			u := userWithoutPtrs{
+				Age: 42,
+			}
+			err := c.WithNullAsZero(true).QueryOne(ctx, &u, `FROM users WHERE name = 'Bob'`)
+			tt.AssertNoErr(t, err)
+			tt.AssertNotEqual(t, u.ID, uint(0))
+			tt.AssertEqual(t, u.Name, "Bob")
+			tt.AssertEqual(t, u.Age, 0)
+		})
+
+		t.Run("should still scan non NULL values when enabled", func(t *testing.T) {
+			_, err = c.Exec(ctx, `INSERT INTO users (name, age) VALUES ('Alice', 22)`)
+			tt.AssertNoErr(t, err)
+
+			var users []userWithoutPtrs
+			err := c.WithNullAsZero(true).Query(ctx, &users, `FROM users ORDER BY name`)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(users), 2)
+			tt.AssertEqual(t, users[0].Name, "Alice")
+			tt.AssertEqual(t, users[0].Age, 22)
+			tt.AssertEqual(t, users[1].Name, "Bob")
+			tt.AssertEqual(t, users[1].Age, 0)
+		})
+
+		t.Run("should keep NULL as nil for pointer attributes when enabled", func(t *testing.T) {
+			var u struct {
+				Name string `ksql:"name"`
+				Age  *int   `ksql:"age"`
+			}
+			err := c.WithNullAsZero(true).QueryOne(ctx, &u, `FROM users WHERE name = 'Bob'`)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, u.Age, (*int)(nil))
+		})
+	})
+}
+
+// PaginateTest runs all tests for making sure the Paginate
+// function is working correctly.
+func PaginateTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("Paginate", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		for i := 1; i <= 5; i++ {
+			err := c.Insert(ctx, usersTable, &user{Name: fmt.Sprintf("Page User %d", i), Age: 30})
+			tt.AssertNoErr(t, err)
+		}
+		err = c.Insert(ctx, usersTable, &user{Name: "Filtered Out", Age: 10})
+		tt.AssertNoErr(t, err)
+
+		query := `FROM users WHERE age = ` + c.dialect.Placeholder(0) + ` ORDER BY name`
+
+		t.Run("should report if there is a next page", func(t *testing.T) {
+			var names [][]string
+			var hasNexts []bool
+			for page := 1; page <= 4; page++ {
+				var users []user
+				hasNext, err := c.Paginate(ctx, &users, page, 2, query, 30)
+				tt.AssertNoErr(t, err)
+
+				pageNames := []string{}
+				for _, u := range users {
+					pageNames = append(pageNames, u.Name)
+				}
+				names = append(names, pageNames)
+				hasNexts = append(hasNexts, hasNext)
+			}
+
+			tt.AssertEqual(t, names, [][]string{
+				{"Page User 1", "Page User 2"},
+				{"Page User 3", "Page User 4"},
+				{"Page User 5"},
+				{},
+			})
+			tt.AssertEqual(t, hasNexts, []bool{true, true, false, false})
+		})
+
+		t.Run("should only return the rows of the page when reusing the slice", func(t *testing.T) {
+			var users []user
+			var ptrUsers []*user
+			var names, ptrNames [][]string
+			var hasNexts, ptrHasNexts []bool
+			for page := 1; page <= 3; page++ {
+				hasNext, err := c.Paginate(ctx, &users, page, 2, query, 30)
+				tt.AssertNoErr(t, err)
+				names = append(names, getUserNames(users))
+				hasNexts = append(hasNexts, hasNext)
+
+				hasNext, err = c.Paginate(ctx, &ptrUsers, page, 2, query, 30)
+				tt.AssertNoErr(t, err)
+				pageNames := []string{}
+				for _, u := range ptrUsers {
+					pageNames = append(pageNames, u.Name)
+				}
+				ptrNames = append(ptrNames, pageNames)
+				ptrHasNexts = append(ptrHasNexts, hasNext)
+			}
+
+			expectedNames := [][]string{
+				{"Page User 1", "Page User 2"},
+				{"Page User 3", "Page User 4"},
+				{"Page User 5"},
+			}
+			tt.AssertEqual(t, names, expectedNames)
+			tt.AssertEqual(t, ptrNames, expectedNames)
+			tt.AssertEqual(t, hasNexts, []bool{true, true, false})
+			tt.AssertEqual(t, ptrHasNexts, []bool{true, true, false})
+		})
+
+		t.Run("should not report a next page when the last page is full", func(t *testing.T) {
+			var users []*user
+			hasNext, err := c.Paginate(ctx, &users, 1, 5, query, 30)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(users), 5)
+			tt.AssertEqual(t, hasNext, false)
+		})
+
+		t.Run("should work with scopes", func(t *testing.T) {
+			var users []user
+			hasNext, err := c.Where("name <> ?", "Page User 1").Paginate(ctx, &users, 2, 2, query, 30)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(users), 2)
+			tt.AssertEqual(t, users[0].Name, "Page User 4")
+			tt.AssertEqual(t, users[1].Name, "Page User 5")
+			tt.AssertEqual(t, hasNext, false)
+		})
+
+		t.Run("should report error for invalid page arguments", func(t *testing.T) {
+			var users []user
+			_, err := c.Paginate(ctx, &users, 0, 10, query, 30)
+			tt.AssertErrContains(t, err, "ksql", "page", "0")
+
+			_, err = c.Paginate(ctx, &users, 1, 0, query, 30)
+			tt.AssertErrContains(t, err, "ksql", "pageSize", "0")
+
+			_, err = c.Paginate(ctx, users, 1, 10, query, 30)
+			tt.AssertErrContains(t, err, "ksql", "pointer to slice")
+		})
+	})
+}
+
+// PaginateCursorTest runs all tests for making sure the PaginateCursor
+// function is working correctly.
+func PaginateCursorTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("PaginateCursor", func(t *testing.T) {
+		variations := []struct {
+			desc        string
+			queryPrefix string
+		}{
+			{
+				desc:        "with select *",
+				queryPrefix: "SELECT * ",
+			},
+			{
+				desc:        "building the SELECT part of the query internally",
+				queryPrefix: "",
+			},
+		}
+		for _, variation := range variations {
+			t.Run(variation.desc, func(t *testing.T) {
+				err := createTables(driver, connStr)
+				if err != nil {
+					t.Fatal("could not create test table!, reason:", err.Error())
+				}
+
+				db, closer := newDBAdapter(t)
+				defer closer.Close()
+
+				ctx := context.Background()
+				c := newTestDB(db, driver)
+
+				for i := 1; i <= 5; i++ {
+					err := c.Insert(ctx, usersTable, &user{Name: fmt.Sprintf("Page User %d", i), Age: 30})
+					tt.AssertNoErr(t, err)
+				}
+				err = c.Insert(ctx, usersTable, &user{Name: "Filtered Out", Age: 10})
+				tt.AssertNoErr(t, err)
+
+				t.Run("should page through all the results", func(t *testing.T) {
+					// Paging twice to make sure the cursors are stable:
+					for pass := 0; pass < 2; pass++ {
+						var names []string
+						var cursor interface{}
+						numPages := 0
+						for {
+							var users []user
+							cursor, err = c.PaginateCursor(
+								ctx, &users, "id", cursor, 2,
+								variation.queryPrefix+`FROM users WHERE age = `+c.dialect.Placeholder(0), 30,
+							)
+							tt.AssertNoErr(t, err)
+							numPages++
+
+							for _, u := range users {
+								names = append(names, u.Name)
+							}
+							if cursor == nil {
+								break
+							}
+							tt.AssertEqual(t, cursor, users[len(users)-1].ID)
+						}
+
+						tt.AssertEqual(t, numPages, 3)
+						tt.AssertEqual(t, names, []string{
+							"Page User 1",
+							"Page User 2",
+							"Page User 3",
+							"Page User 4",
+							"Page User 5",
+						})
+					}
+				})
+
+				t.Run("should end the pagination when reusing the slice", func(t *testing.T) {
+					var names []string
+					var cursor interface{}
+					var users []user
+					for numPages := 1; ; numPages++ {
+						if numPages > 3 {
+							t.Fatal("expected the pagination to end after 3 pages")
+						}
+
+						cursor, err = c.PaginateCursor(
+							ctx, &users, "id", cursor, 2,
+							variation.queryPrefix+`FROM users WHERE age = `+c.dialect.Placeholder(0), 30,
+						)
+						tt.AssertNoErr(t, err)
+
+						names = append(names, getUserNames(users)...)
+						if cursor == nil {
+							break
+						}
+					}
+
+					tt.AssertEqual(t, names, []string{
+						"Page User 1",
+						"Page User 2",
+						"Page User 3",
+						"Page User 4",
+						"Page User 5",
+					})
+				})
+
+				t.Run("should work with slices of pointers", func(t *testing.T) {
+					var users []*user
+					cursor, err := c.PaginateCursor(
+						ctx, &users, "id", nil, 4,
+						variation.queryPrefix+`FROM users WHERE age = `+c.dialect.Placeholder(0), 30,
+					)
+					tt.AssertNoErr(t, err)
+					tt.AssertEqual(t, len(users), 4)
+					tt.AssertEqual(t, cursor, users[3].ID)
+
+					users = nil
+					cursor, err = c.PaginateCursor(
+						ctx, &users, "id", cursor, 4,
+						variation.queryPrefix+`FROM users WHERE age = `+c.dialect.Placeholder(0), 30,
+					)
+					tt.AssertNoErr(t, err)
+					tt.AssertEqual(t, len(users), 1)
+					tt.AssertEqual(t, users[0].Name, "Page User 5")
+					tt.AssertEqual(t, cursor, nil)
+				})
+			})
+		}
+
+		t.Run("should report error if the cursor column is not tagged", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			c := newTestDB(db, driver)
+			var users []user
+			_, err := c.PaginateCursor(context.Background(), &users, "not_tagged", nil, 10, "FROM users")
+			tt.AssertErrContains(t, err, "not_tagged", "not tagged")
+		})
+
+		t.Run("should report error if the limit is not positive", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			c := newTestDB(db, driver)
+			var users []user
+			_, err := c.PaginateCursor(context.Background(), &users, "id", nil, 0, "FROM users")
+			tt.AssertErrContains(t, err, "limit")
+		})
+	})
+}
+
+// WithLocationTest runs all tests for making sure the WithLocation
+// option converts the time values to the configured location.
+func WithLocationTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("WithLocation", func(t *testing.T) {
+		// The mysql driver only parses DATETIME values into
+		// time.Time when using the `parseTime=true` option:
+		if driver == "mysql" {
+			return
+		}
+
+		err := createEventsTable(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		type event struct {
+			ID          int        `ksql:"id"`
+			CreatedAt   time.Time  `ksql:"created_at"`
+			CancelledAt *time.Time `ksql:"cancelled_at"`
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		loc := time.FixedZone("UTC-3", -3*60*60)
+		c := newTestDB(db, driver).WithLocation(loc)
+
+		createdAt := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+		cancelledAt := time.Date(2022, 2, 3, 4, 5, 6, 0, time.UTC)
+		e := event{
+			CreatedAt:   createdAt,
+			CancelledAt: &cancelledAt,
+		}
+		err = c.Insert(ctx, NewTable("events"), &e)
+		tt.AssertNoErr(t, err)
+
+		t.Run("should convert scanned times to the configured location", func(t *testing.T) {
+			var result event
+			err := c.QueryOne(ctx, &result, `FROM events WHERE id = `+c.dialect.Placeholder(0), e.ID)
+			tt.AssertNoErr(t, err)
+
+			tt.AssertEqual(t, result.CreatedAt.Location(), loc)
+			tt.AssertEqual(t, result.CreatedAt.Equal(createdAt), true)
+
+			tt.AssertNotEqual(t, result.CancelledAt, (*time.Time)(nil))
+			tt.AssertEqual(t, result.CancelledAt.Location(), loc)
+			tt.AssertEqual(t, result.CancelledAt.Equal(cancelledAt), true)
+		})
+
+		t.Run("should keep nil time pointers as nil", func(t *testing.T) {
+			e := event{
+				CreatedAt: createdAt,
+			}
+			err = c.Insert(ctx, NewTable("events"), &e)
+			tt.AssertNoErr(t, err)
+
+			var result event
+			err := c.QueryOne(ctx, &result, `FROM events WHERE id = `+c.dialect.Placeholder(0), e.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, result.CreatedAt.Location(), loc)
+			tt.AssertEqual(t, result.CancelledAt, (*time.Time)(nil))
+		})
+
+		t.Run("should convert cached times to the configured location", func(t *testing.T) {
+			cache := &mapQueryCache{entries: map[string][]byte{}}
+			query := `FROM events WHERE id = ` + c.dialect.Placeholder(0)
+
+			// Caching the same query with another location first,
+			// so the key must also depend on the configured location:
+			var utcResults []event
+			err := c.WithLocation(time.UTC).CachedQuery(ctx, cache, time.Minute, &utcResults, query, e.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(utcResults), 1)
+			tt.AssertEqual(t, utcResults[0].CreatedAt.Location(), time.UTC)
+
+			for i := 0; i < 2; i++ {
+				var results []event
+				err := c.CachedQuery(ctx, cache, time.Minute, &results, query, e.ID)
+				tt.AssertNoErr(t, err)
+				tt.AssertEqual(t, len(results), 1)
+				tt.AssertEqual(t, results[0].CreatedAt.Location(), loc)
+				tt.AssertEqual(t, results[0].CreatedAt.Equal(createdAt), true)
+				tt.AssertEqual(t, results[0].CancelledAt.Location(), loc)
+				tt.AssertEqual(t, results[0].CancelledAt.Equal(cancelledAt), true)
+			}
+			tt.AssertEqual(t, len(cache.entries), 2)
+		})
+	})
+}
+
+// WhereTest runs all tests for making sure the scopes
+// created with the Where method are working correctly.
+func WhereTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("Where", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		// The age column is used as the tenant ID on these tests:
+		tenant1User := user{Name: "Tenant 1 User", Age: 1}
+		err = c.Insert(ctx, usersTable, &tenant1User)
+		tt.AssertNoErr(t, err)
+		tenant2User := user{Name: "Tenant 2 User", Age: 2}
+		err = c.Insert(ctx, usersTable, &tenant2User)
+		tt.AssertNoErr(t, err)
+
+		scoped := c.Where("age = ?", 1)
+
+		t.Run("should apply the scope to Query", func(t *testing.T) {
+			for _, queryPrefix := range []string{"", "SELECT * "} {
+				var users []user
+				err := scoped.Query(ctx, &users, queryPrefix+"FROM users WHERE name LIKE "+c.dialect.Placeholder(0), "Tenant %")
+				tt.AssertNoErr(t, err)
+				tt.AssertEqual(t, len(users), 1)
+				tt.AssertEqual(t, users[0].Name, "Tenant 1 User")
+			}
+		})
+
+		t.Run("should apply the scope to queries with ORDER BY", func(t *testing.T) {
+			var users []user
+			err := c.Where("name LIKE ?", "Tenant %").Query(ctx, &users, "FROM users ORDER BY age DESC")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(users), 2)
+			tt.AssertEqual(t, users[0].Name, "Tenant 2 User")
+			tt.AssertEqual(t, users[1].Name, "Tenant 1 User")
+		})
+
+		t.Run("should apply the scope to QueryOne", func(t *testing.T) {
+			var u user
+			err := scoped.QueryOne(ctx, &u, "FROM users WHERE id = "+c.dialect.Placeholder(0), tenant2User.ID)
+			tt.AssertEqual(t, err, ErrRecordNotFound)
+
+			err = scoped.QueryOne(ctx, &u, "FROM users WHERE id = "+c.dialect.Placeholder(0), tenant1User.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, u.Name, "Tenant 1 User")
+		})
+
+		t.Run("should AND multiple scopes", func(t *testing.T) {
+			var users []user
+			err := scoped.Where("name = ?", "Tenant 2 User").Query(ctx, &users, "FROM users")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(users), 0)
+
+			// The original scoped DB should not be affected:
+			err = scoped.Query(ctx, &users, "FROM users")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(users), 1)
+		})
+
+		t.Run("should apply the scope to Patch", func(t *testing.T) {
+			err := scoped.Patch(ctx, usersTable, struct {
+				ID   uint   `ksql:"id"`
+				Name string `ksql:"name"`
+			}{ID: tenant2User.ID, Name: "Should not be updated"})
+			tt.AssertEqual(t, err, ErrRecordNotFound)
+
+			var u user
+			err = c.QueryOne(ctx, &u, "FROM users WHERE id = "+c.dialect.Placeholder(0), tenant2User.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, u.Name, "Tenant 2 User")
+		})
+
+		t.Run("should apply the scope to Delete", func(t *testing.T) {
+			err := scoped.Delete(ctx, usersTable, tenant2User.ID)
+			tt.AssertEqual(t, err, ErrRecordNotFound)
+
+			var u user
+			err = c.QueryOne(ctx, &u, "FROM users WHERE id = "+c.dialect.Placeholder(0), tenant2User.ID)
+			tt.AssertNoErr(t, err)
+
+			err = scoped.Delete(ctx, usersTable, tenant1User.ID)
+			tt.AssertNoErr(t, err)
+
+			err = c.QueryOne(ctx, &u, "FROM users WHERE id = "+c.dialect.Placeholder(0), tenant1User.ID)
+			tt.AssertEqual(t, err, ErrRecordNotFound)
+		})
+
+		t.Run("should report error if the number of params doesn't match the placeholders", func(t *testing.T) {
+			var users []user
+			err := c.Where("age = ? AND name = ?", 1).Query(ctx, &users, "FROM users")
+			tt.AssertErrContains(t, err, "2 placeholders", "1 params")
+		})
+	})
+}
+
+// CountOfTest runs all tests for making sure the CountOf
+// function is working correctly.
+func CountOfTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("CountOf", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		for i := 1; i <= 5; i++ {
+			err := c.Insert(ctx, usersTable, &user{Name: fmt.Sprintf("Count User %d", i), Age: i})
+			tt.AssertNoErr(t, err)
+		}
+
+		t.Run("should count the rows of queries with the SELECT part", func(t *testing.T) {
+			count, err := c.CountOf(ctx, "SELECT id, name FROM users WHERE age > "+c.dialect.Placeholder(0), 2)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, count, int64(3))
+		})
+
+		t.Run("should count the rows of queries without the SELECT part", func(t *testing.T) {
+			count, err := c.CountOf(ctx, "FROM users")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, count, int64(5))
+		})
+
+		t.Run("should work with queries ending with ORDER BY", func(t *testing.T) {
+			count, err := c.CountOf(ctx, "FROM users WHERE age <= "+c.dialect.Placeholder(0)+" ORDER BY name DESC;", 4)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, count, int64(4))
+		})
+
+		t.Run("should apply the scopes of the DB", func(t *testing.T) {
+			count, err := c.Where("age = ?", 1).CountOf(ctx, "FROM users")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, count, int64(1))
+		})
+
+		t.Run("should report error if the query is not valid", func(t *testing.T) {
+			_, err := c.CountOf(ctx, "FROM not a valid query")
+			tt.AssertErrContains(t, err, "error running query")
+		})
+	})
+}
+
+// QueryChanTest runs all tests for making sure the QueryChan
+// function is working correctly.
+func QueryChanTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("QueryChan", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		// More than one chunk of users:
+		for i := 0; i < 150; i++ {
+			err := c.Insert(ctx, usersTable, &user{Name: fmt.Sprintf("Chan User %03d", i), Age: i})
+			tt.AssertNoErr(t, err)
+		}
+
+		t.Run("should send all the rows on the channel", func(t *testing.T) {
+			usersCh, errCh := c.QueryChan(ctx, user{}, "FROM users ORDER BY age")
+
+			var users []user
+			for u := range usersCh {
+				users = append(users, u.(user))
+			}
+			tt.AssertNoErr(t, <-errCh)
+
+			tt.AssertEqual(t, len(users), 150)
+			for i, u := range users {
+				tt.AssertEqual(t, u.Name, fmt.Sprintf("Chan User %03d", i))
+				tt.AssertEqual(t, u.Age, i)
+			}
+		})
+
+		t.Run("should send pointers to structs if elemType is a pointer", func(t *testing.T) {
+			usersCh, errCh := c.QueryChan(ctx, &user{}, "FROM users WHERE age < "+c.dialect.Placeholder(0)+" ORDER BY age", 3)
+
+			var users []*user
+			for u := range usersCh {
+				users = append(users, u.(*user))
+			}
+			tt.AssertNoErr(t, <-errCh)
+
+			tt.AssertEqual(t, len(users), 3)
+			tt.AssertEqual(t, users[0].Name, "Chan User 000")
+			tt.AssertEqual(t, users[1].Name, "Chan User 001")
+			tt.AssertEqual(t, users[2].Name, "Chan User 002")
+		})
+
+		t.Run("should stop and close the channels when the context is canceled", func(t *testing.T) {
+			ctx, cancel := context.WithCancel(ctx)
+			usersCh, errCh := c.QueryChan(ctx, user{}, "FROM users ORDER BY age")
+
+			u := <-usersCh
+			tt.AssertEqual(t, u.(user).Name, "Chan User 000")
+			cancel()
+
+			err := <-errCh
+			tt.AssertErrContains(t, err, "context canceled")
+
+			// The records channel should be closed:
+			for range usersCh {
+			}
+		})
+
+		t.Run("should report error if elemType is not a struct", func(t *testing.T) {
+			usersCh, errCh := c.QueryChan(ctx, []user{}, "FROM users")
+
+			_, ok := <-usersCh
+			tt.AssertEqual(t, ok, false)
+			tt.AssertErrContains(t, <-errCh, "expected elemType to be a struct")
+		})
+
+		t.Run("should report error if the query is not valid", func(t *testing.T) {
+			usersCh, errCh := c.QueryChan(ctx, user{}, "FROM not a valid query")
+
+			_, ok := <-usersCh
+			tt.AssertEqual(t, ok, false)
+			tt.AssertNotEqual(t, <-errCh, nil)
+		})
+	})
+}
+
+// ValidateSchemaTest runs all tests for making sure the ValidateSchema
+// function is working correctly.
+func ValidateSchemaTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("ValidateSchema", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		t.Run("should accept structs matching the table", func(t *testing.T) {
+			err := c.ValidateSchema(ctx, usersTable, &user{})
+			tt.AssertNoErr(t, err)
+		})
+
+		t.Run("should accept structs with a subset of the columns and pointer attributes", func(t *testing.T) {
+			err := c.ValidateSchema(ctx, usersTable, struct {
+				ID   *uint   `ksql:"id"`
+				Name *string `ksql:"name"`
+			}{})
+			tt.AssertNoErr(t, err)
+		})
+
+		t.Run("should report all the mismatched attributes", func(t *testing.T) {
+			err := c.ValidateSchema(ctx, usersTable, struct {
+				ID    uint   `ksql:"id"`
+				Name  int    `ksql:"name"`
+				Age   int    `ksql:"age"`
+				Email string `ksql:"email"`
+			}{})
+			tt.AssertErrContains(t, err,
+				"table `users`",
+				"attribute `Name` (int): column `name` has incompatible type",
+				"attribute `Email` (string): column `email` not found",
+			)
+			tt.AssertEqual(t, strings.Contains(err.Error(), "`Age`"), false)
+		})
+
+		t.Run("should report error if the table doesn't exist", func(t *testing.T) {
+			err := c.ValidateSchema(ctx, NewTable("not_a_table"), &user{})
+			tt.AssertErrContains(t, err, "not_a_table", "not found")
+		})
+	})
+}
+
+// InsertBatchTest runs all tests for making sure the InsertBatch
+// function is working correctly.
+func InsertBatchTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("InsertBatch", func(t *testing.T) {
+		t.Run("should insert all records using one statement per batch", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			numExecs := 0
+			c := newTestDB(execCounterAdapter{DBAdapter: db, numExecs: &numExecs}, driver).WithBatchSize(100)
+
+			users := make([]user, 5000)
+			for i := range users {
+				users[i] = user{
+					Name: fmt.Sprintf("Batch User %d", i),
+					Age:  i,
+					Address: address{
+						Country: "BR",
+					},
+				}
+			}
+
+			err = c.InsertBatch(ctx, usersTable, users)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, numExecs, 50)
+
+			count, err := c.CountOf(ctx, "FROM users WHERE name LIKE "+c.dialect.Placeholder(0), "Batch User %")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, count, int64(5000))
+
+			var u user
+			err = c.QueryOne(ctx, &u, "FROM users WHERE age = "+c.dialect.Placeholder(0), 4242)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, u.Name, "Batch User 4242")
+			tt.AssertEqual(t, u.Address.Country, "BR")
+		})
+
+		t.Run("should work with slices of pointers and the default batch size", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			numExecs := 0
+			c := newTestDB(execCounterAdapter{DBAdapter: db, numExecs: &numExecs}, driver)
+
+			err = c.InsertBatch(ctx, usersTable, []*user{
+				{Name: "Batch User 1"},
+				{Name: "Batch User 2"},
+			})
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, numExecs, 1)
+
+			var users []user
+			err = c.Query(ctx, &users, "FROM users ORDER BY name")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(users), 2)
+			tt.AssertEqual(t, users[0].Name, "Batch User 1")
+			tt.AssertEqual(t, users[1].Name, "Batch User 2")
+		})
+
+		t.Run("should rollback all batches if one of them fails", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver).WithBatchSize(2)
+
+			// The last record has a duplicated ID so only
+			// the second batch should fail:
+			err = c.InsertBatch(ctx, usersTable, []user{
+				{ID: 1, Name: "Batch User 1"},
+				{ID: 2, Name: "Batch User 2"},
+				{ID: 3, Name: "Batch User 3"},
+				{ID: 1, Name: "Batch User 4"},
+			})
+			tt.AssertErrContains(t, err, `InsertBatch into "users" failed`)
+
+			count, err := c.CountOf(ctx, "FROM users")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, count, int64(0))
+		})
+
+		t.Run("should report error for invalid batch sizes", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			c := newTestDB(db, driver).WithBatchSize(-1)
+			err := c.InsertBatch(context.Background(), usersTable, []user{{Name: "fake-name"}})
+			tt.AssertErrContains(t, err, "batch size", "-1")
+		})
+
+		t.Run("should report error if the ID is set only on some records", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			c := newTestDB(db, driver)
+			err := c.InsertBatch(context.Background(), usersTable, []user{{Name: "fake-name"}, {ID: 42, Name: "fake-name"}})
+			tt.AssertErrContains(t, err, "ID column `id`")
+		})
+
+		t.Run("should report error if the input is not a slice of structs", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			c := newTestDB(db, driver)
+			err := c.InsertBatch(context.Background(), usersTable, &user{Name: "fake-name"})
+			tt.AssertErrContains(t, err, "expected records to be a slice of structs")
+		})
+	})
+}
+
+// LoggerTest runs all tests for making sure the QueryLogger
+// set with WithLogger is working correctly.
+func LoggerTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("WithLogger", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		type ctxKey string
+
+		var requestIDs []interface{}
+		var logs []LogValues
+		c := newTestDB(db, driver).WithLogger(func(ctx context.Context, values LogValues) {
+			requestIDs = append(requestIDs, ctx.Value(ctxKey("request-id")))
+			logs = append(logs, values)
+		})
+
+		ctx := context.WithValue(context.Background(), ctxKey("request-id"), "fake-request-id")
+
+		t.Run("should pass the user context to the logger", func(t *testing.T) {
+			requestIDs, logs = nil, nil
+
+			err := c.Insert(ctx, usersTable, &user{Name: "Logged User"})
+			tt.AssertNoErr(t, err)
+
+			var u user
+			err = c.QueryOne(ctx, &u, "FROM users WHERE name = "+c.dialect.Placeholder(0), "Logged User")
+			tt.AssertNoErr(t, err)
+
+			tt.AssertEqual(t, requestIDs, []interface{}{"fake-request-id", "fake-request-id"})
+			tt.AssertEqual(t, len(logs), 2)
+			tt.AssertEqual(t, strings.Contains(logs[0].Query, "INSERT INTO"), true)
+			tt.AssertEqual(t, strings.Contains(logs[1].Query, "FROM users WHERE name ="), true)
+			tt.AssertEqual(t, logs[1].Params, []interface{}{"Logged User"})
+			tt.AssertEqual(t, logs[1].Err, nil)
+		})
+
+		t.Run("should pass the context to the logger inside transactions", func(t *testing.T) {
+			requestIDs, logs = nil, nil
+
+			err := c.Transaction(ctx, func(p Provider) error {
+				_, err := p.Exec(ctx, "DELETE FROM users WHERE name = 'not a user'")
+				return err
+			})
+			tt.AssertNoErr(t, err)
+
+			tt.AssertEqual(t, requestIDs, []interface{}{"fake-request-id"})
+		})
+
+		t.Run("should report query errors to the logger", func(t *testing.T) {
+			requestIDs, logs = nil, nil
+
+			var users []user
+			err := c.Query(ctx, &users, "FROM not a valid query")
+			tt.AssertNotEqual(t, err, nil)
+
+			tt.AssertEqual(t, len(logs), 1)
+			tt.AssertNotEqual(t, logs[0].Err, nil)
+		})
+	})
+}
+
+// PatchOnlyAndExceptTest runs all tests for making sure the PatchOnly
+// and PatchExcept functions are working correctly.
+func PatchOnlyAndExceptTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("PatchOnly and PatchExcept", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		insertUser := func(t *testing.T) user {
+			u := user{Name: "Original Name", Age: 10, Address: address{Country: "BR"}}
+			err := c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+			return u
+		}
+
+		t.Run("should update only the listed columns", func(t *testing.T) {
+			u := insertUser(t)
+
+			err := c.PatchOnly(ctx, usersTable, user{
+				ID:      u.ID,
+				Name:    "New Name",
+				Age:     20,
+				Address: address{Country: "US"},
+			}, "name", "address")
+			tt.AssertNoErr(t, err)
+
+			var result user
+			err = getUserByID(c.db, c.dialect, &result, u.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, result.Name, "New Name")
+			tt.AssertEqual(t, result.Age, 10)
+			tt.AssertEqual(t, result.Address.Country, "US")
+		})
+
+		t.Run("should update all columns except the listed ones", func(t *testing.T) {
+			u := insertUser(t)
+
+			err := c.PatchExcept(ctx, usersTable, user{
+				ID:      u.ID,
+				Name:    "New Name",
+				Age:     20,
+				Address: address{Country: "US"},
+			}, "name")
+			tt.AssertNoErr(t, err)
+
+			var result user
+			err = getUserByID(c.db, c.dialect, &result, u.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, result.Name, "Original Name")
+			tt.AssertEqual(t, result.Age, 20)
+			tt.AssertEqual(t, result.Address.Country, "US")
+		})
+
+		t.Run("should report error for columns not tagged on the struct", func(t *testing.T) {
+			u := insertUser(t)
+
+			err := c.PatchOnly(ctx, usersTable, u, "not_a_column")
+			tt.AssertErrContains(t, err, "not_a_column", "not tagged")
+
+			err = c.PatchExcept(ctx, usersTable, u, "not_a_column")
+			tt.AssertErrContains(t, err, "not_a_column", "not tagged")
+		})
+
+		t.Run("should report error for ID columns", func(t *testing.T) {
+			u := insertUser(t)
+
+			err := c.PatchOnly(ctx, usersTable, u, "id")
+			tt.AssertErrContains(t, err, "ID column `id`")
+		})
+
+		t.Run("should report error if no columns are left to update", func(t *testing.T) {
+			u := insertUser(t)
+
+			err := c.PatchOnly(ctx, usersTable, u)
+			tt.AssertErrContains(t, err, "at least one column")
+
+			err = c.PatchExcept(ctx, usersTable, u, "name", "age", "address")
+			tt.AssertErrContains(t, err, "no attributes left to update")
+		})
+	})
+}
+
+// ServerCursorTest runs all tests for making sure the ServerCursor
+// option of QueryChunks is working correctly.
+func ServerCursorTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("QueryChunks with ServerCursor", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+
+		var queries []string
+		c := newTestDB(db, driver).WithLogger(func(ctx context.Context, values LogValues) {
+			queries = append(queries, values.Query)
+		})
+
+		users := make([]user, 10000)
+		for i := range users {
+			users[i] = user{
+				Name: fmt.Sprintf("Cursor User %d", i),
+				Age:  i,
+			}
+		}
+		err = c.InsertBatch(ctx, usersTable, users)
+		tt.AssertNoErr(t, err)
+
+		countFetches := func() (numFetches int) {
+			for _, query := range queries {
+				if strings.HasPrefix(query, "FETCH") {
+					numFetches++
+				}
+			}
+			return numFetches
+		}
+
+		t.Run("should read all rows one chunk at a time", func(t *testing.T) {
+			queries = nil
+
+			var numChunks, numRows, sumAges int
+			var progress []int
+			err := c.QueryChunks(ctx, ChunkParser{
+				Query:  "FROM users WHERE name LIKE " + c.dialect.Placeholder(0) + " ORDER BY id",
+				Params: []interface{}{"Cursor User %"},
+
+				ChunkSize:    1000,
+				ServerCursor: true,
+				ForEachChunk: func(users []user) error {
+					numChunks++
+					numRows += len(users)
+					for _, u := range users {
+						sumAges += u.Age
+					}
+					return nil
+				},
+				OnProgress: func(rowsSoFar int) {
+					progress = append(progress, rowsSoFar)
+				},
+			})
+			tt.AssertNoErr(t, err)
+
+			tt.AssertEqual(t, numChunks, 10)
+			tt.AssertEqual(t, numRows, 10000)
+			tt.AssertEqual(t, sumAges, 10000*9999/2)
+			tt.AssertEqual(t, progress, []int{1000, 2000, 3000, 4000, 5000, 6000, 7000, 8000, 9000, 10000})
+
+			if driver == "postgres" {
+				// The last FETCH returns no rows and ends the iteration:
+				tt.AssertEqual(t, countFetches(), 11)
+			} else {
+				tt.AssertEqual(t, countFetches(), 0)
+			}
+		})
+
+		t.Run("should call ForEachChunk with a partial last chunk", func(t *testing.T) {
+			queries = nil
+
+			var lengths []int
+			var lastUser user
+			err := c.QueryChunks(ctx, ChunkParser{
+				Query:  "FROM users WHERE age < " + c.dialect.Placeholder(0) + " ORDER BY age",
+				Params: []interface{}{250},
+
+				ChunkSize:    100,
+				ServerCursor: true,
+				ForEachChunk: func(users []user) error {
+					lengths = append(lengths, len(users))
+					lastUser = users[len(users)-1]
+					return nil
+				},
+			})
+			tt.AssertNoErr(t, err)
+
+			tt.AssertEqual(t, lengths, []int{100, 100, 50})
+			tt.AssertEqual(t, lastUser.Name, "Cursor User 249")
+		})
+
+		t.Run("should stop fetching when ErrAbortIteration is returned", func(t *testing.T) {
+			queries = nil
+
+			var numChunks int
+			err := c.QueryChunks(ctx, ChunkParser{
+				Query:  "FROM users ORDER BY id",
+				Params: []interface{}{},
+
+				ChunkSize:    100,
+				ServerCursor: true,
+				ForEachChunk: func(users []user) error {
+					numChunks++
+					return ErrAbortIteration
+				},
+			})
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, numChunks, 1)
+
+			if driver == "postgres" {
+				tt.AssertEqual(t, countFetches(), 1)
+			}
+		})
+
+		t.Run("should reuse the current transaction", func(t *testing.T) {
+			queries = nil
+
+			var numRows int
+			err := c.Transaction(ctx, func(p Provider) error {
+				err := p.Insert(ctx, usersTable, &user{Name: "Cursor User in Tx"})
+				if err != nil {
+					return err
+				}
+
+				return p.QueryChunks(ctx, ChunkParser{
+					Query:  "FROM users WHERE name = " + c.dialect.Placeholder(0),
+					Params: []interface{}{"Cursor User in Tx"},
+
+					ChunkSize:    100,
+					ServerCursor: true,
+					ForEachChunk: func(users []user) error {
+						numRows += len(users)
+						return nil
+					},
+				})
+			})
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, numRows, 1)
+		})
+	})
+}
+
+// InsertSliceTest runs all tests for making sure the InsertSlice
+// function is working correctly.
+func InsertSliceTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("InsertSlice", func(t *testing.T) {
+		t.Run("should insert a slice of structs and write back the IDs", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			users := []user{
+				{Name: "Slice User 1", Age: 21},
+				{Name: "Slice User 2", Age: 22, Address: address{Country: "BR"}},
+			}
+			err = c.InsertSlice(ctx, usersTable, users)
+			tt.AssertNoErr(t, err)
+
+			for _, u := range users {
+				tt.AssertNotEqual(t, u.ID, uint(0))
+
+				var dbUser user
+				err = c.QueryOne(ctx, &dbUser, "FROM users WHERE id = "+c.dialect.Placeholder(0), u.ID)
+				tt.AssertNoErr(t, err)
+				tt.AssertEqual(t, dbUser, u)
+			}
+		})
+
+		t.Run("should accept pointers to slices of pointers", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			users := []*user{
+				{Name: "Slice User 1"},
+				{Name: "Slice User 2"},
+			}
+			err = c.InsertSlice(ctx, usersTable, &users)
+			tt.AssertNoErr(t, err)
+
+			tt.AssertNotEqual(t, users[0].ID, uint(0))
+			tt.AssertNotEqual(t, users[1].ID, uint(0))
+			tt.AssertNotEqual(t, users[0].ID, users[1].ID)
+		})
+
+		t.Run("should insert nothing if one of the records fails", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			err = c.InsertSlice(ctx, usersTable, []*user{
+				{Name: "Slice User 1"},
+				nil,
+			})
+			tt.AssertErrContains(t, err, "position 1")
+
+			count, err := c.CountOf(ctx, "FROM users")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, count, int64(0))
+		})
+
+		t.Run("should do nothing for empty slices", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			c := newTestDB(db, driver)
+
+			err := c.InsertSlice(context.Background(), usersTable, []user{})
+			tt.AssertNoErr(t, err)
+		})
+
+		t.Run("should report error for invalid inputs", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			c := newTestDB(db, driver)
+
+			err := c.InsertSlice(context.Background(), usersTable, &user{})
+			tt.AssertErrContains(t, err, "expected records to be a slice of structs")
+
+			err = c.InsertSlice(context.Background(), usersTable, []int{1, 2})
+			tt.AssertErrContains(t, err, "expected records to be a slice of structs")
+		})
+	})
+}
+
+// InsertBatchReturningTest runs all tests for making sure the
+// InsertBatchReturning function is working correctly.
+func InsertBatchReturningTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("InsertBatchReturning", func(t *testing.T) {
+		if driver != "postgres" && driver != "sqlite3" {
+			t.Run("should report an error for drivers without RETURNING", func(t *testing.T) {
+				db, closer := newDBAdapter(t)
+				defer closer.Close()
+
+				c := newTestDB(db, driver)
+
+				err := c.InsertBatchReturning(context.Background(), usersTable, []user{{Name: "User 1"}})
+				tt.AssertErrContains(t, err, "not supported", driver, "RETURNING")
+			})
+			return
+		}
+
+		t.Run("should write back the generated IDs in order", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver).WithBatchSize(10)
+
+			users := make([]user, 25)
+			for i := range users {
+				users[i] = user{
+					Name: fmt.Sprintf("Returning User %d", i),
+					Age:  i,
+				}
+			}
+
+			err = c.InsertBatchReturning(ctx, usersTable, users)
+			tt.AssertNoErr(t, err)
+
+			for i, u := range users {
+				tt.AssertNotEqual(t, u.ID, uint(0))
+				tt.AssertEqual(t, u.Age, i)
+
+				var dbUser user
+				err = c.QueryOne(ctx, &dbUser, "FROM users WHERE id = "+c.dialect.Placeholder(0), u.ID)
+				tt.AssertNoErr(t, err)
+				tt.AssertEqual(t, dbUser.Name, fmt.Sprintf("Returning User %d", i))
+			}
+		})
+
+		t.Run("should work with pointers to slices of pointers", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			users := []*user{
+				{Name: "Returning User 1", Address: address{Country: "BR"}},
+				{Name: "Returning User 2"},
+			}
+			err = c.InsertBatchReturning(ctx, usersTable, &users)
+			tt.AssertNoErr(t, err)
+
+			tt.AssertNotEqual(t, users[0].ID, uint(0))
+			tt.AssertNotEqual(t, users[1].ID, uint(0))
+			tt.AssertNotEqual(t, users[0].ID, users[1].ID)
+			tt.AssertEqual(t, users[0].Address.Country, "BR")
+		})
+	})
+}
+
+// ErrorWrappingTest runs all tests for making sure the errors returned
+// by the driver are wrapped with the operation and table names.
+func ErrorWrappingTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("error wrapping", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		missingTable := NewTable("not_a_table")
+
+		tests := []struct {
+			desc            string
+			fn              func() error
+			expectedMessage string
+		}{
+			{
+				desc: "Insert",
+				fn: func() error {
+					return c.Insert(ctx, missingTable, &user{Name: "User"})
+				},
+				expectedMessage: `ksql: Insert into "not_a_table" failed: `,
+			},
+			{
+				desc: "Patch",
+				fn: func() error {
+					return c.Patch(ctx, missingTable, &user{ID: 1, Name: "User"})
+				},
+				expectedMessage: `ksql: Patch on "not_a_table" failed: `,
+			},
+			{
+				desc: "Delete",
+				fn: func() error {
+					return c.Delete(ctx, missingTable, 1)
+				},
+				expectedMessage: `ksql: Delete from "not_a_table" failed: `,
+			},
+			{
+				desc: "InsertBatch",
+				fn: func() error {
+					return c.InsertBatch(ctx, missingTable, []user{{Name: "User"}})
+				},
+				expectedMessage: `ksql: InsertBatch into "not_a_table" failed: `,
+			},
+			{
+				desc: "Query",
+				fn: func() error {
+					var users []user
+					return c.Query(ctx, &users, "FROM not_a_table")
+				},
+				expectedMessage: "error running query: ",
+			},
+			{
+				desc: "QueryOne",
+				fn: func() error {
+					var u user
+					return c.QueryOne(ctx, &u, "FROM not_a_table")
+				},
+				expectedMessage: "error running query: ",
+			},
+		}
+
+		for _, test := range tests {
+			t.Run("should wrap the driver errors on "+test.desc, func(t *testing.T) {
+				err := test.fn()
+				tt.AssertErrContains(t, err, test.expectedMessage)
+
+				driverErr := errors.Unwrap(err)
+				tt.AssertNotEqual(t, driverErr, nil)
+				tt.AssertEqual(t, err.Error(), test.expectedMessage+driverErr.Error())
+			})
+		}
+	})
+}
+
+// PatchIfChangedTest runs all tests for making sure the PatchIfChanged
+// function is working correctly.
+func PatchIfChangedTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("PatchIfChanged", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		t.Run("should report false when updating a row to its current values", func(t *testing.T) {
+			u := user{
+				Name:    "Unchanged User",
+				Age:     22,
+				Address: address{Country: "BR"},
+			}
+			err := c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+
+			changed, err := c.PatchIfChanged(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, changed, false)
+		})
+
+		t.Run("should report true and update the row when a value changes", func(t *testing.T) {
+			u := user{
+				Name:    "Changed User",
+				Age:     22,
+				Address: address{Country: "BR"},
+			}
+			err := c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+
+			u.Age = 23
+			changed, err := c.PatchIfChanged(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, changed, true)
+
+			var dbUser user
+			err = c.QueryOne(ctx, &dbUser, "FROM users WHERE id = "+c.dialect.Placeholder(0), u.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, dbUser.Age, 23)
+
+			u.Address.Country = "US"
+			changed, err = c.PatchIfChanged(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, changed, true)
+		})
+
+		t.Run("should compare only the non nil attributes", func(t *testing.T) {
+			u := user{Name: "Partial User", Age: 30}
+			err := c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+
+			name := "Partial User"
+			changed, err := c.PatchIfChanged(ctx, usersTable, &struct {
+				ID   uint    `ksql:"id"`
+				Name *string `ksql:"name"`
+				Age  *int    `ksql:"age"`
+			}{ID: u.ID, Name: &name})
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, changed, false)
+		})
+
+		t.Run("should return ErrRecordNotFound if the record doesn't exist", func(t *testing.T) {
+			changed, err := c.PatchIfChanged(ctx, usersTable, &user{ID: 4242, Name: "Missing User"})
+			tt.AssertEqual(t, err, ErrRecordNotFound)
+			tt.AssertEqual(t, changed, false)
+		})
+	})
+}
+
+// InsertStreamTest runs all tests for making sure the InsertStream
+// function is working correctly.
+func InsertStreamTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("InsertStream", func(t *testing.T) {
+		t.Run("should insert all records sent on the channel in batches", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			numExecs := 0
+			c := newTestDB(execCounterAdapter{DBAdapter: db, numExecs: &numExecs}, driver)
+
+			recordsCh := make(chan interface{})
+			go func() {
+				defer close(recordsCh)
+				for i := 0; i < 1050; i++ {
+					recordsCh <- user{
+						Name: fmt.Sprintf("Stream User %d", i),
+						Age:  i,
+					}
+				}
+			}()
+
+			err = c.InsertStream(ctx, usersTable, recordsCh, 100)
+			tt.AssertNoErr(t, err)
+
+			// 10 full batches and a last one with 50 records:
+			tt.AssertEqual(t, numExecs, 11)
+
+			count, err := c.CountOf(ctx, "FROM users WHERE name LIKE "+c.dialect.Placeholder(0), "Stream User %")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, count, int64(1050))
+
+			var u user
+			err = c.QueryOne(ctx, &u, "FROM users WHERE age = "+c.dialect.Placeholder(0), 1049)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, u.Name, "Stream User 1049")
+		})
+
+		t.Run("should stop when the context is canceled", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			c := newTestDB(db, driver)
+
+			recordsCh := make(chan interface{})
+			go func() {
+				recordsCh <- &user{Name: "Stream User 1"}
+				cancel()
+			}()
+
+			err = c.InsertStream(ctx, usersTable, recordsCh, 100)
+			tt.AssertEqual(t, err, context.Canceled)
+
+			count, err := c.CountOf(context.Background(), "FROM users")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, count, int64(0))
+		})
+
+		t.Run("should report error for records of different types", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			c := newTestDB(db, driver)
+
+			recordsCh := make(chan interface{}, 2)
+			recordsCh <- user{Name: "Stream User 1"}
+			recordsCh <- &user{Name: "Stream User 2"}
+			close(recordsCh)
+
+			err := c.InsertStream(context.Background(), usersTable, recordsCh, 100)
+			tt.AssertErrContains(t, err, "expected all records to have type", "ksql.user", "*ksql.user")
+		})
+
+		t.Run("should report error for invalid batch sizes", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			c := newTestDB(db, driver)
+
+			err := c.InsertStream(context.Background(), usersTable, make(chan interface{}), 0)
+			tt.AssertErrContains(t, err, "batch size must be a positive number")
+		})
+	})
+}
+
+// CaseInsensitiveColumnsTest runs all tests for making sure the
+// WithCaseInsensitiveColumns option is working correctly.
+func CaseInsensitiveColumnsTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("WithCaseInsensitiveColumns", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		err = c.Insert(ctx, usersTable, &user{Name: "Case User", Age: 42})
+		tt.AssertNoErr(t, err)
+
+		query := fmt.Sprintf(
+			"SELECT name AS %s, age AS %s FROM users WHERE name = %s",
+			c.dialect.Escape("Name"), c.dialect.Escape("AGE"), c.dialect.Placeholder(0),
+		)
+
+		t.Run("should not match columns with a different case by default", func(t *testing.T) {
+			var u user
+			err := c.QueryOne(ctx, &u, query, "Case User")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, u.Name, "")
+			tt.AssertEqual(t, u.Age, 0)
+		})
+
+		t.Run("should match columns ignoring the case when enabled", func(t *testing.T) {
+			var users []user
+			err := c.WithCaseInsensitiveColumns(true).Query(ctx, &users, query, "Case User")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(users), 1)
+			tt.AssertEqual(t, users[0].Name, "Case User")
+			tt.AssertEqual(t, users[0].Age, 42)
+		})
+
+		t.Run("should prefer exact matches", func(t *testing.T) {
+			var row struct {
+				LowerName string `ksql:"name"`
+				UpperName string `ksql:"Name"`
+			}
+			err := c.WithCaseInsensitiveColumns(true).QueryOne(ctx, &row, query, "Case User")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, row.LowerName, "")
+			tt.AssertEqual(t, row.UpperName, "Case User")
+		})
+	})
+}
+
+// PatchAndReloadTest runs all tests for making sure the PatchAndReload
+// function is working correctly.
+func PatchAndReloadTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("PatchAndReload", func(t *testing.T) {
+		t.Run("should reload the fields modified by triggers", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			err = createUsersAgeTrigger(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test trigger!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			u := user{Name: "Reload User", Age: 20, Address: address{Country: "BR"}}
+			err = c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+
+			u.Name = "Reloaded User"
+			err = c.PatchAndReload(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+
+			// The trigger adds 100 to the age on every update:
+			tt.AssertEqual(t, u.Name, "Reloaded User")
+			tt.AssertEqual(t, u.Age, 120)
+			tt.AssertEqual(t, u.Address.Country, "BR")
+		})
+
+		t.Run("should load the attributes ignored by the partial update", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			u := user{Name: "Reload User", Age: 20}
+			err = c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+
+			name := "Reloaded User"
+			partialUser := struct {
+				ID   uint    `ksql:"id"`
+				Name *string `ksql:"name"`
+				Age  *int    `ksql:"age"`
+			}{ID: u.ID, Name: &name}
+			err = c.PatchAndReload(ctx, usersTable, &partialUser)
+			tt.AssertNoErr(t, err)
+
+			tt.AssertEqual(t, *partialUser.Name, "Reloaded User")
+			tt.AssertNotEqual(t, partialUser.Age, (*int)(nil))
+			tt.AssertEqual(t, *partialUser.Age, 20)
+		})
+
+		t.Run("should bump the updated at column when configured", func(t *testing.T) {
+			// The mysql driver only parses DATETIME values into
+			// time.Time when using the `parseTime=true` option:
+			if driver == "mysql" {
+				return
+			}
+
+			err := createEventsTable(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver).WithUpdatedAtColumn("updated_at")
+
+			type event struct {
+				ID        int        `ksql:"id"`
+				CreatedAt time.Time  `ksql:"created_at"`
+				UpdatedAt *time.Time `ksql:"updated_at"`
+			}
+
+			createdAt := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+			e := event{CreatedAt: createdAt}
+			err = c.Insert(ctx, NewTable("events"), &e)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, e.UpdatedAt, (*time.Time)(nil))
+
+			start := time.Now()
+			err = c.PatchAndReload(ctx, NewTable("events"), &e)
+			tt.AssertNoErr(t, err)
+
+			tt.AssertEqual(t, e.CreatedAt.Equal(createdAt), true)
+			tt.AssertNotEqual(t, e.UpdatedAt, (*time.Time)(nil))
+			// Some databases store the times with less precision:
+			tt.AssertEqual(t, e.UpdatedAt.After(start.Add(-time.Second)), true)
+			tt.AssertEqual(t, e.UpdatedAt.Before(time.Now().Add(time.Second)), true)
+		})
+
+		t.Run("should return ErrRecordNotFound if the record doesn't exist", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			c := newTestDB(db, driver)
+
+			err = c.PatchAndReload(context.Background(), usersTable, &user{ID: 4242, Name: "Missing User"})
+			tt.AssertEqual(t, err, ErrRecordNotFound)
+		})
+
+		t.Run("should report error if the record is not a pointer to struct", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			c := newTestDB(db, driver)
+
+			err := c.PatchAndReload(context.Background(), usersTable, user{ID: 1, Name: "User"})
+			tt.AssertErrContains(t, err, "expected record to be a pointer to struct")
+		})
+	})
+}
+
+type userStatus int
+
+type userRole string
+
+// DefinedTypesTest runs all tests for making sure attributes of defined
+// types based on integers and strings, e.g. enums, are read and written correctly.
+func DefinedTypesTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("defined types", func(t *testing.T) {
+		type userWithEnums struct {
+			ID     uint       `ksql:"id"`
+			Role   userRole   `ksql:"name"`
+			Status userStatus `ksql:"age"`
+		}
+
+		type partialUserWithEnums struct {
+			ID     uint        `ksql:"id"`
+			Role   *userRole   `ksql:"name"`
+			Status *userStatus `ksql:"age"`
+		}
+
+		t.Run("should round-trip int and string backed types", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			u := userWithEnums{Role: "admin", Status: 2}
+			err = c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+
+			var dbUser userWithEnums
+			err = c.QueryOne(ctx, &dbUser, "FROM users WHERE id = "+c.dialect.Placeholder(0), u.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, dbUser, u)
+
+			status := userStatus(3)
+			err = c.Patch(ctx, usersTable, &partialUserWithEnums{ID: u.ID, Status: &status})
+			tt.AssertNoErr(t, err)
+
+			var users []partialUserWithEnums
+			err = c.Query(ctx, &users, "FROM users WHERE name = "+c.dialect.Placeholder(0), userRole("admin"))
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(users), 1)
+			tt.AssertEqual(t, *users[0].Role, userRole("admin"))
+			tt.AssertEqual(t, *users[0].Status, userStatus(3))
+		})
+
+		t.Run("should scan NULL into defined types when using WithNullAsZero", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver).WithNullAsZero(true)
+
+			_, err = db.ExecContext(ctx, `INSERT INTO users (name, age) VALUES ('user', NULL)`)
+			tt.AssertNoErr(t, err)
+
+			u := userWithEnums{Status: 42}
+			err = c.QueryOne(ctx, &u, "FROM users WHERE name = "+c.dialect.Placeholder(0), "user")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, u.Role, userRole("user"))
+			tt.AssertEqual(t, u.Status, userStatus(0))
+		})
+	})
+}
+
+// NamedParamsTest runs all tests for making sure the queries
+// using ksql.Named() params are working correctly.
+func NamedParamsTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("Named params", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		for _, u := range []user{
+			{Name: "Named User 1", Age: 17},
+			{Name: "Named User 2", Age: 18},
+			{Name: "Named User 3", Age: 30},
+			{Name: "Other User", Age: 40},
+		} {
+			err := c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+		}
+
+		type usersFilter struct {
+			NamePattern string `ksql:"name_pattern"`
+			MinAge      int    `ksql:"min_age"`
+			MaxAge      *int   `ksql:"max_age"`
+		}
+
+		t.Run("should bind the attributes of a struct of filters", func(t *testing.T) {
+			maxAge := 35
+			var users []user
+			err := c.Query(ctx, &users,
+				"FROM users WHERE name LIKE @name_pattern AND age >= @min_age AND age <= @max_age ORDER BY age",
+				Named(usersFilter{NamePattern: "Named User %", MinAge: 18, MaxAge: &maxAge}),
+			)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(users), 2)
+			tt.AssertEqual(t, users[0].Name, "Named User 2")
+			tt.AssertEqual(t, users[1].Name, "Named User 3")
+		})
+
+		t.Run("should work with QueryOne and Exec", func(t *testing.T) {
+			var u user
+			err := c.QueryOne(ctx, &u,
+				"FROM users WHERE name LIKE @name_pattern AND age = @min_age",
+				Named(&usersFilter{NamePattern: "Named User %", MinAge: 17}),
+			)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, u.Name, "Named User 1")
+
+			_, err = c.Exec(ctx,
+				"DELETE FROM users WHERE name LIKE @name_pattern AND age < @min_age",
+				Named(usersFilter{NamePattern: "Named User %", MinAge: 18}),
+			)
+			tt.AssertNoErr(t, err)
+
+			count, err := c.CountOf(ctx, "FROM users")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, count, int64(3))
+		})
+	})
+}
+
+// StatsTest runs all tests for making sure the Stats
+// function is working correctly.
+func StatsTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("Stats", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		t.Run("should report the open connections after some activity", func(t *testing.T) {
+			err := c.Insert(ctx, usersTable, &user{Name: "Stats User"})
+			tt.AssertNoErr(t, err)
+
+			var u user
+			err = c.QueryOne(ctx, &u, "FROM users WHERE name = "+c.dialect.Placeholder(0), "Stats User")
+			tt.AssertNoErr(t, err)
+
+			stats, err := c.Stats()
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, stats.OpenConnections >= 1, true)
+			tt.AssertEqual(t, stats.InUse, 0)
+		})
+
+		t.Run("should report error inside transactions", func(t *testing.T) {
+			err := c.Transaction(ctx, func(p Provider) error {
+				_, err := p.(DB).Stats()
+				return err
+			})
+			tt.AssertErrContains(t, err, "StatsGetter")
+		})
+	})
+}
+
+// CountByGroupTest runs all tests for making sure the CountByGroup
+// function is working correctly.
+func CountByGroupTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("CountByGroup", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		for i, name := range []string{"Bia", "Bia", "Bia", "Caio", "Caio", "Dani"} {
+			err := c.Insert(ctx, usersTable, &user{Name: name, Age: i % 2})
+			tt.AssertNoErr(t, err)
+		}
+
+		t.Run("should return the counts indexed by the string keys", func(t *testing.T) {
+			counts, err := c.CountByGroup(ctx, "SELECT name, count(*) FROM users GROUP BY name")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, counts, map[string]int64{
+				"Bia":  3,
+				"Caio": 2,
+				"Dani": 1,
+			})
+		})
+
+		t.Run("should convert non string keys to strings", func(t *testing.T) {
+			counts, err := c.CountByGroup(ctx,
+				"SELECT age, count(*) FROM users WHERE name <> "+c.dialect.Placeholder(0)+" GROUP BY age",
+				"Dani",
+			)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, counts, map[string]int64{
+				"0": 3,
+				"1": 2,
+			})
+		})
+
+		t.Run("should return an empty map if no rows are returned", func(t *testing.T) {
+			counts, err := c.CountByGroup(ctx, "SELECT name, count(*) FROM users WHERE age > 10 GROUP BY name")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, counts, map[string]int64{})
+		})
+
+		t.Run("should report error if the query doesn't return 2 columns", func(t *testing.T) {
+			_, err := c.CountByGroup(ctx, "SELECT name, age, count(*) FROM users GROUP BY name, age")
+			tt.AssertErrContains(t, err, "CountByGroup", "2 columns", "3")
+
+			_, err = c.CountByGroup(ctx, "SELECT count(*) FROM users")
+			tt.AssertErrContains(t, err, "CountByGroup", "2 columns", "1")
+		})
+
+		t.Run("should report error if the count column is not a number", func(t *testing.T) {
+			_, err := c.CountByGroup(ctx, "SELECT age, name FROM users")
+			tt.AssertErrContains(t, err, "CountByGroup")
+		})
+
+		t.Run("should report error if the query is not valid", func(t *testing.T) {
+			_, err := c.CountByGroup(ctx, "SELECT not a valid query")
+			tt.AssertErrContains(t, err, "error running query")
+		})
+	})
+}
+
+// SnakeCaseColumnNamesTest runs all tests for making sure the
+// UseSnakeCaseColumnNames option is working correctly.
+func SnakeCaseColumnNamesTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("UseSnakeCaseColumnNames", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		UseSnakeCaseColumnNames(true)
+		defer UseSnakeCaseColumnNames(false)
+
+		type untaggedUser struct {
+			ID       uint
+			FullName string `ksql:"name"`
+			Age      int
+			Address  address `ksql:",json"`
+			Password string  `ksql:"-"`
+		}
+
+		t.Run("should insert and load structs without tags", func(t *testing.T) {
+			u := untaggedUser{
+				FullName: "Snake Case User",
+				Age:      22,
+				Address:  address{City: "Belo Horizonte"},
+				Password: "not a column",
+			}
+			err := c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+			tt.AssertNotEqual(t, u.ID, uint(0))
+
+			var loaded untaggedUser
+			err = c.QueryOne(ctx, &loaded, "FROM users WHERE id = "+c.dialect.Placeholder(0), u.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, loaded, untaggedUser{
+				ID:       u.ID,
+				FullName: "Snake Case User",
+				Age:      22,
+				Address:  address{City: "Belo Horizonte"},
+			})
+		})
+	})
+}
+
+// ColumnResolverTest runs all tests for making sure the
+// WithColumnResolver option is working correctly.
+func ColumnResolverTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("WithColumnResolver", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver).WithColumnResolver(func(fieldName string) string {
+			return strings.ToLower(strings.TrimPrefix(fieldName, "User"))
+		})
+
+		type resolvedUser struct {
+			UserID   uint
+			UserName string
+			Age      int     `ksql:"age"`
+			Address  address `ksql:",json"`
+		}
+
+		t.Run("should insert and load structs using the resolved names", func(t *testing.T) {
+			u := resolvedUser{
+				UserName: "Resolved User",
+				Age:      22,
+				Address:  address{City: "Belo Horizonte"},
+			}
+			err := c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+			tt.AssertNotEqual(t, u.UserID, uint(0))
+
+			var loaded resolvedUser
+			err = c.QueryOne(ctx, &loaded, "FROM users WHERE id = "+c.dialect.Placeholder(0), u.UserID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, loaded, u)
+		})
+
+		t.Run("should use the resolver of each DB", func(t *testing.T) {
+			type multiUser struct {
+				ID       uint
+				UserName string
+				FullName string
+			}
+
+			newResolver := func(nameField string) func(string) string {
+				return func(fieldName string) string {
+					switch fieldName {
+					case "ID":
+						return "id"
+					case nameField:
+						return "name"
+					}
+					return ""
+				}
+			}
+
+			u := user{Name: "Multi Resolver User"}
+			err := newTestDB(db, driver).Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+
+			byUserName := newTestDB(db, driver).WithColumnResolver(newResolver("UserName"))
+			byFullName := newTestDB(db, driver).WithColumnResolver(newResolver("FullName"))
+
+			// Loading twice with each DB to make sure the cached information is not shared:
+			query := "FROM users WHERE id = " + c.dialect.Placeholder(0)
+			for i := 0; i < 2; i++ {
+				var loaded multiUser
+				err = byUserName.QueryOne(ctx, &loaded, query, u.ID)
+				tt.AssertNoErr(t, err)
+				tt.AssertEqual(t, loaded, multiUser{ID: u.ID, UserName: "Multi Resolver User"})
+
+				loaded = multiUser{}
+				err = byFullName.QueryOne(ctx, &loaded, query, u.ID)
+				tt.AssertNoErr(t, err)
+				tt.AssertEqual(t, loaded, multiUser{ID: u.ID, FullName: "Multi Resolver User"})
+			}
+
+			// DBs without a resolver are not affected:
+			var loaded multiUser
+			err = newTestDB(db, driver).QueryOne(ctx, &loaded, query, u.ID)
+			tt.AssertErrContains(t, err, "ksql tag")
+		})
+	})
+}
+
+// PatchSliceTest runs all tests for making sure the PatchSlice
+// function is working correctly.
+func PatchSliceTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("PatchSlice", func(t *testing.T) {
+		t.Run("should update a slice of pointers to structs", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			users := []*user{
+				{Name: "Patch Slice User 1", Age: 21},
+				{Name: "Patch Slice User 2", Age: 22},
+				{Name: "Patch Slice User 3", Age: 23},
+			}
+			err = c.InsertSlice(ctx, usersTable, users)
+			tt.AssertNoErr(t, err)
+
+			users[0].Name = "Patched User 1"
+			users[1].Age = 32
+			users[1].Address = address{City: "Recife"}
+			err = c.PatchSlice(ctx, usersTable, &users)
+			tt.AssertNoErr(t, err)
+
+			for _, u := range users {
+				var dbUser user
+				err = c.QueryOne(ctx, &dbUser, "FROM users WHERE id = "+c.dialect.Placeholder(0), u.ID)
+				tt.AssertNoErr(t, err)
+				tt.AssertEqual(t, dbUser, *u)
+			}
+		})
+
+		t.Run("should ignore the nil pointer attributes of each record", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			users := []user{
+				{Name: "Partial User 1", Age: 21},
+				{Name: "Partial User 2", Age: 22},
+			}
+			err = c.InsertSlice(ctx, usersTable, users)
+			tt.AssertNoErr(t, err)
+
+			type partialUser struct {
+				ID   uint    `ksql:"id"`
+				Name *string `ksql:"name"`
+				Age  *int    `ksql:"age"`
+			}
+			err = c.PatchSlice(ctx, usersTable, []partialUser{
+				{ID: users[0].ID, Name: nullable.String("New Name 1")},
+				{ID: users[1].ID, Age: nullable.Int(42)},
+			})
+			tt.AssertNoErr(t, err)
+
+			var dbUsers []user
+			err = c.Query(ctx, &dbUsers, "FROM users ORDER BY id")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(dbUsers), 2)
+			tt.AssertEqual(t, dbUsers[0].Name, "New Name 1")
+			tt.AssertEqual(t, dbUsers[0].Age, 21)
+			tt.AssertEqual(t, dbUsers[1].Name, "Partial User 2")
+			tt.AssertEqual(t, dbUsers[1].Age, 42)
+		})
+
+		t.Run("should update nothing if one of the records fails", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			u := user{Name: "Rollback User"}
+			err = c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+
+			err = c.PatchSlice(ctx, usersTable, []user{
+				{ID: u.ID, Name: "Should Not Be Saved"},
+				{ID: u.ID + 100, Name: "Missing User"},
+			})
+			tt.AssertErrContains(t, err, "position 1")
+			tt.AssertEqual(t, errors.Is(err, ErrRecordNotFound), true)
+
+			var dbUser user
+			err = c.QueryOne(ctx, &dbUser, "FROM users WHERE id = "+c.dialect.Placeholder(0), u.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, dbUser.Name, "Rollback User")
+		})
+
+		t.Run("should report error for invalid inputs", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			err := c.PatchSlice(ctx, usersTable, &user{})
+			tt.AssertErrContains(t, err, "slice of structs")
+
+			err = c.PatchSlice(ctx, usersTable, []int{1, 2})
+			tt.AssertErrContains(t, err, "slice of structs")
+
+			err = c.PatchSlice(ctx, usersTable, []user{})
+			tt.AssertNoErr(t, err)
+		})
+	})
+}
+
+// EscapeLikeTest runs all tests for making sure the EscapeLike
+// function works correctly on the database.
+func EscapeLikeTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("EscapeLike", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		for _, name := range []string{"50% off", "50 off", "user_name", "username", "wow!", "wow", "[a]", "a"} {
+			err := c.Insert(ctx, usersTable, &user{Name: name})
+			tt.AssertNoErr(t, err)
+		}
+
+		tests := []struct {
+			input         string
+			expectedNames []string
+		}{
+			{input: "%", expectedNames: []string{"50% off"}},
+			{input: "_", expectedNames: []string{"user_name"}},
+			{input: "!", expectedNames: []string{"wow!"}},
+			{input: "[a]", expectedNames: []string{"[a]"}},
+			{input: "off", expectedNames: []string{"50 off", "50% off"}},
+		}
+		for _, test := range tests {
+			t.Run(test.input, func(t *testing.T) {
+				var users []user
+				err := c.Query(ctx, &users,
+					"FROM users WHERE name LIKE "+c.dialect.Placeholder(0)+" ESCAPE '!' ORDER BY name",
+					"%"+EscapeLike(test.input)+"%",
+				)
+				tt.AssertNoErr(t, err)
+
+				names := []string{}
+				for _, u := range users {
+					names = append(names, u.Name)
+				}
+				tt.AssertEqual(t, names, test.expectedNames)
+			})
+		}
+	})
+}
+
+// DeleteReturningTest runs all tests for making sure the DeleteReturning
+// function is working correctly.
+func DeleteReturningTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("DeleteReturning", func(t *testing.T) {
+		t.Run("should return the ids of the deleted records", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			users := []user{
+				{Name: "Young User 1", Age: 10},
+				{Name: "Adult User", Age: 30},
+				{Name: "Young User 2", Age: 15},
+			}
+			err = c.InsertSlice(ctx, usersTable, users)
+			tt.AssertNoErr(t, err)
+
+			var deleted []struct {
+				ID uint `ksql:"id"`
+			}
+			err = c.DeleteReturning(ctx, usersTable, &deleted, "age < "+c.dialect.Placeholder(0), 18)
+			tt.AssertNoErr(t, err)
+
+			ids := []uint{}
+			for _, d := range deleted {
+				ids = append(ids, d.ID)
+			}
+			sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+			tt.AssertEqual(t, ids, []uint{users[0].ID, users[2].ID})
+
+			var remaining []user
+			err = c.Query(ctx, &remaining, "FROM users")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, remaining, []user{users[1]})
+		})
+
+		t.Run("should load all the tagged columns of the deleted records", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			u := user{Name: "Deleted User", Age: 42, Address: address{City: "Natal"}}
+			err = c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+
+			var deleted []*user
+			err = c.DeleteReturning(ctx, usersTable, &deleted, "name = "+c.dialect.Placeholder(0), "Deleted User")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, deleted, []*user{&u})
+		})
+
+		t.Run("should leave the slice empty if nothing is deleted", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			deleted := []user{{Name: "Stale User"}}
+			err = c.DeleteReturning(ctx, usersTable, &deleted, "age > "+c.dialect.Placeholder(0), 100)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(deleted), 0)
+		})
+
+		t.Run("should apply the scopes of the DB", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			users := []user{
+				{Name: "Scoped User", Age: 10},
+				{Name: "Other User", Age: 11},
+			}
+			err = c.InsertSlice(ctx, usersTable, users)
+			tt.AssertNoErr(t, err)
+
+			var deleted []user
+			err = c.Where("name = ?", "Scoped User").DeleteReturning(ctx, usersTable, &deleted,
+				"age = "+c.dialect.Placeholder(0)+" OR age = "+c.dialect.Placeholder(1), 10, 11,
+			)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, deleted, []user{users[0]})
+
+			count, err := c.CountOf(ctx, "FROM users")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, count, int64(1))
+		})
+
+		t.Run("should report error for invalid inputs", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			var users []user
+			err := c.DeleteReturning(ctx, NewTable(""), &users, "age > 0")
+			tt.AssertErrContains(t, err, "empty string")
+
+			err = c.DeleteReturning(ctx, usersTable, users, "age > 0")
+			tt.AssertErrContains(t, err, "pointer to slice")
+
+			err = c.DeleteReturning(ctx, usersTable, &users, "not a valid condition")
+			tt.AssertErrContains(t, err, "DeleteReturning", "users")
+		})
+	})
+}
+
+// MergeTest runs all tests for making sure the Merge
+// function is working correctly.
+func MergeTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	type account struct {
+		Email string `ksql:"email"`
+		Name  string `ksql:"name"`
+		Score int    `ksql:"score"`
+	}
+	accountsTable := NewTable("accounts", "email")
+
+	t.Run("Merge", func(t *testing.T) {
+		t.Run("should insert the record if there is no conflict", func(t *testing.T) {
+			err := createAccountsTable(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			err = c.Merge(ctx, accountsTable, &account{Email: "new@email.com", Name: "New Account", Score: 10},
+				[]string{"email"}, []string{"name"},
+			)
+			tt.AssertNoErr(t, err)
+
+			var a account
+			err = c.QueryOne(ctx, &a, "FROM accounts WHERE email = "+c.dialect.Placeholder(0), "new@email.com")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, a, account{Email: "new@email.com", Name: "New Account", Score: 10})
+		})
+
+		t.Run("should only update the update columns on conflict", func(t *testing.T) {
+			err := createAccountsTable(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			err = c.Insert(ctx, accountsTable, &account{Email: "old@email.com", Name: "Old Name", Score: 10})
+			tt.AssertNoErr(t, err)
+			err = c.Insert(ctx, accountsTable, &account{Email: "other@email.com", Name: "Other Name", Score: 20})
+			tt.AssertNoErr(t, err)
+
+			err = c.Merge(ctx, accountsTable, &account{Email: "old@email.com", Name: "New Name", Score: 99},
+				[]string{"email"}, []string{"name"},
+			)
+			tt.AssertNoErr(t, err)
+
+			var accounts []account
+			err = c.Query(ctx, &accounts, "FROM accounts ORDER BY email")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, accounts, []account{
+				{Email: "old@email.com", Name: "New Name", Score: 10},
+				{Email: "other@email.com", Name: "Other Name", Score: 20},
+			})
+		})
+
+		t.Run("should report error for invalid inputs", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			err := c.Merge(ctx, accountsTable, account{Email: "a@email.com"}, []string{"email"}, []string{"name"})
+			tt.AssertErrContains(t, err, "pointer to struct")
+
+			err = c.Merge(ctx, accountsTable, &account{Email: "a@email.com"}, []string{"email"}, []string{"not_tagged"})
+			tt.AssertErrContains(t, err, "not_tagged")
+
+			err = c.Merge(ctx, accountsTable, &account{Email: "a@email.com"}, []string{"email"}, []string{})
+			tt.AssertErrContains(t, err, "update column")
+
+			type partialAccount struct {
+				Email string  `ksql:"email"`
+				Name  *string `ksql:"name"`
+			}
+			err = c.Merge(ctx, accountsTable, &partialAccount{Email: "a@email.com"}, []string{"email"}, []string{"name"})
+			tt.AssertErrContains(t, err, "update column", "name", "must be set")
+		})
+	})
+}
+
+// LoadChildrenTest runs all tests for making sure the LoadChildren
+// function is working correctly.
+func LoadChildrenTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	type userWithPosts struct {
+		ID    uint   `ksql:"id"`
+		Name  string `ksql:"name"`
+		Posts []post
+	}
+
+	t.Run("LoadChildren", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		var ids []uint
+		for _, name := range []string{"Ana Children", "Beto Children", "Caio Children"} {
+			u := user{Name: name}
+			tt.AssertNoErr(t, c.Insert(ctx, usersTable, &u))
+			ids = append(ids, u.ID)
+		}
+
+		for _, p := range []post{
+			{UserID: ids[0], Title: "Ana Post1"},
+			{UserID: ids[0], Title: "Ana Post2"},
+			{UserID: ids[1], Title: "Beto Post1"},
+		} {
+			tt.AssertNoErr(t, c.Insert(ctx, postsTable, &p))
+		}
+
+		t.Run("should assign the children of each parent in a single query", func(t *testing.T) {
+			var parents []userWithPosts
+			err := c.Query(ctx, &parents, "FROM users ORDER BY id")
+			tt.AssertNoErr(t, err)
+
+			var queries []string
+			c := c
+			c.db = mockDBAdapter{
+				ExecContextFn: db.ExecContext,
+				QueryContextFn: func(ctx context.Context, query string, params ...interface{}) (Rows, error) {
+					queries = append(queries, query)
+					return db.QueryContext(ctx, query, params...)
+				},
+			}
+
+			err = c.LoadChildren(ctx, postsTable, parents, "Posts", "user_id", "id")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(queries), 1)
+
+			tt.AssertEqual(t, len(parents), 3)
+			tt.AssertEqual(t, getPostTitles(parents[0].Posts), []string{"Ana Post1", "Ana Post2"})
+			tt.AssertEqual(t, getPostTitles(parents[1].Posts), []string{"Beto Post1"})
+			tt.AssertEqual(t, parents[2].Posts, []post(nil))
+		})
+
+		t.Run("should work with pointers to slices of pointers", func(t *testing.T) {
+			type userWithPostPtrs struct {
+				ID    uint    `ksql:"id"`
+				Posts []*post `ksql:"-"`
+			}
+
+			parents := []*userWithPostPtrs{
+				{ID: ids[1], Posts: []*post{{Title: "Stale Post"}}},
+				nil,
+				{ID: ids[2], Posts: []*post{{Title: "Stale Post"}}},
+			}
+			err := c.LoadChildren(ctx, postsTable, &parents, "Posts", "user_id", "id")
+			tt.AssertNoErr(t, err)
+
+			tt.AssertEqual(t, len(parents[0].Posts), 1)
+			tt.AssertEqual(t, parents[0].Posts[0].Title, "Beto Post1")
+			tt.AssertEqual(t, parents[0].Posts[0].UserID, ids[1])
+			tt.AssertEqual(t, parents[2].Posts, []*post(nil))
+		})
+
+		t.Run("should report error for invalid children fields", func(t *testing.T) {
+			parents := []userWithPosts{{ID: ids[0]}}
+
+			err := c.LoadChildren(ctx, postsTable, parents, "Comments", "user_id", "id")
+			tt.AssertErrContains(t, err, "Comments", "not an exported attribute")
+
+			err = c.LoadChildren(ctx, postsTable, parents, "Name", "user_id", "id")
+			tt.AssertErrContains(t, err, "Name", "must not be tagged")
+
+			err = c.LoadChildren(ctx, postsTable, []struct {
+				ID    uint `ksql:"id"`
+				Posts post
+			}{{ID: ids[0]}}, "Posts", "user_id", "id")
+			tt.AssertErrContains(t, err, "Posts", "slice of structs")
+
+			err = c.LoadChildren(ctx, postsTable, userWithPosts{ID: ids[0]}, "Posts", "user_id", "id")
+			tt.AssertErrContains(t, err, "slice of structs")
+
+			err = c.LoadChildren(ctx, postsTable, parents, "Posts", "user_id", "not_a_key")
+			tt.AssertErrContains(t, err, "not_a_key")
+		})
+	})
+}
+
+// StrictColumnsTest runs all tests for making sure the
+// WithStrictColumns option is working correctly.
+func StrictColumnsTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("WithStrictColumns", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		u := user{Name: "Strict User", Age: 22}
+		err = c.Insert(ctx, usersTable, &u)
+		tt.AssertNoErr(t, err)
+
+		type userName struct {
+			ID   uint   `ksql:"id"`
+			Name string `ksql:"name"`
+		}
+
+		t.Run("should ignore unmapped columns by default", func(t *testing.T) {
+			var users []userName
+			err := c.Query(ctx, &users, "SELECT id, name, age AS user_age FROM users")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, users, []userName{{ID: u.ID, Name: "Strict User"}})
+		})
+
+		t.Run("should report all the unmapped columns when enabled", func(t *testing.T) {
+			strictDB := c.WithStrictColumns(true)
+
+			var users []userName
+			err := strictDB.Query(ctx, &users, "SELECT id, name AS nmae, age AS user_age FROM users")
+			tt.AssertErrContains(t, err, "ksql: unmapped columns: [nmae user_age]")
+
+			var row userName
+			err = strictDB.QueryOne(ctx, &row, "SELECT id, name, age AS user_age FROM users")
+			tt.AssertErrContains(t, err, "ksql: unmapped columns: [user_age]")
+		})
+
+		t.Run("should work normally if all the columns are mapped", func(t *testing.T) {
+			var row userName
+			err := c.WithStrictColumns(true).QueryOne(ctx, &row, "FROM users WHERE id = "+c.dialect.Placeholder(0), u.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, row, userName{ID: u.ID, Name: "Strict User"})
+		})
+	})
+}
+
+// QueryOneIntoTest runs all tests for making sure the QueryOneInto
+// function is working correctly.
+func QueryOneIntoTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("QueryOneInto", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		author := user{Name: "Into Author", Age: 30}
+		err = c.Insert(ctx, usersTable, &author)
+		tt.AssertNoErr(t, err)
+
+		p := post{UserID: author.ID, Title: "Into Post"}
+		err = c.Insert(ctx, postsTable, &p)
+		tt.AssertNoErr(t, err)
+
+		t.Run("should split the columns of a JOIN between the records", func(t *testing.T) {
+			var u user
+			var loadedPost post
+			err := c.QueryOneInto(ctx, []interface{}{&u, &loadedPost},
+				"SELECT u.id, u.name, u.age, p.id, p.user_id, p.title FROM users u JOIN posts p ON p.user_id = u.id WHERE p.id = "+c.dialect.Placeholder(0),
+				p.ID,
+			)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, u, user{ID: author.ID, Name: "Into Author", Age: 30})
+			tt.AssertEqual(t, loadedPost, p)
+		})
+
+		t.Run("should use the aliases for matching the columns", func(t *testing.T) {
+			type postTitle struct {
+				ID    int    `ksql:"post_id"`
+				Title string `ksql:"title"`
+			}
+
+			var u user
+			var title postTitle
+			err := c.QueryOneInto(ctx, []interface{}{&u, &title},
+				"SELECT u.id, u.name, p.id AS post_id, p.title FROM users u JOIN posts p ON p.user_id = u.id WHERE p.id = "+c.dialect.Placeholder(0),
+				p.ID,
+			)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, u, user{ID: author.ID, Name: "Into Author"})
+			tt.AssertEqual(t, title, postTitle{ID: p.ID, Title: "Into Post"})
+		})
+
+		t.Run("should return ErrRecordNotFound if there are no rows", func(t *testing.T) {
+			var u user
+			var loadedPost post
+			err := c.QueryOneInto(ctx, []interface{}{&u, &loadedPost},
+				"SELECT u.id, p.id FROM users u JOIN posts p ON p.user_id = u.id WHERE p.id = "+c.dialect.Placeholder(0),
+				p.ID+100,
+			)
+			tt.AssertEqual(t, err, ErrRecordNotFound)
+		})
+
+		t.Run("should report error for invalid inputs", func(t *testing.T) {
+			var u user
+			err := c.QueryOneInto(ctx, []interface{}{}, "SELECT id FROM users")
+			tt.AssertErrContains(t, err, "at least one record")
+
+			err = c.QueryOneInto(ctx, []interface{}{&u, post{}}, "SELECT id FROM users")
+			tt.AssertErrContains(t, err, "position 1", "pointer to struct")
+
+			err = c.QueryOneInto(ctx, []interface{}{&u, nil}, "SELECT id FROM users")
+			tt.AssertErrContains(t, err, "position 1", "pointer to struct")
+
+			err = c.QueryOneInto(ctx, []interface{}{&u}, "FROM users")
+			tt.AssertErrContains(t, err, "SELECT")
+
+			err = c.Where("age > ?", 0).QueryOneInto(ctx, []interface{}{&u}, "SELECT id FROM users")
+			tt.AssertErrContains(t, err, "scopes")
+		})
+	})
+}
+
+// ReadOnlyColumnsTest runs all tests for making sure the
+// `readonly` tag option is working correctly.
+func ReadOnlyColumnsTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("ReadOnlyColumns", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		// The age column simulates a column filled by the database:
+		type userWithReadOnlyAge struct {
+			ID   uint   `ksql:"id"`
+			Name string `ksql:"name"`
+			Age  int    `ksql:"age,readonly"`
+		}
+
+		t.Run("should not insert readonly columns", func(t *testing.T) {
+			u := userWithReadOnlyAge{Name: "ReadOnly Insert", Age: 42}
+			err := c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+			tt.AssertNotEqual(t, u.ID, uint(0))
+
+			var dbUser struct {
+				Name string `ksql:"name"`
+				Age  *int   `ksql:"age"`
+			}
+			err = c.QueryOne(ctx, &dbUser, "FROM users WHERE id = "+c.dialect.Placeholder(0), u.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, dbUser.Name, "ReadOnly Insert")
+			tt.AssertEqual(t, dbUser.Age, (*int)(nil))
+		})
+
+		t.Run("should not update readonly columns but still read them", func(t *testing.T) {
+			u := user{Name: "ReadOnly Patch", Age: 22}
+			err := c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+
+			err = c.Patch(ctx, usersTable, &userWithReadOnlyAge{ID: u.ID, Name: "Patched Name", Age: 99})
+			tt.AssertNoErr(t, err)
+
+			var dbUser userWithReadOnlyAge
+			err = c.QueryOne(ctx, &dbUser, "FROM users WHERE id = "+c.dialect.Placeholder(0), u.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, dbUser, userWithReadOnlyAge{ID: u.ID, Name: "Patched Name", Age: 22})
+		})
+
+		t.Run("should not insert readonly columns on batches", func(t *testing.T) {
+			err := c.InsertBatch(ctx, usersTable, []userWithReadOnlyAge{
+				{Name: "ReadOnly Batch", Age: 42},
+			})
+			tt.AssertNoErr(t, err)
+
+			var dbUser struct {
+				Age *int `ksql:"age"`
+			}
+			err = c.QueryOne(ctx, &dbUser, "FROM users WHERE name = "+c.dialect.Placeholder(0), "ReadOnly Batch")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, dbUser.Age, (*int)(nil))
+		})
+	})
+}
+
+// QueryMapIterTest runs all tests for making sure the QueryMapIter
+// function is working for each of the supported drivers.
+func QueryMapIterTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("QueryMapIter", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		for i := 0; i < 10; i++ {
+			err := c.Insert(ctx, usersTable, &user{Name: fmt.Sprintf("Iter User %d", i), Age: i})
+			tt.AssertNoErr(t, err)
+		}
+
+		t.Run("should iterate over all the rows", func(t *testing.T) {
+			it, err := c.QueryMapIter(ctx, "SELECT name, age FROM users ORDER BY id")
+			tt.AssertNoErr(t, err)
+			defer it.Close()
+
+			count := 0
+			for row, ok := it.Next(); ok; row, ok = it.Next() {
+				tt.AssertEqual(t, len(row), 2)
+				// The string columns are returned as []byte on mysql:
+				tt.AssertEqual(t, fmt.Sprintf("%s", row["name"]), fmt.Sprintf("Iter User %d", count))
+				count++
+			}
+			tt.AssertNoErr(t, it.Err())
+			tt.AssertEqual(t, count, 10)
+
+			_, ok := it.Next()
+			tt.AssertEqual(t, ok, false)
+			tt.AssertNoErr(t, it.Close())
+		})
+
+		t.Run("should work with queries without results", func(t *testing.T) {
+			it, err := c.QueryMapIter(ctx, "SELECT name FROM users WHERE age > "+c.dialect.Placeholder(0), 100)
+			tt.AssertNoErr(t, err)
+			defer it.Close()
+
+			_, ok := it.Next()
+			tt.AssertEqual(t, ok, false)
+			tt.AssertNoErr(t, it.Err())
+		})
+
+		t.Run("should allow the iteration to be interrupted", func(t *testing.T) {
+			it, err := c.QueryMapIter(ctx, "SELECT name FROM users")
+			tt.AssertNoErr(t, err)
+
+			_, ok := it.Next()
+			tt.AssertEqual(t, ok, true)
+			tt.AssertNoErr(t, it.Close())
+
+			_, ok = it.Next()
+			tt.AssertEqual(t, ok, false)
+			tt.AssertNoErr(t, it.Err())
+		})
+
+		t.Run("should report errors", func(t *testing.T) {
+			it, err := c.QueryMapIter(ctx, "SELECT not_a_column FROM users")
+			if err == nil {
+				// Some drivers only report the error when reading the rows:
+				defer it.Close()
+				_, ok := it.Next()
+				tt.AssertEqual(t, ok, false)
+				err = it.Err()
+			}
+			tt.AssertNotEqual(t, err, nil)
+		})
+	})
+}
+
+// InsertIfNotExistsTest runs all tests for making sure the InsertIfNotExists
+// function is working for each of the supported drivers.
+func InsertIfNotExistsTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("InsertIfNotExists", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		t.Run("should insert only if no row matches the condition", func(t *testing.T) {
+			u := user{Name: "Unique Name", Age: 22}
+			inserted, err := c.InsertIfNotExists(ctx, usersTable, &u, "name = "+c.dialect.Placeholder(0), u.Name)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, inserted, true)
+
+			inserted, err = c.InsertIfNotExists(ctx, usersTable, &user{Name: "Unique Name", Age: 42}, "name = "+c.dialect.Placeholder(0), u.Name)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, inserted, false)
+
+			var users []user
+			err = c.Query(ctx, &users, "FROM users WHERE name = "+c.dialect.Placeholder(0), u.Name)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(users), 1)
+			tt.AssertEqual(t, users[0].Age, 22)
+		})
+
+		t.Run("should work with conditions with several params", func(t *testing.T) {
+			condition := "name = " + c.dialect.Placeholder(0) + " AND age = " + c.dialect.Placeholder(1)
+
+			inserted, err := c.InsertIfNotExists(ctx, usersTable, &user{Name: "Multi Param", Age: 30}, condition, "Multi Param", 30)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, inserted, true)
+
+			inserted, err = c.InsertIfNotExists(ctx, usersTable, &user{Name: "Multi Param", Age: 31}, condition, "Multi Param", 31)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, inserted, true)
+
+			inserted, err = c.InsertIfNotExists(ctx, usersTable, &user{Name: "Multi Param", Age: 30}, condition, "Multi Param", 30)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, inserted, false)
+
+			var users []user
+			err = c.Query(ctx, &users, "FROM users WHERE name = "+c.dialect.Placeholder(0), "Multi Param")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(users), 2)
+		})
+
+		t.Run("should report invalid inputs", func(t *testing.T) {
+			_, err := c.InsertIfNotExists(ctx, usersTable, user{Name: "Not a Pointer"}, "name = 'foo'")
+			tt.AssertErrContains(t, err, "pointer to struct")
+
+			_, err = c.InsertIfNotExists(ctx, usersTable, &user{Name: "Empty Condition"}, "")
+			tt.AssertErrContains(t, err, "condition")
+		})
+	})
+}
+
+// CustomIDFieldTest runs all tests for making sure the IDs are written
+// back to the attributes tagged as ID regardless of their names.
+func CustomIDFieldTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("CustomIDField", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		t.Run("should write the ID back to attributes not named ID", func(t *testing.T) {
+			type userWithCustomIDField struct {
+				Name   string `ksql:"name"`
+				UserID uint   `ksql:"id"`
+			}
+
+			u := userWithCustomIDField{Name: "Custom ID Field"}
+			err := c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+			tt.AssertNotEqual(t, u.UserID, uint(0))
+
+			var dbUser userWithCustomIDField
+			err = c.QueryOne(ctx, &dbUser, "FROM users WHERE id = "+c.dialect.Placeholder(0), u.UserID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, dbUser, u)
+		})
+
+		t.Run("should not write the ID to other attributes if the ID is not tagged", func(t *testing.T) {
+			type userWithoutID struct {
+				Age  int    `ksql:"age"`
+				Name string `ksql:"name"`
+			}
+
+			u := userWithoutID{Age: 22, Name: "Untagged ID"}
+			err := c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, u, userWithoutID{Age: 22, Name: "Untagged ID"})
+
+			var dbUser user
+			err = c.QueryOne(ctx, &dbUser, "FROM users WHERE name = "+c.dialect.Placeholder(0), "Untagged ID")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, dbUser.Age, 22)
+		})
+	})
+}
+
+// PluckTest runs all tests for making sure the Pluck and
+// PluckDistinct functions are working for each of the supported drivers.
+func PluckTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("Pluck", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		var insertedIDs []int
+		for i, name := range []string{"Bia", "Bia", "Caio", "Dani"} {
+			u := user{Name: name, Age: 20 + i}
+			err := c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+			insertedIDs = append(insertedIDs, int(u.ID))
+		}
+
+		t.Run("should pluck the ids into a slice of ints", func(t *testing.T) {
+			var ids []int
+			err := c.Pluck(ctx, &ids, "id", "FROM users ORDER BY id")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, ids, insertedIDs)
+		})
+
+		t.Run("should read the column by name from queries with a SELECT", func(t *testing.T) {
+			var names []string
+			err := c.Pluck(ctx, &names, "name",
+				"SELECT id, name, age FROM users WHERE age > "+c.dialect.Placeholder(0)+" ORDER BY id", 20,
+			)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, names, []string{"Bia", "Caio", "Dani"})
+		})
+
+		t.Run("should return each value only once with PluckDistinct", func(t *testing.T) {
+			var names []string
+			err := c.PluckDistinct(ctx, &names, "name", "FROM users")
+			tt.AssertNoErr(t, err)
+			sort.Strings(names)
+			tt.AssertEqual(t, names, []string{"Bia", "Caio", "Dani"})
+
+			names = nil
+			err = c.PluckDistinct(ctx, &names, "name", "SELECT id, name FROM users")
+			tt.AssertNoErr(t, err)
+			sort.Strings(names)
+			tt.AssertEqual(t, names, []string{"Bia", "Caio", "Dani"})
+		})
+
+		t.Run("should support NULL values on slices of pointers", func(t *testing.T) {
+			_, err := c.Exec(ctx, "UPDATE users SET age = NULL WHERE name = "+c.dialect.Placeholder(0), "Dani")
+			tt.AssertNoErr(t, err)
+
+			var ages []*int
+			err = c.Pluck(ctx, &ages, "age", "FROM users WHERE name = "+c.dialect.Placeholder(0), "Dani")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, ages, []*int{nil})
+		})
+
+		t.Run("should replace the previous contents of the slice", func(t *testing.T) {
+			ids := []int{42, 43}
+			err := c.Pluck(ctx, &ids, "id", "FROM users WHERE name = "+c.dialect.Placeholder(0), "not a name")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, ids, []int{})
+		})
+
+		t.Run("should report invalid inputs", func(t *testing.T) {
+			var ids []int
+			err := c.Pluck(ctx, ids, "id", "FROM users")
+			tt.AssertErrContains(t, err, "pointer to slice")
+
+			var users []user
+			err = c.Pluck(ctx, &users, "id", "FROM users")
+			tt.AssertErrContains(t, err, "pointer to slice of values")
+
+			err = c.Pluck(ctx, &ids, "id; DROP TABLE users", "FROM users")
+			tt.AssertErrContains(t, err, "invalid column")
+
+			err = c.Pluck(ctx, &ids, "id", "SELECT name FROM users")
+			tt.AssertErrContains(t, err, "id", "not returned by the query")
+		})
+	})
+}
+
+// MergeReturningInsertedTest runs all tests for making sure the MergeReturningInserted
+// function is working for each of the supported drivers.
+func MergeReturningInsertedTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	type account struct {
+		Email string  `ksql:"email"`
+		Name  *string `ksql:"name"`
+		Score int     `ksql:"score"`
+	}
+	accountsTable := NewTable("accounts", "email")
+
+	t.Run("MergeReturningInserted", func(t *testing.T) {
+		err := createAccountsTable(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		t.Run("should report if the record was inserted or updated", func(t *testing.T) {
+			inserted, err := c.MergeReturningInserted(ctx, accountsTable,
+				&account{Email: "merge@email.com", Name: nullable.String("First Name"), Score: 10},
+				[]string{"email"}, []string{"name"},
+			)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, inserted, true)
+
+			inserted, err = c.MergeReturningInserted(ctx, accountsTable,
+				&account{Email: "merge@email.com", Name: nullable.String("Second Name"), Score: 20},
+				[]string{"email"}, []string{"name"},
+			)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, inserted, false)
+
+			inserted, err = c.MergeReturningInserted(ctx, accountsTable,
+				&account{Email: "other@email.com", Name: nullable.String("Other Name"), Score: 30},
+				[]string{"email"}, []string{"name"},
+			)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, inserted, true)
+
+			var accounts []account
+			err = c.Query(ctx, &accounts, "FROM accounts ORDER BY email")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, accounts, []account{
+				{Email: "merge@email.com", Name: nullable.String("Second Name"), Score: 10},
+				{Email: "other@email.com", Name: nullable.String("Other Name"), Score: 30},
+			})
+		})
+
+		t.Run("should report invalid inputs", func(t *testing.T) {
+			_, err := c.MergeReturningInserted(ctx, accountsTable,
+				account{Email: "merge@email.com"},
+				[]string{"email"}, []string{"name"},
+			)
+			tt.AssertErrContains(t, err, "pointer to struct")
+
+			_, err = c.MergeReturningInserted(ctx, accountsTable,
+				&account{Email: "merge@email.com"},
+				[]string{"email"}, nil,
+			)
+			tt.AssertErrContains(t, err, "update column")
+		})
+	})
+}
+
+// TransactionRollbackTest runs all tests for making sure the TransactionRollback
+// function is working for each of the supported drivers.
+func TransactionRollbackTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("TransactionRollback", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		t.Run("should make the writes invisible after the call", func(t *testing.T) {
+			existing := user{Name: "Existing User", Age: 22}
+			err := c.Insert(ctx, usersTable, &existing)
+			tt.AssertNoErr(t, err)
+
+			err = c.TransactionRollback(ctx, func(p Provider) error {
+				err := p.Insert(ctx, usersTable, &user{Name: "Rolled Back User"})
+				tt.AssertNoErr(t, err)
+
+				err = p.Patch(ctx, usersTable, &user{ID: existing.ID, Name: "Rolled Back Name", Age: 42})
+				tt.AssertNoErr(t, err)
+
+				// The writes are visible inside the transaction:
+				var users []user
+				err = p.Query(ctx, &users, "FROM users ORDER BY id")
+				tt.AssertNoErr(t, err)
+				tt.AssertEqual(t, len(users), 2)
+				tt.AssertEqual(t, users[0].Name, "Rolled Back Name")
+				return nil
+			})
+			tt.AssertNoErr(t, err)
+
+			var users []user
+			err = c.Query(ctx, &users, "FROM users ORDER BY id")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(users), 1)
+			tt.AssertEqual(t, users[0].Name, "Existing User")
+			tt.AssertEqual(t, users[0].Age, 22)
+		})
+
+		t.Run("should return the error of the function", func(t *testing.T) {
+			err := c.TransactionRollback(ctx, func(p Provider) error {
+				err := p.Insert(ctx, usersTable, &user{Name: "Failed User"})
+				tt.AssertNoErr(t, err)
+				return fmt.Errorf("fake error")
+			})
+			tt.AssertErrContains(t, err, "fake error")
+
+			var users []user
+			err = c.Query(ctx, &users, "FROM users WHERE name = "+c.dialect.Placeholder(0), "Failed User")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(users), 0)
+		})
+
+		t.Run("should not be allowed inside other transactions", func(t *testing.T) {
+			err := c.Transaction(ctx, func(p Provider) error {
+				return p.(DB).TransactionRollback(ctx, func(p Provider) error {
+					return nil
+				})
+			})
+			tt.AssertErrContains(t, err, "TransactionRollback", "inside a transaction")
+		})
+	})
+}
+
+// DeleteCascadeTest runs all tests for making sure the DeleteCascade
+// function is working for each of the supported drivers.
+func DeleteCascadeTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("DeleteCascade", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		children := []CascadeChild{
+			{Table: postsTable, FKColumn: "user_id"},
+			{Table: userPermissionsTable, FKColumn: "user_id"},
+		}
+
+		t.Run("should delete the parent and its children", func(t *testing.T) {
+			parent := user{Name: "Cascade Parent"}
+			tt.AssertNoErr(t, c.Insert(ctx, usersTable, &parent))
+			other := user{Name: "Cascade Other"}
+			tt.AssertNoErr(t, c.Insert(ctx, usersTable, &other))
+
+			tt.AssertNoErr(t, c.Insert(ctx, postsTable, &post{UserID: parent.ID, Title: "Parent Post1"}))
+			tt.AssertNoErr(t, c.Insert(ctx, postsTable, &post{UserID: parent.ID, Title: "Parent Post2"}))
+			tt.AssertNoErr(t, c.Insert(ctx, postsTable, &post{UserID: other.ID, Title: "Other Post"}))
+			tt.AssertNoErr(t, c.Insert(ctx, NewTable("user_permissions", "id"), &userPermission{UserID: int(parent.ID), PermID: 1}))
+			tt.AssertNoErr(t, c.Insert(ctx, NewTable("user_permissions", "id"), &userPermission{UserID: int(other.ID), PermID: 1}))
+
+			err := c.DeleteCascade(ctx, usersTable, parent.ID, children)
+			tt.AssertNoErr(t, err)
+
+			var users []user
+			err = c.Query(ctx, &users, "FROM users ORDER BY id")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(users), 1)
+			tt.AssertEqual(t, users[0].ID, other.ID)
+
+			var posts []post
+			err = c.Query(ctx, &posts, "FROM posts")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(posts), 1)
+			tt.AssertEqual(t, posts[0].Title, "Other Post")
+
+			var permissions []userPermission
+			err = c.Query(ctx, &permissions, "FROM user_permissions")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(permissions), 1)
+			tt.AssertEqual(t, permissions[0].UserID, int(other.ID))
+		})
+
+		t.Run("should not delete the children if the parent is not found", func(t *testing.T) {
+			tt.AssertNoErr(t, c.Insert(ctx, postsTable, &post{UserID: 4242, Title: "Orphan Post"}))
+
+			err := c.DeleteCascade(ctx, usersTable, 4242, children)
+			tt.AssertEqual(t, err, ErrRecordNotFound)
+
+			var posts []post
+			err = c.Query(ctx, &posts, "FROM posts WHERE user_id = "+c.dialect.Placeholder(0), 4242)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(posts), 1)
+		})
+
+		t.Run("should report invalid inputs", func(t *testing.T) {
+			err := c.DeleteCascade(ctx, userPermissionsTable, map[string]interface{}{"user_id": 1, "perm_id": 1}, nil)
+			tt.AssertErrContains(t, err, "single ID column")
+
+			err = c.DeleteCascade(ctx, usersTable, 1, []CascadeChild{
+				{Table: postsTable, FKColumn: "user_id; DROP TABLE users"},
+			})
+			tt.AssertErrContains(t, err, "invalid foreign key column", "posts")
+
+			err = c.DeleteCascade(ctx, usersTable, 1, []CascadeChild{
+				{Table: NewTable(""), FKColumn: "user_id"},
+			})
+			tt.AssertErrContains(t, err, "empty string")
+		})
+	})
+}
+
+// RetryOnConnectionLossTest runs all tests for making sure the
+// WithRetryOnConnectionLoss option is working for each of the supported drivers.
+func RetryOnConnectionLossTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("RetryOnConnectionLoss", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		tt.AssertNoErr(t, c.Insert(ctx, usersTable, &user{Name: "Retry User"}))
+
+		droppedConnErr := fmt.Errorf("write tcp 127.0.0.1:5432: %w", syscall.EPIPE)
+
+		// newDroppingDB returns a DB whose first query
+		// fails as if the connection was dropped:
+		newDroppingDB := func(firstErr error) (DB, *int) {
+			var numQueries int
+			c := c.WithRetryOnConnectionLoss(true)
+			c.db = mockDBAdapter{
+				ExecContextFn: func(ctx context.Context, query string, params ...interface{}) (Result, error) {
+					numQueries++
+					if numQueries == 1 {
+						return nil, firstErr
+					}
+					return db.ExecContext(ctx, query, params...)
+				},
+				QueryContextFn: func(ctx context.Context, query string, params ...interface{}) (Rows, error) {
+					numQueries++
+					if numQueries == 1 {
+						return nil, firstErr
+					}
+					return db.QueryContext(ctx, query, params...)
+				},
+			}
+			return c, &numQueries
+		}
+
+		t.Run("should retry reads once after a dropped connection", func(t *testing.T) {
+			c, numQueries := newDroppingDB(droppedConnErr)
+
+			var u user
+			err := c.QueryOne(ctx, &u, "FROM users WHERE name = "+c.dialect.Placeholder(0), "Retry User")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, u.Name, "Retry User")
+			tt.AssertEqual(t, *numQueries, 2)
+
+			c, numQueries = newDroppingDB(droppedConnErr)
+			var users []user
+			err = c.Query(ctx, &users, "SELECT * FROM users")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(users), 1)
+			tt.AssertEqual(t, *numQueries, 2)
+		})
+
+		t.Run("should not retry if the option is disabled", func(t *testing.T) {
+			c, numQueries := newDroppingDB(droppedConnErr)
+			c = c.WithRetryOnConnectionLoss(false)
+
+			var u user
+			err := c.QueryOne(ctx, &u, "FROM users WHERE name = "+c.dialect.Placeholder(0), "Retry User")
+			tt.AssertErrContains(t, err, "broken pipe")
+			tt.AssertEqual(t, *numQueries, 1)
+		})
+
+		t.Run("should not retry application errors", func(t *testing.T) {
+			c, numQueries := newDroppingDB(fmt.Errorf("fake syntax error"))
+
+			var u user
+			err := c.QueryOne(ctx, &u, "FROM users WHERE name = "+c.dialect.Placeholder(0), "Retry User")
+			tt.AssertErrContains(t, err, "fake syntax error")
+			tt.AssertEqual(t, *numQueries, 1)
+		})
+
+		t.Run("should not retry writes", func(t *testing.T) {
+			c, numQueries := newDroppingDB(droppedConnErr)
+			err := c.Insert(ctx, usersTable, &user{Name: "Not Retried User"})
+			tt.AssertErrContains(t, err, "broken pipe")
+			tt.AssertEqual(t, *numQueries, 1)
+
+			c, numQueries = newDroppingDB(droppedConnErr)
+			_, err = c.Exec(ctx, "DELETE FROM users")
+			tt.AssertErrContains(t, err, "broken pipe")
+			tt.AssertEqual(t, *numQueries, 1)
+
+			var users []user
+			err = c.Query(ctx, &users, "FROM users")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(users), 1)
+			tt.AssertEqual(t, users[0].Name, "Retry User")
+		})
+	})
+}
+
+// RetryPolicyTest runs all tests for making sure the
+// WithRetryPolicy option is working for each of the supported drivers.
+func RetryPolicyTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("RetryPolicy", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		tt.AssertNoErr(t, c.Insert(ctx, usersTable, &user{Name: "Retry User"}))
+
+		policy := RetryPolicy{
+			MaxAttempts: 3,
+			BaseDelay:   time.Millisecond,
+			Jitter:      0.5,
+		}
+
+		// newFailingDB returns a DB whose first queries
+		// fail with the input errors:
+		newFailingDB := func(errs ...error) (DB, *int) {
+			var numQueries int
+			c := c.WithRetryPolicy(policy)
+			c.db = mockDBAdapter{
+				ExecContextFn: func(ctx context.Context, query string, params ...interface{}) (Result, error) {
+					numQueries++
+					if numQueries <= len(errs) {
+						return nil, errs[numQueries-1]
+					}
+					return db.ExecContext(ctx, query, params...)
+				},
+				QueryContextFn: func(ctx context.Context, query string, params ...interface{}) (Rows, error) {
+					numQueries++
+					if numQueries <= len(errs) {
+						return nil, errs[numQueries-1]
+					}
+					return db.QueryContext(ctx, query, params...)
+				},
+			}
+			return c, &numQueries
+		}
+
+		connResetErr := fmt.Errorf("read tcp 127.0.0.1:5432: %w", syscall.ECONNRESET)
+		deadlockErr := fmt.Errorf("Error 1213: Deadlock found when trying to get lock")
+
+		t.Run("should retry reads after transient failures", func(t *testing.T) {
+			c, numQueries := newFailingDB(connResetErr, deadlockErr)
+
+			var u user
+			err := c.QueryOne(ctx, &u, "FROM users WHERE name = "+c.dialect.Placeholder(0), "Retry User")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, u.Name, "Retry User")
+			tt.AssertEqual(t, *numQueries, 3)
+
+			c, numQueries = newFailingDB(connResetErr, connResetErr)
+			count, err := c.CountOf(ctx, "SELECT * FROM users")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, count, int64(1))
+			tt.AssertEqual(t, *numQueries, 3)
+		})
+
+		t.Run("should stop after the max attempts", func(t *testing.T) {
+			c, numQueries := newFailingDB(connResetErr, connResetErr, connResetErr)
+
+			var users []user
+			err := c.Query(ctx, &users, "FROM users")
+			tt.AssertErrContains(t, err, "connection reset")
+			tt.AssertEqual(t, *numQueries, 3)
+		})
+
+		t.Run("should not retry errors that are not retryable", func(t *testing.T) {
+			c, numQueries := newFailingDB(fmt.Errorf("fake syntax error"))
+
+			var users []user
+			err := c.Query(ctx, &users, "FROM users")
+			tt.AssertErrContains(t, err, "fake syntax error")
+			tt.AssertEqual(t, *numQueries, 1)
+
+			policy := policy
+			policy.IsRetryable = func(err error) bool {
+				return strings.Contains(err.Error(), "fake syntax error")
+			}
+			c, numQueries = newFailingDB(fmt.Errorf("fake syntax error"), connResetErr)
+			c = c.WithRetryPolicy(policy)
+
+			users = nil
+			err = c.Query(ctx, &users, "FROM users")
+			tt.AssertErrContains(t, err, "connection reset")
+			tt.AssertEqual(t, *numQueries, 2)
+		})
+
+		t.Run("should only retry writes marked as idempotent", func(t *testing.T) {
+			c, numQueries := newFailingDB(connResetErr, connResetErr)
+			_, err := c.Exec(ctx, "UPDATE users SET age = 42")
+			tt.AssertErrContains(t, err, "connection reset")
+			tt.AssertEqual(t, *numQueries, 1)
+
+			c, numQueries = newFailingDB(connResetErr, connResetErr)
+			_, err = c.Idempotent().Exec(ctx, "UPDATE users SET age = 42")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, *numQueries, 3)
+
+			var u user
+			err = c.QueryOne(ctx, &u, "FROM users WHERE name = "+c.dialect.Placeholder(0), "Retry User")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, u.Age, 42)
+		})
+
+		t.Run("should retry transactions that fail with deadlocks", func(t *testing.T) {
+			c := c.WithRetryPolicy(policy)
+
+			var numCalls int
+			err := c.Transaction(ctx, func(p Provider) error {
+				numCalls++
+				err := p.Insert(ctx, usersTable, &user{Name: "Tx Retry User"})
+				if err != nil {
+					return err
+				}
+				if numCalls < 3 {
+					return deadlockErr
+				}
+				return nil
+			})
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, numCalls, 3)
+
+			var users []user
+			err = c.Query(ctx, &users, "FROM users WHERE name = "+c.dialect.Placeholder(0), "Tx Retry User")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(users), 1)
+
+			numCalls = 0
+			err = c.WithRetryPolicy(RetryPolicy{}).Transaction(ctx, func(p Provider) error {
+				numCalls++
+				return deadlockErr
+			})
+			tt.AssertErrContains(t, err, "Deadlock found")
+			tt.AssertEqual(t, numCalls, 1)
+		})
+	})
+}
+
+// UnixTimestampTest runs all tests for making sure the attributes
+// tagged with the `unix` and `unixmilli` options are converted
+// to and from the timestamp columns of the database.
+func UnixTimestampTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("UnixTimestamp", func(t *testing.T) {
+		err := createEventsTable(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		type event struct {
+			ID          int    `ksql:"id"`
+			CreatedAt   int64  `ksql:"created_at,unix"`
+			CancelledAt *int64 `ksql:"cancelled_at,unixmilli"`
+		}
+
+		type timeEvent struct {
+			ID          int        `ksql:"id"`
+			CreatedAt   time.Time  `ksql:"created_at"`
+			CancelledAt *time.Time `ksql:"cancelled_at"`
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+		eventsTable := NewTable("events")
+
+		createdAt := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+		cancelledAt := time.Date(2022, 2, 3, 4, 5, 6, 250*int(time.Millisecond), time.UTC)
+		if driver == "mysql" {
+			// DATETIME columns have no fractional seconds on mysql:
+			cancelledAt = cancelledAt.Truncate(time.Second)
+		}
+		cancelledAtMillis := cancelledAt.UnixNano() / int64(time.Millisecond)
+
+		t.Run("should round-trip the timestamps through integer attributes", func(t *testing.T) {
+			e := event{
+				CreatedAt:   createdAt.Unix(),
+				CancelledAt: &cancelledAtMillis,
+			}
+			err := c.Insert(ctx, eventsTable, &e)
+			tt.AssertNoErr(t, err)
+
+			var result event
+			err = c.QueryOne(ctx, &result, `FROM events WHERE id = `+c.dialect.Placeholder(0), e.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, result, e)
+		})
+
+		t.Run("should store the timestamps as time values", func(t *testing.T) {
+			e := event{
+				CreatedAt:   createdAt.Unix(),
+				CancelledAt: &cancelledAtMillis,
+			}
+			err := c.Insert(ctx, eventsTable, &e)
+			tt.AssertNoErr(t, err)
+
+			var result timeEvent
+			err = c.QueryOne(ctx, &result, `FROM events WHERE id = `+c.dialect.Placeholder(0), e.ID)
+			if driver == "mysql" {
+				// The mysql driver only parses DATETIME values into
+				// time.Time when using the `parseTime=true` option:
+				tt.AssertErrContains(t, err, "time.Time")
+				return
+			}
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, result.CreatedAt.Equal(createdAt), true)
+			tt.AssertNotEqual(t, result.CancelledAt, (*time.Time)(nil))
+			tt.AssertEqual(t, result.CancelledAt.Equal(cancelledAt), true)
+		})
+
+		t.Run("should keep nil pointers as nil", func(t *testing.T) {
+			e := event{
+				CreatedAt: createdAt.Unix(),
+			}
+			err := c.Insert(ctx, eventsTable, &e)
+			tt.AssertNoErr(t, err)
+
+			var result event
+			err = c.QueryOne(ctx, &result, `FROM events WHERE id = `+c.dialect.Placeholder(0), e.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, result.CreatedAt, createdAt.Unix())
+			tt.AssertEqual(t, result.CancelledAt, (*int64)(nil))
+		})
+
+		t.Run("should convert the timestamps on updates", func(t *testing.T) {
+			e := event{
+				CreatedAt: createdAt.Unix(),
+			}
+			err := c.Insert(ctx, eventsTable, &e)
+			tt.AssertNoErr(t, err)
+
+			e.CreatedAt = createdAt.Add(time.Hour).Unix()
+			e.CancelledAt = &cancelledAtMillis
+			err = c.Patch(ctx, eventsTable, e)
+			tt.AssertNoErr(t, err)
+
+			var result event
+			err = c.QueryOne(ctx, &result, `FROM events WHERE id = `+c.dialect.Placeholder(0), e.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, result, e)
+		})
+
+		t.Run("should convert the timestamps on batch inserts", func(t *testing.T) {
+			records := []event{
+				{CreatedAt: createdAt.Unix(), CancelledAt: &cancelledAtMillis},
+				{CreatedAt: createdAt.Add(time.Minute).Unix()},
+			}
+			err := c.InsertBatch(ctx, eventsTable, records)
+			tt.AssertNoErr(t, err)
+
+			var results []event
+			err = c.Query(ctx, &results, `FROM events ORDER BY id`)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(results) >= 2, true)
+
+			results = results[len(results)-2:]
+			for i := range results {
+				records[i].ID = results[i].ID
+			}
+			tt.AssertEqual(t, results, records)
+		})
+	})
+}
+
+// QueryToCSVTest runs all tests for making sure the QueryToCSV
+// function writes the query results as valid CSV.
+func QueryToCSVTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("QueryToCSV", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		type nullableAgeUser struct {
+			ID   uint   `ksql:"id"`
+			Name string `ksql:"name"`
+			Age  *int   `ksql:"age"`
+		}
+
+		age := 30
+		err = c.Insert(ctx, usersTable, &nullableAgeUser{Name: "Smith, John", Age: &age})
+		tt.AssertNoErr(t, err)
+		err = c.Insert(ctx, usersTable, &nullableAgeUser{Name: `Ana "Nana" Silva`})
+		tt.AssertNoErr(t, err)
+
+		t.Run("should write the header and quote the values correctly", func(t *testing.T) {
+			var b strings.Builder
+			err := c.QueryToCSV(ctx, &b, "SELECT name, age FROM users ORDER BY id")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, b.String(), "name,age\n"+
+				"\"Smith, John\",30\n"+
+				"\"Ana \"\"Nana\"\" Silva\",\n",
+			)
+		})
+
+		t.Run("should write only the header for queries without results", func(t *testing.T) {
+			var b strings.Builder
+			err := c.QueryToCSV(ctx, &b, "SELECT name, age FROM users WHERE age > "+c.dialect.Placeholder(0), 100)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, b.String(), "name,age\n")
+		})
+
+		t.Run("should report errors on the query", func(t *testing.T) {
+			var b strings.Builder
+			err := c.QueryToCSV(ctx, &b, "SELECT not_a_column FROM users")
+			tt.AssertNotEqual(t, err, nil)
+			tt.AssertEqual(t, b.String(), "")
+		})
+	})
+}
+
+// ReadReplicaTest runs all tests for making sure the read queries
+// are sent to the replica configured with WithReadReplica.
+func ReadReplicaTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("ReadReplica", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+
+		var replicaQueries []string
+		replica := mockDBAdapter{
+			QueryContextFn: func(ctx context.Context, query string, args ...interface{}) (Rows, error) {
+				replicaQueries = append(replicaQueries, query)
+				return db.QueryContext(ctx, query, args...)
+			},
+			ExecContextFn: func(ctx context.Context, query string, args ...interface{}) (Result, error) {
+				t.Fatalf("unexpected write sent to the replica: %s", query)
+				return nil, nil
+			},
+		}
+		c := newTestDB(db, driver).WithReadReplica(replica)
+
+		u := user{Name: "Replica User", Age: 22}
+		err = c.Insert(ctx, usersTable, &u)
+		tt.AssertNoErr(t, err)
+		tt.AssertEqual(t, len(replicaQueries), 0)
+
+		t.Run("should send the reads to the replica by default", func(t *testing.T) {
+			replicaQueries = nil
+
+			var result user
+			err := c.QueryOne(ctx, &result, "FROM users WHERE id = "+c.dialect.Placeholder(0), u.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, result.Name, "Replica User")
+			tt.AssertEqual(t, len(replicaQueries), 1)
+		})
+
+		t.Run("should send the reads to the primary after calling ReadFromPrimary", func(t *testing.T) {
+			replicaQueries = nil
+
+			var result user
+			err := c.ReadFromPrimary().QueryOne(ctx, &result, "FROM users WHERE id = "+c.dialect.Placeholder(0), u.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, result.Name, "Replica User")
+			tt.AssertEqual(t, len(replicaQueries), 0)
+		})
+
+		t.Run("should send the reads inside transactions to the primary", func(t *testing.T) {
+			replicaQueries = nil
+
+			err := c.Transaction(ctx, func(p Provider) error {
+				var result user
+				return p.QueryOne(ctx, &result, "FROM users WHERE id = "+c.dialect.Placeholder(0), u.ID)
+			})
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(replicaQueries), 0)
+		})
+	})
+}
+
+// CachedQueryTest runs all tests for making sure the CachedQuery
+// function serves the cached results without querying the database.
+func CachedQueryTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("CachedQuery", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+
+		var queries []string
+		c := newTestDB(mockDBAdapter{
+			QueryContextFn: func(ctx context.Context, query string, args ...interface{}) (Rows, error) {
+				queries = append(queries, query)
+				return db.QueryContext(ctx, query, args...)
+			},
+			ExecContextFn: db.ExecContext,
+		}, driver)
+
+		err = c.Insert(ctx, usersTable, &user{Name: "Cached User 1", Age: 22})
+		tt.AssertNoErr(t, err)
+		err = c.Insert(ctx, usersTable, &user{Name: "Cached User 2", Age: 23})
+		tt.AssertNoErr(t, err)
+
+		t.Run("should query the database only once", func(t *testing.T) {
+			cache := &mapQueryCache{entries: map[string][]byte{}}
+			queries = nil
+
+			for i := 0; i < 3; i++ {
+				var users []user
+				err := c.CachedQuery(ctx, cache, time.Minute, &users, "FROM users WHERE age > "+c.dialect.Placeholder(0)+" ORDER BY id", 20)
+				tt.AssertNoErr(t, err)
+				tt.AssertEqual(t, len(users), 2)
+				tt.AssertEqual(t, users[0].Name, "Cached User 1")
+				tt.AssertEqual(t, users[1].Name, "Cached User 2")
+			}
+
+			tt.AssertEqual(t, len(queries), 1)
+			tt.AssertEqual(t, len(cache.entries), 1)
+			tt.AssertEqual(t, cache.ttl, time.Minute)
+		})
+
+		t.Run("should use different keys for different params", func(t *testing.T) {
+			cache := &mapQueryCache{entries: map[string][]byte{}}
+			queries = nil
+
+			var users []user
+			err := c.CachedQuery(ctx, cache, time.Minute, &users, "FROM users WHERE age > "+c.dialect.Placeholder(0), 20)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(users), 2)
+
+			var olderUsers []user
+			err = c.CachedQuery(ctx, cache, time.Minute, &olderUsers, "FROM users WHERE age > "+c.dialect.Placeholder(0), 22)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(olderUsers), 1)
+			tt.AssertEqual(t, olderUsers[0].Name, "Cached User 2")
+
+			tt.AssertEqual(t, len(queries), 2)
+			tt.AssertEqual(t, len(cache.entries), 2)
+		})
+
+		t.Run("should use different keys for different named params", func(t *testing.T) {
+			cache := &mapQueryCache{entries: map[string][]byte{}}
+
+			type ageFilter struct {
+				MinAge int `ksql:"min_age"`
+			}
+
+			var users []user
+			err := c.CachedQuery(ctx, cache, time.Minute, &users, "FROM users WHERE age > @min_age", Named(ageFilter{MinAge: 20}))
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(users), 2)
+
+			var olderUsers []user
+			err = c.CachedQuery(ctx, cache, time.Minute, &olderUsers, "FROM users WHERE age > @min_age", Named(ageFilter{MinAge: 22}))
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(olderUsers), 1)
+			tt.AssertEqual(t, olderUsers[0].Name, "Cached User 2")
+
+			tt.AssertEqual(t, len(cache.entries), 2)
+		})
+
+		t.Run("should use different keys for different scopes", func(t *testing.T) {
+			cache := &mapQueryCache{entries: map[string][]byte{}}
+
+			var users []user
+			err := c.Where("age > ?", 20).CachedQuery(ctx, cache, time.Minute, &users, "FROM users")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(users), 2)
+
+			var olderUsers []user
+			err = c.Where("age > ?", 22).CachedQuery(ctx, cache, time.Minute, &olderUsers, "FROM users")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(olderUsers), 1)
+			tt.AssertEqual(t, olderUsers[0].Name, "Cached User 2")
+
+			tt.AssertEqual(t, len(cache.entries), 2)
+		})
+
+		t.Run("should use different keys for different column resolvers", func(t *testing.T) {
+			cache := &mapQueryCache{entries: map[string][]byte{}}
+
+			type resolvedUser struct {
+				UserName string
+				FullName string
+			}
+			newResolver := func(nameField string) func(string) string {
+				return func(fieldName string) string {
+					if fieldName == nameField {
+						return "name"
+					}
+					return ""
+				}
+			}
+
+			query := "SELECT name FROM users WHERE name = " + c.dialect.Placeholder(0)
+
+			var byUserName []resolvedUser
+			err := newTestDB(db, driver).WithColumnResolver(newResolver("UserName")).
+				CachedQuery(ctx, cache, time.Minute, &byUserName, query, "Cached User 1")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, byUserName, []resolvedUser{{UserName: "Cached User 1"}})
+
+			var byFullName []resolvedUser
+			err = newTestDB(db, driver).WithColumnResolver(newResolver("FullName")).
+				CachedQuery(ctx, cache, time.Minute, &byFullName, query, "Cached User 1")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, byFullName, []resolvedUser{{FullName: "Cached User 1"}})
+
+			tt.AssertEqual(t, len(cache.entries), 2)
+		})
+
+		t.Run("should not cache failed queries", func(t *testing.T) {
+			cache := &mapQueryCache{entries: map[string][]byte{}}
+
+			var users []user
+			err := c.CachedQuery(ctx, cache, time.Minute, &users, "FROM not_a_table")
+			tt.AssertNotEqual(t, err, nil)
+			tt.AssertEqual(t, len(cache.entries), 0)
+		})
+	})
+}
+
+type mapQueryCache struct {
+	entries map[string][]byte
+	ttl     time.Duration
+}
+
+func (m *mapQueryCache) Get(key string) ([]byte, bool) {
+	val, found := m.entries[key]
+	return val, found
+}
+
+func (m *mapQueryCache) Set(key string, val []byte, ttl time.Duration) {
+	m.entries[key] = val
+	m.ttl = ttl
+}
+
+// IsUniqueTest runs all tests for making sure the IsUnique
+// function detects the values that are already in use.
+func IsUniqueTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("IsUnique", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		u := user{Name: "Unique User", Age: 22}
+		err = c.Insert(ctx, usersTable, &u)
+		tt.AssertNoErr(t, err)
+
+		t.Run("should check the uniqueness for inserts", func(t *testing.T) {
+			unique, err := c.IsUnique(ctx, usersTable, "name", "Unique User", nil)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, unique, false)
+
+			unique, err = c.IsUnique(ctx, usersTable, "name", "Another User", nil)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, unique, true)
+		})
+
+		t.Run("should ignore the excluded record for edits", func(t *testing.T) {
+			unique, err := c.IsUnique(ctx, usersTable, "name", "Unique User", u.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, unique, true)
+
+			other := user{Name: "Other User", Age: 23}
+			err = c.Insert(ctx, usersTable, &other)
+			tt.AssertNoErr(t, err)
+
+			unique, err = c.IsUnique(ctx, usersTable, "name", "Unique User", other.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, unique, false)
+		})
+
+		t.Run("should report error for invalid arguments", func(t *testing.T) {
+			_, err := c.IsUnique(ctx, usersTable, "name; DROP TABLE users", "Unique User", nil)
+			tt.AssertErrContains(t, err, "invalid column")
+
+			_, err = c.IsUnique(ctx, userPermissionsTable, "perm_id", 1, nil)
+			tt.AssertErrContains(t, err, "single ID column")
+		})
+	})
+}
+
+// QueryOneWithPrefixTest runs all tests for making sure the QueryOneWithPrefix
+// function scans only the columns with the input prefix.
+func QueryOneWithPrefixTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("QueryOneWithPrefix", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		u := user{Name: "Prefixed User", Age: 22}
+		err = c.Insert(ctx, usersTable, &u)
+		tt.AssertNoErr(t, err)
+
+		p := post{UserID: u.ID, Title: "Prefixed Post"}
+		err = c.Insert(ctx, postsTable, &p)
+		tt.AssertNoErr(t, err)
+
+		query := `SELECT u.id AS u_id, u.name AS u_name, u.age AS u_age, p.id AS p_id, p.user_id AS p_user_id, p.title AS p_title
+			FROM users u JOIN posts p ON p.user_id = u.id WHERE u.id = ` + c.dialect.Placeholder(0)
+
+		t.Run("should scan the prefixed columns into unprefixed structs", func(t *testing.T) {
+			var resultUser user
+			err := c.QueryOneWithPrefix(ctx, &resultUser, "u_", query, u.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, resultUser.ID, u.ID)
+			tt.AssertEqual(t, resultUser.Name, "Prefixed User")
+			tt.AssertEqual(t, resultUser.Age, 22)
+
+			var resultPost post
+			err = c.QueryOneWithPrefix(ctx, &resultPost, "p_", query, u.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, resultPost, p)
+		})
+
+		t.Run("should ignore the unprefixed columns on strict mode", func(t *testing.T) {
+			var resultUser user
+			err := c.WithStrictColumns(true).QueryOneWithPrefix(ctx, &resultUser, "u_", query, u.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, resultUser.Name, "Prefixed User")
+		})
+
+		t.Run("should return ErrRecordNotFound if there are no results", func(t *testing.T) {
+			var resultUser user
+			err := c.QueryOneWithPrefix(ctx, &resultUser, "u_", query, u.ID+1000)
+			tt.AssertEqual(t, err, ErrRecordNotFound)
+		})
+
+		t.Run("should report error for invalid arguments", func(t *testing.T) {
+			var resultUser user
+			err := c.QueryOneWithPrefix(ctx, &resultUser, "", query, u.ID)
+			tt.AssertErrContains(t, err, "non empty prefix")
+
+			err = c.QueryOneWithPrefix(ctx, &resultUser, "u_", "FROM users")
+			tt.AssertErrContains(t, err, "SELECT")
+
+			var nested struct {
+				User user `tablename:"u"`
+				Post post `tablename:"p"`
+			}
+			err = c.QueryOneWithPrefix(ctx, &nested, "u_", query, u.ID)
+			tt.AssertErrContains(t, err, "nested structs")
+		})
+	})
+}
+
+// InsertStreamReturningIDsTest runs all tests for making sure the
+// InsertStreamReturningIDs function reports the generated IDs.
+func InsertStreamReturningIDsTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("InsertStreamReturningIDs", func(t *testing.T) {
+		t.Run("should report the generated ID of every record in order", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			recordsCh := make(chan interface{})
+			go func() {
+				defer close(recordsCh)
+				for i := 0; i < 25; i++ {
+					recordsCh <- &user{
+						Name: fmt.Sprintf("Stream User %d", i),
+						Age:  i,
+					}
+				}
+			}()
+
+			var inserted []user
+			var ids []uint
+			err = c.InsertStreamReturningIDs(ctx, usersTable, recordsCh, 10, func(record interface{}, id interface{}) error {
+				inserted = append(inserted, *record.(*user))
+				ids = append(ids, id.(uint))
+				return nil
+			})
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(ids), 25)
+
+			for i, id := range ids {
+				tt.AssertNotEqual(t, id, uint(0))
+				// The records read from the channel are not modified:
+				tt.AssertEqual(t, inserted[i].ID, uint(0))
+				tt.AssertEqual(t, inserted[i].Name, fmt.Sprintf("Stream User %d", i))
+
+				var u user
+				err = c.QueryOne(ctx, &u, "FROM users WHERE id = "+c.dialect.Placeholder(0), id)
+				tt.AssertNoErr(t, err)
+				tt.AssertEqual(t, u.Name, inserted[i].Name)
+			}
+		})
+
+		t.Run("should stop on errors returned by the callback", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			c := newTestDB(db, driver)
+
+			recordsCh := make(chan interface{}, 3)
+			recordsCh <- user{Name: "Stream User 1"}
+			recordsCh <- user{Name: "Stream User 2"}
+			recordsCh <- user{Name: "Stream User 3"}
+			close(recordsCh)
+
+			calls := 0
+			err = c.InsertStreamReturningIDs(context.Background(), usersTable, recordsCh, 1, func(record interface{}, id interface{}) error {
+				calls++
+				return fmt.Errorf("fake-callback-error")
+			})
+			tt.AssertErrContains(t, err, "fake-callback-error")
+			tt.AssertEqual(t, calls, 1)
+		})
+
+		t.Run("should report error for tables with composite IDs", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			c := newTestDB(db, driver)
+
+			err := c.InsertStreamReturningIDs(context.Background(), userPermissionsTable, make(chan interface{}), 10,
+				func(record interface{}, id interface{}) error {
+					return nil
+				},
+			)
+			tt.AssertErrContains(t, err, "single ID column")
+		})
+	})
+}
+
+// QueryOneMapTest runs all tests for making sure the QueryOneMap
+// function returns a single row as a map.
+func QueryOneMapTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("QueryOneMap", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		type nullableAgeUser struct {
+			ID   uint   `ksql:"id"`
+			Name string `ksql:"name"`
+			Age  *int   `ksql:"age"`
+		}
+
+		u := nullableAgeUser{Name: "Map User"}
+		err = c.Insert(ctx, usersTable, &u)
+		tt.AssertNoErr(t, err)
+		err = c.Insert(ctx, usersTable, &nullableAgeUser{Name: "Other Map User"})
+		tt.AssertNoErr(t, err)
+
+		t.Run("should return the row as a map", func(t *testing.T) {
+			row, err := c.QueryOneMap(ctx, "SELECT name, age FROM users WHERE id = "+c.dialect.Placeholder(0), u.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(row), 2)
+			// The string columns are returned as []byte on mysql:
+			tt.AssertEqual(t, fmt.Sprintf("%s", row["name"]), "Map User")
+
+			age, found := row["age"]
+			tt.AssertEqual(t, found, true)
+			tt.AssertEqual(t, age, nil)
+		})
+
+		t.Run("should return ErrRecordNotFound if there are no rows", func(t *testing.T) {
+			_, err := c.QueryOneMap(ctx, "SELECT name FROM users WHERE id = "+c.dialect.Placeholder(0), u.ID+1000)
+			tt.AssertEqual(t, err, ErrRecordNotFound)
+		})
+
+		t.Run("should return ErrMultipleRecordsFound if there is more than one row", func(t *testing.T) {
+			_, err := c.QueryOneMap(ctx, "SELECT name FROM users")
+			tt.AssertEqual(t, err, ErrMultipleRecordsFound)
+		})
+	})
+}
+
+// ExistingIDsTest runs all tests for making sure the ExistingIDs
+// function returns only the IDs present on the table.
+func ExistingIDsTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("ExistingIDs", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		u1 := user{Name: "Existing User 1", Age: 22}
+		err = c.Insert(ctx, usersTable, &u1)
+		tt.AssertNoErr(t, err)
+		u2 := user{Name: "Existing User 2", Age: 23}
+		err = c.Insert(ctx, usersTable, &u2)
+		tt.AssertNoErr(t, err)
+
+		t.Run("should return only the existing IDs in the input order", func(t *testing.T) {
+			existing, err := c.ExistingIDs(ctx, usersTable, []interface{}{u2.ID + 1000, u2.ID, u1.ID + 2000, u1.ID})
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, existing, []interface{}{u2.ID, u1.ID})
+		})
+
+		t.Run("should return an empty slice if no IDs exist", func(t *testing.T) {
+			existing, err := c.ExistingIDs(ctx, usersTable, []interface{}{u2.ID + 1000})
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, existing, []interface{}{})
+		})
+
+		t.Run("should not run any queries for empty inputs", func(t *testing.T) {
+			c := newTestDB(mockDBAdapter{}, driver)
+
+			existing, err := c.ExistingIDs(ctx, usersTable, []interface{}{})
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, existing, []interface{}{})
+		})
+
+		t.Run("should report error for invalid inputs", func(t *testing.T) {
+			_, err := c.ExistingIDs(ctx, usersTable, []interface{}{u1.ID, "not-an-uint"})
+			tt.AssertErrContains(t, err, "all the IDs to have type uint", "string")
+
+			_, err = c.ExistingIDs(ctx, usersTable, []interface{}{nil})
+			tt.AssertErrContains(t, err, "comparable")
+
+			_, err = c.ExistingIDs(ctx, userPermissionsTable, []interface{}{1})
+			tt.AssertErrContains(t, err, "single ID column")
+		})
+	})
+}
+
+// RowMapperTest runs all tests for mapping rows without a DB instance
+func RowMapperTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("RowMapper", func(t *testing.T) {
+		t.Run("should map rows read with database/sql", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			ctx := context.TODO()
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+			c := newTestDB(db, driver)
+			_ = c.Insert(ctx, usersTable, &user{Name: "User1", Age: 22, Address: address{City: "City1"}})
+			_ = c.Insert(ctx, usersTable, &user{Name: "User2", Age: 14})
+
+			sqlDB, err := sql.Open(driver, connStr)
+			tt.AssertNoErr(t, err)
+			defer sqlDB.Close()
+
+			rows, err := sqlDB.QueryContext(ctx, "SELECT name, 42 AS extra, age, address FROM users ORDER BY name")
+			tt.AssertNoErr(t, err)
+			defer rows.Close()
+
+			mapper, err := NewRowMapper(user{})
+			tt.AssertNoErr(t, err)
+
+			var users []user
+			for rows.Next() {
+				var u user
+				err = mapper.Map(rows, &u)
+				tt.AssertNoErr(t, err)
+				users = append(users, u)
+			}
+			tt.AssertNoErr(t, rows.Err())
+
+			tt.AssertEqual(t, users, []user{
+				{Name: "User1", Age: 22, Address: address{City: "City1"}},
+				{Name: "User2", Age: 14},
+			})
+		})
+
+		t.Run("should resolve the columns again for new rows", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			ctx := context.TODO()
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+			c := newTestDB(db, driver)
+			_ = c.Insert(ctx, usersTable, &user{Name: "User1", Age: 22})
+
+			mapper, err := NewRowMapper(&user{})
+			tt.AssertNoErr(t, err)
+
+			for _, query := range []string{
+				"SELECT name, age FROM users",
+				"SELECT age, name FROM users",
+			} {
+				rows, err := db.QueryContext(ctx, query)
+				tt.AssertNoErr(t, err)
+
+				tt.AssertEqual(t, rows.Next(), true)
+				var u user
+				err = mapper.Map(rows, &u)
+				tt.AssertNoErr(t, err)
+				rows.Close()
+
+				tt.AssertEqual(t, u, user{Name: "User1", Age: 22})
+			}
+		})
+
+		t.Run("should report invalid arguments", func(t *testing.T) {
+			_, err := NewRowMapper(42)
+			tt.AssertErrContains(t, err, "ksql", "struct", "int")
+
+			_, err = NewRowMapper((*user)(nil))
+			tt.AssertNoErr(t, err)
+
+			_, err = NewRowMapper(struct {
+				User user `tablename:"u"`
+			}{})
+			tt.AssertErrContains(t, err, "nested structs")
+
+			mapper, err := NewRowMapper(user{})
+			tt.AssertNoErr(t, err)
+
+			var u user
+			err = mapper.Map(nil, u)
+			tt.AssertErrContains(t, err, "ksql", "dest", "*ksql.user")
+
+			var p post
+			err = mapper.Map(nil, &p)
+			tt.AssertErrContains(t, err, "ksql", "dest", "*ksql.post")
+		})
+	})
+}
+
+// InsertDefaultsTest runs all tests for making sure the default
+// tag option is applied by the Insert function.
+func InsertDefaultsTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("InsertDefaults", func(t *testing.T) {
+		type userWithDefaults struct {
+			ID   uint   `ksql:"id"`
+			Name string `ksql:"name,default=Unnamed"`
+			Age  int    `ksql:"age,default=18"`
+		}
+
+		t.Run("should insert the default values of zero attributes", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			u := userWithDefaults{}
+			err = c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, u.Name, "Unnamed")
+			tt.AssertEqual(t, u.Age, 18)
+
+			var result userWithDefaults
+			err = c.QueryOne(ctx, &result, "FROM users WHERE id = "+c.dialect.Placeholder(0), u.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, result, userWithDefaults{ID: u.ID, Name: "Unnamed", Age: 18})
+		})
+
+		t.Run("should keep the values that are set", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			u := userWithDefaults{Name: "Bob", Age: 42}
+			err = c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+
+			var result userWithDefaults
+			err = c.QueryOne(ctx, &result, "FROM users WHERE id = "+c.dialect.Placeholder(0), u.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, result, userWithDefaults{ID: u.ID, Name: "Bob", Age: 42})
+		})
+
+		t.Run("should report error for invalid default values", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			c := newTestDB(db, driver)
+
+			err := c.Insert(context.Background(), usersTable, &struct {
+				ID  uint `ksql:"id"`
+				Age int  `ksql:"age,default=eighteen"`
+			}{})
+			tt.AssertErrContains(t, err, "Age", "eighteen")
+		})
+
+		t.Run("should report error for unexported attributes with default values", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			c := newTestDB(db, driver)
+
+			err := c.Insert(context.Background(), usersTable, &struct {
+				ID   uint   `ksql:"id"`
+				Name string `ksql:"name"`
+				age  int    `ksql:"age,default=18"`
+			}{Name: "fake-name"})
+			tt.AssertErrContains(t, err, "age", "not exported")
+		})
+	})
+}
+
+// UpsertReturningTest runs all tests for making sure the UpsertReturning
+// function loads the state of the row after the upsert.
+func UpsertReturningTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	type account struct {
+		Email string  `ksql:"email"`
+		Name  *string `ksql:"name"`
+		Score int     `ksql:"score"`
+	}
+	accountsTable := NewTable("accounts", "email")
+
+	t.Run("UpsertReturning", func(t *testing.T) {
+		t.Run("should insert the record and load it back if there is no conflict", func(t *testing.T) {
+			err := createAccountsTable(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			a := account{Email: "new@email.com", Name: nullable.String("New Account"), Score: 10}
+			err = c.UpsertReturning(ctx, accountsTable, &a, []string{"email"})
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, a, account{Email: "new@email.com", Name: nullable.String("New Account"), Score: 10})
+
+			var result account
+			err = c.QueryOne(ctx, &result, "FROM accounts WHERE email = "+c.dialect.Placeholder(0), "new@email.com")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, result, a)
+		})
+
+		t.Run("should update the row and load its current state on conflict", func(t *testing.T) {
+			err := createAccountsTable(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			err = c.Insert(ctx, accountsTable, &account{Email: "old@email.com", Name: nullable.String("Old Name"), Score: 10})
+			tt.AssertNoErr(t, err)
+			err = c.Insert(ctx, accountsTable, &account{Email: "other@email.com", Name: nullable.String("Other Name"), Score: 20})
+			tt.AssertNoErr(t, err)
+
+			// Since the name is nil it is not updated, so
+			// it should be loaded from the existing row:
+			a := account{Email: "old@email.com", Score: 99}
+			err = c.UpsertReturning(ctx, accountsTable, &a, []string{"email"})
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, a, account{Email: "old@email.com", Name: nullable.String("Old Name"), Score: 99})
+
+			var accounts []account
+			err = c.Query(ctx, &accounts, "FROM accounts ORDER BY email")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, accounts, []account{
+				{Email: "old@email.com", Name: nullable.String("Old Name"), Score: 99},
+				{Email: "other@email.com", Name: nullable.String("Other Name"), Score: 20},
+			})
+		})
+
+		t.Run("should report error if there are no columns to update", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			c := newTestDB(db, driver)
+
+			err := c.UpsertReturning(context.Background(), accountsTable, &account{Email: "new@email.com"}, []string{"email", "score"})
+			tt.AssertErrContains(t, err, "update column")
+		})
+
+		t.Run("should report error for invalid records", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			c := newTestDB(db, driver)
+
+			err := c.UpsertReturning(context.Background(), accountsTable, account{}, []string{"email"})
+			tt.AssertErrContains(t, err, "pointer to struct")
+
+			err = c.UpsertReturning(context.Background(), accountsTable, &account{Email: "new@email.com", Score: 1}, []string{"not_tagged"})
+			tt.AssertErrContains(t, err, "not_tagged", "not tagged")
+		})
+	})
+}
+
+// ScanErrorTest runs all tests for making sure the scan errors
+// report the columns and attributes that caused them.
+func ScanErrorTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("ScanError", func(t *testing.T) {
+		t.Run("should name the column and the attribute that caused the error", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			err = c.Insert(ctx, usersTable, &user{Name: "Bob", Age: 42})
+			tt.AssertNoErr(t, err)
+
+			var u struct {
+				ID   uint `ksql:"id"`
+				Name int  `ksql:"name"`
+				Age  int  `ksql:"age"`
+			}
+			err = c.QueryOne(ctx, &u, "SELECT id, name, age FROM users")
+			tt.AssertErrContains(t, err, `ksql: scanning column "name" into field Name (int)`)
+
+			var scanErr ScanError
+			tt.AssertEqual(t, errors.As(err, &scanErr), true)
+			tt.AssertEqual(t, scanErr.Column, "name")
+			tt.AssertEqual(t, scanErr.Field, "Name")
+			tt.AssertEqual(t, scanErr.FieldType.String(), "int")
+		})
+
+		t.Run("should name the attributes of nested structs", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			err = c.Insert(ctx, usersTable, &user{Name: "Bob", Age: 42})
+			tt.AssertNoErr(t, err)
+
+			var row struct {
+				User struct {
+					ID   uint `ksql:"id"`
+					Name int  `ksql:"name"`
+				} `tablename:"u"`
+			}
+			err = c.QueryOne(ctx, &row, "FROM users AS u")
+			tt.AssertErrContains(t, err, `ksql: scanning column "name" into field User.Name (int)`)
+		})
+	})
+}
+
+// BeginTest runs all tests for making sure the transactions
+// started with Begin are finalized by Commit and Rollback.
+func BeginTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("Begin", func(t *testing.T) {
+		t.Run("should persist the changes on commit", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			tx, err := c.Begin(ctx)
+			tt.AssertNoErr(t, err)
+			defer tx.Rollback(ctx)
+
+			u := user{Name: "User1", Age: 22}
+			err = tx.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+
+			// The changes should be visible inside the transaction:
+			var txUser user
+			err = tx.QueryOne(ctx, &txUser, "FROM users WHERE id = "+c.dialect.Placeholder(0), u.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, txUser.Name, "User1")
+
+			err = tx.Commit(ctx)
+			tt.AssertNoErr(t, err)
+
+			var result user
+			err = c.QueryOne(ctx, &result, "FROM users WHERE id = "+c.dialect.Placeholder(0), u.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, result.Name, "User1")
+			tt.AssertEqual(t, result.Age, 22)
+		})
+
+		t.Run("should discard the changes on rollback", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			u := user{Name: "User1", Age: 22}
+			err = c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+
+			tx, err := c.Begin(ctx)
+			tt.AssertNoErr(t, err)
+
+			err = tx.Patch(ctx, usersTable, struct {
+				ID  uint `ksql:"id"`
+				Age int  `ksql:"age"`
+			}{ID: u.ID, Age: 42})
+			tt.AssertNoErr(t, err)
+
+			err = tx.Insert(ctx, usersTable, &user{Name: "User2"})
+			tt.AssertNoErr(t, err)
+
+			err = tx.Rollback(ctx)
+			tt.AssertNoErr(t, err)
+
+			var users []user
+			err = c.Query(ctx, &users, "FROM users")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(users), 1)
+			tt.AssertEqual(t, users[0].Age, 22)
+		})
+
+		t.Run("should run nested transactions inside the same transaction", func(t *testing.T) {
+			err := createTables(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			tx, err := c.Begin(ctx)
+			tt.AssertNoErr(t, err)
+
+			err = tx.Transaction(ctx, func(p Provider) error {
+				return p.Insert(ctx, usersTable, &user{Name: "User1"})
+			})
+			tt.AssertNoErr(t, err)
+
+			err = tx.Rollback(ctx)
+			tt.AssertNoErr(t, err)
+
+			var users []user
+			err = c.Query(ctx, &users, "FROM users")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(users), 0)
+		})
+
+		t.Run("should report error if called inside a transaction", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver)
+
+			tx, err := c.Begin(ctx)
+			tt.AssertNoErr(t, err)
+			defer tx.Rollback(ctx)
+
+			_, err = tx.Begin(ctx)
+			tt.AssertErrContains(t, err, "ksql", "Begin", "inside a transaction")
+		})
+	})
+}
+
+// UUIDKeyTest runs all tests for making sure the WithUUIDKey
+// option generates the keys of the inserted records.
+func UUIDKeyTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	type tag struct {
+		ID   string `ksql:"id"`
+		Name string `ksql:"name"`
+	}
+	tagsTable := NewTable("tags")
+
+	uuidRegexp := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	t.Run("WithUUIDKey", func(t *testing.T) {
+		t.Run("should generate the key of records with empty keys", func(t *testing.T) {
+			err := createTagsTable(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver).WithUUIDKey("id")
+
+			tag1 := tag{Name: "tag1"}
+			err = c.Insert(ctx, tagsTable, &tag1)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, uuidRegexp.MatchString(tag1.ID), true)
+
+			tag2 := tag{Name: "tag2"}
+			err = c.Insert(ctx, tagsTable, &tag2)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, uuidRegexp.MatchString(tag2.ID), true)
+			tt.AssertNotEqual(t, tag1.ID, tag2.ID)
+
+			var result tag
+			err = c.QueryOne(ctx, &result, "FROM tags WHERE id = "+c.dialect.Placeholder(0), tag1.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, result, tag1)
+		})
+
+		t.Run("should keep the keys that are already set", func(t *testing.T) {
+			err := createTagsTable(driver, connStr)
+			if err != nil {
+				t.Fatal("could not create test table!, reason:", err.Error())
+			}
+
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			ctx := context.Background()
+			c := newTestDB(db, driver).WithUUIDKey("id")
+
+			tag1 := tag{ID: "fake-id", Name: "tag1"}
+			err = c.Insert(ctx, tagsTable, &tag1)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, tag1.ID, "fake-id")
+
+			var result tag
+			err = c.QueryOne(ctx, &result, "FROM tags WHERE id = "+c.dialect.Placeholder(0), "fake-id")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, result, tag1)
+		})
+
+		t.Run("should report error for unsupported key types", func(t *testing.T) {
+			db, closer := newDBAdapter(t)
+			defer closer.Close()
+
+			c := newTestDB(db, driver).WithUUIDKey("id")
+
+			err := c.Insert(context.Background(), usersTable, &user{Name: "User1"})
+			tt.AssertErrContains(t, err, "ksql", "UUID", "ID", "uint")
+		})
+	})
+}
+
+// FirstTest runs all tests for making sure the First
+// function is working correctly.
+func FirstTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("First", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		for _, u := range []user{
+			{Name: "Bia", Age: 30},
+			{Name: "Alan", Age: 30},
+			{Name: "Carl", Age: 10},
+		} {
+			err := c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+		}
+
+		t.Run("should load the first row if found", func(t *testing.T) {
+			var u user
+			found, err := c.First(ctx, &u, "FROM users WHERE age = "+c.dialect.Placeholder(0)+" ORDER BY name", 30)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, found, true)
+			tt.AssertEqual(t, u.Name, "Alan")
+		})
+
+		t.Run("should work without ORDER BY and with pointers to pointers", func(t *testing.T) {
+			var u *user
+			found, err := c.First(ctx, &u, "SELECT * FROM users WHERE age = "+c.dialect.Placeholder(0), 10)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, found, true)
+			tt.AssertEqual(t, u.Name, "Carl")
+		})
+
+		t.Run("should report not found without an error", func(t *testing.T) {
+			u := user{Name: "untouched"}
+			found, err := c.First(ctx, &u, "FROM users WHERE age = "+c.dialect.Placeholder(0), 99)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, found, false)
+			tt.AssertEqual(t, u.Name, "untouched")
+
+			var uPtr *user
+			found, err = c.First(ctx, &uPtr, "FROM users WHERE age = "+c.dialect.Placeholder(0), 99)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, found, false)
+			tt.AssertEqual(t, uPtr, (*user)(nil))
+		})
+
+		t.Run("should apply the scopes before the limit", func(t *testing.T) {
+			var u user
+			found, err := c.Where("name <> ?", "Alan").First(ctx, &u, "FROM users WHERE age = "+c.dialect.Placeholder(0)+" ORDER BY name", 30)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, found, true)
+			tt.AssertEqual(t, u.Name, "Bia")
+		})
+
+		t.Run("should not be affected by the strict QueryOne option", func(t *testing.T) {
+			var u user
+			found, err := c.WithStrictQueryOne(true).First(ctx, &u, "FROM users ORDER BY name")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, found, true)
+			tt.AssertEqual(t, u.Name, "Alan")
+		})
+
+		t.Run("should report error for invalid records", func(t *testing.T) {
+			var u user
+			_, err := c.First(ctx, u, "FROM users")
+			tt.AssertErrContains(t, err, "ksql", "pointer to struct")
+		})
+	})
+}
+
+// DefaultOrderByTest runs all tests for making sure the
+// WithDefaultOrderBy option is working correctly.
+func DefaultOrderByTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("WithDefaultOrderBy", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		for _, u := range []user{
+			{Name: "Bia", Age: 20},
+			{Name: "Carl", Age: 10},
+			{Name: "Alan", Age: 30},
+		} {
+			err := c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+		}
+
+		orderedDB := c.WithDefaultOrderBy("age DESC")
+
+		t.Run("should sort queries without ORDER BY", func(t *testing.T) {
+			var users []user
+			err := orderedDB.Query(ctx, &users, "FROM users")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, getUserNames(users), []string{"Alan", "Bia", "Carl"})
+
+			var allUsers []user
+			err = orderedDB.QueryAll(ctx, usersTable, &allUsers)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, getUserNames(allUsers), []string{"Alan", "Bia", "Carl"})
+
+			var pageUsers []user
+			_, err = orderedDB.Paginate(ctx, &pageUsers, 1, 2, "FROM users")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, getUserNames(pageUsers), []string{"Alan", "Bia"})
+
+			var first user
+			found, err := orderedDB.First(ctx, &first, "FROM users")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, found, true)
+			tt.AssertEqual(t, first.Name, "Alan")
+		})
+
+		t.Run("should keep the ORDER BY of the queries", func(t *testing.T) {
+			var users []user
+			err := orderedDB.Query(ctx, &users, "FROM users ORDER BY name")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, getUserNames(users), []string{"Alan", "Bia", "Carl"})
+
+			users = nil
+			err = orderedDB.Query(ctx, &users, "FROM users ORDER BY age")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, getUserNames(users), []string{"Carl", "Bia", "Alan"})
+
+			var allUsers []user
+			err = orderedDB.QueryAll(ctx, usersTable, &allUsers, "name DESC")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, getUserNames(allUsers), []string{"Carl", "Bia", "Alan"})
+		})
+
+		t.Run("should sort the results of scoped queries", func(t *testing.T) {
+			var users []user
+			err := orderedDB.Where("age > ?", 10).Query(ctx, &users, "FROM users")
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, getUserNames(users), []string{"Alan", "Bia"})
+		})
+	})
+}
+
+func getUserNames(users []user) []string {
+	names := []string{}
+	for _, u := range users {
+		names = append(names, u.Name)
+	}
+	return names
+}
+
+// QueryTemplateTest runs all tests for making sure the
+// QueryTemplate function is working correctly.
+func QueryTemplateTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("QueryTemplate", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
+
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
+
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		for _, u := range []user{
+			{Name: "Bia", Age: 20},
+			{Name: "Carl", Age: 10},
+		} {
+			err := c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+		}
+
+		t.Run("should replace the identifiers and bind the params", func(t *testing.T) {
+			var users []user
+			err := c.QueryTemplate(ctx, &users,
+				"FROM ${table} WHERE ${column} = "+c.dialect.Placeholder(0),
+				map[string]string{"table": "users", "column": "name"},
+				"Carl",
+			)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(users), 1)
+			tt.AssertEqual(t, users[0].Name, "Carl")
+			tt.AssertEqual(t, users[0].Age, 10)
+		})
 
-						ChunkSize: 2,
-						ForEachChunk: func(buffer []user) error {
-							lengths = append(lengths, len(buffer))
-							users = append(users, buffer...)
+		t.Run("should reject invalid identifiers without querying the database", func(t *testing.T) {
+			c := newTestDB(mockDBAdapter{
+				QueryContextFn: func(ctx context.Context, query string, params ...interface{}) (Rows, error) {
+					t.Fatal("the database should not be queried")
+					return nil, nil
+				},
+			}, driver)
 
-							return shiftErrSlice(&returnVals)
-						},
-					})
+			var users []user
+			err := c.QueryTemplate(ctx, &users,
+				"FROM users WHERE ${column} = "+c.dialect.Placeholder(0),
+				map[string]string{"column": "name = name OR 1"},
+				"Carl",
+			)
+			tt.AssertErrContains(t, err, "${column}", "invalid identifier")
+		})
+	})
+}
 
-					assert.Equal(t, nil, err)
-					assert.Equal(t, 3, len(users))
-					assert.NotEqual(t, uint(0), users[0].ID)
-					assert.Equal(t, "User1", users[0].Name)
-					assert.NotEqual(t, uint(0), users[1].ID)
-					assert.Equal(t, "User2", users[1].Name)
-					assert.NotEqual(t, uint(0), users[2].ID)
-					assert.Equal(t, "User3", users[2].Name)
-					assert.Equal(t, []int{2, 1}, lengths)
-				})
+// MetricsTest runs all tests for making sure the
+// WithMetrics option is working correctly.
+func MetricsTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("WithMetrics", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
 
-				t.Run("should return error if the callback returns an error in the first iteration", func(t *testing.T) {
-					err := createTables(driver, connStr)
-					if err != nil {
-						t.Fatal("could not create test table!, reason:", err.Error())
-					}
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
 
-					db, closer := newDBAdapter(t)
-					defer closer.Close()
+		ctx := context.Background()
 
-					ctx := context.Background()
-					c := newTestDB(db, driver)
+		t.Run("should observe each method with its op name", func(t *testing.T) {
+			metrics := &metricsRecorder{}
+			c := newTestDB(db, driver).WithMetrics(metrics)
 
-					_ = c.Insert(ctx, usersTable, &user{Name: "User1"})
-					_ = c.Insert(ctx, usersTable, &user{Name: "User2"})
-					_ = c.Insert(ctx, usersTable, &user{Name: "User3"})
+			u := user{Name: "Bia", Age: 20}
+			err := c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
 
-					var lengths []int
-					var users []user
-					err = c.QueryChunks(ctx, ChunkParser{
-						Query:  variation.queryPrefix + `from users where name like ` + c.dialect.Placeholder(0) + ` order by name asc;`,
-						Params: []interface{}{"User%"},
+			err = c.QueryOne(ctx, &u, "FROM users WHERE id = "+c.dialect.Placeholder(0), u.ID)
+			tt.AssertNoErr(t, err)
 
-						ChunkSize: 2,
-						ForEachChunk: func(buffer []user) error {
-							lengths = append(lengths, len(buffer))
-							users = append(users, buffer...)
-							return errors.New("fake error msg")
-						},
-					})
+			var users []user
+			err = c.Query(ctx, &users, "FROM users")
+			tt.AssertNoErr(t, err)
 
-					assert.NotEqual(t, nil, err)
-					assert.Equal(t, 2, len(users))
-					assert.NotEqual(t, uint(0), users[0].ID)
-					assert.Equal(t, "User1", users[0].Name)
-					assert.NotEqual(t, uint(0), users[1].ID)
-					assert.Equal(t, "User2", users[1].Name)
-					assert.Equal(t, []int{2}, lengths)
-				})
+			users = nil
+			err = c.QueryAll(ctx, usersTable, &users)
+			tt.AssertNoErr(t, err)
 
-				t.Run("should return error if the callback returns an error in the last iteration", func(t *testing.T) {
-					err := createTables(driver, connStr)
-					if err != nil {
-						t.Fatal("could not create test table!, reason:", err.Error())
-					}
+			_, err = c.First(ctx, &u, "FROM users")
+			tt.AssertNoErr(t, err)
 
-					db, closer := newDBAdapter(t)
-					defer closer.Close()
+			_, err = c.CountOf(ctx, "SELECT id FROM users")
+			tt.AssertNoErr(t, err)
 
-					ctx := context.Background()
-					c := newTestDB(db, driver)
+			u.Age = 21
+			err = c.Patch(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
 
-					_ = c.Insert(ctx, usersTable, &user{Name: "User1"})
-					_ = c.Insert(ctx, usersTable, &user{Name: "User2"})
-					_ = c.Insert(ctx, usersTable, &user{Name: "User3"})
+			err = c.Update(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
 
-					returnVals := []error{nil, errors.New("fake error msg")}
-					var lengths []int
-					var users []user
-					err = c.QueryChunks(ctx, ChunkParser{
-						Query:  variation.queryPrefix + `from users where name like ` + c.dialect.Placeholder(0) + ` order by name asc;`,
-						Params: []interface{}{"User%"},
+			err = c.PatchAndReload(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
 
-						ChunkSize: 2,
-						ForEachChunk: func(buffer []user) error {
-							lengths = append(lengths, len(buffer))
-							users = append(users, buffer...)
+			_, err = c.Exec(ctx, "UPDATE users SET age = 22")
+			tt.AssertNoErr(t, err)
 
-							return shiftErrSlice(&returnVals)
-						},
-					})
+			err = c.Transaction(ctx, func(p Provider) error {
+				return p.Delete(ctx, usersTable, u.ID)
+			})
+			tt.AssertNoErr(t, err)
 
-					assert.NotEqual(t, nil, err)
-					assert.Equal(t, 3, len(users))
-					assert.NotEqual(t, uint(0), users[0].ID)
-					assert.Equal(t, "User1", users[0].Name)
-					assert.NotEqual(t, uint(0), users[1].ID)
-					assert.Equal(t, "User2", users[1].Name)
-					assert.NotEqual(t, uint(0), users[2].ID)
-					assert.Equal(t, "User3", users[2].Name)
-					assert.Equal(t, []int{2, 1}, lengths)
-				})
+			tt.AssertEqual(t, metrics.ops(), []string{
+				"insert:users",
+				"query_one:",
+				"query:",
+				"query_all:users",
+				"first:",
+				"count_of:",
+				"patch:users",
+				"update:users",
+				"patch_and_reload:users",
+				"exec:",
+				"delete:users",
+				"transaction:",
+			})
+		})
 
-				t.Run("should report error if the input function is invalid", func(t *testing.T) {
-					db, closer := newDBAdapter(t)
-					defer closer.Close()
+		t.Run("should report the errors and durations", func(t *testing.T) {
+			metrics := &metricsRecorder{}
+			c := newTestDB(db, driver).WithMetrics(metrics)
 
-					ctx := context.Background()
-					c := newTestDB(db, driver)
+			var u user
+			err := c.QueryOne(ctx, &u, "FROM users WHERE id = "+c.dialect.Placeholder(0), 4242)
+			tt.AssertEqual(t, err, ErrRecordNotFound)
 
-					funcs := []interface{}{
-						nil,
-						"not a function",
-						func() error {
-							return nil
-						},
-						func(extraInputValue []user, extra []user) error {
-							return nil
-						},
-						func(invalidArgType string) error {
-							return nil
-						},
-						func(missingReturnType []user) {
-							return
-						},
-						func(users []user) string {
-							return ""
-						},
-						func(extraReturnValue []user) ([]user, error) {
-							return nil, nil
-						},
-						func(notSliceOfStructs []string) error {
-							return nil
-						},
-					}
+			tt.AssertEqual(t, len(metrics.observed), 1)
+			tt.AssertEqual(t, metrics.observed[0].op, "query_one")
+			tt.AssertEqual(t, metrics.observed[0].err, ErrRecordNotFound)
+			tt.AssertEqual(t, metrics.observed[0].d > 0, true)
+		})
 
-					for _, fn := range funcs {
-						err := c.QueryChunks(ctx, ChunkParser{
-							Query:  variation.queryPrefix + `FROM users`,
-							Params: []interface{}{},
+		t.Run("should not observe anything by default", func(t *testing.T) {
+			metrics := &metricsRecorder{}
+			c := newTestDB(db, driver).WithMetrics(metrics).WithMetrics(nil)
 
-							ChunkSize:    2,
-							ForEachChunk: fn,
-						})
-						assert.NotEqual(t, nil, err)
-					}
-				})
+			var users []user
+			err := c.Query(ctx, &users, "FROM users")
+			tt.AssertNoErr(t, err)
 
-				t.Run("should report error if the query is not valid", func(t *testing.T) {
-					db, closer := newDBAdapter(t)
-					defer closer.Close()
+			tt.AssertEqual(t, len(metrics.observed), 0)
+		})
+	})
+}
 
-					ctx := context.Background()
-					c := newTestDB(db, driver)
-					err := c.QueryChunks(ctx, ChunkParser{
-						Query:  `SELECT * FROM not a valid query`,
-						Params: []interface{}{},
+type observedQuery struct {
+	op    string
+	table string
+	d     time.Duration
+	err   error
+}
 
-						ChunkSize: 2,
-						ForEachChunk: func(buffer []user) error {
-							return nil
-						},
-					})
-					assert.NotEqual(t, nil, err)
-				})
+type metricsRecorder struct {
+	observed []observedQuery
+}
 
-				t.Run("should report error if using nested struct and the query starts with SELECT", func(t *testing.T) {
-					db, closer := newDBAdapter(t)
-					defer closer.Close()
+func (m *metricsRecorder) ObserveQuery(op string, table string, d time.Duration, err error) {
+	m.observed = append(m.observed, observedQuery{op: op, table: table, d: d, err: err})
+}
 
-					ctx := context.Background()
-					c := newTestDB(db, driver)
+func (m *metricsRecorder) ops() []string {
+	ops := []string{}
+	for _, o := range m.observed {
+		ops = append(ops, o.op+":"+o.table)
+	}
+	return ops
+}
 
-					err := c.QueryChunks(ctx, ChunkParser{
-						Query:  `SELECT * FROM users u JOIN posts p ON u.id = p.user_id`,
-						Params: []interface{}{},
+// AggregateColumnsTest runs all tests for making sure structs can be
+// filled with computed columns alongside the columns of the table.
+func AggregateColumnsTest(
+	t *testing.T,
+	driver string,
+	connStr string,
+	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
+) {
+	t.Run("AggregateColumns", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
 
-						ChunkSize: 2,
-						ForEachChunk: func(buffer []struct {
-							User user `tablename:"users"`
-							Post post `tablename:"posts"`
-						}) error {
-							return nil
-						},
-					})
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
 
-					assert.NotEqual(t, nil, err)
-					assert.Equal(t, true, strings.Contains(err.Error(), "nested struct"), "unexpected error msg: "+err.Error())
-					assert.Equal(t, true, strings.Contains(err.Error(), "feature"), "unexpected error msg: "+err.Error())
-				})
-			})
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		for _, u := range []user{
+			{Name: "Bia", Age: 20},
+			{Name: "Carl", Age: 10},
+			{Name: "Alan", Age: 30},
+		} {
+			err := c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+		}
+
+		type userWithTotal struct {
+			ID    uint   `ksql:"id"`
+			Name  string `ksql:"name"`
+			Age   int    `ksql:"age"`
+			Total int    `ksql:"total"`
 		}
+
+		t.Run("should scan a window count along with the table columns", func(t *testing.T) {
+			var users []userWithTotal
+			err := c.Query(ctx, &users,
+				"SELECT *, count(*) OVER() AS total FROM users WHERE age > "+c.dialect.Placeholder(0)+" ORDER BY age",
+				15,
+			)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, len(users), 2)
+			tt.AssertEqual(t, users[0].Name, "Bia")
+			tt.AssertEqual(t, users[0].Age, 20)
+			tt.AssertEqual(t, users[0].Total, 2)
+			tt.AssertEqual(t, users[1].Name, "Alan")
+			tt.AssertEqual(t, users[1].Total, 2)
+			tt.AssertNotEqual(t, users[0].ID, uint(0))
+		})
+
+		t.Run("should work with QueryOne and strict columns", func(t *testing.T) {
+			var u userWithTotal
+			err := c.WithStrictColumns(true).QueryOne(ctx, &u,
+				"SELECT id, name, age, count(*) OVER() AS total FROM users WHERE name = "+c.dialect.Placeholder(0),
+				"Carl",
+			)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, u.Name, "Carl")
+			tt.AssertEqual(t, u.Age, 10)
+			tt.AssertEqual(t, u.Total, 1)
+		})
 	})
 }
 
-// TransactionTest runs all tests for making sure the Transaction function is
-// working for a given adapter and driver.
-func TransactionTest(
+// UpdateJSONFieldTest runs all tests for making sure the
+// UpdateJSONField function is working correctly.
+func UpdateJSONFieldTest(
 	t *testing.T,
 	driver string,
 	connStr string,
 	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
 ) {
-	t.Run("Transaction", func(t *testing.T) {
-		t.Run("should query a single row correctly", func(t *testing.T) {
-			err := createTables(driver, connStr)
-			if err != nil {
-				t.Fatal("could not create test table!, reason:", err.Error())
-			}
+	t.Run("UpdateJSONField", func(t *testing.T) {
+		if driver == "sqlserver" {
+			t.Skip("UpdateJSONField is not supported on sqlserver")
+		}
 
-			db, closer := newDBAdapter(t)
-			defer closer.Close()
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
 
-			ctx := context.Background()
-			c := newTestDB(db, driver)
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
 
-			_ = c.Insert(ctx, usersTable, &user{Name: "User1"})
-			_ = c.Insert(ctx, usersTable, &user{Name: "User2"})
+		ctx := context.Background()
+		c := newTestDB(db, driver)
+
+		type userDoc struct {
+			ID  uint                   `ksql:"id"`
+			Doc map[string]interface{} `ksql:"address,json"`
+		}
+
+		t.Run("should merge the patch into the existing document", func(t *testing.T) {
+			u := user{
+				Name: "Bia",
+				Address: address{
+					Street: "Rua Lapa",
+					Number: "12",
+					City:   "Rio",
+				},
+			}
+			err := c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+
+			err = c.UpdateJSONField(ctx, usersTable, u.ID, "address", map[string]interface{}{
+				"number": "42",
+				"city":   nil,
+				"geo": map[string]interface{}{
+					"lat": 1.5,
+				},
+			})
+			tt.AssertNoErr(t, err)
+
+			err = c.UpdateJSONField(ctx, usersTable, u.ID, "address", map[string]interface{}{
+				"geo": map[string]interface{}{
+					"lng": 2.5,
+				},
+			})
+			tt.AssertNoErr(t, err)
 
-			var users []user
-			err = c.Transaction(ctx, func(db Provider) error {
-				db.Query(ctx, &users, "SELECT * FROM users ORDER BY id ASC")
-				return nil
+			var result userDoc
+			err = c.QueryOne(ctx, &result, "FROM users WHERE id = "+c.dialect.Placeholder(0), u.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, result.Doc, map[string]interface{}{
+				"street":  "Rua Lapa",
+				"number":  "42",
+				"state":   "",
+				"country": "",
+				"geo": map[string]interface{}{
+					"lat": 1.5,
+					"lng": 2.5,
+				},
 			})
-			assert.Equal(t, nil, err)
 
-			assert.Equal(t, 2, len(users))
-			assert.Equal(t, "User1", users[0].Name)
-			assert.Equal(t, "User2", users[1].Name)
+			var reloaded user
+			err = c.QueryOne(ctx, &reloaded, "FROM users WHERE id = "+c.dialect.Placeholder(0), u.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, reloaded.Name, "Bia")
 		})
 
-		t.Run("should rollback when there are errors", func(t *testing.T) {
-			err := createTables(driver, connStr)
-			if err != nil {
-				t.Fatal("could not create test table!, reason:", err.Error())
-			}
-
-			db, closer := newDBAdapter(t)
-			defer closer.Close()
-
-			ctx := context.Background()
-			c := newTestDB(db, driver)
+		t.Run("should replace NULL documents with the patch", func(t *testing.T) {
+			var u userDoc
+			err := c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
 
-			u1 := user{Name: "User1", Age: 42}
-			u2 := user{Name: "User2", Age: 42}
-			_ = c.Insert(ctx, usersTable, &u1)
-			_ = c.Insert(ctx, usersTable, &u2)
+			err = c.UpdateJSONField(ctx, usersTable, u.ID, "address", map[string]interface{}{
+				"street": "Rua Lapa",
+			})
+			tt.AssertNoErr(t, err)
 
-			err = c.Transaction(ctx, func(db Provider) error {
-				err = db.Insert(ctx, usersTable, &user{Name: "User3"})
-				assert.Equal(t, nil, err)
-				err = db.Insert(ctx, usersTable, &user{Name: "User4"})
-				assert.Equal(t, nil, err)
-				_, err = db.Exec(ctx, "UPDATE users SET age = 22")
-				assert.Equal(t, nil, err)
+			var result userDoc
+			err = c.QueryOne(ctx, &result, "FROM users WHERE id = "+c.dialect.Placeholder(0), u.ID)
+			tt.AssertNoErr(t, err)
+			tt.AssertEqual(t, result.Doc, map[string]interface{}{
+				"street": "Rua Lapa",
+			})
+		})
 
-				return errors.New("fake-error")
+		t.Run("should report errors", func(t *testing.T) {
+			err := c.UpdateJSONField(ctx, usersTable, 4242, "address", map[string]interface{}{
+				"street": "Rua Lapa",
 			})
-			assert.NotEqual(t, nil, err)
-			assert.Equal(t, "fake-error", err.Error())
+			tt.AssertEqual(t, err, ErrRecordNotFound)
 
-			var users []user
-			err = c.Query(ctx, &users, "SELECT * FROM users ORDER BY id ASC")
-			assert.Equal(t, nil, err)
+			err = c.UpdateJSONField(ctx, usersTable, 1, "address", map[string]interface{}{})
+			tt.AssertErrContains(t, err, "JSON patch", "empty")
 
-			assert.Equal(t, []user{u1, u2}, users)
+			err = c.UpdateJSONField(ctx, usersTable, 1, "address = NULL --", map[string]interface{}{
+				"street": "Rua Lapa",
+			})
+			tt.AssertErrContains(t, err, "invalid JSON column name")
 		})
 	})
 }
 
-// ScanRowsTest runs all tests for making sure the ScanRows feature is
-// working for a given adapter and driver.
-func ScanRowsTest(
+// LoaderTest runs all tests for making sure the
+// Loader is working correctly.
+func LoaderTest(
 	t *testing.T,
 	driver string,
 	connStr string,
 	newDBAdapter func(t *testing.T) (DBAdapter, io.Closer),
 ) {
-	t.Run("ScanRows", func(t *testing.T) {
-		t.Run("should scan users correctly", func(t *testing.T) {
-			err := createTables(driver, connStr)
-			if err != nil {
-				t.Fatal("could not create test table!, reason:", err.Error())
-			}
+	t.Run("Loader", func(t *testing.T) {
+		err := createTables(driver, connStr)
+		if err != nil {
+			t.Fatal("could not create test table!, reason:", err.Error())
+		}
 
-			dialect := supportedDialects[driver]
-			ctx := context.TODO()
-			db, closer := newDBAdapter(t)
-			defer closer.Close()
-			c := newTestDB(db, driver)
-			_ = c.Insert(ctx, usersTable, &user{Name: "User1", Age: 22})
-			_ = c.Insert(ctx, usersTable, &user{Name: "User2", Age: 14})
-			_ = c.Insert(ctx, usersTable, &user{Name: "User3", Age: 43})
+		db, closer := newDBAdapter(t)
+		defer closer.Close()
 
-			rows, err := db.QueryContext(ctx, "SELECT * FROM users WHERE name='User2'")
-			assert.Equal(t, nil, err)
-			defer rows.Close()
+		ctx := context.Background()
+		c := newTestDB(db, driver)
 
-			assert.Equal(t, true, rows.Next())
+		var ids []uint
+		for i := 0; i < 5; i++ {
+			u := user{Name: fmt.Sprint("User ", i), Age: i}
+			err := c.Insert(ctx, usersTable, &u)
+			tt.AssertNoErr(t, err)
+			ids = append(ids, u.ID)
+		}
 
-			var u user
-			err = scanRows(dialect, rows, &u)
-			assert.Equal(t, nil, err)
+		// newCountingDB returns a DB that counts the queries it runs,
+		// it is safe for concurrent use since the loads run concurrently:
+		newCountingDB := func() (DB, func() []string) {
+			var mu sync.Mutex
+			var queries []string
+			c := c
+			c.db = mockDBAdapter{
+				QueryContextFn: func(ctx context.Context, query string, params ...interface{}) (Rows, error) {
+					mu.Lock()
+					queries = append(queries, query)
+					mu.Unlock()
+					return db.QueryContext(ctx, query, params...)
+				},
+			}
+			return c, func() []string {
+				mu.Lock()
+				defer mu.Unlock()
+				return queries
+			}
+		}
 
-			assert.Equal(t, "User2", u.Name)
-			assert.Equal(t, 14, u.Age)
-		})
+		t.Run("should coalesce concurrent loads into one query", func(t *testing.T) {
+			c, getQueries := newCountingDB()
+			loader, err := NewLoader(c, usersTable, user{}, 50*time.Millisecond)
+			tt.AssertNoErr(t, err)
 
-		t.Run("should ignore extra columns from query", func(t *testing.T) {
-			err := createTables(driver, connStr)
-			if err != nil {
-				t.Fatal("could not create test table!, reason:", err.Error())
+			// The missing and the repeated ids are part of the same batch:
+			loadIDs := append([]interface{}{}, 4242, ids[0])
+			for _, id := range ids {
+				loadIDs = append(loadIDs, int(id))
 			}
 
-			dialect := supportedDialects[driver]
-			ctx := context.TODO()
-			db, closer := newDBAdapter(t)
-			defer closer.Close()
-			c := newTestDB(db, driver)
-			_ = c.Insert(ctx, usersTable, &user{Name: "User1", Age: 22})
-
-			rows, err := db.QueryContext(ctx, "SELECT * FROM users WHERE name='User1'")
-			assert.Equal(t, nil, err)
-			defer rows.Close()
+			results := make([]user, len(loadIDs))
+			errs := make([]error, len(loadIDs))
+			var wg sync.WaitGroup
+			for i, id := range loadIDs {
+				wg.Add(1)
+				go func(i int, id interface{}) {
+					defer wg.Done()
+					errs[i] = loader.Load(ctx, id, &results[i])
+				}(i, id)
+			}
+			wg.Wait()
 
-			assert.Equal(t, true, rows.Next())
+			tt.AssertEqual(t, len(getQueries()), 1)
+			tt.AssertEqual(t, errs[0], ErrRecordNotFound)
+			for i := 1; i < len(loadIDs); i++ {
+				tt.AssertNoErr(t, errs[i])
+			}
+			tt.AssertEqual(t, results[1].ID, ids[0])
+			for i, id := range ids {
+				tt.AssertEqual(t, results[i+2].ID, id)
+				tt.AssertEqual(t, results[i+2].Name, fmt.Sprint("User ", i))
+				tt.AssertEqual(t, results[i+2].Age, i)
+			}
+		})
 
-			var u struct {
-				ID  int `ksql:"id"`
-				Age int `ksql:"age"`
+		t.Run("should send the batches once they are full", func(t *testing.T) {
+			c, getQueries := newCountingDB()
+			loader, err := NewLoader(c.WithBatchSize(2), usersTable, &user{}, time.Hour)
+			tt.AssertNoErr(t, err)
 
-				// Omitted for testing purposes:
-				// Name string `ksql:"name"`
+			results := make([]user, 4)
+			errs := make([]error, 4)
+			var wg sync.WaitGroup
+			for i := range results {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					errs[i] = loader.Load(ctx, ids[i], &results[i])
+				}(i)
 			}
-			err = scanRows(dialect, rows, &u)
-			assert.Equal(t, nil, err)
+			wg.Wait()
 
-			assert.Equal(t, 22, u.Age)
-		})
-
-		t.Run("should report error for closed rows", func(t *testing.T) {
-			err := createTables(driver, connStr)
-			if err != nil {
-				t.Fatal("could not create test table!, reason:", err.Error())
+			tt.AssertEqual(t, len(getQueries()), 2)
+			for i := range results {
+				tt.AssertNoErr(t, errs[i])
+				tt.AssertEqual(t, results[i].ID, ids[i])
 			}
+		})
 
-			dialect := supportedDialects[driver]
-			ctx := context.TODO()
-			db, closer := newDBAdapter(t)
-			defer closer.Close()
+		t.Run("should stop waiting when the context is canceled", func(t *testing.T) {
+			loader, err := NewLoader(c, usersTable, user{}, time.Hour)
+			tt.AssertNoErr(t, err)
 
-			rows, err := db.QueryContext(ctx, "SELECT * FROM users WHERE name='User2'")
-			assert.Equal(t, nil, err)
+			ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+			defer cancel()
 
 			var u user
-			err = rows.Close()
-			assert.Equal(t, nil, err)
-			err = scanRows(dialect, rows, &u)
-			assert.NotEqual(t, nil, err)
+			err = loader.Load(ctx, ids[0], &u)
+			tt.AssertEqual(t, err, context.DeadlineExceeded)
 		})
 
-		t.Run("should report if record is not a pointer", func(t *testing.T) {
-			err := createTables(driver, connStr)
-			if err != nil {
-				t.Fatal("could not create test table!, reason:", err.Error())
+		t.Run("should not fail the other callers when the first one is canceled", func(t *testing.T) {
+			c := c
+			c.db = mockDBAdapter{
+				QueryContextFn: func(ctx context.Context, query string, params ...interface{}) (Rows, error) {
+					if ctx.Err() != nil {
+						return nil, ctx.Err()
+					}
+					return db.QueryContext(ctx, query, params...)
+				},
 			}
+			loader, err := NewLoader(c, usersTable, user{}, 100*time.Millisecond)
+			tt.AssertNoErr(t, err)
 
-			dialect := supportedDialects[driver]
-			ctx := context.TODO()
-			db, closer := newDBAdapter(t)
-			defer closer.Close()
+			firstCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
 
-			rows, err := db.QueryContext(ctx, "SELECT * FROM users WHERE name='User2'")
-			tt.AssertNoErr(t, err)
-			defer rows.Close()
+			var firstErr error
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var u user
+				firstErr = loader.Load(firstCtx, ids[0], &u)
+			}()
+
+			// Making sure the first caller is the one that creates the batch:
+			time.Sleep(10 * time.Millisecond)
+
+			results := make([]user, 3)
+			errs := make([]error, 3)
+			for i := range results {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					errs[i] = loader.Load(ctx, ids[i+1], &results[i])
+				}(i)
+			}
 
-			var u user
-			err = scanRows(dialect, rows, u)
-			tt.AssertErrContains(t, err, "ksql", "expected", "pointer to struct", "user")
-		})
+			time.Sleep(10 * time.Millisecond)
+			cancel()
+			wg.Wait()
 
-		t.Run("should report if record is not a pointer to struct", func(t *testing.T) {
-			err := createTables(driver, connStr)
-			if err != nil {
-				t.Fatal("could not create test table!, reason:", err.Error())
+			tt.AssertEqual(t, firstErr, context.Canceled)
+			for i := range results {
+				tt.AssertNoErr(t, errs[i])
+				tt.AssertEqual(t, results[i].ID, ids[i+1])
 			}
+		})
 
-			dialect := supportedDialects[driver]
-			ctx := context.TODO()
-			db, closer := newDBAdapter(t)
-			defer closer.Close()
+		t.Run("should report errors", func(t *testing.T) {
+			_, err := NewLoader(c, NewTable("user_permissions", "user_id", "perm_id"), user{}, time.Millisecond)
+			tt.AssertErrContains(t, err, "Loader", "single ID column")
 
-			rows, err := db.QueryContext(ctx, "SELECT * FROM users WHERE name='User2'")
+			_, err = NewLoader(c, usersTable, []user{}, time.Millisecond)
+			tt.AssertErrContains(t, err, "expected record to be a struct")
+
+			_, err = NewLoader(c, usersTable, struct {
+				Name string `ksql:"name"`
+			}{}, time.Millisecond)
+			tt.AssertErrContains(t, err, "ID column", "not tagged")
+
+			loader, err := NewLoader(c, usersTable, user{}, time.Millisecond)
 			tt.AssertNoErr(t, err)
-			defer rows.Close()
 
-			var u map[string]interface{}
-			err = scanRows(dialect, rows, &u)
-			tt.AssertErrContains(t, err, "ksql", "expected", "pointer to struct", "map[string]interface")
+			var p post
+			err = loader.Load(ctx, ids[0], &p)
+			tt.AssertErrContains(t, err, "expected dest", "*ksql.user")
+
+			var u user
+			err = loader.Load(ctx, "not an id", &u)
+			tt.AssertErrContains(t, err, "can't use id", "not an id")
 		})
 	})
 }
@@ -2342,6 +9274,29 @@ func newTestDB(db DBAdapter, driver string) DB {
 	}
 }
 
+// mockDBAdapter is used on tests that need to intercept
+// or count the queries sent to the database.
+type mockDBAdapter struct {
+	ExecContextFn  func(ctx context.Context, query string, args ...interface{}) (Result, error)
+	QueryContextFn func(ctx context.Context, query string, args ...interface{}) (Rows, error)
+}
+
+func (m mockDBAdapter) ExecContext(ctx context.Context, query string, args ...interface{}) (Result, error) {
+	return m.ExecContextFn(ctx, query, args...)
+}
+
+func (m mockDBAdapter) QueryContext(ctx context.Context, query string, args ...interface{}) (Rows, error) {
+	return m.QueryContextFn(ctx, query, args...)
+}
+
+func getPostTitles(posts []post) (titles []string) {
+	for _, p := range posts {
+		titles = append(titles, p.Title)
+	}
+	sort.Strings(titles)
+	return titles
+}
+
 func shiftErrSlice(errs *[]error) error {
 	err := (*errs)[0]
 	*errs = (*errs)[1:]
@@ -2471,3 +9426,166 @@ func getUserPermissionsByUser(db DBAdapter, driver string, userID int) (results
 
 	return results, nil
 }
+
+func createEventsTable(driver string, connStr string) error {
+	db, err := sql.Open(driver, connStr)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	db.Exec(`DROP TABLE events`)
+
+	switch driver {
+	case "sqlite3":
+		_, err = db.Exec(`CREATE TABLE events (
+			id INTEGER PRIMARY KEY,
+			created_at DATETIME,
+			cancelled_at DATETIME,
+			updated_at DATETIME
+		)`)
+	case "postgres":
+		_, err = db.Exec(`CREATE TABLE events (
+			id serial PRIMARY KEY,
+			created_at TIMESTAMPTZ,
+			cancelled_at TIMESTAMPTZ,
+			updated_at TIMESTAMPTZ
+		)`)
+	case "mysql":
+		_, err = db.Exec(`CREATE TABLE events (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			created_at DATETIME,
+			cancelled_at DATETIME,
+			updated_at DATETIME
+		)`)
+	case "sqlserver":
+		_, err = db.Exec(`CREATE TABLE events (
+			id INT IDENTITY(1,1) PRIMARY KEY,
+			created_at DATETIMEOFFSET,
+			cancelled_at DATETIMEOFFSET,
+			updated_at DATETIMEOFFSET
+		)`)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create new events table: %s", err.Error())
+	}
+
+	return nil
+}
+
+// createUsersAgeTrigger creates a trigger that adds 100
+// to the age of the users on every update.
+func createUsersAgeTrigger(driver string, connStr string) error {
+	db, err := sql.Open(driver, connStr)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var queries []string
+	switch driver {
+	case "sqlite3":
+		queries = []string{`CREATE TRIGGER users_age_trigger AFTER UPDATE OF name ON users BEGIN
+			UPDATE users SET age = age + 100 WHERE id = NEW.id;
+		END`}
+	case "postgres":
+		queries = []string{
+			`CREATE OR REPLACE FUNCTION users_age_trigger() RETURNS trigger AS $$
+			BEGIN
+				NEW.age := NEW.age + 100;
+				RETURN NEW;
+			END;
+			$$ LANGUAGE plpgsql`,
+			`CREATE TRIGGER users_age_trigger BEFORE UPDATE ON users
+				FOR EACH ROW EXECUTE PROCEDURE users_age_trigger()`,
+		}
+	case "mysql":
+		queries = []string{`CREATE TRIGGER users_age_trigger BEFORE UPDATE ON users
+			FOR EACH ROW SET NEW.age = NEW.age + 100`}
+	case "sqlserver":
+		queries = []string{`CREATE TRIGGER users_age_trigger ON users AFTER UPDATE AS
+			UPDATE users SET age = age + 100 WHERE id IN (SELECT id FROM inserted)`}
+	}
+
+	for _, query := range queries {
+		_, err = db.Exec(query)
+		if err != nil {
+			return fmt.Errorf("failed to create the users age trigger: %s", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// execCounterAdapter counts the number of statements executed with
+// ExecContext including the ones executed inside transactions.
+type execCounterAdapter struct {
+	DBAdapter
+	numExecs *int
+}
+
+func (e execCounterAdapter) ExecContext(ctx context.Context, query string, args ...interface{}) (Result, error) {
+	*e.numExecs++
+	return e.DBAdapter.ExecContext(ctx, query, args...)
+}
+
+func (e execCounterAdapter) BeginTx(ctx context.Context) (Tx, error) {
+	tx, err := e.DBAdapter.(TxBeginner).BeginTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return execCounterTx{Tx: tx, numExecs: e.numExecs}, nil
+}
+
+type execCounterTx struct {
+	Tx
+	numExecs *int
+}
+
+func (e execCounterTx) ExecContext(ctx context.Context, query string, args ...interface{}) (Result, error) {
+	*e.numExecs++
+	return e.Tx.ExecContext(ctx, query, args...)
+}
+
+// createAccountsTable creates a table keyed by a
+// non auto incremented column, i.e. the email.
+func createAccountsTable(driver string, connStr string) error {
+	db, err := sql.Open(driver, connStr)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	db.Exec(`DROP TABLE accounts`)
+
+	_, err = db.Exec(`CREATE TABLE accounts (
+		email VARCHAR(255) PRIMARY KEY,
+		name VARCHAR(50),
+		score INT
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create new accounts table: %s", err.Error())
+	}
+
+	return nil
+}
+
+func createTagsTable(driver string, connStr string) error {
+	db, err := sql.Open(driver, connStr)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	db.Exec(`DROP TABLE tags`)
+
+	_, err = db.Exec(`CREATE TABLE tags (
+		id VARCHAR(36) PRIMARY KEY,
+		name VARCHAR(50)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create new tags table: %s", err.Error())
+	}
+
+	return nil
+}
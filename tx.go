@@ -0,0 +1,72 @@
+package ksql
+
+import (
+	"context"
+	"fmt"
+)
+
+var _ Provider = TxDB{}
+
+// TxDB is a DB bound to a transaction started with DB.Begin,
+// all its operations run inside the transaction until it is
+// finalized by calling either Commit or Rollback.
+type TxDB struct {
+	DB
+
+	tx Tx
+}
+
+// Begin starts a transaction and returns a TxDB bound to it, e.g.:
+//
+//	tx, err := c.Begin(ctx)
+//	// ...
+//	err = tx.Insert(ctx, UsersTable, &user)
+//	// ...
+//	err = tx.Commit(ctx)
+//
+// Unlike Transaction, which finalizes the transaction when its callback
+// returns, the caller is responsible for calling Commit or Rollback, and
+// while the transaction is open it holds one of the connections of the
+// pool, so leaking a TxDB also leaks a connection. A common pattern is to
+// defer a call to Rollback right after Begin, since calling it after
+// Commit has no effect on the committed changes.
+func (c DB) Begin(ctx context.Context) (txDB TxDB, err error) {
+	ctx, finish := c.observe(ctx, "begin", "")
+	defer func() { finish(err) }()
+
+	switch txBeginner := c.db.(type) {
+	case Tx:
+		return TxDB{}, fmt.Errorf("ksql: Begin can't be used inside a transaction")
+	case TxBeginner:
+		tx, err := txBeginner.BeginTx(ctx)
+		if err != nil {
+			return TxDB{}, err
+		}
+
+		dbCopy := c
+		dbCopy.db = tx
+		return TxDB{
+			DB: dbCopy,
+			tx: tx,
+		}, nil
+
+	default:
+		return TxDB{}, fmt.Errorf("can't start transaction: The DBAdapter doesn't implement the TxBegginner interface")
+	}
+}
+
+// Commit commits the transaction of the TxDB.
+func (t TxDB) Commit(ctx context.Context) (err error) {
+	ctx, finish := t.DB.observe(ctx, "commit", "")
+	defer func() { finish(err) }()
+
+	return wrapDeadlockError(t.tx.Commit(ctx))
+}
+
+// Rollback rolls back the transaction of the TxDB.
+func (t TxDB) Rollback(ctx context.Context) (err error) {
+	ctx, finish := t.DB.observe(ctx, "rollback", "")
+	defer func() { finish(err) }()
+
+	return t.tx.Rollback(ctx)
+}
@@ -0,0 +1,97 @@
+package ksql
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/vingarcia/ksql/internal/structs"
+)
+
+// unixTimestamp adapts integer attributes tagged with the `unix`
+// or `unixmilli` options, so they can be scanned from timestamp
+// columns of the database.
+type unixTimestamp struct {
+	Unit time.Duration
+
+	// Attr is a pointer to the attribute being scanned,
+	// which might itself be a pointer to an integer.
+	Attr interface{}
+}
+
+// sqliteTimeLayouts are the formats used by sqlite3 for
+// storing timestamps, which are returned as strings when
+// the column is not declared with a time type.
+var sqliteTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// Scan implements the Scanner interface in order to load
+// this attribute from the timestamp stored in the database.
+func (u *unixTimestamp) Scan(value interface{}) error {
+	v := reflect.ValueOf(u.Attr).Elem()
+	if value == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	var timestamp int64
+	switch value := value.(type) {
+	case time.Time:
+		timestamp = structs.TimeToUnix(value, u.Unit)
+	case int64:
+		// Columns that already store integers are read as they are:
+		timestamp = value
+	case []byte:
+		t, err := parseTimestamp(string(value))
+		if err != nil {
+			return err
+		}
+		timestamp = structs.TimeToUnix(t, u.Unit)
+	case string:
+		t, err := parseTimestamp(value)
+		if err != nil {
+			return err
+		}
+		timestamp = structs.TimeToUnix(t, u.Unit)
+	default:
+		return fmt.Errorf("unexpected type received to Scan: %T", value)
+	}
+
+	if v.Kind() == reflect.Ptr {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+	v.SetInt(timestamp)
+
+	return nil
+}
+
+func parseTimestamp(s string) (time.Time, error) {
+	for _, layout := range sqliteTimeLayouts {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unable to parse timestamp: %q", s)
+}
+
+// getUnixTimestampParam converts the value of an attribute tagged
+// with the `unix` or `unixmilli` options to the time.Time that
+// should be sent to the database, other values are returned as they are.
+//
+// The value must not be a pointer.
+func getUnixTimestampParam(fieldInfo *structs.FieldInfo, value interface{}) interface{} {
+	if fieldInfo.UnixTimestampUnit == 0 || value == nil {
+		return value
+	}
+
+	return structs.UnixToTime(reflect.ValueOf(value).Int(), fieldInfo.UnixTimestampUnit)
+}
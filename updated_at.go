@@ -0,0 +1,71 @@
+package ksql
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/vingarcia/ksql/internal/structs"
+)
+
+// WithUpdatedAtColumn returns a copy of the DB configured to set the
+// attribute tagged with the input column to the current time before
+// each Update, Patch, PatchSlice and PatchAndReload, e.g.:
+//
+//	db := c.WithUpdatedAtColumn("updated_at")
+//	err := db.PatchAndReload(ctx, UsersTable, &user)
+//	// user.UpdatedAt now contains the time of the update
+//
+// The attribute must be either a time.Time or a *time.Time, and when
+// the record is passed by value the time is only sent to the database.
+//
+// PatchOnly and PatchExcept keep the attribute untouched, since they
+// update an explicit set of columns, and so does PatchIfChanged, since
+// the new time would always be different from the stored one.
+//
+// Records without an attribute tagged with the column are updated as usual.
+func (c DB) WithUpdatedAtColumn(column string) DB {
+	c.updatedAtColumn = column
+	return c
+}
+
+// setUpdatedAt sets the attribute configured with
+// WithUpdatedAtColumn on the input struct to the current time.
+func (c DB) setUpdatedAt(v reflect.Value, info structs.StructInfo) error {
+	if c.updatedAtColumn == "" {
+		return nil
+	}
+
+	fieldInfo := info.ByName(c.updatedAtColumn)
+	if !fieldInfo.Valid {
+		return nil
+	}
+
+	field := v.Field(fieldInfo.Index)
+	if field.Type() != timeType && field.Type() != reflect.PtrTo(timeType) {
+		return fmt.Errorf(
+			"ksql: the updated at attribute `%s` must be a time.Time or a *time.Time, but got: %v",
+			v.Type().Field(fieldInfo.Index).Name, field.Type(),
+		)
+	}
+
+	if !field.CanSet() {
+		return fmt.Errorf(
+			"ksql: the updated at attribute `%s` can't be set since it is not exported",
+			v.Type().Field(fieldInfo.Index).Name,
+		)
+	}
+
+	now := time.Now()
+	if c.location != nil {
+		now = now.In(c.location)
+	}
+
+	if field.Kind() == reflect.Ptr {
+		field.Set(reflect.ValueOf(&now))
+		return nil
+	}
+
+	field.Set(reflect.ValueOf(now))
+	return nil
+}
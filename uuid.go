@@ -0,0 +1,90 @@
+package ksql
+
+import (
+	"crypto/rand"
+	"fmt"
+	"reflect"
+
+	"github.com/vingarcia/ksql/internal/structs"
+)
+
+// WithUUIDKey returns a copy of the DB configured to generate a random
+// UUID (version 4) for the attribute tagged with the input column when
+// it is empty on Insert, so the generated key is also available on the
+// record after the insert, e.g.:
+//
+//	db := c.WithUUIDKey("id")
+//	user := User{Name: "Bob"}
+//	err := db.Insert(ctx, UsersTable, &user)
+//	// user.ID is now set to something like "0b8bd1e6-0b5c-4a0e-8d1e-3ef0c2a3c0a5"
+//
+// The attribute must be either a string, which is set to the canonical
+// text format of the UUID, or a [16]byte, which is set to its raw bytes,
+// in which case its type must also implement driver.Valuer, as `uuid.UUID`
+// types usually do, since the drivers don't accept arrays as arguments.
+//
+// Records without an attribute tagged with the column are inserted as usual.
+func (c DB) WithUUIDKey(column string) DB {
+	c.uuidKeyColumn = column
+	return c
+}
+
+// setUUIDKey generates the UUID configured with WithUUIDKey
+// on the input struct if the key attribute is empty.
+func (c DB) setUUIDKey(v reflect.Value, info structs.StructInfo) error {
+	if c.uuidKeyColumn == "" {
+		return nil
+	}
+
+	fieldInfo := info.ByName(c.uuidKeyColumn)
+	if !fieldInfo.Valid {
+		return nil
+	}
+
+	field := v.Field(fieldInfo.Index)
+	isString := field.Kind() == reflect.String
+	isBytes := field.Kind() == reflect.Array && field.Len() == 16 && field.Type().Elem().Kind() == reflect.Uint8
+	if !isString && !isBytes {
+		return fmt.Errorf(
+			"ksql: the UUID key attribute `%s` must be a string or a [16]byte, but got: %v",
+			v.Type().Field(fieldInfo.Index).Name, field.Type(),
+		)
+	}
+
+	if !field.CanSet() {
+		return fmt.Errorf(
+			"ksql: the UUID key attribute `%s` can't be set since it is not exported",
+			v.Type().Field(fieldInfo.Index).Name,
+		)
+	}
+
+	if !field.IsZero() {
+		return nil
+	}
+
+	uuid, err := newUUIDv4()
+	if err != nil {
+		return err
+	}
+
+	if isString {
+		field.SetString(fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:]))
+		return nil
+	}
+
+	reflect.Copy(field, reflect.ValueOf(uuid[:]))
+	return nil
+}
+
+// newUUIDv4 generates a random UUID as described on RFC 4122.
+func newUUIDv4() ([16]byte, error) {
+	var uuid [16]byte
+	_, err := rand.Read(uuid[:])
+	if err != nil {
+		return uuid, fmt.Errorf("ksql: unable to generate UUID: %w", err)
+	}
+
+	uuid[6] = (uuid[6] & 0x0f) | 0x40 // version 4
+	uuid[8] = (uuid[8] & 0x3f) | 0x80 // variant 10
+	return uuid, nil
+}